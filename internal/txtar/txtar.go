@@ -0,0 +1,102 @@
+// Package txtar reads and writes txtar archives, the plain text format
+// the Go project uses for test fixtures:
+//
+//	comment
+//	-- hello.txt --
+//	hello, world
+//	-- dir/empty.txt --
+//
+// Each file starts at a "-- name --" line and runs to the next one.
+// Anything before the first file is the comment.
+package txtar
+
+import (
+	"bytes"
+	"strings"
+)
+
+// Archive is a txtar archive.
+type Archive struct {
+	Comment []byte
+	Files   []File
+}
+
+// File is a file of an Archive.
+type File struct {
+	Name string
+	Data []byte
+}
+
+var (
+	newlineMarker = []byte("\n-- ")
+	marker        = []byte("-- ")
+	markerEnd     = []byte(" --")
+)
+
+// Format returns the text of a.  The comment and each file's data are
+// given a final newline if they lack one, as the format needs it.
+func Format(a *Archive) []byte {
+	var buf bytes.Buffer
+	buf.Write(fixNL(a.Comment))
+	for _, f := range a.Files {
+		buf.WriteString("-- " + f.Name + " --\n")
+		buf.Write(fixNL(f.Data))
+	}
+	return buf.Bytes()
+}
+
+// Parse parses the text of an archive.  It cannot fail: text that has
+// no file markers is all comment.
+func Parse(data []byte) *Archive {
+	var a = &Archive{}
+
+	var name string
+	a.Comment, name, data = findFile(data)
+	for name != "" {
+		var f = File{Name: name}
+		f.Data, name, data = findFile(data)
+		a.Files = append(a.Files, f)
+	}
+	return a
+}
+
+// findFile returns the data before the next file marker in data, the
+// name in that marker and the data after it.  The name is empty if
+// there is no marker.
+func findFile(data []byte) (before []byte, name string, after []byte) {
+	var i int
+	for {
+		if name, after = isMarker(data[i:]); name != "" {
+			return data[:i], name, after
+		}
+		j := bytes.Index(data[i:], newlineMarker)
+		if j < 0 {
+			return data, "", nil
+		}
+		i += j + 1
+	}
+}
+
+// isMarker reports the name of the file marker data starts with, and
+// the data after the marker line.
+func isMarker(data []byte) (name string, after []byte) {
+	if !bytes.HasPrefix(data, marker) {
+		return "", nil
+	}
+	line := data
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		line, after = data[:i], data[i+1:]
+	}
+	line = bytes.TrimRight(line, "\r")
+	if !bytes.HasSuffix(line, markerEnd) || len(line) < len(marker)+len(markerEnd) {
+		return "", nil
+	}
+	return strings.TrimSpace(string(line[len(marker) : len(line)-len(markerEnd)])), after
+}
+
+func fixNL(data []byte) []byte {
+	if len(data) == 0 || data[len(data)-1] == '\n' {
+		return data
+	}
+	return append(data[:len(data):len(data)], '\n')
+}
@@ -0,0 +1,42 @@
+package txtar
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	a := Parse([]byte("comment\n-- a.txt --\nhello\n-- not a marker\n-- dir/b.txt --\n-- c --\nno newline"))
+
+	want := &Archive{
+		Comment: []byte("comment\n"),
+		Files: []File{
+			{Name: "a.txt", Data: []byte("hello\n-- not a marker\n")},
+			{Name: "dir/b.txt", Data: []byte{}},
+			{Name: "c", Data: []byte("no newline")},
+		},
+	}
+	if !reflect.DeepEqual(a, want) {
+		t.Errorf("Parse() = %q, want %q", a, want)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	a := &Archive{
+		Comment: []byte("comment"),
+		Files: []File{
+			{Name: "a.txt", Data: []byte("hello")},
+			{Name: "empty"},
+		},
+	}
+
+	got := string(Format(a))
+	want := "comment\n-- a.txt --\nhello\n-- empty --\n"
+	if got != want {
+		t.Errorf("Format() = %q, want %q", got, want)
+	}
+
+	if b := Parse([]byte(got)); b.Files[0].Name != "a.txt" || string(b.Files[0].Data) != "hello\n" {
+		t.Errorf("Parse(Format()) = %q", b)
+	}
+}
@@ -0,0 +1,139 @@
+package fs
+
+import (
+	"io/fs"
+	"time"
+)
+
+// FileInfoOption sets a field of a fake FileInfo.
+type FileInfoOption func(*fakeFileInfo)
+
+// WithSize sets the size, which is 0 by default.
+func WithSize(size int64) FileInfoOption {
+	return func(fi *fakeFileInfo) {
+		fi.size = size
+	}
+}
+
+// WithMode sets the mode, which is 0644 by default.
+func WithMode(mode FSFileMode) FileInfoOption {
+	return func(fi *fakeFileInfo) {
+		fi.mode = mode
+	}
+}
+
+// WithModTime sets the modification time, and the access and change
+// times unless they are set too.  It is the zero time by default.
+func WithModTime(t time.Time) FileInfoOption {
+	return func(fi *fakeFileInfo) {
+		fi.modTime = t
+	}
+}
+
+// WithOwner sets the owner and group, which are 0 by default.
+func WithOwner(uid, gid int) FileInfoOption {
+	return func(fi *fakeFileInfo) {
+		fi.stat.Uid, fi.stat.Gid = uid, gid
+	}
+}
+
+// WithInode sets the inode number, which is 0 by default.
+func WithInode(ino uint64) FileInfoOption {
+	return func(fi *fakeFileInfo) {
+		fi.stat.Inode = ino
+	}
+}
+
+// WithLinks sets the number of hard links, which is 1 by default.
+func WithLinks(n uint64) FileInfoOption {
+	return func(fi *fakeFileInfo) {
+		fi.stat.Links = n
+	}
+}
+
+// WithAtime sets the access time.
+func WithAtime(t time.Time) FileInfoOption {
+	return func(fi *fakeFileInfo) {
+		fi.stat.Atime = t
+	}
+}
+
+// WithCtime sets the status change time.
+func WithCtime(t time.Time) FileInfoOption {
+	return func(fi *fakeFileInfo) {
+		fi.stat.Ctime = t
+	}
+}
+
+// fakeFileInfo is the fs.FileInfo behind a fake FileInfo.  Its Sys is
+// a *Stat.
+type fakeFileInfo struct {
+	name    string
+	size    int64
+	mode    FSFileMode
+	modTime time.Time
+	stat    Stat
+}
+
+func newFakeFileInfo(name string, options []FileInfoOption) *fakeFileInfo {
+	var fi = &fakeFileInfo{
+		name: name,
+		mode: 0644,
+		stat: Stat{Links: 1},
+	}
+
+	for _, f := range options {
+		f(fi)
+	}
+
+	if fi.stat.Atime.IsZero() {
+		fi.stat.Atime = fi.modTime
+	}
+	if fi.stat.Ctime.IsZero() {
+		fi.stat.Ctime = fi.modTime
+	}
+
+	return fi
+}
+
+// NewFakeFileInfo creates a FileInfo of a file called name that is not
+// there: a regular file of mode 0644 and size 0, with one link, unless
+// the options say otherwise.  Its Sys is a *Stat, which its Owner,
+// Group, Inode, Links, Atime and Ctime read.
+//
+//	fi := fs.NewFakeFileInfo("run.sh",
+//		fs.WithMode(0755), fs.WithSize(120), fs.WithOwner(1000, 1000))
+func NewFakeFileInfo(name string, options ...FileInfoOption) FileInfo {
+	return NewFileInfo(newFakeFileInfo(name, options))
+}
+
+// NewFakeDirEntry creates a DirEntry, as NewFakeFileInfo creates a
+// FileInfo, whose Info returns that FileInfo.
+func NewFakeDirEntry(name string, options ...FileInfoOption) DirEntry {
+	return NewDirEntry(fs.FileInfoToDirEntry(newFakeFileInfo(name, options)))
+}
+
+func (fi *fakeFileInfo) Name() string {
+	return fi.name
+}
+
+func (fi *fakeFileInfo) Size() int64 {
+	return fi.size
+}
+
+func (fi *fakeFileInfo) Mode() FSFileMode {
+	return fi.mode
+}
+
+func (fi *fakeFileInfo) ModTime() time.Time {
+	return fi.modTime
+}
+
+func (fi *fakeFileInfo) IsDir() bool {
+	return fi.mode.IsDir()
+}
+
+func (fi *fakeFileInfo) Sys() any {
+	st := fi.stat
+	return &st
+}
@@ -0,0 +1,112 @@
+package fs
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestNewFakeFileInfo(t *testing.T) {
+	mtime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	atime := mtime.Add(time.Hour)
+
+	fi := NewFakeFileInfo("run.sh",
+		WithSize(120),
+		WithMode(0755),
+		WithModTime(mtime),
+		WithAtime(atime),
+		WithOwner(1000, 100),
+		WithInode(42),
+		WithLinks(2),
+	)
+
+	if fi.Name() != "run.sh" || fi.Size() != 120 || fi.Nub().Mode() != 0755 || !fi.ModTime().Equal(mtime) || fi.IsDir() {
+		t.Errorf("NewFakeFileInfo() = %s", formatFileInfo(fi))
+	}
+	if uid, ok := fi.Owner(); !ok || uid != 1000 {
+		t.Errorf("Owner() = %d, %v, want 1000", uid, ok)
+	}
+	if gid, ok := fi.Group(); !ok || gid != 100 {
+		t.Errorf("Group() = %d, %v, want 100", gid, ok)
+	}
+	if ino, ok := fi.Inode(); !ok || ino != 42 {
+		t.Errorf("Inode() = %d, %v, want 42", ino, ok)
+	}
+	if n, ok := fi.Links(); !ok || n != 2 {
+		t.Errorf("Links() = %d, %v, want 2", n, ok)
+	}
+	if at, ok := fi.Atime(); !ok || !at.Equal(atime) {
+		t.Errorf("Atime() = %v, %v, want %v", at, ok, atime)
+	}
+	if ct, ok := fi.Ctime(); !ok || !ct.Equal(mtime) {
+		t.Errorf("Ctime() = %v, %v, want the mtime %v", ct, ok, mtime)
+	}
+
+	// changing what Sys returns does not change the FileInfo
+	fi.Sys().(*Stat).Uid = 0
+	if uid, _ := fi.Owner(); uid != 1000 {
+		t.Errorf("Owner() after change to Sys = %d, want 1000", uid)
+	}
+}
+
+func formatFileInfo(fi FileInfo) string {
+	return fs.FormatFileInfo(fi.Nub())
+}
+
+func TestNewFakeDirEntry(t *testing.T) {
+	de := NewFakeDirEntry("src", WithMode(fs.ModeDir|0755), WithInode(7))
+
+	if de.Name() != "src" || !de.IsDir() || !de.Type().IsDir() {
+		t.Errorf("NewFakeDirEntry() = %s", de.Format())
+	}
+
+	fi, err := de.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ino, ok := fi.Inode(); !ok || ino != 7 {
+		t.Errorf("Info().Inode() = %d, %v, want 7", ino, ok)
+	}
+	if n, ok := fi.Links(); !ok || n != 1 {
+		t.Errorf("Info().Links() = %d, %v, want 1", n, ok)
+	}
+}
+
+func TestFileInfo_Accessors(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "f")
+	if err := os.WriteFile(name, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	nub, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi := NewFileInfo(nub)
+
+	uid, ok := fi.Owner()
+	if runtime.GOOS != "linux" {
+		if ok {
+			t.Errorf("Owner() ok on %s", runtime.GOOS)
+		}
+		return
+	}
+
+	if !ok || uid != os.Getuid() {
+		t.Errorf("Owner() = %d, %v, want %d", uid, ok, os.Getuid())
+	}
+	if gid, ok := fi.Group(); !ok || gid != os.Getgid() {
+		t.Errorf("Group() = %d, %v, want %d", gid, ok, os.Getgid())
+	}
+	if ino, ok := fi.Inode(); !ok || ino == 0 {
+		t.Errorf("Inode() = %d, %v, want non-zero", ino, ok)
+	}
+	if n, ok := fi.Links(); !ok || n != 1 {
+		t.Errorf("Links() = %d, %v, want 1", n, ok)
+	}
+	if ct, ok := fi.Ctime(); !ok || time.Since(ct) > time.Minute {
+		t.Errorf("Ctime() = %v, %v, want about now", ct, ok)
+	}
+}
@@ -13,6 +13,15 @@ type FileInfo interface {
 	IsDir() bool
 	Sys() any
 
+	// Typed access to what Sys holds: a *syscall.Stat_t on Linux, or
+	// the *Stat of a fake.  The bool is false where Sys does not say.
+	Owner() (int, bool)
+	Group() (int, bool)
+	Inode() (uint64, bool)
+	Links() (uint64, bool)
+	Atime() (time.Time, bool)
+	Ctime() (time.Time, bool)
+
 	Nub() fs.FileInfo
 }
 
@@ -63,3 +72,33 @@ func (fi fileInfoFacade) IsDir() bool {
 func (fi fileInfoFacade) Sys() any {
 	return fi.nub.Sys()
 }
+
+func (fi fileInfoFacade) Owner() (int, bool) {
+	st, ok := statOf(fi.nub.Sys())
+	return st.Uid, ok
+}
+
+func (fi fileInfoFacade) Group() (int, bool) {
+	st, ok := statOf(fi.nub.Sys())
+	return st.Gid, ok
+}
+
+func (fi fileInfoFacade) Inode() (uint64, bool) {
+	st, ok := statOf(fi.nub.Sys())
+	return st.Inode, ok
+}
+
+func (fi fileInfoFacade) Links() (uint64, bool) {
+	st, ok := statOf(fi.nub.Sys())
+	return st.Links, ok
+}
+
+func (fi fileInfoFacade) Atime() (time.Time, bool) {
+	st, ok := statOf(fi.nub.Sys())
+	return st.Atime, ok
+}
+
+func (fi fileInfoFacade) Ctime() (time.Time, bool) {
+	st, ok := statOf(fi.nub.Sys())
+	return st.Ctime, ok
+}
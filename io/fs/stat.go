@@ -0,0 +1,26 @@
+package fs
+
+import (
+	"time"
+)
+
+// Stat is what the Sys of a fake FileInfo holds: what a FileInfo says
+// about a file beyond its name, size, mode and modification time.  The
+// in-memory OS of package os uses it too.
+type Stat struct {
+	Uid   int
+	Gid   int
+	Inode uint64
+	Links uint64
+	Atime time.Time
+	Ctime time.Time
+}
+
+// statOf returns what sys says, if it is a *Stat or, on systems whose
+// layout statOfSys knows, the system's own stat structure.
+func statOf(sys any) (Stat, bool) {
+	if st, ok := sys.(*Stat); ok && st != nil {
+		return *st, true
+	}
+	return statOfSys(sys)
+}
@@ -0,0 +1,22 @@
+package fs
+
+import (
+	"syscall"
+	"time"
+)
+
+func statOfSys(sys any) (Stat, bool) {
+	st, ok := sys.(*syscall.Stat_t)
+	if !ok || st == nil {
+		return Stat{}, false
+	}
+
+	return Stat{
+		Uid:   int(st.Uid),
+		Gid:   int(st.Gid),
+		Inode: st.Ino,
+		Links: uint64(st.Nlink),
+		Atime: time.Unix(st.Atim.Unix()),
+		Ctime: time.Unix(st.Ctim.Unix()),
+	}, true
+}
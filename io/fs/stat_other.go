@@ -0,0 +1,8 @@
+//go:build !linux
+
+package fs
+
+// statOfSys knows only the Linux stat structure.
+func statOfSys(sys any) (Stat, bool) {
+	return Stat{}, false
+}
@@ -0,0 +1,282 @@
+package fs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+)
+
+// The writable counterparts of the io/fs interfaces.  Names are those
+// of an FS: slash-separated, unrooted and checked with ValidPath.  Code
+// that writes files can take an FS with the methods it needs, or just
+// an FS and the functions below, rather than a whole os.OS.
+
+// WriteFileFS is a file system that can write whole files.
+type WriteFileFS interface {
+	FS
+
+	// WriteFile writes data to the named file, creating it with perm
+	// (before the umask) if need be, and truncating it otherwise.
+	WriteFile(name string, data []byte, perm FSFileMode) error
+}
+
+// MkdirFS is a file system that can make directories.
+type MkdirFS interface {
+	FS
+
+	// Mkdir makes the named directory with perm (before the umask).
+	Mkdir(name string, perm FSFileMode) error
+}
+
+// MkdirAllFS is a file system that can make a directory along with the
+// directories above it.
+type MkdirAllFS interface {
+	FS
+
+	// MkdirAll makes the named directory and any that it needs, with
+	// perm (before the umask).  It does nothing if the directory
+	// exists.
+	MkdirAll(name string, perm FSFileMode) error
+}
+
+// RemoveFS is a file system that can remove files and empty
+// directories.
+type RemoveFS interface {
+	FS
+
+	// Remove removes the named file or empty directory.
+	Remove(name string) error
+}
+
+// RemoveAllFS is a file system that can remove a tree.
+type RemoveAllFS interface {
+	FS
+
+	// RemoveAll removes the named file or directory and everything in
+	// it.  It does nothing if there is no such file.
+	RemoveAll(name string) error
+}
+
+// RenameFS is a file system that can rename files.
+type RenameFS interface {
+	FS
+
+	// Rename renames oldname to newname, replacing newname if it is a
+	// file.
+	Rename(oldname, newname string) error
+}
+
+// OpenFileFS is a file system that can open files for writing.
+type OpenFileFS interface {
+	FS
+
+	// OpenFile opens the named file with the flags of os.OpenFile,
+	// creating it with perm (before the umask) if O_CREATE is given.  A
+	// file opened for writing also implements io.Writer.
+	OpenFile(name string, flag int, perm FSFileMode) (File, error)
+}
+
+// WritableFS is a file system that can do all of the above, bar
+// MkdirAll and RemoveAll, which the functions of the same names do with
+// Mkdir and Remove.
+type WritableFS interface {
+	WriteFileFS
+	MkdirFS
+	RemoveFS
+	RenameFS
+	OpenFileFS
+}
+
+func checkPath(op, name string) error {
+	if !fs.ValidPath(name) {
+		return &PathError{Op: op, Path: name, Err: ErrInvalid}
+	}
+	return nil
+}
+
+func unsupported(op, name string) error {
+	return &PathError{Op: op, Path: name, Err: errors.ErrUnsupported}
+}
+
+// OpenFile opens the named file of fsys with the flags of os.OpenFile.
+// If fsys is not an OpenFileFS, only O_RDONLY is supported, which opens
+// the file with Open.
+func OpenFile(fsys FS, name string, flag int, perm FSFileMode) (File, error) {
+	if fsys, ok := fsys.(OpenFileFS); ok {
+		return fsys.OpenFile(name, flag, perm)
+	}
+	if flag == os.O_RDONLY {
+		return fsys.Open(name)
+	}
+
+	if err := checkPath("open", name); err != nil {
+		return nil, err
+	}
+	return nil, unsupported("open", name)
+}
+
+// Create creates or truncates the named file of fsys, with OpenFile.
+func Create(fsys FS, name string) (File, error) {
+	return OpenFile(fsys, name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+// WriteFile writes data to the named file of fsys.  If fsys is not a
+// WriteFileFS, it opens the file with OpenFile and writes to it.
+func WriteFile(fsys FS, name string, data []byte, perm FSFileMode) error {
+	if fsys, ok := fsys.(WriteFileFS); ok {
+		return fsys.WriteFile(name, data, perm)
+	}
+
+	f, err := OpenFile(fsys, name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	w, ok := f.(io.Writer)
+	if !ok {
+		f.Close()
+		return unsupported("write", name)
+	}
+
+	_, err = w.Write(data)
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	return err
+}
+
+// Mkdir makes the named directory of fsys, which must be a MkdirFS.
+func Mkdir(fsys FS, name string, perm FSFileMode) error {
+	if fsys, ok := fsys.(MkdirFS); ok {
+		return fsys.Mkdir(name, perm)
+	}
+
+	if err := checkPath("mkdir", name); err != nil {
+		return err
+	}
+	return unsupported("mkdir", name)
+}
+
+// MkdirAll makes the named directory of fsys and any that it needs.  If
+// fsys is not a MkdirAllFS, it makes them one by one with Mkdir.
+func MkdirAll(fsys FS, name string, perm FSFileMode) error {
+	if fsys, ok := fsys.(MkdirAllFS); ok {
+		return fsys.MkdirAll(name, perm)
+	}
+
+	if err := checkPath("mkdir", name); err != nil {
+		return err
+	}
+
+	fi, err := fs.Stat(fsys, name)
+	switch {
+	case err == nil && fi.IsDir():
+		return nil
+	case err == nil:
+		return &PathError{Op: "mkdir", Path: name, Err: ErrExist}
+	case !errors.Is(err, ErrNotExist):
+		return err
+	}
+
+	if dir := path.Dir(name); dir != "." {
+		if err := MkdirAll(fsys, dir, perm); err != nil {
+			return err
+		}
+	}
+
+	err = Mkdir(fsys, name, perm)
+	if err != nil && errors.Is(err, ErrExist) {
+		// made since the Stat
+		if fi, err1 := fs.Stat(fsys, name); err1 == nil && fi.IsDir() {
+			return nil
+		}
+	}
+	return err
+}
+
+// Remove removes the named file or empty directory of fsys, which must
+// be a RemoveFS.
+func Remove(fsys FS, name string) error {
+	if fsys, ok := fsys.(RemoveFS); ok {
+		return fsys.Remove(name)
+	}
+
+	if err := checkPath("remove", name); err != nil {
+		return err
+	}
+	return unsupported("remove", name)
+}
+
+// RemoveAll removes the named file or directory of fsys and everything
+// in it.  If fsys is not a RemoveAllFS, it removes them one by one with
+// ReadDir and Remove.
+func RemoveAll(fsys FS, name string) error {
+	if fsys, ok := fsys.(RemoveAllFS); ok {
+		return fsys.RemoveAll(name)
+	}
+
+	if err := checkPath("removeall", name); err != nil {
+		return err
+	}
+	if name == "." {
+		// as os.RemoveAll refuses to remove "."
+		return &PathError{Op: "removeall", Path: name, Err: ErrInvalid}
+	}
+
+	err := Remove(fsys, name)
+	switch {
+	case err == nil, errors.Is(err, ErrNotExist):
+		return nil
+	case errors.Is(err, errors.ErrUnsupported):
+		return err
+	}
+
+	// a directory that is not empty
+	entries, rerr := fs.ReadDir(fsys, name)
+	if rerr != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := RemoveAll(fsys, path.Join(name, e.Name())); err != nil {
+			return err
+		}
+	}
+	return Remove(fsys, name)
+}
+
+// Rename renames oldname to newname in fsys.  If fsys is not a
+// RenameFS, or its Rename is unsupported, a regular file is copied
+// with ReadFile and WriteFile, keeping its permissions, and the old
+// one removed.  Directories cannot be moved that way.
+func Rename(fsys FS, oldname, newname string) error {
+	if fsys, ok := fsys.(RenameFS); ok {
+		err := fsys.Rename(oldname, newname)
+		if !errors.Is(err, errors.ErrUnsupported) {
+			return err
+		}
+	}
+
+	for _, name := range []string{oldname, newname} {
+		if err := checkPath("rename", name); err != nil {
+			return err
+		}
+	}
+
+	fi, err := fs.Stat(fsys, oldname)
+	if err != nil {
+		return err
+	}
+	if !fi.Mode().IsRegular() {
+		return unsupported("rename", oldname)
+	}
+
+	data, err := fs.ReadFile(fsys, oldname)
+	if err != nil {
+		return err
+	}
+	if err := WriteFile(fsys, newname, data, fi.Mode().Perm()); err != nil {
+		return err
+	}
+	return Remove(fsys, oldname)
+}
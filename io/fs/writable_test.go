@@ -0,0 +1,99 @@
+package fs
+
+import (
+	"errors"
+	stdfs "io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+// openFileFS is a file system with OpenFile, Mkdir and Remove, and
+// nothing else, to make the helpers fall back.
+type openFileFS struct {
+	FS
+	dir string
+}
+
+func (f openFileFS) path(name string) string {
+	return filepath.Join(f.dir, filepath.FromSlash(name))
+}
+
+func (f openFileFS) OpenFile(name string, flag int, perm FSFileMode) (File, error) {
+	return os.OpenFile(f.path(name), flag, perm)
+}
+
+func (f openFileFS) Mkdir(name string, perm FSFileMode) error {
+	return os.Mkdir(f.path(name), perm)
+}
+
+func (f openFileFS) Remove(name string) error {
+	return os.Remove(f.path(name))
+}
+
+func TestWritable_Fallbacks(t *testing.T) {
+	dir := t.TempDir()
+	fsys := openFileFS{FS: os.DirFS(dir), dir: dir}
+
+	if err := MkdirAll(fsys, "a/b/c", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := MkdirAll(fsys, "a/b", 0755); err != nil {
+		t.Errorf("MkdirAll() of existing directory error = %v", err)
+	}
+	if err := WriteFile(fsys, "a/b/x.txt", []byte("x"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := MkdirAll(fsys, "a/b/x.txt", 0755); !errors.Is(err, ErrExist) {
+		t.Errorf("MkdirAll() of file error = %v, want ErrExist", err)
+	}
+
+	if err := Rename(fsys, "a/b/x.txt", "a/y.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(fsys, "a/y.txt", "a/b/c"); err != nil {
+		t.Error(err)
+	}
+	if fi, err := stdfs.Stat(fsys, "a/y.txt"); err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("Stat() of renamed file = %v, %v, want mode 0600", fi, err)
+	}
+	if err := Rename(fsys, "a/b", "a/d"); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("Rename() of directory error = %v, want ErrUnsupported", err)
+	}
+
+	if err := RemoveAll(fsys, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := RemoveAll(fsys, "a"); err != nil {
+		t.Errorf("RemoveAll() of missing directory error = %v", err)
+	}
+	if _, err := stdfs.Stat(fsys, "a"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Stat() after RemoveAll() error = %v, want ErrNotExist", err)
+	}
+}
+
+func TestWritable_ReadOnly(t *testing.T) {
+	fsys := fstest.MapFS{"a": {Data: []byte("a")}}
+
+	f, err := OpenFile(fsys, "a", os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	for name, err := range map[string]error{
+		"OpenFile":  func() error { _, err := OpenFile(fsys, "a", os.O_RDWR, 0); return err }(),
+		"WriteFile": WriteFile(fsys, "b", nil, 0644),
+		"Mkdir":     Mkdir(fsys, "d", 0755),
+		"Remove":    Remove(fsys, "a"),
+	} {
+		if !errors.Is(err, errors.ErrUnsupported) {
+			t.Errorf("%s() on read-only FS error = %v, want ErrUnsupported", name, err)
+		}
+	}
+
+	if err := Mkdir(fsys, "/abs", 0755); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Mkdir() of invalid path error = %v, want ErrInvalid", err)
+	}
+}
@@ -0,0 +1,205 @@
+package os
+
+import (
+	"errors"
+	stdfs "io/fs"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"syscall"
+)
+
+// AtomicFile is a file that replaces another only once it is complete.
+// It is written as a temporary file in the same directory, and Commit
+// moves it over the target.  Until then, the target is untouched.
+type AtomicFile interface {
+	File
+
+	// Commit syncs and closes the file, renames it over the target and
+	// syncs the directory.  If the sync, close or rename fails, the
+	// temporary file is removed and the target is left as it was.  If
+	// only the sync of the directory fails, the target already has its
+	// new contents, but they may not survive a crash; the error is then
+	// a *PathError for the directory.
+	Commit() error
+
+	// Abort closes and removes the temporary file.  It does nothing once
+	// the file has been committed or aborted, so it can be deferred.
+	Abort() error
+}
+
+// AtomicOption sets how an atomic write treats the target.
+type AtomicOption func(*atomicOptions)
+
+type atomicOptions struct {
+	keepMode bool
+}
+
+// WithKeepMode sets whether the target keeps its permissions when it is
+// replaced, which is the default, or gets those given to the write.  A
+// new target always gets those given to the write.
+func WithKeepMode(keep bool) AtomicOption {
+	return func(o *atomicOptions) {
+		o.keepMode = keep
+	}
+}
+
+type atomicFile struct {
+	File
+	os     OS
+	target string
+
+	mu   sync.Mutex
+	done bool
+}
+
+// CreateAtomic creates a file that replaces name when it is committed,
+// with the permissions perm, or those of name if it exists.  The
+// permissions are set as they are given, without the umask.  If name is
+// a symbolic link, it is the file the link leads to that is replaced,
+// and the link is kept.  It works through o, so any OS will do,
+// including an in-memory one.
+func CreateAtomic(o OS, name string, perm FSFileMode, options ...AtomicOption) (AtomicFile, error) {
+	var opts = atomicOptions{
+		keepMode: true,
+	}
+	for _, f := range options {
+		f(&opts)
+	}
+
+	name, err := resolveLinks(o, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.keepMode {
+		if fi, err := o.Stat(name); err == nil {
+			perm = fi.Mode().Perm()
+		}
+	}
+
+	// CreateTemp takes "" to mean the temporary directory
+	dir, base := filepath.Split(name)
+	if dir == "" {
+		dir = "."
+	}
+	f, err := o.CreateTemp(dir, "."+base+".tmp-*")
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		o.Remove(f.Name())
+		return nil, err
+	}
+
+	return &atomicFile{
+		File:   f,
+		os:     o,
+		target: name,
+	}, nil
+}
+
+// WriteFileAtomic writes data to name, so that name holds either its
+// old contents or data, even if the process or the machine stops half
+// way.  Permissions are as for CreateAtomic.
+func WriteFileAtomic(o OS, name string, data []byte, perm FSFileMode, options ...AtomicOption) error {
+	f, err := CreateAtomic(o, name, perm, options...)
+	if err != nil {
+		return err
+	}
+	defer f.Abort()
+
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	return f.Commit()
+}
+
+// Close aborts the file, unless it has been committed.
+func (f *atomicFile) Close() error {
+	return f.Abort()
+}
+
+// Unwrap returns the temporary file, for LockerFor.
+func (f *atomicFile) Unwrap() File {
+	return f.File
+}
+
+func (f *atomicFile) Commit() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.done {
+		return ErrClosed
+	}
+	f.done = true
+
+	tmp := f.File.Name()
+	err := f.File.Sync()
+	if err1 := f.File.Close(); err == nil {
+		err = err1
+	}
+	if err == nil {
+		err = f.os.Rename(tmp, f.target)
+	}
+	if err != nil {
+		f.os.Remove(tmp)
+		return err
+	}
+
+	return syncDir(f.os, filepath.Dir(f.target))
+}
+
+func (f *atomicFile) Abort() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.done {
+		return nil
+	}
+	f.done = true
+
+	return errors.Join(f.File.Close(), f.os.Remove(f.File.Name()))
+}
+
+// resolveLinks follows name while it is a symbolic link, and returns
+// the name of what it leads to, which need not exist.
+func resolveLinks(o OS, name string) (string, error) {
+	for links := 0; ; links++ {
+		fi, err := o.Lstat(name)
+		if err != nil || fi.Nub().Mode()&stdfs.ModeSymlink == 0 {
+			return name, nil
+		}
+		if links == 255 {
+			return "", &PathError{Op: "open", Path: name, Err: syscall.ELOOP}
+		}
+
+		target, err := o.Readlink(name)
+		if err != nil {
+			return "", err
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(name), target)
+		}
+		name = target
+	}
+}
+
+// syncDir syncs the directory dir, so that a rename in it is durable.
+// Windows cannot sync a directory, and has no need to.
+func syncDir(o OS, dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	d, err := o.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if err1 := d.Close(); err == nil {
+		err = err1
+	}
+	return err
+}
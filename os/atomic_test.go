@@ -0,0 +1,124 @@
+package os
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		os   OS
+		dir  func(*testing.T) string
+	}{
+		{"os", NewOS(), func(t *testing.T) string { return t.TempDir() }},
+		{"mem", NewMemOS(), func(*testing.T) string { return "/tmp" }},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			o, dir := tt.os, tt.dir(t)
+			name := filepath.Join(dir, "config")
+
+			if err := WriteFileAtomic(o, name, []byte("v1"), 0600); err != nil {
+				t.Fatalf("WriteFileAtomic() error = %v", err)
+			}
+			if err := o.Chmod(name, 0640); err != nil {
+				t.Fatal(err)
+			}
+			if err := WriteFileAtomic(o, name, []byte("v2"), 0600); err != nil {
+				t.Fatalf("WriteFileAtomic() error = %v", err)
+			}
+			if data, err := o.ReadFile(name); err != nil || string(data) != "v2" {
+				t.Errorf("ReadFile() = %q, %v, want %q", data, err, "v2")
+			}
+			if fi, err := o.Stat(name); err != nil || fi.Mode().Perm() != 0640 {
+				t.Errorf("Stat() = %v, %v, want mode 0640 kept", fi, err)
+			}
+
+			if err := WriteFileAtomic(o, name, []byte("v3"), 0600, WithKeepMode(false)); err != nil {
+				t.Fatalf("WriteFileAtomic() error = %v", err)
+			}
+			if fi, err := o.Stat(name); err != nil || fi.Mode().Perm() != 0600 {
+				t.Errorf("Stat() = %v, %v, want mode 0600", fi, err)
+			}
+
+			if entries, err := o.ReadDir(dir); err != nil || len(entries) != 1 {
+				t.Errorf("ReadDir() = %v, %v, want only the target", entries, err)
+			}
+		})
+	}
+}
+
+func TestWriteFileAtomic_Symlink(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		os   OS
+		dir  func(*testing.T) string
+	}{
+		{"os", NewOS(), func(t *testing.T) string { return t.TempDir() }},
+		{"mem", NewMemOS(), func(*testing.T) string { return "/tmp" }},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			o, dir := tt.os, tt.dir(t)
+			target := filepath.Join(dir, "real")
+			link := filepath.Join(dir, "link")
+			if err := o.WriteFile(target, []byte("v1"), 0640); err != nil {
+				t.Fatal(err)
+			}
+			if err := o.Symlink("real", link); err != nil {
+				t.Skipf("Symlink() error = %v", err)
+			}
+
+			if err := WriteFileAtomic(o, link, []byte("v2"), 0600); err != nil {
+				t.Fatalf("WriteFileAtomic() error = %v", err)
+			}
+			if got, err := o.Readlink(link); err != nil || got != "real" {
+				t.Errorf("Readlink() = %q, %v, want the link kept", got, err)
+			}
+			if data, err := o.ReadFile(target); err != nil || string(data) != "v2" {
+				t.Errorf("ReadFile() of the target = %q, %v, want %q", data, err, "v2")
+			}
+			if fi, err := o.Stat(target); err != nil || fi.Mode().Perm() != 0640 {
+				t.Errorf("Stat() = %v, %v, want mode 0640 kept", fi, err)
+			}
+		})
+	}
+}
+
+func TestCreateAtomic_Abort(t *testing.T) {
+	o := NewMemOS()
+	if err := o.WriteFile("/tmp/state", []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := CreateAtomic(o, "/tmp/state", 0644)
+	if err != nil {
+		t.Fatalf("CreateAtomic() error = %v", err)
+	}
+	f.WriteString("half")
+	if err := f.Abort(); err != nil {
+		t.Errorf("Abort() error = %v", err)
+	}
+	if err := f.Commit(); !errors.Is(err, ErrClosed) {
+		t.Errorf("Commit() after Abort() error = %v, want ErrClosed", err)
+	}
+
+	if data, _ := o.ReadFile("/tmp/state"); string(data) != "old" {
+		t.Errorf("target = %q after Abort(), want %q", data, "old")
+	}
+	if entries, _ := o.ReadDir("/tmp"); len(entries) != 1 {
+		t.Errorf("ReadDir() = %v, want the temporary file removed", entries)
+	}
+}
+
+func TestCreateAtomic_RenameFails(t *testing.T) {
+	o := NewFaultOS(NewMemOS(), Fault{Op: "Rename"})
+
+	err := WriteFileAtomic(o, "/tmp/state", []byte("new"), 0644)
+	if err == nil {
+		t.Fatal("WriteFileAtomic() succeeded despite a failing Rename")
+	}
+	if entries, _ := o.ReadDir("/tmp"); len(entries) != 0 {
+		t.Errorf("ReadDir() = %v, want the temporary file removed", entries)
+	}
+}
@@ -0,0 +1,184 @@
+package os
+
+import (
+	"io"
+	stdfs "io/fs"
+	"slices"
+	"strings"
+)
+
+// fileOpener is the part of OS and Root that is needed to present
+// a directory tree as an fs.FS.
+type fileOpener interface {
+	Open(string) (File, error)
+	Stat(string) (FileInfo, error)
+}
+
+// dirFS presents the tree below dir as an fs.FS, the way os.DirFS
+// and os.Root.FS do for the real file system.  An empty dir means
+// that names are passed to the opener unchanged, which is what a
+// Root wants.
+type dirFS struct {
+	fsys fileOpener
+	dir  string
+}
+
+func newDirFS(fsys fileOpener, dir string) dirFS {
+	return dirFS{
+		fsys: fsys,
+		dir:  dir,
+	}
+}
+
+func (d dirFS) join(op, name string) (string, error) {
+	if !stdfs.ValidPath(name) {
+		return "", &PathError{Op: op, Path: name, Err: ErrInvalid}
+	}
+	if d.dir == "" {
+		return name, nil
+	}
+	if name == "." {
+		return d.dir, nil
+	}
+	return strings.TrimSuffix(d.dir, "/") + "/" + name, nil
+}
+
+// rename reports errors in terms of the name passed to the fs.FS
+// rather than the name it was mapped to.
+func (d dirFS) rename(err error, name string) error {
+	if pe, ok := err.(*PathError); ok {
+		return &PathError{Op: pe.Op, Path: name, Err: pe.Err}
+	}
+	return err
+}
+
+func (d dirFS) Open(name string) (stdfs.File, error) {
+	full, err := d.join("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := d.fsys.Open(full)
+	if err != nil {
+		return nil, d.rename(err, name)
+	}
+
+	return fsFile{File: f}, nil
+}
+
+func (d dirFS) ReadFile(name string) ([]byte, error) {
+	full, err := d.join("readfile", name)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := d.fsys.Open(full)
+	if err != nil {
+		return nil, d.rename(err, name)
+	}
+	defer f.Close()
+
+	return io.ReadAll(f)
+}
+
+func (d dirFS) ReadDir(name string) ([]stdfs.DirEntry, error) {
+	full, err := d.join("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := d.fsys.Open(full)
+	if err != nil {
+		return nil, d.rename(err, name)
+	}
+	defer f.Close()
+
+	entries, err := fsFile{File: f}.ReadDir(-1)
+	slices.SortFunc(entries, func(a, b stdfs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+	return entries, err
+}
+
+func (d dirFS) Stat(name string) (stdfs.FileInfo, error) {
+	full, err := d.join("stat", name)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := d.fsys.Stat(full)
+	if err != nil {
+		return nil, d.rename(err, name)
+	}
+
+	return fsFileInfo{FileInfo: fi}, nil
+}
+
+// fsFile adapts a File to the fs.File and fs.ReadDirFile interfaces,
+// which use the standard library FileInfo and DirEntry types.
+type fsFile struct {
+	File
+}
+
+func (f fsFile) Stat() (stdfs.FileInfo, error) {
+	fi, err := f.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	return fsFileInfo{FileInfo: fi}, nil
+}
+
+func (f fsFile) ReadDir(n int) ([]stdfs.DirEntry, error) {
+	dea, err := f.File.ReadDir(n)
+
+	var entries = make([]stdfs.DirEntry, 0, len(dea))
+	for _, de := range dea {
+		entries = append(entries, fsDirEntry{DirEntry: de})
+	}
+
+	return entries, err
+}
+
+// fsFileInfo is a FileInfo of this package that is also an fs.FileInfo
+// of the standard library, as an fs.FS must return.  The two differ
+// only in the type of Mode, so it keeps the methods of the FileInfo,
+// such as Owner and Inode, and Unwrap returns the FileInfo itself.
+type fsFileInfo struct {
+	FileInfo
+}
+
+func (fi fsFileInfo) Mode() FSFileMode {
+	return fi.FileInfo.Nub().Mode()
+}
+
+func (fi fsFileInfo) Unwrap() FileInfo {
+	return fi.FileInfo
+}
+
+// fsDirEntry is a DirEntry of this package that is also an fs.DirEntry
+// of the standard library, as fsFileInfo is for a FileInfo.
+type fsDirEntry struct {
+	DirEntry
+}
+
+func (de fsDirEntry) Type() FSFileMode {
+	return de.DirEntry.Nub().Type()
+}
+
+func (de fsDirEntry) Info() (stdfs.FileInfo, error) {
+	fi, err := de.DirEntry.Info()
+	if err != nil {
+		return nil, err
+	}
+
+	return fsFileInfo{FileInfo: fi}, nil
+}
+
+func (de fsDirEntry) String() string {
+	return de.DirEntry.Format()
+}
+
+func (de fsDirEntry) Unwrap() DirEntry {
+	return de.DirEntry
+}
@@ -2,9 +2,12 @@ package exec
 
 import (
 	"context"
+	"errors"
 	"io"
-	"os"
 	"os/exec"
+	"time"
+
+	"github.com/pdutton/go-interfaces/os"
 )
 
 type Cmd interface {
@@ -16,8 +19,11 @@ type Cmd interface {
 	Stdin() io.Reader
 	Stdout() io.Writer
 	Stderr() io.Writer
-	Process() *os.Process
-	ProcessState() *os.ProcessState
+	Process() os.Process
+	ProcessState() os.ProcessState
+	Cancel() func() error
+	WaitDelay() time.Duration
+	Err() error
 
 	// Members:
 	CombinedOutput() ([]byte, error)
@@ -37,9 +43,23 @@ type cmdFacade struct {
 
 	args []string
 	ctxt context.Context
+
+	// env holds what the environment options asked for, which is
+	// built into realCmd.Env once they have all run
+	env cmdEnv
 }
 
 func (_ execFacade) NewCommand(name string, options ...CommandOption) Cmd {
+	return newCmdFacade(name, options, func(ctxt context.Context, args []string) *exec.Cmd {
+		if ctxt == nil {
+			return exec.Command(name, args...)
+		}
+		return exec.CommandContext(ctxt, name, args...)
+	})
+}
+
+// newCmdFacade applies options to a command made by newCmd.
+func newCmdFacade(name string, options []CommandOption, newCmd func(context.Context, []string) *exec.Cmd) cmdFacade {
 	var cmd = cmdFacade{}
 
 	// Only the options that run before the real command is created
@@ -48,17 +68,14 @@ func (_ execFacade) NewCommand(name string, options ...CommandOption) Cmd {
 		f(&cmd)
 	}
 
-	if cmd.ctxt == nil {
-		cmd.realCmd = exec.Command(name, cmd.args...)
-	} else {
-		cmd.realCmd = exec.CommandContext(cmd.ctxt, name, cmd.args...)
-	}
+	cmd.realCmd = newCmd(cmd.ctxt, cmd.args)
 
 	// Only the options that run after the real command is created
 	// will run here because cmd.realCmd != nil.
 	for _, f := range options {
 		f(&cmd)
 	}
+	cmd.env.build(cmd.realCmd)
 
 	return cmd
 }
@@ -91,12 +108,30 @@ func (cmd cmdFacade) Stderr() io.Writer {
 	return cmd.realCmd.Stderr
 }
 
-func (cmd cmdFacade) Process() *os.Process {
-	return cmd.realCmd.Process
+func (cmd cmdFacade) Process() os.Process {
+	if cmd.realCmd.Process == nil {
+		return nil
+	}
+	return os.WrapProcess(cmd.realCmd.Process)
+}
+
+func (cmd cmdFacade) ProcessState() os.ProcessState {
+	if cmd.realCmd.ProcessState == nil {
+		return nil
+	}
+	return os.WrapProcessState(cmd.realCmd.ProcessState)
+}
+
+func (cmd cmdFacade) Cancel() func() error {
+	return cmd.realCmd.Cancel
+}
+
+func (cmd cmdFacade) WaitDelay() time.Duration {
+	return cmd.realCmd.WaitDelay
 }
 
-func (cmd cmdFacade) ProcessState() *os.ProcessState {
-	return cmd.realCmd.ProcessState
+func (cmd cmdFacade) Err() error {
+	return cmd.realCmd.Err
 }
 
 func (cmd cmdFacade) CombinedOutput() ([]byte, error) {
@@ -138,3 +173,12 @@ func (cmd cmdFacade) String() string {
 func (cmd cmdFacade) Wait() error {
 	return cmd.realCmd.Wait()
 }
+
+// release closes the pipes of a command that will not be started, as a
+// failed Start would.
+func (cmd cmdFacade) release() {
+	if cmd.realCmd.Err == nil {
+		cmd.realCmd.Err = errors.New("exec: command not started")
+	}
+	cmd.realCmd.Start()
+}
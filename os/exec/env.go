@@ -0,0 +1,123 @@
+package exec
+
+import (
+	stdos "os"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/pdutton/go-interfaces/os"
+)
+
+// A command starts out with a base environment, which WithEnv and
+// WithUnsetEnv then change, in the order they are given.  The base is
+// the environment of the process unless WithCleanEnv, WithEnviron or
+// WithEnvFrom says otherwise; whichever of those comes last wins.  A
+// command given none of these options leaves Env nil, and inherits
+// the environment of the process as exec.Cmd does.
+
+type cmdEnv struct {
+	base    []string
+	hasBase bool
+	changes []envChange
+}
+
+type envChange struct {
+	name  string
+	value string
+	unset bool
+}
+
+func (e *cmdEnv) setBase(env []string) {
+	e.base = slices.Clone(env)
+	if e.base == nil {
+		e.base = []string{}
+	}
+	e.hasBase = true
+}
+
+func (e *cmdEnv) build(cmd *exec.Cmd) {
+	if !e.hasBase && len(e.changes) == 0 {
+		return
+	}
+
+	env := e.base
+	if !e.hasBase {
+		env = stdos.Environ()
+	}
+	env = slices.Clone(env)
+	if env == nil {
+		env = []string{}
+	}
+
+	for _, c := range e.changes {
+		env = slices.DeleteFunc(env, func(kv string) bool {
+			name, _, _ := strings.Cut(kv, "=")
+			return name == c.name
+		})
+		if !c.unset {
+			env = append(env, c.name+"="+c.value)
+		}
+	}
+
+	cmd.Env = env
+}
+
+// WithEnv sets the variable name to value, on top of the base
+// environment, replacing any value it already had.
+func WithEnv(name, value string) CommandOption {
+	return func(cmd *cmdFacade) {
+		if cmd.realCmd != nil {
+			cmd.env.changes = append(cmd.env.changes, envChange{name: name, value: value})
+		}
+	}
+}
+
+// WithUnsetEnv removes the variable name from the environment.
+func WithUnsetEnv(name string) CommandOption {
+	return func(cmd *cmdFacade) {
+		if cmd.realCmd != nil {
+			cmd.env.changes = append(cmd.env.changes, envChange{name: name, unset: true})
+		}
+	}
+}
+
+// WithInheritedEnv bases the environment on that of the process, which
+// is also what happens without any of the options that set the base.
+func WithInheritedEnv() CommandOption {
+	return func(cmd *cmdFacade) {
+		if cmd.realCmd != nil {
+			cmd.env.base, cmd.env.hasBase = nil, false
+		}
+	}
+}
+
+// WithCleanEnv bases the environment on an empty one, so that the
+// command sees only the variables given with WithEnv.
+func WithCleanEnv() CommandOption {
+	return func(cmd *cmdFacade) {
+		if cmd.realCmd != nil {
+			cmd.env.setBase(nil)
+		}
+	}
+}
+
+// WithEnviron bases the environment on env, a list of "key=value"
+// strings like that returned by os.Environ.
+func WithEnviron(env []string) CommandOption {
+	return func(cmd *cmdFacade) {
+		if cmd.realCmd != nil {
+			cmd.env.setBase(env)
+		}
+	}
+}
+
+// WithEnvFrom bases the environment on that of env, such as an os.OS,
+// read when the command is created.
+func WithEnvFrom(env os.EnvReader) CommandOption {
+	return func(cmd *cmdFacade) {
+		if cmd.realCmd != nil {
+			cmd.env.setBase(env.Environ())
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package exec
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/pdutton/go-interfaces/os"
+)
+
+func TestCmd_EnvModes(t *testing.T) {
+	t.Setenv("EXEC_TEST_INHERITED", "parent")
+
+	fake := os.NewMemOS()
+	fake.Clearenv()
+	fake.Setenv("FROM_OS", "1")
+	fake.Setenv("DROP", "x")
+
+	for _, tt := range []struct {
+		name    string
+		options []CommandOption
+		want    []string
+	}{
+		{"none", nil, nil},
+		{"clean", []CommandOption{WithCleanEnv()}, []string{}},
+		{"clean override", []CommandOption{WithCleanEnv(), WithEnv("A", "1"), WithEnv("A", "2")}, []string{"A=2"}},
+		{"environ", []CommandOption{WithEnviron([]string{"A=1", "B=2"}), WithUnsetEnv("A")}, []string{"B=2"}},
+		{"from os", []CommandOption{WithEnvFrom(fake), WithUnsetEnv("DROP"), WithEnv("NEW", "2")}, []string{"FROM_OS=1", "NEW=2"}},
+		{"last base wins", []CommandOption{WithEnviron([]string{"A=1"}), WithCleanEnv()}, []string{}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewExec().NewCommand("go", tt.options...)
+			env := cmd.Env()
+			slices.Sort(env)
+			if !slices.Equal(env, tt.want) || (env == nil) != (tt.want == nil) {
+				t.Errorf("Env() = %q, want %q", env, tt.want)
+			}
+		})
+	}
+}
+
+func TestCmd_EnvInheritAndOverride(t *testing.T) {
+	t.Setenv("EXEC_TEST_INHERITED", "parent")
+	t.Setenv("EXEC_TEST_OVERRIDDEN", "parent")
+
+	cmd := NewExec().NewCommand("go", WithEnv("EXEC_TEST_OVERRIDDEN", "child"), WithUnsetEnv("PATH"))
+	env := cmd.Env()
+
+	if !slices.Contains(env, "EXEC_TEST_INHERITED=parent") {
+		t.Error("Env() lost an inherited variable")
+	}
+	if !slices.Contains(env, "EXEC_TEST_OVERRIDDEN=child") || slices.Contains(env, "EXEC_TEST_OVERRIDDEN=parent") {
+		t.Errorf("Env() did not override EXEC_TEST_OVERRIDDEN")
+	}
+	if slices.ContainsFunc(env, func(kv string) bool { return strings.HasPrefix(kv, "PATH=") }) {
+		t.Error("Env() still has PATH after WithUnsetEnv")
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/pdutton/go-interfaces/os"
 )
 
 func TestNewExec(t *testing.T) {
@@ -270,8 +272,8 @@ func TestCmd_Process(t *testing.T) {
 	if proc == nil {
 		t.Error("Process() returned nil after Start()")
 	}
-	if proc.Pid == 0 {
-		t.Error("Process().Pid = 0, want > 0")
+	if proc.PID() == 0 {
+		t.Error("Process().PID() = 0, want > 0")
 	}
 
 	cmd.Wait()
@@ -364,6 +366,43 @@ func TestWithExtraOptions(t *testing.T) {
 	if err := cmd.Run(); err != nil {
 		t.Errorf("Run() with WaitDelay returned error: %v", err)
 	}
+	if cmd.WaitDelay() != 1*time.Second {
+		t.Errorf("WaitDelay() = %v, want 1s", cmd.WaitDelay())
+	}
+
+	// Test WithCancel
+	cancel := func() error { return nil }
+	cmd = e.NewCommand("go", WithArgs("version"), WithContext(context.Background()), WithCancel(cancel))
+	if cmd.Cancel() == nil {
+		t.Error("Cancel() returned nil after WithCancel")
+	}
+}
+
+func TestWithExtraFiles(t *testing.T) {
+	e := NewExec()
+
+	f, err := os.NewOS().CreateTemp(t.TempDir(), "extra")
+	if err != nil {
+		t.Fatalf("CreateTemp() error = %v", err)
+	}
+	defer f.Close()
+
+	cmd := e.NewCommand("go", WithArgs("version"), WithExtraFiles([]os.File{f}))
+	if err := cmd.Err(); err != nil {
+		t.Errorf("Err() = %v, want nil", err)
+	}
+	if err := cmd.Run(); err != nil {
+		t.Errorf("Run() with ExtraFiles returned error: %v", err)
+	}
+
+	mf, _ := os.NewMemOS().Create("/tmp/extra")
+	cmd = e.NewCommand("go", WithArgs("version"), WithExtraFiles([]os.File{mf}))
+	if cmd.Err() == nil {
+		t.Error("Err() = nil for a file without an *os.File")
+	}
+	if err := cmd.Start(); err == nil {
+		t.Error("Start() succeeded for a file without an *os.File")
+	}
 }
 
 func TestErrorTypes(t *testing.T) {
@@ -0,0 +1,369 @@
+package exec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pdutton/go-interfaces/os"
+)
+
+// fakeCmd is a Cmd of a FakeExec.  The exec.Cmd it holds describes the
+// command, as the options left it, but is never started.  Once started,
+// its Process is an os.FakeProcess, whose signals go to the handler,
+// and once it has exited and been waited for, its ProcessState is a
+// fake one.
+type fakeCmd struct {
+	exec *fakeExec
+	cmd  *exec.Cmd
+	ctxt context.Context
+
+	mu      sync.Mutex
+	started bool
+	waited  bool
+	done    chan struct{}
+	err     error
+
+	proc    os.FakeProcess
+	state   os.ProcessState
+	signals chan os.Signal
+	kill    context.CancelFunc
+	killed  bool
+
+	// closeAfter holds the ends of pipes to close once the command exits
+	closeAfter []io.Closer
+}
+
+func (c *fakeCmd) Path() string {
+	return c.cmd.Path
+}
+
+func (c *fakeCmd) Args() []string {
+	return c.cmd.Args
+}
+
+func (c *fakeCmd) Env() []string {
+	return c.cmd.Env
+}
+
+func (c *fakeCmd) Dir() string {
+	return c.cmd.Dir
+}
+
+func (c *fakeCmd) Stdin() io.Reader {
+	return c.cmd.Stdin
+}
+
+func (c *fakeCmd) Stdout() io.Writer {
+	return c.cmd.Stdout
+}
+
+func (c *fakeCmd) Stderr() io.Writer {
+	return c.cmd.Stderr
+}
+
+func (c *fakeCmd) Process() os.Process {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.started {
+		return nil
+	}
+	return c.proc
+}
+
+func (c *fakeCmd) ProcessState() os.ProcessState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.waited || c.state == nil {
+		return nil
+	}
+	return c.state
+}
+
+func (c *fakeCmd) Cancel() func() error {
+	return c.cmd.Cancel
+}
+
+func (c *fakeCmd) WaitDelay() time.Duration {
+	return c.cmd.WaitDelay
+}
+
+func (c *fakeCmd) Err() error {
+	return c.cmd.Err
+}
+
+func (c *fakeCmd) CombinedOutput() ([]byte, error) {
+	if c.cmd.Stdout != nil {
+		return nil, errors.New("exec: Stdout already set")
+	}
+	if c.cmd.Stderr != nil {
+		return nil, errors.New("exec: Stderr already set")
+	}
+
+	var b bytes.Buffer
+	c.cmd.Stdout = &b
+	c.cmd.Stderr = &b
+	err := c.Run()
+	return b.Bytes(), err
+}
+
+func (c *fakeCmd) Environ() []string {
+	return c.cmd.Environ()
+}
+
+func (c *fakeCmd) Output() ([]byte, error) {
+	if c.cmd.Stdout != nil {
+		return nil, errors.New("exec: Stdout already set")
+	}
+
+	var stdout bytes.Buffer
+	c.cmd.Stdout = &stdout
+
+	var stderr *bytes.Buffer
+	if c.cmd.Stderr == nil {
+		stderr = &bytes.Buffer{}
+		c.cmd.Stderr = stderr
+	}
+
+	err := c.Run()
+	if ee, ok := err.(*FakeExitError); ok && stderr != nil {
+		ee.Stderr = stderr.Bytes()
+	}
+	return stdout.Bytes(), err
+}
+
+func (c *fakeCmd) Run() error {
+	if err := c.Start(); err != nil {
+		return err
+	}
+	return c.Wait()
+}
+
+// Start runs the handler of the command in the background.
+func (c *fakeCmd) Start() error {
+	c.exec.t.Helper()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.started {
+		return errors.New("exec: already started")
+	}
+	if c.cmd.Err != nil {
+		return c.cmd.Err
+	}
+	if c.ctxt != nil {
+		if err := c.ctxt.Err(); err != nil {
+			return err
+		}
+	}
+
+	x, err := c.exec.expect(c.cmd)
+	if err != nil {
+		return err
+	}
+	c.started = true
+	c.done = make(chan struct{})
+	c.proc = os.NewFakeProcess(c.exec.nextPID())
+	c.proc.OnSignal(c.signal)
+	c.signals = make(chan os.Signal, 16)
+
+	ctxt := c.ctxt
+	if ctxt == nil {
+		ctxt = context.Background()
+	}
+	ctxt, c.kill = context.WithCancel(ctxt)
+
+	var inv = &Invocation{
+		Context: ctxt,
+		Name:    c.cmd.Path,
+		Args:    c.cmd.Args[1:],
+		Env:     c.cmd.Env,
+		Dir:     c.cmd.Dir,
+		Stdin:   c.cmd.Stdin,
+		Signals: c.signals,
+	}
+	if inv.Stdin == nil {
+		inv.Stdin = strings.NewReader("")
+	}
+
+	go c.run(x.handler, inv)
+
+	return nil
+}
+
+func (c *fakeCmd) run(h Handler, inv *Invocation) {
+	var res Result
+	if h != nil {
+		res = h(inv)
+	}
+
+	killed := false
+	if res.Delay > 0 {
+		timer := time.NewTimer(res.Delay)
+		select {
+		case <-timer.C:
+		case <-inv.Context.Done():
+			timer.Stop()
+			killed = true
+		}
+	}
+
+	c.mu.Lock()
+	killed = killed || c.killed
+	c.mu.Unlock()
+
+	var (
+		err   error
+		state = []os.ProcessStateOption{os.WithExitCode(res.ExitCode)}
+	)
+	switch {
+	case killed:
+		err = &FakeExitError{Killed: true}
+		state = []os.ProcessStateOption{os.WithSignaled(syscall.SIGKILL)}
+	default:
+		err = c.write(c.cmd.Stdout, res.Stdout)
+		if err1 := c.write(c.cmd.Stderr, res.Stderr); err == nil {
+			err = err1
+		}
+		switch {
+		case res.Err != nil:
+			err = res.Err
+		case res.ExitCode != 0:
+			err = &FakeExitError{Code: res.ExitCode}
+		}
+	}
+
+	c.mu.Lock()
+	for _, closer := range c.closeAfter {
+		closer.Close()
+	}
+	c.err = err
+	c.state = os.NewFakeProcessState(c.proc.PID(), state...)
+	c.mu.Unlock()
+
+	c.proc.Exit(c.state)
+	c.kill()
+	close(c.done)
+}
+
+// signal passes sig to the handler, or kills the command if it is
+// SIGKILL.  Signals are dropped if too many are waiting, as a real
+// process may merge them.
+func (c *fakeCmd) signal(_ os.FakeProcess, sig os.Signal) {
+	if sig == syscall.SIGKILL {
+		c.mu.Lock()
+		c.killed = true
+		c.mu.Unlock()
+
+		c.kill()
+		return
+	}
+
+	select {
+	case c.signals <- sig:
+	default:
+	}
+}
+
+func (c *fakeCmd) write(w io.Writer, s string) error {
+	if w == nil || s == "" {
+		return nil
+	}
+	_, err := io.WriteString(w, s)
+	return err
+}
+
+func (c *fakeCmd) StderrPipe() (io.ReadCloser, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cmd.Stderr != nil {
+		return nil, errors.New("exec: Stderr already set")
+	}
+	if c.started {
+		return nil, errors.New("exec: StderrPipe after process started")
+	}
+
+	pr, pw := io.Pipe()
+	c.cmd.Stderr = pw
+	c.closeAfter = append(c.closeAfter, pw)
+	return pr, nil
+}
+
+func (c *fakeCmd) StdinPipe() (io.WriteCloser, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cmd.Stdin != nil {
+		return nil, errors.New("exec: Stdin already set")
+	}
+	if c.started {
+		return nil, errors.New("exec: StdinPipe after process started")
+	}
+
+	pr, pw := io.Pipe()
+	c.cmd.Stdin = pr
+	c.closeAfter = append(c.closeAfter, pr)
+	return pw, nil
+}
+
+func (c *fakeCmd) StdoutPipe() (io.ReadCloser, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cmd.Stdout != nil {
+		return nil, errors.New("exec: Stdout already set")
+	}
+	if c.started {
+		return nil, errors.New("exec: StdoutPipe after process started")
+	}
+
+	pr, pw := io.Pipe()
+	c.cmd.Stdout = pw
+	c.closeAfter = append(c.closeAfter, pw)
+	return pr, nil
+}
+
+func (c *fakeCmd) String() string {
+	return c.cmd.String()
+}
+
+func (c *fakeCmd) Wait() error {
+	c.mu.Lock()
+	switch {
+	case !c.started:
+		c.mu.Unlock()
+		return errors.New("exec: not started")
+	case c.waited:
+		c.mu.Unlock()
+		return errors.New("exec: Wait was already called")
+	}
+	c.waited = true
+	c.mu.Unlock()
+
+	<-c.done
+	return c.err
+}
+
+// release closes the pipes of a command that will not be started.
+func (c *fakeCmd) release() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.started {
+		return
+	}
+	for _, closer := range c.closeAfter {
+		closer.Close()
+	}
+	c.closeAfter = nil
+}
@@ -0,0 +1,356 @@
+package exec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pdutton/go-interfaces/os"
+)
+
+// FakeExec is an Exec whose commands call handlers registered by a
+// test, instead of starting processes.  A command runs the handler of
+// the first expectation that matches its name and arguments and has
+// not yet run as often as it may.  Commands that match no expectation
+// fail to start, and are reported to the test, as are expectations
+// that have not run often enough by the time the test finishes.
+type FakeExec interface {
+	Exec
+
+	// Expect registers h to run a command named name, or whose base
+	// name is name, with arguments matched by args, which may be nil
+	// to match any.  The command is expected to run once, unless the
+	// Expectation says otherwise.
+	Expect(name string, args ArgsMatcher, h Handler) *Expectation
+}
+
+// Invocation is a run of a command, as its handler sees it.
+type Invocation struct {
+	Context context.Context
+	Name    string
+	Args    []string
+
+	// Env is the environment the options gave the command.  If it is
+	// nil, the command inherits the environment of the process.
+	Env []string
+
+	Dir string
+
+	// Stdin is the input of the command, which is empty if the command
+	// has none.
+	Stdin io.Reader
+
+	// Signals receives the signals sent to the Process of the command,
+	// other than SIGKILL, which cancels Context instead.
+	Signals <-chan os.Signal
+}
+
+// Getenv returns the value of the variable key given to the command,
+// or "" if there is none.
+func (inv *Invocation) Getenv(key string) string {
+	for i := len(inv.Env) - 1; i >= 0; i-- {
+		if k, v, ok := strings.Cut(inv.Env[i], "="); ok && k == key {
+			return v
+		}
+	}
+	return ""
+}
+
+// Result is what a fake command does once its handler returns.
+type Result struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+
+	// Delay is how long the command takes to exit.  It is killed if its
+	// context is done first.
+	Delay time.Duration
+
+	// Err is returned by Wait in place of an exit status, as if waiting
+	// for the command failed.
+	Err error
+}
+
+// Handler runs a fake command.
+type Handler func(*Invocation) Result
+
+// Respond returns a Handler that always has the Result r.
+func Respond(r Result) Handler {
+	return func(*Invocation) Result {
+		return r
+	}
+}
+
+// ArgsMatcher decides which arguments an Expectation matches.
+type ArgsMatcher interface {
+	Match(args []string) bool
+	String() string
+}
+
+type argsMatcher struct {
+	match func([]string) bool
+	desc  string
+}
+
+func (m argsMatcher) Match(args []string) bool {
+	return m.match(args)
+}
+
+func (m argsMatcher) String() string {
+	return m.desc
+}
+
+// AnyArgs matches any arguments.
+func AnyArgs() ArgsMatcher {
+	return argsMatcher{
+		match: func([]string) bool { return true },
+		desc:  "...",
+	}
+}
+
+// ExactArgs matches exactly the arguments args.
+func ExactArgs(args ...string) ArgsMatcher {
+	return argsMatcher{
+		match: func(a []string) bool { return slices.Equal(a, args) },
+		desc:  strings.Join(args, " "),
+	}
+}
+
+// ArgsPrefix matches arguments that start with prefix.
+func ArgsPrefix(prefix ...string) ArgsMatcher {
+	return argsMatcher{
+		match: func(a []string) bool { return len(a) >= len(prefix) && slices.Equal(a[:len(prefix)], prefix) },
+		desc:  strings.Join(append(slices.Clip(prefix), "..."), " "),
+	}
+}
+
+// MatchArgs matches the arguments for which f returns true.  desc
+// describes them in reports.
+func MatchArgs(desc string, f func([]string) bool) ArgsMatcher {
+	return argsMatcher{
+		match: f,
+		desc:  desc,
+	}
+}
+
+// Expectation is a command that a FakeExec expects to run.
+type Expectation struct {
+	exec    *fakeExec
+	name    string
+	args    ArgsMatcher
+	handler Handler
+
+	// min and max bound the number of runs; max < 0 means no limit
+	min, max int
+	calls    int
+}
+
+// Times expects the command to run exactly n times.
+func (x *Expectation) Times(n int) *Expectation {
+	x.exec.mu.Lock()
+	defer x.exec.mu.Unlock()
+
+	x.min, x.max = n, n
+	return x
+}
+
+// AnyTimes lets the command run any number of times, including none.
+func (x *Expectation) AnyTimes() *Expectation {
+	x.exec.mu.Lock()
+	defer x.exec.mu.Unlock()
+
+	x.min, x.max = 0, -1
+	return x
+}
+
+func (x *Expectation) String() string {
+	if x.args == nil {
+		return x.name + " " + AnyArgs().String()
+	}
+	if desc := x.args.String(); desc != "" {
+		return x.name + " " + desc
+	}
+	return x.name
+}
+
+func (x *Expectation) matches(name string, args []string) bool {
+	if name != x.name && path.Base(name) != x.name {
+		return false
+	}
+	return x.args == nil || x.args.Match(args)
+}
+
+func (x *Expectation) full() bool {
+	return x.max >= 0 && x.calls >= x.max
+}
+
+var errUnexpectedCommand = errors.New("unexpected command")
+
+type fakeExec struct {
+	t       os.TB
+	ordered bool
+
+	mu      sync.Mutex
+	expects []*Expectation
+
+	// pos is the first expectation that may still run, when ordered
+	pos int
+
+	// pid is the last process id given to a command
+	pid int
+}
+
+type FakeExecOption func(*fakeExec)
+
+// WithOrder expects commands to run in the order their expectations
+// were registered.  A command may skip an expectation only if that has
+// already run as often as it must.
+func WithOrder() FakeExecOption {
+	return func(e *fakeExec) {
+		e.ordered = true
+	}
+}
+
+// NewFakeExec creates a FakeExec that reports to t.
+func NewFakeExec(t os.TB, options ...FakeExecOption) FakeExec {
+	var e = &fakeExec{
+		t: t,
+	}
+
+	for _, f := range options {
+		f(e)
+	}
+
+	t.Cleanup(e.verify)
+
+	return e
+}
+
+func (e *fakeExec) verify() {
+	e.t.Helper()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, x := range e.expects {
+		if x.calls < x.min {
+			e.t.Errorf("exec: expected %s to run %d times, but it ran %d", x, x.min, x.calls)
+		}
+	}
+}
+
+func (e *fakeExec) Expect(name string, args ArgsMatcher, h Handler) *Expectation {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var x = &Expectation{
+		exec:    e,
+		name:    name,
+		args:    args,
+		handler: h,
+		min:     1,
+		max:     1,
+	}
+	e.expects = append(e.expects, x)
+	return x
+}
+
+// LookPath finds only the commands that are expected.
+func (e *fakeExec) LookPath(file string) (string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, x := range e.expects {
+		if x.name == file || x.name == path.Base(file) {
+			return file, nil
+		}
+	}
+	return "", &Error{Name: file, Err: ErrNotFound}
+}
+
+func (e *fakeExec) NewCommand(name string, options ...CommandOption) Cmd {
+	var cmd = newCmdFacade(name, options, func(_ context.Context, args []string) *exec.Cmd {
+		return &exec.Cmd{
+			Path: name,
+			Args: append([]string{name}, args...),
+		}
+	})
+
+	return &fakeCmd{
+		exec: e,
+		cmd:  cmd.realCmd,
+		ctxt: cmd.ctxt,
+	}
+}
+
+// expect finds the expectation that a run of cmd meets, and counts the
+// run against it.
+func (e *fakeExec) expect(cmd *exec.Cmd) (*Expectation, error) {
+	e.t.Helper()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	name, args := cmd.Path, cmd.Args[1:]
+
+	start := 0
+	if e.ordered {
+		start = e.pos
+	}
+	for i := start; i < len(e.expects); i++ {
+		x := e.expects[i]
+		if x.matches(name, args) && !x.full() {
+			x.calls++
+			if e.ordered {
+				e.pos = i
+			}
+			return x, nil
+		}
+		if e.ordered && x.calls < x.min {
+			e.t.Errorf("exec: command %s ran before %s", cmd, x)
+			return nil, &Error{Name: name, Err: errUnexpectedCommand}
+		}
+	}
+
+	e.t.Errorf("exec: unexpected command %s", cmd)
+	return nil, &Error{Name: name, Err: errUnexpectedCommand}
+}
+
+func (e *fakeExec) nextPID() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.pid++
+	return 1000 + e.pid
+}
+
+// FakeExitError is the error of a fake command that exits with a
+// status other than zero, or is killed.  Like *ExitError, it has an
+// ExitCode method, and holds the standard error that Output collected.
+type FakeExitError struct {
+	Code   int
+	Killed bool
+	Stderr []byte
+}
+
+func (e *FakeExitError) Error() string {
+	if e.Killed {
+		return "signal: killed"
+	}
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// ExitCode returns the exit status, or -1 if the command was killed.
+func (e *FakeExitError) ExitCode() int {
+	if e.Killed {
+		return -1
+	}
+	return e.Code
+}
@@ -0,0 +1,212 @@
+package exec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// fakeTB collects what a FakeExec reports.
+type fakeTB struct {
+	errors   []string
+	cleanups []func()
+}
+
+func (tb *fakeTB) Helper() {}
+
+func (tb *fakeTB) Errorf(format string, args ...any) {
+	tb.errors = append(tb.errors, fmt.Sprintf(format, args...))
+}
+
+func (tb *fakeTB) Cleanup(f func()) {
+	tb.cleanups = append(tb.cleanups, f)
+}
+
+func (tb *fakeTB) finish() {
+	for i := len(tb.cleanups) - 1; i >= 0; i-- {
+		tb.cleanups[i]()
+	}
+}
+
+func TestNewFakeExec(t *testing.T) {
+	var _ Exec = NewFakeExec(t)
+}
+
+func TestFakeExec_Output(t *testing.T) {
+	e := NewFakeExec(t)
+	e.Expect("git", ExactArgs("log", "-1"), func(inv *Invocation) Result {
+		stdin, _ := io.ReadAll(inv.Stdin)
+		if inv.Dir != "/repo" || inv.Getenv("GIT_DIR") != ".git" || string(stdin) != "input" {
+			t.Errorf("Invocation = %+v, stdin %q", inv, stdin)
+		}
+		return Result{Stdout: "abc123\n"}
+	})
+
+	cmd := e.NewCommand("git",
+		WithArgs("log", "-1"),
+		WithDir("/repo"),
+		WithEnv("GIT_DIR", ".git"),
+		WithStdin(strings.NewReader("input")),
+	)
+	out, err := cmd.Output()
+	if err != nil || string(out) != "abc123\n" {
+		t.Errorf("Output() = %q, %v, want %q", out, err, "abc123\n")
+	}
+	if err := cmd.Wait(); err == nil {
+		t.Error("second Wait() succeeded")
+	}
+}
+
+func TestFakeExec_ExitCode(t *testing.T) {
+	e := NewFakeExec(t)
+	e.Expect("kubectl", ArgsPrefix("get"), Respond(Result{Stderr: "not found\n", ExitCode: 2}))
+
+	_, err := e.NewCommand("kubectl", WithArgs("get", "pods")).Output()
+	var ee *FakeExitError
+	if !errors.As(err, &ee) || ee.ExitCode() != 2 || string(ee.Stderr) != "not found\n" {
+		t.Errorf("Output() error = %#v, want exit status 2", err)
+	}
+	if err.Error() != "exit status 2" {
+		t.Errorf("Error() = %q, want %q", err.Error(), "exit status 2")
+	}
+}
+
+func TestFakeExec_StdoutPipe(t *testing.T) {
+	e := NewFakeExec(t)
+	e.Expect("cat", nil, func(inv *Invocation) Result {
+		data, _ := io.ReadAll(inv.Stdin)
+		return Result{Stdout: string(data)}
+	})
+
+	cmd := e.NewCommand("cat")
+	stdin, _ := cmd.StdinPipe()
+	stdout, _ := cmd.StdoutPipe()
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	io.WriteString(stdin, "piped")
+	stdin.Close()
+
+	if data, err := io.ReadAll(stdout); err != nil || string(data) != "piped" {
+		t.Errorf("ReadAll() = %q, %v, want %q", data, err, "piped")
+	}
+	if err := cmd.Wait(); err != nil {
+		t.Errorf("Wait() error = %v", err)
+	}
+}
+
+func TestFakeExec_Delay(t *testing.T) {
+	e := NewFakeExec(t)
+	e.Expect("sleep", nil, Respond(Result{Stdout: "late", Delay: time.Hour}))
+
+	ctxt, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	out, err := e.NewCommand("sleep", WithContext(ctxt)).Output()
+	var ee *FakeExitError
+	if !errors.As(err, &ee) || ee.ExitCode() != -1 || len(out) != 0 {
+		t.Errorf("Output() = %q, %v, want killed", out, err)
+	}
+}
+
+func TestFakeExec_Times(t *testing.T) {
+	e := NewFakeExec(t)
+	e.Expect("true", nil, nil).Times(2)
+	e.Expect("/bin/false", nil, Respond(Result{ExitCode: 1})).AnyTimes()
+
+	for range 2 {
+		if err := e.NewCommand("/usr/bin/true").Run(); err != nil {
+			t.Errorf("Run() error = %v", err)
+		}
+	}
+	if err := e.NewCommand("/bin/false").Run(); err == nil {
+		t.Error("Run() of false succeeded")
+	}
+
+	if _, err := e.LookPath("true"); err != nil {
+		t.Errorf("LookPath() error = %v", err)
+	}
+	if _, err := e.LookPath("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("LookPath() of missing command error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestFakeExec_Unexpected(t *testing.T) {
+	tb := &fakeTB{}
+	e := NewFakeExec(tb)
+	e.Expect("git", ExactArgs("status"), nil)
+	e.Expect("go", ExactArgs("test", "./..."), nil)
+
+	err := e.NewCommand("git", WithArgs("push")).Run()
+	if !errors.Is(err, errUnexpectedCommand) {
+		t.Errorf("Run() error = %v, want errUnexpectedCommand", err)
+	}
+	e.NewCommand("git", WithArgs("status")).Run()
+	tb.finish()
+
+	if len(tb.errors) != 2 ||
+		!strings.Contains(tb.errors[0], "unexpected command git push") ||
+		!strings.Contains(tb.errors[1], "expected go test ./... to run 1 times, but it ran 0") {
+		t.Errorf("reported %q", tb.errors)
+	}
+}
+
+func TestFakeExec_Order(t *testing.T) {
+	tb := &fakeTB{}
+	e := NewFakeExec(tb, WithOrder())
+	e.Expect("make", ExactArgs("build"), nil)
+	e.Expect("make", ExactArgs("test"), nil)
+
+	if err := e.NewCommand("make", WithArgs("test")).Run(); err == nil {
+		t.Error("Run() out of order succeeded")
+	}
+	e.NewCommand("make", WithArgs("build")).Run()
+	e.NewCommand("make", WithArgs("test")).Run()
+	tb.finish()
+
+	if len(tb.errors) != 1 || !strings.Contains(tb.errors[0], "make test ran before make build") {
+		t.Errorf("reported %q", tb.errors)
+	}
+}
+
+func TestFakeExec_Process(t *testing.T) {
+	e := NewFakeExec(t)
+	e.Expect("daemon", nil, func(inv *Invocation) Result {
+		if sig := <-inv.Signals; sig != syscall.SIGHUP {
+			t.Errorf("Signals got %v, want SIGHUP", sig)
+		}
+		<-inv.Context.Done()
+		return Result{}
+	})
+
+	cmd := e.NewCommand("daemon")
+	if cmd.Process() != nil {
+		t.Error("Process() before Start() is not nil")
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	proc := cmd.Process()
+	if proc.PID() <= 0 {
+		t.Errorf("PID() = %d", proc.PID())
+	}
+	proc.Signal(syscall.SIGHUP)
+	proc.Kill()
+
+	var ee *FakeExitError
+	if err := cmd.Wait(); !errors.As(err, &ee) || !ee.Killed {
+		t.Errorf("Wait() error = %v, want killed", err)
+	}
+	if ps := cmd.ProcessState(); ps == nil || ps.Pid() != proc.PID() || ps.ExitCode() != -1 {
+		t.Errorf("ProcessState() = %v, want killed", ps)
+	}
+	if err := proc.Signal(syscall.SIGHUP); !errors.Is(err, os.ErrProcessDone) {
+		t.Errorf("Signal() after exit error = %v, want ErrProcessDone", err)
+	}
+}
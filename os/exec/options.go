@@ -2,10 +2,13 @@ package exec
 
 import (
 	"context"
+	"fmt"
 	"io"
-	"os"
+	stdos "os"
 	"syscall"
 	"time"
+
+	"github.com/pdutton/go-interfaces/os"
 )
 
 type CommandOption func(*cmdFacade)
@@ -26,14 +29,6 @@ func WithContext(ctxt context.Context) CommandOption {
 	}
 }
 
-func WithEnv(name, value string) CommandOption {
-	return func(cmd *cmdFacade) {
-		if cmd.realCmd != nil {
-			cmd.realCmd.Env = append(cmd.realCmd.Env, name+`=`+value)
-		}
-	}
-}
-
 func WithDir(dir string) CommandOption {
 	return func(cmd *cmdFacade) {
 		if cmd.realCmd != nil {
@@ -66,11 +61,30 @@ func WithStderr(w io.Writer) CommandOption {
 	}
 }
 
-func WithExtraFiles(files []*os.File) CommandOption {
+// WithExtraFiles passes files to the command after its standard
+// input, output and error.  Each must be nil or have a Nub method that
+// returns its *os.File, as those returned by NewOS do; if one does
+// not, the command fails to start with an error held in Err.
+func WithExtraFiles(files []os.File) CommandOption {
 	return func(cmd *cmdFacade) {
-		if cmd.realCmd != nil {
-			cmd.realCmd.ExtraFiles = files
+		if cmd.realCmd == nil {
+			return
+		}
+
+		var extra = make([]*stdos.File, 0, len(files))
+		for i, f := range files {
+			var nub *stdos.File
+			if f != nil {
+				if n, ok := f.(interface{ Nub() *stdos.File }); ok {
+					nub = n.Nub()
+				}
+				if nub == nil && cmd.realCmd.Err == nil {
+					cmd.realCmd.Err = fmt.Errorf("exec: ExtraFiles[%d] %s is not an *os.File", i, f.Name())
+				}
+			}
+			extra = append(extra, nub)
 		}
+		cmd.realCmd.ExtraFiles = extra
 	}
 }
 
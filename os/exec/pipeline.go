@@ -0,0 +1,406 @@
+package exec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Pipeline runs commands with the standard output of each connected to
+// the standard input of the next, as a shell does with "a | b | c".
+// The commands may come from any Exec, and must not have been started.
+// The standard input of the first and the standard output of the last
+// are left as the commands were given them, and the standard error of
+// each command that has none is collected.
+//
+// Since a Cmd has no way of setting its standard input once it has been
+// created, the commands are connected with StdoutPipe and StdinPipe and
+// a goroutine copying between them.
+type Pipeline struct {
+	stages   []Cmd
+	pipefail bool
+	ctxt     context.Context
+
+	mu      sync.Mutex
+	started bool
+	waited  bool
+	stopped error
+
+	// copies[i] is closed once the output of stage i has been read
+	copies []chan struct{}
+
+	// stderr[i] collects the standard error of stage i, if it had none
+	stderr      []*bytes.Buffer
+	stderrPipes []io.ReadCloser
+	stderrDone  []chan struct{}
+
+	// closers holds the pipes that connect the stages
+	closers []io.Closer
+	stop    chan struct{}
+}
+
+type PipelineOption func(*Pipeline)
+
+// WithPipefail sets whether the pipeline fails when any of its commands
+// fails, which is the default, or only when the last one does, as in a
+// shell without "set -o pipefail".
+func WithPipefail(pipefail bool) PipelineOption {
+	return func(p *Pipeline) {
+		p.pipefail = pipefail
+	}
+}
+
+// WithPipelineContext stops the commands of the pipeline when ctxt is
+// done.  A command is stopped with its Cancel function if it has one,
+// and otherwise by killing its Process; either way, the pipes between
+// the commands are closed.  A command with neither, such as one from a
+// fake Exec, should be given the context too, with WithContext.
+func WithPipelineContext(ctxt context.Context) PipelineOption {
+	return func(p *Pipeline) {
+		p.ctxt = ctxt
+	}
+}
+
+// NewPipeline creates a Pipeline of cmds, in order.
+func NewPipeline(cmds []Cmd, options ...PipelineOption) *Pipeline {
+	var p = &Pipeline{
+		stages:   cmds,
+		pipefail: true,
+	}
+
+	for _, f := range options {
+		f(p)
+	}
+
+	return p
+}
+
+// StageError is the failure of one command of a Pipeline.
+type StageError struct {
+	Index  int
+	Cmd    string
+	Err    error
+	Stderr []byte
+}
+
+func (e *StageError) Error() string {
+	return fmt.Sprintf("exec: pipeline stage %d (%s): %v", e.Index, e.Cmd, e.Err)
+}
+
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
+// PipelineError reports the commands of a Pipeline that failed, in
+// order, and the error of its context if that stopped it.
+type PipelineError struct {
+	Stages []*StageError
+	Err    error
+}
+
+func (e *PipelineError) Error() string {
+	var msgs []string
+	if e.Err != nil {
+		msgs = append(msgs, "exec: pipeline stopped: "+e.Err.Error())
+	}
+	for _, s := range e.Stages {
+		msgs = append(msgs, s.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e *PipelineError) Unwrap() []error {
+	var errs []error
+	if e.Err != nil {
+		errs = append(errs, e.Err)
+	}
+	for _, s := range e.Stages {
+		errs = append(errs, s)
+	}
+	return errs
+}
+
+// Stages returns the commands of the pipeline.
+func (p *Pipeline) Stages() []Cmd {
+	return p.stages
+}
+
+// Stderr returns what each command wrote to its standard error, or nil
+// for those that were given one of their own.  It is complete once
+// Wait has returned.
+func (p *Pipeline) Stderr() [][]byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var stderr = make([][]byte, len(p.stages))
+	for i, b := range p.stderr {
+		if b != nil {
+			stderr[i] = b.Bytes()
+		}
+	}
+	return stderr
+}
+
+// Output runs the pipeline and returns the standard output of its last
+// command.
+func (p *Pipeline) Output() ([]byte, error) {
+	if len(p.stages) == 0 {
+		return nil, errors.New("exec: empty pipeline")
+	}
+
+	last := p.stages[len(p.stages)-1]
+	if last.Stdout() != nil {
+		return nil, errors.New("exec: Stdout already set")
+	}
+	r, err := last.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		out  bytes.Buffer
+		done = make(chan struct{})
+	)
+	go func() {
+		io.Copy(&out, r)
+		close(done)
+	}()
+
+	if err := p.start(done); err != nil {
+		r.Close()
+		<-done
+		return nil, err
+	}
+	err = p.Wait()
+	return out.Bytes(), err
+}
+
+// Run starts the pipeline and waits for it to finish.
+func (p *Pipeline) Run() error {
+	if err := p.Start(); err != nil {
+		return err
+	}
+	return p.Wait()
+}
+
+// Start connects the commands and starts them all.  If one fails to
+// start, those already started are stopped and waited for, and the
+// pipes of the rest are closed.
+func (p *Pipeline) Start() error {
+	return p.start(nil)
+}
+
+// start starts the pipeline.  output, if not nil, is closed once the
+// output of the last command has been read.
+func (p *Pipeline) start(output chan struct{}) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch {
+	case p.started:
+		return errors.New("exec: pipeline already started")
+	case len(p.stages) == 0:
+		return errors.New("exec: empty pipeline")
+	}
+	p.started = true
+
+	n := len(p.stages)
+	p.copies = make([]chan struct{}, n)
+	p.stderr = make([]*bytes.Buffer, n)
+	p.stderrPipes = make([]io.ReadCloser, n)
+	p.stderrDone = make([]chan struct{}, n)
+	p.copies[n-1] = output
+
+	for i, c := range p.stages {
+		if c.Stderr() == nil {
+			r, err := c.StderrPipe()
+			if err != nil {
+				p.closePipes()
+				p.closeStderr(0)
+				return err
+			}
+			p.stderr[i] = &bytes.Buffer{}
+			p.stderrPipes[i] = r
+			p.stderrDone[i] = make(chan struct{})
+			go p.collect(p.stderr[i], r, p.stderrDone[i])
+		}
+
+		if i == n-1 {
+			break
+		}
+		out, err := c.StdoutPipe()
+		if err != nil {
+			p.closePipes()
+			p.closeStderr(0)
+			return err
+		}
+		in, err := p.stages[i+1].StdinPipe()
+		if err != nil {
+			out.Close()
+			p.closePipes()
+			p.closeStderr(0)
+			return err
+		}
+		p.closers = append(p.closers, out, in)
+		p.copies[i] = make(chan struct{})
+		go p.copy(in, out, p.copies[i])
+	}
+
+	for i, c := range p.stages {
+		if err := c.Start(); err != nil {
+			p.cancel(i)
+			p.closeStderr(i)
+			p.release(i)
+			p.mu.Unlock()
+			p.wait(i)
+			p.mu.Lock()
+			return &PipelineError{Stages: []*StageError{{Index: i, Cmd: c.String(), Err: err}}}
+		}
+	}
+
+	if p.ctxt != nil {
+		p.stop = make(chan struct{})
+		go p.watch()
+	}
+
+	return nil
+}
+
+// copy feeds the output of one command to the next.  If the next one
+// stops reading, the output is closed, so that the first one finds out
+// too, as it would from SIGPIPE.
+func (p *Pipeline) copy(in io.WriteCloser, out io.ReadCloser, done chan struct{}) {
+	if _, err := io.Copy(in, out); err != nil {
+		out.Close()
+	}
+	in.Close()
+	close(done)
+}
+
+func (p *Pipeline) collect(b *bytes.Buffer, r io.Reader, done chan struct{}) {
+	var buf bytes.Buffer
+	io.Copy(&buf, r)
+
+	p.mu.Lock()
+	b.Write(buf.Bytes())
+	p.mu.Unlock()
+	close(done)
+}
+
+func (p *Pipeline) watch() {
+	select {
+	case <-p.ctxt.Done():
+		p.mu.Lock()
+		p.stopped = p.ctxt.Err()
+		p.cancel(len(p.stages))
+		p.mu.Unlock()
+	case <-p.stop:
+	}
+}
+
+// cancel stops the first n commands and closes the pipes between all
+// of them.
+func (p *Pipeline) cancel(n int) {
+	for _, c := range p.stages[:n] {
+		if f := c.Cancel(); f != nil {
+			f()
+		} else if proc := c.Process(); proc != nil {
+			proc.Kill()
+		}
+	}
+	p.closePipes()
+}
+
+// release closes the pipes the commands from the i'th on hold for
+// themselves, which they would have closed once started.
+func (p *Pipeline) release(i int) {
+	for _, c := range p.stages[i:] {
+		if r, ok := c.(interface{ release() }); ok {
+			r.release()
+		}
+	}
+}
+
+func (p *Pipeline) closePipes() {
+	for _, c := range p.closers {
+		c.Close()
+	}
+}
+
+// closeStderr closes the standard error of the commands from the i'th
+// on, which have not been started.
+func (p *Pipeline) closeStderr(i int) {
+	for _, r := range p.stderrPipes[i:] {
+		if r != nil {
+			r.Close()
+		}
+	}
+}
+
+// wait waits for the first n commands, and returns their errors.
+func (p *Pipeline) wait(n int) []error {
+	var errs = make([]error, n)
+	for i, c := range p.stages[:n] {
+		if done := p.copies[i]; done != nil {
+			<-done
+		}
+		if done := p.stderrDone[i]; done != nil {
+			<-done
+		}
+		errs[i] = c.Wait()
+	}
+	return errs
+}
+
+// Wait waits for every command of the pipeline to exit.  With pipefail,
+// which is the default, it returns a *PipelineError if any of them
+// failed; without, only if the last one did.
+func (p *Pipeline) Wait() error {
+	p.mu.Lock()
+	switch {
+	case !p.started:
+		p.mu.Unlock()
+		return errors.New("exec: pipeline not started")
+	case p.waited:
+		p.mu.Unlock()
+		return errors.New("exec: Wait was already called")
+	}
+	p.waited = true
+	p.mu.Unlock()
+
+	errs := p.wait(len(p.stages))
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.stop != nil {
+		close(p.stop)
+	}
+
+	// the context stopped the pipeline only if it stopped a command that
+	// was still running, which then failed
+	var pe = &PipelineError{}
+	if slices.ContainsFunc(errs, func(err error) bool { return err != nil }) {
+		pe.Err = p.stopped
+	}
+	for i, err := range errs {
+		if err == nil || !p.pipefail && i != len(errs)-1 {
+			continue
+		}
+		se := &StageError{Index: i, Cmd: p.stages[i].String(), Err: err}
+		if p.stderr[i] != nil {
+			se.Stderr = p.stderr[i].Bytes()
+		}
+		pe.Stages = append(pe.Stages, se)
+	}
+
+	if pe.Err == nil && len(pe.Stages) == 0 {
+		return nil
+	}
+	return pe
+}
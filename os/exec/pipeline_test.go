@@ -0,0 +1,215 @@
+package exec
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// upper is a fake command that upper-cases its input.
+func upper(inv *Invocation) Result {
+	data, _ := io.ReadAll(inv.Stdin)
+	return Result{Stdout: strings.ToUpper(string(data))}
+}
+
+func TestPipeline_Output(t *testing.T) {
+	e := NewFakeExec(t)
+	e.Expect("echo", ExactArgs("hello"), Respond(Result{Stdout: "hello\n"}))
+	e.Expect("tr", nil, upper)
+	e.Expect("cat", nil, func(inv *Invocation) Result {
+		data, _ := io.ReadAll(inv.Stdin)
+		return Result{Stdout: string(data)}
+	})
+
+	p := NewPipeline([]Cmd{
+		e.NewCommand("echo", WithArgs("hello")),
+		e.NewCommand("tr", WithArgs("a-z", "A-Z")),
+		e.NewCommand("cat"),
+	})
+	out, err := p.Output()
+	if err != nil || string(out) != "HELLO\n" {
+		t.Errorf("Output() = %q, %v, want %q", out, err, "HELLO\n")
+	}
+	if err := p.Run(); err == nil {
+		t.Error("second Run() succeeded")
+	}
+}
+
+func TestPipeline_Pipefail(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		pipefail bool
+		wantErr  bool
+	}{
+		{"pipefail", true, true},
+		{"no pipefail", false, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewFakeExec(t)
+			e.Expect("grep", nil, func(inv *Invocation) Result {
+				io.Copy(io.Discard, inv.Stdin)
+				return Result{Stderr: "grep: bad pattern\n", ExitCode: 2}
+			})
+			e.Expect("wc", nil, func(inv *Invocation) Result {
+				io.Copy(io.Discard, inv.Stdin)
+				return Result{Stdout: "0\n"}
+			})
+
+			p := NewPipeline([]Cmd{
+				e.NewCommand("grep", WithArgs("[")),
+				e.NewCommand("wc", WithArgs("-l")),
+			}, WithPipefail(tt.pipefail))
+			out, err := p.Output()
+			if string(out) != "0\n" {
+				t.Errorf("Output() = %q, want %q", out, "0\n")
+			}
+			if stderr := p.Stderr(); string(stderr[0]) != "grep: bad pattern\n" || len(stderr[1]) != 0 {
+				t.Errorf("Stderr() = %q", stderr)
+			}
+			if !tt.wantErr {
+				if err != nil {
+					t.Errorf("Output() error = %v, want nil", err)
+				}
+				return
+			}
+
+			var pe *PipelineError
+			if !errors.As(err, &pe) || len(pe.Stages) != 1 {
+				t.Fatalf("Output() error = %v, want one failed stage", err)
+			}
+			se := pe.Stages[0]
+			if se.Index != 0 || string(se.Stderr) != "grep: bad pattern\n" {
+				t.Errorf("StageError = %+v", se)
+			}
+			var ee *FakeExitError
+			if !errors.As(err, &ee) || ee.ExitCode() != 2 {
+				t.Errorf("Output() error = %v, want exit status 2", err)
+			}
+		})
+	}
+}
+
+func TestPipeline_StartError(t *testing.T) {
+	tb := &fakeTB{}
+	e := NewFakeExec(tb)
+	e.Expect("cat", nil, func(inv *Invocation) Result {
+		io.Copy(io.Discard, inv.Stdin)
+		return Result{}
+	})
+
+	p := NewPipeline([]Cmd{
+		e.NewCommand("cat"),
+		e.NewCommand("missing"),
+	})
+	err := p.Run()
+	var pe *PipelineError
+	if !errors.As(err, &pe) || pe.Stages[0].Index != 1 || !errors.Is(err, errUnexpectedCommand) {
+		t.Errorf("Run() error = %v, want stage 1 to fail to start", err)
+	}
+}
+
+func TestPipeline_Context(t *testing.T) {
+	e := NewFakeExec(t)
+	e.Expect("yes", nil, Respond(Result{Stdout: "y\n", Delay: time.Hour}))
+	e.Expect("head", nil, func(inv *Invocation) Result {
+		io.Copy(io.Discard, inv.Stdin)
+		return Result{}
+	})
+
+	ctxt, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	p := NewPipeline([]Cmd{
+		e.NewCommand("yes", WithContext(ctxt)),
+		e.NewCommand("head", WithArgs("-1"), WithContext(ctxt)),
+	}, WithPipelineContext(ctxt))
+	err := p.Run()
+	var pe *PipelineError
+	if !errors.As(err, &pe) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Run() error = %v, want DeadlineExceeded", err)
+	}
+}
+
+func TestPipeline_Real(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sh")
+	}
+	e := NewExec()
+
+	p := NewPipeline([]Cmd{
+		e.NewCommand("sh", WithArgs("-c", "echo one; echo two; echo oops >&2")),
+		e.NewCommand("sh", WithArgs("-c", "tr a-z A-Z")),
+	})
+	out, err := p.Output()
+	if err != nil || string(out) != "ONE\nTWO\n" {
+		t.Errorf("Output() = %q, %v, want %q", out, err, "ONE\nTWO\n")
+	}
+	if stderr := p.Stderr(); string(stderr[0]) != "oops\n" {
+		t.Errorf("Stderr() = %q", stderr)
+	}
+}
+
+func TestPipeline_ContextAfterExit(t *testing.T) {
+	e := NewFakeExec(t)
+	var exited = make(chan struct{}, 2)
+	e.Expect("echo", nil, func(inv *Invocation) Result {
+		defer func() { exited <- struct{}{} }()
+		return Result{Stdout: "hi\n"}
+	})
+	e.Expect("cat", nil, func(inv *Invocation) Result {
+		defer func() { exited <- struct{}{} }()
+		io.Copy(io.Discard, inv.Stdin)
+		return Result{}
+	})
+
+	ctxt, cancel := context.WithCancel(context.Background())
+	p := NewPipeline([]Cmd{
+		e.NewCommand("echo"),
+		e.NewCommand("cat"),
+	}, WithPipelineContext(ctxt))
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	<-exited
+	<-exited
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+
+	if err := p.Wait(); err != nil {
+		t.Errorf("Wait() error = %v, want nil once every command had exited", err)
+	}
+}
+
+func TestPipeline_StartErrorFiles(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("counts descriptors in /proc")
+	}
+	fds := func() int {
+		entries, err := os.ReadDir("/proc/self/fd")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(entries)
+	}
+	e := NewExec()
+
+	before := fds()
+	p := NewPipeline([]Cmd{
+		e.NewCommand("true"),
+		e.NewCommand("/nonexistent/command"),
+		e.NewCommand("cat"),
+		e.NewCommand("cat"),
+	})
+	if err := p.Run(); err == nil {
+		t.Fatal("Run() error = nil")
+	}
+	if after := fds(); after != before {
+		t.Errorf("%d descriptors open after a failed Start, want %d", after, before)
+	}
+}
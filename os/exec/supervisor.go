@@ -0,0 +1,420 @@
+package exec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pdutton/go-interfaces/os"
+	"github.com/pdutton/go-interfaces/os/signal"
+)
+
+// RestartPolicy decides whether a Supervisor starts its command again
+// once it has exited.
+type RestartPolicy int
+
+const (
+	// RestartNever runs the command only once.
+	RestartNever RestartPolicy = iota
+
+	// RestartAlways runs the command again whenever it exits.
+	RestartAlways
+
+	// RestartOnFailure runs the command again when it fails, but not
+	// when it exits with status zero.
+	RestartOnFailure
+)
+
+func (p RestartPolicy) String() string {
+	switch p {
+	case RestartNever:
+		return "never"
+	case RestartAlways:
+		return "always"
+	case RestartOnFailure:
+		return "on-failure"
+	}
+	return fmt.Sprintf("RestartPolicy(%d)", int(p))
+}
+
+// State is a stage in the life of a supervised command.
+type State int
+
+const (
+	// Starting is reported before the command is started.
+	Starting State = iota
+
+	// Running is reported once the command has started.
+	Running
+
+	// Stopping is reported when the supervisor starts to stop the
+	// command.
+	Stopping
+
+	// Exited is reported when the command exits, or fails to start.
+	Exited
+
+	// Backoff is reported when the supervisor waits to start the
+	// command again.
+	Backoff
+
+	// Stopped is reported when the supervisor has finished, and will
+	// not start the command again.
+	Stopped
+)
+
+func (s State) String() string {
+	switch s {
+	case Starting:
+		return "starting"
+	case Running:
+		return "running"
+	case Stopping:
+		return "stopping"
+	case Exited:
+		return "exited"
+	case Backoff:
+		return "backoff"
+	case Stopped:
+		return "stopped"
+	}
+	return fmt.Sprintf("State(%d)", int(s))
+}
+
+// Event is a change in the state of a supervised command.
+type Event struct {
+	State State
+
+	// PID is the process id of the command, when it has one.
+	PID int
+
+	// Restarts is how often the command has been started again.
+	Restarts int
+
+	// Err is the error the command exited with, or that stopped the
+	// supervisor.
+	Err error
+
+	// Delay is how long the supervisor waits, in Backoff.
+	Delay time.Duration
+}
+
+// ErrMaxRestarts is reported when a Supervisor gives up on a command
+// that has been started again as often as it may be.
+var ErrMaxRestarts = errors.New("exec: too many restarts")
+
+// Supervisor runs a command, and starts it again when it exits, as its
+// RestartPolicy says.  Each start makes a new Cmd from the Exec, so a
+// FakeExec can stand in for the real one.  Between starts it waits,
+// with a delay that doubles each time, up to a limit.
+//
+// A Supervisor can stop its command gracefully: it sends the command a
+// signal, SIGTERM by default, and kills it if it has not exited within
+// the kill timeout.  It does so when Stop is called, when the context
+// given to Run is done, or when the process receives SIGTERM or
+// SIGINT from the Signal given with WithSignals.  Other signals from
+// that Signal, SIGHUP by default, are forwarded to the command.
+type Supervisor struct {
+	exec    Exec
+	name    string
+	options []CommandOption
+
+	policy      RestartPolicy
+	maxRestarts int
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+	stopSignal  os.Signal
+	killTimeout time.Duration
+	signals     signal.Signal
+	forward     []os.Signal
+	events      chan<- Event
+
+	mu      sync.Mutex
+	running bool
+	stop    chan struct{}
+	stopped sync.Once
+}
+
+type SupervisorOption func(*Supervisor)
+
+// WithCommandOptions gives the options for each Cmd the supervisor
+// makes.  Options holding a reader, such as WithStdin, are shared by
+// every run of the command.
+func WithCommandOptions(options ...CommandOption) SupervisorOption {
+	return func(s *Supervisor) {
+		s.options = append(s.options, options...)
+	}
+}
+
+// WithRestart sets the restart policy, which is RestartOnFailure by
+// default.
+func WithRestart(policy RestartPolicy) SupervisorOption {
+	return func(s *Supervisor) {
+		s.policy = policy
+	}
+}
+
+// WithMaxRestarts limits how often the command is started again, over
+// the life of the supervisor.  A negative n, the default, sets no
+// limit.
+func WithMaxRestarts(n int) SupervisorOption {
+	return func(s *Supervisor) {
+		s.maxRestarts = n
+	}
+}
+
+// WithBackoff sets the delay before the command is first started again,
+// and the most it can grow to.  The delay goes back to min once the
+// command has run for at least max.  The default is 100ms to 30s.
+func WithBackoff(min, max time.Duration) SupervisorOption {
+	return func(s *Supervisor) {
+		s.minBackoff = min
+		s.maxBackoff = max
+	}
+}
+
+// WithStopSignal sets the signal that asks the command to exit, which
+// is SIGTERM by default.
+func WithStopSignal(sig os.Signal) SupervisorOption {
+	return func(s *Supervisor) {
+		s.stopSignal = sig
+	}
+}
+
+// WithKillTimeout sets how long the command has to exit once it has
+// been asked to, before it is killed.  The default is 10s.
+func WithKillTimeout(d time.Duration) SupervisorOption {
+	return func(s *Supervisor) {
+		s.killTimeout = d
+	}
+}
+
+// WithSignals has the supervisor handle the signals of the process,
+// as sig delivers them.  SIGTERM and SIGINT stop the supervisor, and
+// the signals in forward, or SIGHUP if there are none, are passed on to
+// the command.  It panics if forward holds SIGTERM or SIGINT, which
+// would then never stop the supervisor.
+func WithSignals(sig signal.Signal, forward ...os.Signal) SupervisorOption {
+	if slices.Contains(forward, os.Signal(syscall.SIGTERM)) || slices.Contains(forward, os.Signal(syscall.SIGINT)) {
+		panic("exec: WithSignals cannot forward SIGTERM or SIGINT")
+	}
+
+	return func(s *Supervisor) {
+		s.signals = sig
+		s.forward = forward
+	}
+}
+
+// WithEvents sends each change in the state of the command to c.  The
+// supervisor waits for each Event to be received, so c must be read
+// until Run returns.
+func WithEvents(c chan<- Event) SupervisorOption {
+	return func(s *Supervisor) {
+		s.events = c
+	}
+}
+
+// NewSupervisor creates a Supervisor of the command name, made by e.
+func NewSupervisor(e Exec, name string, options ...SupervisorOption) *Supervisor {
+	var s = &Supervisor{
+		exec:        e,
+		name:        name,
+		policy:      RestartOnFailure,
+		maxRestarts: -1,
+		minBackoff:  100 * time.Millisecond,
+		maxBackoff:  30 * time.Second,
+		stopSignal:  syscall.SIGTERM,
+		killTimeout: 10 * time.Second,
+		stop:        make(chan struct{}),
+	}
+
+	for _, f := range options {
+		f(s)
+	}
+
+	if s.signals != nil && len(s.forward) == 0 {
+		s.forward = []os.Signal{syscall.SIGHUP}
+	}
+
+	return s
+}
+
+// Stop asks the supervisor to stop its command and return from Run.
+// It does not wait for that to happen.
+func (s *Supervisor) Stop() {
+	s.stopped.Do(func() {
+		close(s.stop)
+	})
+}
+
+// Run runs the command until the supervisor is stopped, or the restart
+// policy says not to start it again.  It returns nil if the supervisor
+// was stopped or the command last succeeded, the error of the command
+// if the policy let it fail, and an error wrapping ErrMaxRestarts and
+// the error of the command if that ran out of restarts.
+func (s *Supervisor) Run(ctxt context.Context) error {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return errors.New("exec: supervisor already run")
+	}
+	s.running = true
+	s.mu.Unlock()
+
+	var sigs chan os.Signal
+	if s.signals != nil {
+		sigs = make(chan os.Signal, 1)
+		s.signals.Notify(sigs, append([]os.Signal{syscall.SIGTERM, syscall.SIGINT}, s.forward...)...)
+		defer s.signals.Stop(sigs)
+	}
+
+	var (
+		delay    = s.minBackoff
+		restarts = 0
+	)
+	for {
+		start := time.Now()
+		stopped, err := s.runOnce(ctxt, sigs, restarts)
+		if stopped {
+			s.emit(Event{State: Stopped, Restarts: restarts})
+			return nil
+		}
+
+		if !s.restart(err) {
+			s.emit(Event{State: Stopped, Restarts: restarts, Err: err})
+			return err
+		}
+		if s.maxRestarts >= 0 && restarts >= s.maxRestarts {
+			err = fmt.Errorf("%w (%d): %w", ErrMaxRestarts, restarts, err)
+			s.emit(Event{State: Stopped, Restarts: restarts, Err: err})
+			return err
+		}
+
+		if time.Since(start) >= s.maxBackoff {
+			delay = s.minBackoff
+		}
+		s.emit(Event{State: Backoff, Restarts: restarts, Err: err, Delay: delay})
+		if !s.sleep(ctxt, sigs, delay) {
+			s.emit(Event{State: Stopped, Restarts: restarts})
+			return nil
+		}
+		restarts++
+		delay = min(2*delay, s.maxBackoff)
+	}
+}
+
+func (s *Supervisor) restart(err error) bool {
+	switch s.policy {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return err != nil
+	}
+	return false
+}
+
+// runOnce starts the command and waits for it to exit, forwarding
+// signals to it and stopping it if asked to.  It reports whether it
+// stopped the command.
+func (s *Supervisor) runOnce(ctxt context.Context, sigs <-chan os.Signal, restarts int) (stopped bool, err error) {
+	// a stop asked for before the command starts need not start it
+	select {
+	case <-s.stop:
+		return true, nil
+	case <-ctxt.Done():
+		return true, nil
+	default:
+	}
+
+	s.emit(Event{State: Starting, Restarts: restarts})
+	cmd := s.exec.NewCommand(s.name, s.options...)
+	if err := cmd.Start(); err != nil {
+		s.emit(Event{State: Exited, Restarts: restarts, Err: err})
+		return false, err
+	}
+	proc := cmd.Process()
+	s.emit(Event{State: Running, PID: proc.PID(), Restarts: restarts})
+
+	var done = make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	var (
+		stopping bool
+		stop     = s.stop
+		cancel   = ctxt.Done()
+		timer    *time.Timer
+		kill     <-chan time.Time
+	)
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+	beginStop := func() {
+		stopping = true
+		stop, cancel = nil, nil
+		s.emit(Event{State: Stopping, PID: proc.PID(), Restarts: restarts})
+		if err := proc.Signal(s.stopSignal); err != nil {
+			proc.Kill()
+			return
+		}
+		timer = time.NewTimer(s.killTimeout)
+		kill = timer.C
+	}
+
+	for {
+		select {
+		case err := <-done:
+			s.emit(Event{State: Exited, PID: proc.PID(), Restarts: restarts, Err: err})
+			return stopping, err
+		case <-stop:
+			beginStop()
+		case <-cancel:
+			beginStop()
+		case sig := <-sigs:
+			switch {
+			case slices.Contains(s.forward, sig):
+				proc.Signal(sig)
+			case !stopping:
+				beginStop()
+			}
+		case <-kill:
+			kill = nil
+			proc.Kill()
+		}
+	}
+}
+
+// sleep waits for delay, and reports whether it did so without being
+// asked to stop.
+func (s *Supervisor) sleep(ctxt context.Context, sigs <-chan os.Signal, delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			return true
+		case <-s.stop:
+			return false
+		case <-ctxt.Done():
+			return false
+		case sig := <-sigs:
+			if !slices.Contains(s.forward, sig) {
+				return false
+			}
+		}
+	}
+}
+
+func (s *Supervisor) emit(ev Event) {
+	if s.events != nil {
+		s.events <- ev
+	}
+}
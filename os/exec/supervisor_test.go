@@ -0,0 +1,260 @@
+package exec
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/pdutton/go-interfaces/os/signal"
+)
+
+// daemon is a fake command that runs until it is sent SIGTERM, and
+// passes on the other signals it is sent.
+func daemon(got chan<- os.Signal) Handler {
+	return func(inv *Invocation) Result {
+		for {
+			select {
+			case sig := <-inv.Signals:
+				if sig == syscall.SIGTERM {
+					return Result{ExitCode: 143}
+				}
+				got <- sig
+			case <-inv.Context.Done():
+				return Result{}
+			}
+		}
+	}
+}
+
+// states runs s, and returns the states it reports and the error of Run.
+func states(t *testing.T, s func(chan<- Event) *Supervisor, each func(Event)) ([]State, []Event, error) {
+	t.Helper()
+
+	var (
+		events = make(chan Event)
+		errc   = make(chan error, 1)
+	)
+	sup := s(events)
+	go func() {
+		errc <- sup.Run(context.Background())
+		close(events)
+	}()
+
+	var (
+		got []State
+		all []Event
+	)
+	for ev := range events {
+		got = append(got, ev.State)
+		all = append(all, ev)
+		if each != nil {
+			each(ev)
+		}
+	}
+	return got, all, <-errc
+}
+
+func TestSupervisor_MaxRestarts(t *testing.T) {
+	e := NewFakeExec(t)
+	e.Expect("worker", nil, Respond(Result{ExitCode: 1})).Times(3)
+
+	got, events, err := states(t, func(c chan<- Event) *Supervisor {
+		return NewSupervisor(e, "worker",
+			WithMaxRestarts(2),
+			WithBackoff(time.Millisecond, 2*time.Millisecond),
+			WithEvents(c),
+		)
+	}, nil)
+
+	var ee *FakeExitError
+	if !errors.Is(err, ErrMaxRestarts) || !errors.As(err, &ee) || ee.ExitCode() != 1 {
+		t.Errorf("Run() error = %v, want ErrMaxRestarts and exit status 1", err)
+	}
+	want := []State{
+		Starting, Running, Exited, Backoff,
+		Starting, Running, Exited, Backoff,
+		Starting, Running, Exited, Stopped,
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("states = %v, want %v", got, want)
+	}
+
+	var delays []time.Duration
+	for _, ev := range events {
+		if ev.State == Backoff {
+			delays = append(delays, ev.Delay)
+		}
+	}
+	if !slices.Equal(delays, []time.Duration{time.Millisecond, 2 * time.Millisecond}) {
+		t.Errorf("backoff delays = %v", delays)
+	}
+}
+
+func TestSupervisor_Policies(t *testing.T) {
+	for _, tt := range []struct {
+		policy  RestartPolicy
+		code    int
+		wantErr bool
+	}{
+		{RestartNever, 0, false},
+		{RestartNever, 3, true},
+		{RestartOnFailure, 0, false},
+	} {
+		t.Run(tt.policy.String(), func(t *testing.T) {
+			e := NewFakeExec(t)
+			e.Expect("job", nil, Respond(Result{ExitCode: tt.code}))
+
+			err := NewSupervisor(e, "job", WithRestart(tt.policy)).Run(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Run() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSupervisor_Stop(t *testing.T) {
+	e := NewFakeExec(t)
+	e.Expect("server", nil, Respond(Result{})).Times(2)
+	e.Expect("server", nil, daemon(nil))
+
+	var (
+		sup     *Supervisor
+		running int
+	)
+	got, _, err := states(t, func(c chan<- Event) *Supervisor {
+		sup = NewSupervisor(e, "server",
+			WithRestart(RestartAlways),
+			WithBackoff(time.Millisecond, time.Millisecond),
+			WithEvents(c),
+		)
+		return sup
+	}, func(ev Event) {
+		if ev.State == Running {
+			if running++; running == 3 {
+				sup.Stop()
+			}
+		}
+	})
+
+	if err != nil {
+		t.Errorf("Run() error = %v", err)
+	}
+	if n := len(got); n < 3 || !slices.Equal(got[n-3:], []State{Stopping, Exited, Stopped}) {
+		t.Errorf("states = %v, want to end stopping, exited, stopped", got)
+	}
+}
+
+func TestSupervisor_Signals(t *testing.T) {
+	e := NewFakeExec(t)
+	var (
+		sigs   = signal.NewFakeSignal()
+		hups   = make(chan os.Signal, 1)
+		ctxt   = context.Background()
+		events = make(chan Event)
+	)
+	e.Expect("server", nil, daemon(hups))
+
+	sup := NewSupervisor(e, "server", WithSignals(sigs), WithEvents(events))
+	errc := make(chan error, 1)
+	go func() {
+		errc <- sup.Run(ctxt)
+		close(events)
+	}()
+
+	for ev := range events {
+		switch ev.State {
+		case Running:
+			sigs.Raise(syscall.SIGHUP)
+			if sig := <-hups; sig != syscall.SIGHUP {
+				t.Errorf("command got %v, want SIGHUP", sig)
+			}
+			sigs.Raise(syscall.SIGTERM)
+		case Exited:
+			var ee *FakeExitError
+			if !errors.As(ev.Err, &ee) || ee.ExitCode() != 143 {
+				t.Errorf("command exited with %v, want exit status 143", ev.Err)
+			}
+		}
+	}
+	if err := <-errc; err != nil {
+		t.Errorf("Run() error = %v", err)
+	}
+}
+
+func TestSupervisor_ForwardStopSignal(t *testing.T) {
+	for _, sig := range []os.Signal{syscall.SIGTERM, syscall.SIGINT} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("WithSignals() forwarding %v did not panic", sig)
+				}
+			}()
+			WithSignals(signal.NewFakeSignal(), syscall.SIGHUP, sig)
+		}()
+	}
+}
+
+func TestSupervisor_KillTimeout(t *testing.T) {
+	e := NewFakeExec(t)
+	e.Expect("stubborn", nil, func(inv *Invocation) Result {
+		<-inv.Context.Done()
+		return Result{}
+	})
+
+	var sup *Supervisor
+	_, events, err := states(t, func(c chan<- Event) *Supervisor {
+		sup = NewSupervisor(e, "stubborn", WithKillTimeout(10*time.Millisecond), WithEvents(c))
+		return sup
+	}, func(ev Event) {
+		if ev.State == Running {
+			sup.Stop()
+		}
+	})
+
+	if err != nil {
+		t.Errorf("Run() error = %v", err)
+	}
+	for _, ev := range events {
+		var ee *FakeExitError
+		if ev.State == Exited && (!errors.As(ev.Err, &ee) || !ee.Killed) {
+			t.Errorf("command exited with %v, want to be killed", ev.Err)
+		}
+	}
+}
+
+func TestSupervisor_Real(t *testing.T) {
+	if _, err := NewExec().LookPath("sleep"); err != nil {
+		t.Skip("needs sleep")
+	}
+
+	var events = make(chan Event)
+	sup := NewSupervisor(NewExec(), "sleep",
+		WithCommandOptions(WithArgs("60")),
+		WithEvents(events),
+	)
+	go func() {
+		sup.Run(context.Background())
+		close(events)
+	}()
+
+	var exited error
+	for ev := range events {
+		switch ev.State {
+		case Running:
+			if ev.PID <= 0 {
+				t.Errorf("Running PID = %d", ev.PID)
+			}
+			sup.Stop()
+		case Exited:
+			exited = ev.Err
+		}
+	}
+	var ee *ExitError
+	if !errors.As(exited, &ee) || ee.ExitCode() != -1 {
+		t.Errorf("sleep exited with %v, want to be terminated", exited)
+	}
+}
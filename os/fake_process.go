@@ -0,0 +1,354 @@
+package os
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ProcessStateOption sets what a fake ProcessState reports.
+type ProcessStateOption func(*fakeProcessState)
+
+// WithExitCode sets the exit status, which is 0 by default.
+func WithExitCode(code int) ProcessStateOption {
+	return func(ps *fakeProcessState) {
+		ps.code = code
+	}
+}
+
+// WithSignaled makes the process have been killed by sig, rather than
+// have exited.
+func WithSignaled(sig Signal) ProcessStateOption {
+	return func(ps *fakeProcessState) {
+		ps.signal = sig
+	}
+}
+
+// WithUserTime sets the user CPU time of the process.
+func WithUserTime(d time.Duration) ProcessStateOption {
+	return func(ps *fakeProcessState) {
+		ps.user = d
+	}
+}
+
+// WithSystemTime sets the system CPU time of the process.
+func WithSystemTime(d time.Duration) ProcessStateOption {
+	return func(ps *fakeProcessState) {
+		ps.system = d
+	}
+}
+
+// WithSysUsage sets the value returned by SysUsage, such as a
+// *syscall.Rusage.
+func WithSysUsage(usage any) ProcessStateOption {
+	return func(ps *fakeProcessState) {
+		ps.usage = usage
+	}
+}
+
+// WithSys sets the value returned by Sys, such as a
+// syscall.WaitStatus.
+func WithSys(sys any) ProcessStateOption {
+	return func(ps *fakeProcessState) {
+		ps.sys = sys
+	}
+}
+
+type fakeProcessState struct {
+	pid    int
+	code   int
+	signal Signal
+	user   time.Duration
+	system time.Duration
+	usage  any
+	sys    any
+}
+
+// NewFakeProcessState creates a ProcessState of the process pid, which
+// exited with status 0 unless the options say otherwise.  Its Nub is
+// nil.
+func NewFakeProcessState(pid int, options ...ProcessStateOption) ProcessState {
+	var ps = &fakeProcessState{
+		pid: pid,
+	}
+
+	for _, f := range options {
+		f(ps)
+	}
+
+	return ps
+}
+
+func (ps *fakeProcessState) Nub() *os.ProcessState {
+	return nil
+}
+
+// ExitCode returns the exit status, or -1 if the process was killed.
+func (ps *fakeProcessState) ExitCode() int {
+	if ps.signal != nil {
+		return -1
+	}
+	return ps.code
+}
+
+func (ps *fakeProcessState) Exited() bool {
+	return ps.signal == nil
+}
+
+func (ps *fakeProcessState) Pid() int {
+	return ps.pid
+}
+
+func (ps *fakeProcessState) String() string {
+	if ps.signal != nil {
+		return "signal: " + ps.signal.String()
+	}
+	return "exit status " + strconv.Itoa(ps.code)
+}
+
+func (ps *fakeProcessState) Success() bool {
+	return ps.signal == nil && ps.code == 0
+}
+
+func (ps *fakeProcessState) Sys() any {
+	return ps.sys
+}
+
+func (ps *fakeProcessState) SysUsage() any {
+	return ps.usage
+}
+
+func (ps *fakeProcessState) SystemTime() time.Duration {
+	return ps.system
+}
+
+func (ps *fakeProcessState) UserTime() time.Duration {
+	return ps.user
+}
+
+// FakeProcess is a Process that a test controls.  It records the
+// signals sent to it, and its Wait returns only once the test calls
+// Exit.
+type FakeProcess interface {
+	Process
+
+	// Signals returns the signals sent to the process, in order, with
+	// Kill recorded as os.Kill.
+	Signals() []Signal
+
+	// OnSignal calls f with each signal sent to the process from now
+	// on, after it has been recorded.  f may call Exit.
+	OnSignal(f func(p FakeProcess, sig Signal))
+
+	// Exit ends the process, so that Wait returns state and further
+	// signals fail with ErrProcessDone.
+	Exit(state ProcessState)
+}
+
+var errProcessReleased = errors.New("os: process already released")
+
+type fakeProcess struct {
+	pid int
+
+	mu       sync.Mutex
+	signals  []Signal
+	onSignal func(FakeProcess, Signal)
+	state    ProcessState
+	exited   chan struct{}
+	waited   bool
+	released bool
+}
+
+// NewFakeProcess creates a running FakeProcess with the id pid.
+func NewFakeProcess(pid int) FakeProcess {
+	return &fakeProcess{
+		pid:    pid,
+		exited: make(chan struct{}),
+	}
+}
+
+func (p *fakeProcess) Nub() *os.Process {
+	return nil
+}
+
+func (p *fakeProcess) PID() int {
+	return p.pid
+}
+
+func (p *fakeProcess) Kill() error {
+	return p.Signal(os.Kill)
+}
+
+func (p *fakeProcess) Release() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.released = true
+	return nil
+}
+
+func (p *fakeProcess) Signal(sig Signal) error {
+	p.mu.Lock()
+	switch {
+	case p.released:
+		p.mu.Unlock()
+		return errProcessReleased
+	case p.state != nil:
+		p.mu.Unlock()
+		return ErrProcessDone
+	}
+	p.signals = append(p.signals, sig)
+	f := p.onSignal
+	p.mu.Unlock()
+
+	if f != nil {
+		f(p, sig)
+	}
+	return nil
+}
+
+// Wait waits for Exit.  Like a real process, it can be waited for only
+// once.
+func (p *fakeProcess) Wait() (ProcessState, error) {
+	p.mu.Lock()
+	if p.waited {
+		p.mu.Unlock()
+		return nil, os.NewSyscallError("wait", syscall.ECHILD)
+	}
+	p.waited = true
+	p.mu.Unlock()
+
+	<-p.exited
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.state, nil
+}
+
+func (p *fakeProcess) Signals() []Signal {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return slices.Clone(p.signals)
+}
+
+func (p *fakeProcess) OnSignal(f func(FakeProcess, Signal)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.onSignal = f
+}
+
+// Exit ends the process.  Only the first call has any effect.
+func (p *fakeProcess) Exit(state ProcessState) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.state != nil {
+		return
+	}
+	p.state = state
+	close(p.exited)
+}
+
+// ProcessTable is a set of fake processes, for an OS to find and start
+// instead of real ones.  Give it to NewMemOS with WithProcesses.
+type ProcessTable interface {
+	ProcessStarter
+
+	// Add adds a running process with the id pid, for FindProcess to
+	// find.
+	Add(pid int) FakeProcess
+
+	// OnStart sets a function that StartProcess calls with each process
+	// it starts.  If f returns an error, the process is not started,
+	// and StartProcess returns the error.
+	OnStart(f func(p FakeProcess, name string, argv []string, attr *ProcAttr) error)
+
+	// Processes returns the processes of the table, in the order they
+	// were added or started.
+	Processes() []FakeProcess
+}
+
+type processTable struct {
+	mu      sync.Mutex
+	procs   []FakeProcess
+	byPID   map[int]FakeProcess
+	nextPID int
+	onStart func(FakeProcess, string, []string, *ProcAttr) error
+}
+
+// NewProcessTable creates an empty ProcessTable.  The processes it
+// starts get ids from 100 on.
+func NewProcessTable() ProcessTable {
+	return &processTable{
+		byPID:   make(map[int]FakeProcess),
+		nextPID: 100,
+	}
+}
+
+func (t *processTable) Add(pid int) FakeProcess {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p := NewFakeProcess(pid)
+	t.add(p)
+	return p
+}
+
+func (t *processTable) add(p FakeProcess) {
+	t.procs = append(t.procs, p)
+	t.byPID[p.PID()] = p
+}
+
+func (t *processTable) OnStart(f func(FakeProcess, string, []string, *ProcAttr) error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.onStart = f
+}
+
+func (t *processTable) Processes() []FakeProcess {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return slices.Clone(t.procs)
+}
+
+func (t *processTable) FindProcess(pid int) (Process, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if p, ok := t.byPID[pid]; ok {
+		return p, nil
+	}
+	return nil, os.NewSyscallError("findprocess", syscall.ESRCH)
+}
+
+func (t *processTable) StartProcess(name string, argv []string, attr *ProcAttr) (Process, error) {
+	t.mu.Lock()
+	for t.byPID[t.nextPID] != nil {
+		t.nextPID++
+	}
+	p := NewFakeProcess(t.nextPID)
+	t.nextPID++
+	f := t.onStart
+	t.mu.Unlock()
+
+	if f != nil {
+		if err := f(p, name, argv, attr); err != nil {
+			return nil, err
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.add(p)
+	return p, nil
+}
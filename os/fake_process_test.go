@@ -0,0 +1,105 @@
+package os
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestNewFakeProcessState(t *testing.T) {
+	ps := NewFakeProcessState(42, WithExitCode(3), WithUserTime(time.Second), WithSysUsage("usage"))
+	if ps.Pid() != 42 || ps.ExitCode() != 3 || !ps.Exited() || ps.Success() ||
+		ps.UserTime() != time.Second || ps.SysUsage() != "usage" || ps.String() != "exit status 3" {
+		t.Errorf("ProcessState = %+v", ps)
+	}
+
+	ps = NewFakeProcessState(42, WithSignaled(syscall.SIGKILL))
+	if ps.ExitCode() != -1 || ps.Exited() || ps.Success() || ps.String() != "signal: killed" {
+		t.Errorf("killed ProcessState = %+v", ps)
+	}
+}
+
+func TestProcessTable(t *testing.T) {
+	procs := NewProcessTable()
+	o := NewMemOS(WithProcesses(procs))
+
+	daemon := procs.Add(7)
+	daemon.OnSignal(func(p FakeProcess, sig Signal) {
+		if sig == syscall.SIGTERM {
+			p.Exit(NewFakeProcessState(p.PID(), WithSignaled(sig)))
+		}
+	})
+
+	p, err := o.FindProcess(7)
+	if err != nil {
+		t.Fatalf("FindProcess() error = %v", err)
+	}
+	p.Signal(syscall.SIGHUP)
+	p.Signal(syscall.SIGTERM)
+	if got := daemon.Signals(); !slices.Equal(got, []Signal{syscall.SIGHUP, syscall.SIGTERM}) {
+		t.Errorf("Signals() = %v", got)
+	}
+	if err := p.Kill(); !errors.Is(err, ErrProcessDone) {
+		t.Errorf("Kill() after exit error = %v, want ErrProcessDone", err)
+	}
+	if ps, err := p.Wait(); err != nil || ps.ExitCode() != -1 {
+		t.Errorf("Wait() = %v, %v", ps, err)
+	}
+	if _, err := p.Wait(); !errors.Is(err, syscall.ECHILD) {
+		t.Errorf("second Wait() error = %v, want ECHILD", err)
+	}
+
+	if _, err := o.FindProcess(8); !errors.Is(err, syscall.ESRCH) {
+		t.Errorf("FindProcess() of missing process error = %v, want ESRCH", err)
+	}
+}
+
+func TestProcessTable_StartProcess(t *testing.T) {
+	procs := NewProcessTable()
+	o := NewMemOS(WithProcesses(procs))
+
+	procs.OnStart(func(p FakeProcess, name string, argv []string, _ *ProcAttr) error {
+		if name == "/bin/missing" {
+			return &PathError{Op: "fork/exec", Path: name, Err: syscall.ENOENT}
+		}
+		if !slices.Equal(argv, []string{"worker", "-v"}) {
+			t.Errorf("argv = %q", argv)
+		}
+		return nil
+	})
+
+	if _, err := o.StartProcess("/bin/missing", nil, &ProcAttr{}); !errors.Is(err, ErrNotExist) {
+		t.Errorf("StartProcess() error = %v, want ErrNotExist", err)
+	}
+	p, err := o.StartProcess("/bin/worker", []string{"worker", "-v"}, &ProcAttr{})
+	if err != nil {
+		t.Fatalf("StartProcess() error = %v", err)
+	}
+
+	var waited = make(chan ProcessState)
+	go func() {
+		ps, _ := p.Wait()
+		waited <- ps
+	}()
+	select {
+	case <-waited:
+		t.Fatal("Wait() returned before Exit()")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	started := procs.Processes()
+	if len(started) != 1 || started[0].PID() != p.PID() {
+		t.Fatalf("Processes() = %v", started)
+	}
+	p.Kill()
+	if got := started[0].Signals(); !slices.Equal(got, []Signal{os.Kill}) {
+		t.Errorf("Signals() = %v, want [killed]", got)
+	}
+	started[0].Exit(NewFakeProcessState(p.PID()))
+	if ps := <-waited; !ps.Success() {
+		t.Errorf("Wait() = %v, want success", ps)
+	}
+}
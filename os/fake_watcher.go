@@ -0,0 +1,150 @@
+package os
+
+import (
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// FakeWatcher is a Watcher whose events are sent by a test, rather than
+// caused by changes to files.  It watches names without looking for
+// them, so it needs no file system.
+type FakeWatcher interface {
+	Watcher
+
+	// Emit sends an event for name, if name or its directory is being
+	// watched, and waits for it to be received.  It reports whether
+	// the event was sent.  A rename is usually an event with WatchRename
+	// for the old name and one with WatchCreate for the new.
+	Emit(name string, op WatchOp) bool
+
+	// Fail sends err on the Errors channel, and waits for it to be
+	// received, unless the watcher is closed first.
+	Fail(err error)
+
+	// Watched returns the names being watched, in order.
+	Watched() []string
+}
+
+type fakeWatcher struct {
+	events chan WatchEvent
+	errors chan error
+	done   chan struct{}
+
+	mu      sync.Mutex
+	watched map[string]bool
+	closed  bool
+	sending sync.WaitGroup
+}
+
+// NewFakeWatcher creates a FakeWatcher that watches nothing.
+func NewFakeWatcher() FakeWatcher {
+	return &fakeWatcher{
+		events:  make(chan WatchEvent),
+		errors:  make(chan error),
+		done:    make(chan struct{}),
+		watched: make(map[string]bool),
+	}
+}
+
+func (w *fakeWatcher) Events() <-chan WatchEvent {
+	return w.events
+}
+
+func (w *fakeWatcher) Errors() <-chan error {
+	return w.errors
+}
+
+func (w *fakeWatcher) Add(name string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return errWatcherClosed
+	}
+	w.watched[filepath.Clean(name)] = true
+	return nil
+}
+
+func (w *fakeWatcher) Remove(name string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	name = filepath.Clean(name)
+	if !w.watched[name] {
+		return &PathError{Op: "remove watch", Path: name, Err: ErrNotExist}
+	}
+	delete(w.watched, name)
+	return nil
+}
+
+func (w *fakeWatcher) Watched() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var names = make([]string, 0, len(w.watched))
+	for name := range w.watched {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// start registers a send, unless the watcher is closed.
+func (w *fakeWatcher) start() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return false
+	}
+	w.sending.Add(1)
+	return true
+}
+
+func (w *fakeWatcher) Emit(name string, op WatchOp) bool {
+	name = filepath.Clean(name)
+
+	w.mu.Lock()
+	watched := w.watched[name] || w.watched[filepath.Dir(name)]
+	w.mu.Unlock()
+	if !watched || !w.start() {
+		return false
+	}
+	defer w.sending.Done()
+
+	select {
+	case w.events <- WatchEvent{Name: name, Op: op}:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+func (w *fakeWatcher) Fail(err error) {
+	if !w.start() {
+		return
+	}
+	defer w.sending.Done()
+
+	select {
+	case w.errors <- err:
+	case <-w.done:
+	}
+}
+
+func (w *fakeWatcher) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.done)
+	w.mu.Unlock()
+
+	w.sending.Wait()
+	close(w.events)
+	close(w.errors)
+	return nil
+}
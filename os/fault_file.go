@@ -0,0 +1,222 @@
+package os
+
+import (
+	"io"
+	"syscall"
+	"time"
+)
+
+// faultFile is a File opened through a FaultOS, whose methods fail
+// according to the rules of the FaultOS.
+type faultFile struct {
+	File
+	os *faultOS
+}
+
+// Unwrap returns the file of the base OS, for LockerFor.
+func (f *faultFile) Unwrap() File {
+	return f.File
+}
+
+// check returns the *PathError for a call to method, if a rule makes
+// it fail.
+func (f *faultFile) check(method string) error {
+	return f.os.check(method, f.Name())
+}
+
+// write carries out a write through fn, unless a rule makes it fail,
+// in which case it writes as much as the rule allows.
+func (f *faultFile) write(method string, b []byte, fn func([]byte) (int, error)) (int, error) {
+	fault, ok := f.os.fault(method, f.Name())
+	if !ok {
+		return fn(b)
+	}
+
+	if fault.ShortWrite > 0 {
+		if len(b) <= fault.ShortWrite {
+			return fn(b)
+		}
+		n, err := fn(b[:fault.ShortWrite])
+		if err != nil {
+			return n, err
+		}
+		err = fault.Err
+		if err == nil {
+			err = io.ErrShortWrite
+		}
+		return n, &PathError{Op: faultOp(method), Path: f.Name(), Err: err}
+	}
+
+	return 0, &PathError{Op: faultOp(method), Path: f.Name(), Err: fault.err()}
+}
+
+func (f *faultFile) Chdir() error {
+	if err := f.check("File.Chdir"); err != nil {
+		return err
+	}
+	return f.File.Chdir()
+}
+
+func (f *faultFile) Chmod(mode FSFileMode) error {
+	if err := f.check("File.Chmod"); err != nil {
+		return err
+	}
+	return f.File.Chmod(mode)
+}
+
+func (f *faultFile) Chown(uid, gid int) error {
+	if err := f.check("File.Chown"); err != nil {
+		return err
+	}
+	return f.File.Chown(uid, gid)
+}
+
+// Close closes the file even when it fails, as the descriptor is
+// released regardless of the error.
+func (f *faultFile) Close() error {
+	err := f.File.Close()
+	if ferr := f.check("File.Close"); ferr != nil && err == nil {
+		err = ferr
+	}
+	return err
+}
+
+func (f *faultFile) Read(b []byte) (int, error) {
+	if err := f.check("File.Read"); err != nil {
+		return 0, err
+	}
+	return f.File.Read(b)
+}
+
+func (f *faultFile) ReadAt(b []byte, off int64) (int, error) {
+	if err := f.check("File.ReadAt"); err != nil {
+		return 0, err
+	}
+	return f.File.ReadAt(b, off)
+}
+
+func (f *faultFile) ReadDir(n int) ([]DirEntry, error) {
+	if err := f.check("File.ReadDir"); err != nil {
+		return []DirEntry{}, err
+	}
+	return f.File.ReadDir(n)
+}
+
+// ReadFrom copies through Write, so that rules for writes apply.
+func (f *faultFile) ReadFrom(r io.Reader) (int64, error) {
+	if err := f.check("File.ReadFrom"); err != nil {
+		return 0, err
+	}
+	return io.Copy(faultFileWriter{f}, r)
+}
+
+func (f *faultFile) Readdir(n int) ([]FileInfo, error) {
+	if err := f.check("File.Readdir"); err != nil {
+		return []FileInfo{}, err
+	}
+	return f.File.Readdir(n)
+}
+
+func (f *faultFile) Readdirnames(n int) ([]string, error) {
+	if err := f.check("File.Readdirnames"); err != nil {
+		return []string{}, err
+	}
+	return f.File.Readdirnames(n)
+}
+
+func (f *faultFile) Seek(offset int64, whence int) (int64, error) {
+	if err := f.check("File.Seek"); err != nil {
+		return 0, err
+	}
+	return f.File.Seek(offset, whence)
+}
+
+func (f *faultFile) SetDeadline(t time.Time) error {
+	if err := f.check("File.SetDeadline"); err != nil {
+		return err
+	}
+	return f.File.SetDeadline(t)
+}
+
+func (f *faultFile) SetReadDeadline(t time.Time) error {
+	if err := f.check("File.SetReadDeadline"); err != nil {
+		return err
+	}
+	return f.File.SetReadDeadline(t)
+}
+
+func (f *faultFile) SetWriteDeadline(t time.Time) error {
+	if err := f.check("File.SetWriteDeadline"); err != nil {
+		return err
+	}
+	return f.File.SetWriteDeadline(t)
+}
+
+func (f *faultFile) Stat() (FileInfo, error) {
+	if err := f.check("File.Stat"); err != nil {
+		return nil, err
+	}
+	return f.File.Stat()
+}
+
+func (f *faultFile) Sync() error {
+	if err := f.check("File.Sync"); err != nil {
+		return err
+	}
+	return f.File.Sync()
+}
+
+func (f *faultFile) SyscallConn() (syscall.RawConn, error) {
+	if err := f.check("File.SyscallConn"); err != nil {
+		return nil, err
+	}
+	return f.File.SyscallConn()
+}
+
+func (f *faultFile) Truncate(size int64) error {
+	if err := f.check("File.Truncate"); err != nil {
+		return err
+	}
+	return f.File.Truncate(size)
+}
+
+func (f *faultFile) Write(b []byte) (int, error) {
+	return f.write("File.Write", b, f.File.Write)
+}
+
+func (f *faultFile) WriteAt(b []byte, off int64) (int, error) {
+	return f.write("File.WriteAt", b, func(b []byte) (int, error) {
+		return f.File.WriteAt(b, off)
+	})
+}
+
+func (f *faultFile) WriteString(s string) (int, error) {
+	return f.write("File.WriteString", []byte(s), f.File.Write)
+}
+
+// WriteTo copies through Read, so that rules for reads apply.
+func (f *faultFile) WriteTo(w io.Writer) (int64, error) {
+	if err := f.check("File.WriteTo"); err != nil {
+		return 0, err
+	}
+	return io.Copy(w, faultFileReader{f})
+}
+
+// faultFileReader and faultFileWriter hide the ReaderFrom and WriterTo
+// methods of a faultFile from io.Copy, which would otherwise call them
+// again.
+type faultFileReader struct {
+	f *faultFile
+}
+
+func (r faultFileReader) Read(b []byte) (int, error) {
+	return r.f.Read(b)
+}
+
+type faultFileWriter struct {
+	f *faultFile
+}
+
+func (w faultFileWriter) Write(b []byte) (int, error) {
+	return w.f.Write(b)
+}
@@ -0,0 +1,554 @@
+package os
+
+import (
+	"path"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pdutton/go-interfaces/io/fs"
+)
+
+// FaultOS is an OS that passes everything through to another OS,
+// except for the file system operations that match one of its rules,
+// which fail instead.  Files opened through it are checked against the
+// rules as well.
+type FaultOS interface {
+	OS
+
+	// Inject adds rules, which are checked after those added before
+	Inject(...Fault)
+
+	// Clear removes all of the rules
+	Clear()
+}
+
+// Fault is a rule of a FaultOS, saying which operations fail and how.
+type Fault struct {
+	// Op is matched against the name of the method, such as "Rename"
+	// for a method of the OS or "File.Sync" for a method of a File.
+	// It may be a pattern, as for path.Match.  Empty matches every
+	// method.  Methods such as WriteFile are built on the others, so
+	// the rules for OpenFile and File.Write apply to them as well.
+	Op string
+
+	// Path is a pattern, as for path.Match, for the name given to the
+	// method, or the name of the File.  A pattern without a slash is
+	// also matched against the last element of the name.  Empty
+	// matches every name.
+	Path string
+
+	// Err is the error returned, wrapped in a *PathError or *LinkError
+	// as the os package would.  It defaults to syscall.EIO.
+	Err error
+
+	// Nth makes only the Nth matching call fail, counting from 1.
+	// By default every matching call fails.
+	Nth int
+
+	// ShortWrite makes matching writes write the first ShortWrite
+	// bytes of their data before they fail.  Writes of no more than
+	// that succeed.  Err defaults to io.ErrShortWrite for them, in a
+	// *PathError like any other.
+	ShortWrite int
+}
+
+// faultRule counts the calls that matched a Fault.
+type faultRule struct {
+	Fault
+	calls int
+}
+
+func (r *faultRule) matches(method string, names []string) bool {
+	if r.Op != "" {
+		if ok, _ := path.Match(r.Op, method); !ok {
+			return false
+		}
+	}
+	if r.Path == "" {
+		return true
+	}
+
+	for _, name := range names {
+		if ok, _ := path.Match(r.Path, name); ok {
+			return true
+		}
+		if !strings.Contains(r.Path, "/") {
+			if ok, _ := path.Match(r.Path, path.Base(name)); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type faultOS struct {
+	base OS
+
+	mu    sync.Mutex
+	rules []*faultRule
+}
+
+// NewFaultOS creates a FaultOS that wraps base and fails according to
+// the given rules.
+func NewFaultOS(base OS, faults ...Fault) FaultOS {
+	var f = &faultOS{
+		base: base,
+	}
+	f.Inject(faults...)
+
+	return f
+}
+
+func (f *faultOS) Inject(faults ...Fault) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, fault := range faults {
+		f.rules = append(f.rules, &faultRule{Fault: fault})
+	}
+}
+
+func (f *faultOS) Clear() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.rules = nil
+}
+
+// fault returns the first rule that makes the call to method fail.
+func (f *faultOS) fault(method string, names ...string) (Fault, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, r := range f.rules {
+		if !r.matches(method, names) {
+			continue
+		}
+		r.calls++
+		if r.Nth == 0 || r.Nth == r.calls {
+			return r.Fault, true
+		}
+	}
+	return Fault{}, false
+}
+
+// The names of the operations in the errors of the os package, where
+// they differ from the lower case name of the method.
+var faultOps = map[string]string{
+	"Create":            "open",
+	"CreateTemp":        "createtemp",
+	"MkdirAll":          "mkdir",
+	"MkdirTemp":         "mkdirtemp",
+	"Open":              "open",
+	"OpenFile":          "open",
+	"OpenInRoot":        "openat",
+	"OpenRoot":          "open",
+	"ReadDir":           "open",
+	"ReadFile":          "open",
+	"RemoveAll":         "unlinkat",
+	"WriteFile":         "open",
+	"File.ReadAt":       "read",
+	"File.ReadDir":      "readdirent",
+	"File.ReadFrom":     "write",
+	"File.Readdir":      "readdirent",
+	"File.Readdirnames": "readdirent",
+	"File.WriteAt":      "write",
+	"File.WriteString":  "write",
+	"File.WriteTo":      "read",
+}
+
+func faultOp(method string) string {
+	if op, ok := faultOps[method]; ok {
+		return op
+	}
+	return strings.ToLower(strings.TrimPrefix(method, "File."))
+}
+
+func (fault Fault) err() error {
+	if fault.Err == nil {
+		return syscall.EIO
+	}
+	return fault.Err
+}
+
+// check returns the *PathError for a call to method with name, if a
+// rule makes it fail.
+func (f *faultOS) check(method, name string) error {
+	if fault, ok := f.fault(method, name); ok {
+		return &PathError{Op: faultOp(method), Path: name, Err: fault.err()}
+	}
+	return nil
+}
+
+// checkLink returns the *LinkError for a call to method with oldname
+// and newname, if a rule makes it fail.
+func (f *faultOS) checkLink(method, oldname, newname string) error {
+	if fault, ok := f.fault(method, oldname, newname); ok {
+		return &LinkError{Op: faultOp(method), Old: oldname, New: newname, Err: fault.err()}
+	}
+	return nil
+}
+
+func (f *faultOS) wrap(file File) File {
+	if file == nil {
+		return nil
+	}
+	return &faultFile{File: file, os: f}
+}
+
+func (f *faultOS) Stdin() File {
+	return f.wrap(f.base.Stdin())
+}
+
+func (f *faultOS) Stderr() File {
+	return f.wrap(f.base.Stderr())
+}
+
+func (f *faultOS) Stdout() File {
+	return f.wrap(f.base.Stdout())
+}
+
+func (f *faultOS) Args() []string {
+	return f.base.Args()
+}
+
+func (f *faultOS) Chdir(dir string) error {
+	if err := f.check("Chdir", dir); err != nil {
+		return err
+	}
+	return f.base.Chdir(dir)
+}
+
+func (f *faultOS) Chmod(name string, mode FSFileMode) error {
+	if err := f.check("Chmod", name); err != nil {
+		return err
+	}
+	return f.base.Chmod(name, mode)
+}
+
+func (f *faultOS) Chown(name string, uid, gid int) error {
+	if err := f.check("Chown", name); err != nil {
+		return err
+	}
+	return f.base.Chown(name, uid, gid)
+}
+
+func (f *faultOS) Chtimes(name string, atime, mtime time.Time) error {
+	if err := f.check("Chtimes", name); err != nil {
+		return err
+	}
+	return f.base.Chtimes(name, atime, mtime)
+}
+
+func (f *faultOS) Clearenv() {
+	f.base.Clearenv()
+}
+
+func (f *faultOS) CopyFS(dir string, fsys fs.FS) error {
+	if err := f.check("CopyFS", dir); err != nil {
+		return err
+	}
+	return copyFS(f, dir, fsys)
+}
+
+func (f *faultOS) DirFS(dir string) fs.FS {
+	return newDirFS(f, dir)
+}
+
+func (f *faultOS) Environ() []string {
+	return f.base.Environ()
+}
+
+func (f *faultOS) Executable() (string, error) {
+	return f.base.Executable()
+}
+
+func (f *faultOS) Exit(code int) {
+	f.base.Exit(code)
+}
+
+func (f *faultOS) Expand(s string, mapping func(string) string) string {
+	return f.base.Expand(s, mapping)
+}
+
+func (f *faultOS) ExpandEnv(s string) string {
+	return f.base.ExpandEnv(s)
+}
+
+func (f *faultOS) Getegid() int {
+	return f.base.Getegid()
+}
+
+func (f *faultOS) Getenv(key string) string {
+	return f.base.Getenv(key)
+}
+
+func (f *faultOS) Geteuid() int {
+	return f.base.Geteuid()
+}
+
+func (f *faultOS) Getgid() int {
+	return f.base.Getgid()
+}
+
+func (f *faultOS) Getgroups() ([]int, error) {
+	return f.base.Getgroups()
+}
+
+func (f *faultOS) Getpagesize() int {
+	return f.base.Getpagesize()
+}
+
+func (f *faultOS) Getpid() int {
+	return f.base.Getpid()
+}
+
+func (f *faultOS) Getppid() int {
+	return f.base.Getppid()
+}
+
+func (f *faultOS) Getuid() int {
+	return f.base.Getuid()
+}
+
+func (f *faultOS) Getwd() (string, error) {
+	return f.base.Getwd()
+}
+
+func (f *faultOS) Hostname() (string, error) {
+	return f.base.Hostname()
+}
+
+func (f *faultOS) IsExist(err error) bool {
+	return f.base.IsExist(err)
+}
+
+func (f *faultOS) IsNotExist(err error) bool {
+	return f.base.IsNotExist(err)
+}
+
+func (f *faultOS) IsPathSeparator(c uint8) bool {
+	return f.base.IsPathSeparator(c)
+}
+
+func (f *faultOS) IsPermission(err error) bool {
+	return f.base.IsPermission(err)
+}
+
+func (f *faultOS) IsTimeout(err error) bool {
+	return f.base.IsTimeout(err)
+}
+
+func (f *faultOS) Lchown(name string, uid, gid int) error {
+	if err := f.check("Lchown", name); err != nil {
+		return err
+	}
+	return f.base.Lchown(name, uid, gid)
+}
+
+func (f *faultOS) Link(oldname, newname string) error {
+	if err := f.checkLink("Link", oldname, newname); err != nil {
+		return err
+	}
+	return f.base.Link(oldname, newname)
+}
+
+func (f *faultOS) LookupEnv(key string) (string, bool) {
+	return f.base.LookupEnv(key)
+}
+
+func (f *faultOS) Mkdir(name string, perm FSFileMode) error {
+	if err := f.check("Mkdir", name); err != nil {
+		return err
+	}
+	return f.base.Mkdir(name, perm)
+}
+
+func (f *faultOS) MkdirAll(name string, perm FSFileMode) error {
+	if err := f.check("MkdirAll", name); err != nil {
+		return err
+	}
+	return mkdirAll(f, name, perm)
+}
+
+func (f *faultOS) MkdirTemp(dir, pattern string) (string, error) {
+	if err := f.check("MkdirTemp", dir); err != nil {
+		return "", err
+	}
+	return mkdirTemp(f, dir, pattern)
+}
+
+func (f *faultOS) NewSyscallError(syscall string, err error) error {
+	return f.base.NewSyscallError(syscall, err)
+}
+
+func (f *faultOS) Pipe() (File, File, error) {
+	r, w, err := f.base.Pipe()
+	return f.wrap(r), f.wrap(w), err
+}
+
+func (f *faultOS) ReadFile(name string) ([]byte, error) {
+	if err := f.check("ReadFile", name); err != nil {
+		return nil, err
+	}
+	return readFile(f, name)
+}
+
+func (f *faultOS) Readlink(name string) (string, error) {
+	if err := f.check("Readlink", name); err != nil {
+		return "", err
+	}
+	return f.base.Readlink(name)
+}
+
+func (f *faultOS) Remove(name string) error {
+	if err := f.check("Remove", name); err != nil {
+		return err
+	}
+	return f.base.Remove(name)
+}
+
+func (f *faultOS) RemoveAll(name string) error {
+	if err := f.check("RemoveAll", name); err != nil {
+		return err
+	}
+	return removeAll(f, name)
+}
+
+func (f *faultOS) Rename(oldpath, newpath string) error {
+	if err := f.checkLink("Rename", oldpath, newpath); err != nil {
+		return err
+	}
+	return f.base.Rename(oldpath, newpath)
+}
+
+func (f *faultOS) SameFile(fi1, fi2 FileInfo) bool {
+	return f.base.SameFile(fi1, fi2)
+}
+
+func (f *faultOS) Setenv(key, value string) error {
+	return f.base.Setenv(key, value)
+}
+
+func (f *faultOS) Symlink(oldname, newname string) error {
+	if err := f.checkLink("Symlink", oldname, newname); err != nil {
+		return err
+	}
+	return f.base.Symlink(oldname, newname)
+}
+
+func (f *faultOS) TempDir() string {
+	return f.base.TempDir()
+}
+
+func (f *faultOS) Truncate(name string, size int64) error {
+	if err := f.check("Truncate", name); err != nil {
+		return err
+	}
+	return f.base.Truncate(name, size)
+}
+
+func (f *faultOS) Unsetenv(key string) error {
+	return f.base.Unsetenv(key)
+}
+
+func (f *faultOS) UserCacheDir() (string, error) {
+	return f.base.UserCacheDir()
+}
+
+func (f *faultOS) UserConfigDir() (string, error) {
+	return f.base.UserConfigDir()
+}
+
+func (f *faultOS) UserHomeDir() (string, error) {
+	return f.base.UserHomeDir()
+}
+
+func (f *faultOS) WriteFile(name string, data []byte, perm FSFileMode) error {
+	if err := f.check("WriteFile", name); err != nil {
+		return err
+	}
+	return writeFile(f, name, data, perm)
+}
+
+func (f *faultOS) ReadDir(name string) ([]DirEntry, error) {
+	if err := f.check("ReadDir", name); err != nil {
+		return nil, err
+	}
+	return readDir(f, name)
+}
+
+func (f *faultOS) Create(name string) (File, error) {
+	return f.openFile("Create", name, O_RDWR|O_CREATE|O_TRUNC, 0666)
+}
+
+func (f *faultOS) CreateTemp(dir, pattern string) (File, error) {
+	if err := f.check("CreateTemp", dir); err != nil {
+		return nil, err
+	}
+	return createTemp(f, dir, pattern)
+}
+
+func (f *faultOS) NewFile(fd uintptr, name string) File {
+	return f.wrap(f.base.NewFile(fd, name))
+}
+
+func (f *faultOS) Open(name string) (File, error) {
+	return f.openFile("Open", name, O_RDONLY, 0)
+}
+
+func (f *faultOS) OpenFile(name string, flag int, perm FSFileMode) (File, error) {
+	return f.openFile("OpenFile", name, flag, perm)
+}
+
+func (f *faultOS) openFile(method, name string, flag int, perm FSFileMode) (File, error) {
+	if err := f.check(method, name); err != nil {
+		return nil, err
+	}
+
+	file, err := f.base.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return f.wrap(file), nil
+}
+
+func (f *faultOS) OpenInRoot(dir, name string) (File, error) {
+	if err := f.check("OpenInRoot", name); err != nil {
+		return nil, err
+	}
+	return openInRoot(f, dir, name)
+}
+
+func (f *faultOS) Lstat(name string) (FileInfo, error) {
+	if err := f.check("Lstat", name); err != nil {
+		return nil, err
+	}
+	return f.base.Lstat(name)
+}
+
+func (f *faultOS) Stat(name string) (FileInfo, error) {
+	if err := f.check("Stat", name); err != nil {
+		return nil, err
+	}
+	return f.base.Stat(name)
+}
+
+func (f *faultOS) FindProcess(pid int) (Process, error) {
+	return f.base.FindProcess(pid)
+}
+
+func (f *faultOS) StartProcess(name string, argv []string, attr *ProcAttr) (Process, error) {
+	return f.base.StartProcess(name, argv, attr)
+}
+
+// OpenRoot returns a Root whose operations go through the FaultOS, so
+// that they are subject to its rules.
+func (f *faultOS) OpenRoot(name string) (Root, error) {
+	if err := f.check("OpenRoot", name); err != nil {
+		return nil, err
+	}
+	return newPathRoot(f, name)
+}
@@ -0,0 +1,116 @@
+package os
+
+import (
+	"errors"
+	"io"
+	stdfs "io/fs"
+	"syscall"
+	"testing"
+)
+
+func TestNewFaultOS(t *testing.T) {
+	var _ OS = NewFaultOS(NewMemOS())
+}
+
+func TestFaultOS_PathErrors(t *testing.T) {
+	f := NewFaultOS(NewMemOS(),
+		Fault{Op: "WriteFile", Path: "*.json", Err: syscall.EACCES},
+		Fault{Op: "Rename", Path: "/tmp/dst", Err: syscall.ENOSPC},
+	)
+
+	err := f.WriteFile("/tmp/config.json", []byte("{}"), 0644)
+	var pe *PathError
+	if !errors.As(err, &pe) || pe.Op != "open" || pe.Path != "/tmp/config.json" || pe.Err != syscall.EACCES {
+		t.Errorf("WriteFile() error = %#v, want EACCES", err)
+	}
+	if !errors.Is(err, stdfs.ErrPermission) {
+		t.Error("errors.Is(err, fs.ErrPermission) = false, want true")
+	}
+
+	if err := f.WriteFile("/tmp/config.txt", nil, 0644); err != nil {
+		t.Errorf("WriteFile() of unmatched path error = %v", err)
+	}
+
+	err = f.Rename("/tmp/config.txt", "/tmp/dst")
+	var le *LinkError
+	if !errors.As(err, &le) || le.Op != "rename" || le.Err != syscall.ENOSPC {
+		t.Errorf("Rename() error = %#v, want ENOSPC", err)
+	}
+
+	f.Clear()
+	if err := f.Rename("/tmp/config.txt", "/tmp/dst"); err != nil {
+		t.Errorf("Rename() after Clear() error = %v", err)
+	}
+}
+
+func TestFaultOS_Nth(t *testing.T) {
+	f := NewFaultOS(NewMemOS(), Fault{Op: "Mkdir", Nth: 2})
+
+	for i, want := range []error{nil, syscall.EIO, nil} {
+		err := f.Mkdir("/tmp/d"+string(rune('0'+i)), 0755)
+		if want == nil && err != nil || want != nil && !errors.Is(err, want) {
+			t.Errorf("Mkdir() call %d error = %v, want %v", i+1, err, want)
+		}
+	}
+}
+
+func TestFaultOS_ShortWrite(t *testing.T) {
+	m := NewMemOS()
+	f := NewFaultOS(m, Fault{Op: "File.Write", ShortWrite: 4, Err: syscall.ENOSPC})
+
+	err := f.WriteFile("/tmp/out", []byte("hello world"), 0644)
+	if !errors.Is(err, syscall.ENOSPC) {
+		t.Errorf("WriteFile() error = %v, want ENOSPC", err)
+	}
+	if data, _ := m.ReadFile("/tmp/out"); string(data) != "hell" {
+		t.Errorf("written data = %q, want %q", data, "hell")
+	}
+
+	f.Clear()
+	f.Inject(Fault{Op: "File.Write*", ShortWrite: 2})
+	file, _ := f.Create("/tmp/out")
+	defer file.Close()
+
+	n, err := file.WriteString("abc")
+	var pe *PathError
+	if n != 2 || !errors.As(err, &pe) || pe.Path != "/tmp/out" || !errors.Is(err, io.ErrShortWrite) {
+		t.Errorf("WriteString() = %d, %v, want 2 and a *PathError of io.ErrShortWrite", n, err)
+	}
+	if n, err := file.Write([]byte("de")); n != 2 || err != nil {
+		t.Errorf("Write() = %d, %v, want 2, nil", n, err)
+	}
+}
+
+func TestFaultOS_Sync(t *testing.T) {
+	f := NewFaultOS(NewMemOS(), Fault{Op: "File.Sync"})
+
+	file, err := f.Create("/tmp/file")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if _, err := file.Write([]byte("data")); err != nil {
+		t.Errorf("Write() error = %v", err)
+	}
+
+	err = file.Sync()
+	var pe *PathError
+	if !errors.As(err, &pe) || pe.Op != "sync" || pe.Path != "/tmp/file" || pe.Err != syscall.EIO {
+		t.Errorf("Sync() error = %#v, want EIO", err)
+	}
+	if err := file.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+}
+
+func TestFaultOS_Close(t *testing.T) {
+	m := NewMemOS()
+	f := NewFaultOS(m, Fault{Op: "File.Close", Path: "/tmp/*"})
+
+	file, _ := f.Create("/tmp/file")
+	if err := file.Close(); !errors.Is(err, syscall.EIO) {
+		t.Errorf("Close() error = %v, want EIO", err)
+	}
+	if err := file.Close(); !errors.Is(err, ErrClosed) {
+		t.Errorf("second Close() error = %v, want ErrClosed", err)
+	}
+}
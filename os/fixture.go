@@ -0,0 +1,344 @@
+package os
+
+import (
+	"fmt"
+	stdfs "io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pdutton/go-interfaces/internal/txtar"
+	"github.com/pdutton/go-interfaces/io/fs"
+)
+
+// fixtureEntry is a file, directory or symbolic link of a fixture.
+type fixtureEntry struct {
+	name   string
+	data   string
+	dir    bool
+	target string
+	mode   FSFileMode
+	mtime  time.Time
+	link   bool
+
+	// modeSet is whether mode was given, which it may be as 0.
+	modeSet bool
+}
+
+// parseFixtureHeader parses a name and its directives.
+func parseFixtureHeader(header string) (fixtureEntry, error) {
+	var e fixtureEntry
+
+	name, rest, err := unquotePath(strings.TrimSpace(header))
+	if err != nil || name == "" {
+		return e, fmt.Errorf("os: bad fixture name %q", header)
+	}
+	e.name, e.dir = strings.TrimSuffix(name, "/"), strings.HasSuffix(name, "/")
+	if e.name == "" {
+		e.name = "/"
+	}
+
+	if target, ok := strings.CutPrefix(strings.TrimSpace(rest), "-> "); ok {
+		var rest string
+		if e.target, rest, err = unquotePath(strings.TrimSpace(target)); err != nil || e.target == "" || e.dir {
+			return e, fmt.Errorf("os: bad fixture link %q", header)
+		}
+		if strings.TrimSpace(rest) != "" {
+			// a link has no mode or mtime of its own
+			return e, fmt.Errorf("os: directives after fixture link %q", header)
+		}
+		e.link = true
+		return e, nil
+	}
+
+	for _, d := range strings.Fields(rest) {
+		key, value, _ := strings.Cut(d, "=")
+		switch key {
+		case "mode":
+			perm, err := strconv.ParseUint(value, 8, 32)
+			if err != nil || perm > 07777 {
+				return e, fmt.Errorf("os: bad mode in fixture %q", header)
+			}
+			e.mode, e.modeSet = FSFileMode(perm&0777), true
+			if perm&04000 != 0 {
+				e.mode |= stdfs.ModeSetuid
+			}
+			if perm&02000 != 0 {
+				e.mode |= stdfs.ModeSetgid
+			}
+			if perm&01000 != 0 {
+				e.mode |= stdfs.ModeSticky
+			}
+		case "mtime":
+			if secs, err := strconv.ParseInt(value, 10, 64); err == nil {
+				e.mtime = time.Unix(secs, 0).UTC()
+			} else if e.mtime, err = time.Parse(time.RFC3339Nano, value); err != nil {
+				return e, fmt.Errorf("os: bad mtime in fixture %q", header)
+			}
+		default:
+			return e, fmt.Errorf("os: unknown directive %q in fixture %q", d, header)
+		}
+	}
+
+	if !e.modeSet {
+		e.mode = 0644
+		if e.dir {
+			e.mode = 0755
+		}
+	}
+	return e, nil
+}
+
+func mapFixture(files map[string]string) ([]fixtureEntry, error) {
+	var headers = make([]string, 0, len(files))
+	for header := range files {
+		headers = append(headers, header)
+	}
+	slices.Sort(headers)
+
+	var entries = make([]fixtureEntry, 0, len(files))
+	for _, header := range headers {
+		e, err := parseFixtureHeader(header)
+		if err != nil {
+			return nil, err
+		}
+		e.data = files[header]
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+func txtarFixture(archive []byte) ([]fixtureEntry, error) {
+	a := txtar.Parse(archive)
+
+	var entries = make([]fixtureEntry, 0, len(a.Files))
+	for _, f := range a.Files {
+		e, err := parseFixtureHeader(f.Name)
+		if err != nil {
+			return nil, err
+		}
+		e.data = string(f.Data)
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// buildFixture makes entries in m, with relative names below dir.
+func buildFixture(m *memOS, dir string, entries []fixtureEntry) error {
+	var names = make(map[string]bool)
+	for i := range entries {
+		e := &entries[i]
+		if !path.IsAbs(e.name) {
+			e.name = path.Join(dir, e.name)
+		}
+		e.name = path.Clean(e.name)
+		if names[e.name] {
+			return fmt.Errorf("os: fixture has %s twice", e.name)
+		}
+		if (e.dir || e.link) && e.data != "" {
+			return fmt.Errorf("os: fixture gives contents to %s, which is not a file", e.name)
+		}
+		names[e.name] = true
+	}
+
+	// Build as root, to get past permissions, then give what was made
+	// to the user.
+	uid, gid := m.uid, m.gid
+	m.uid, m.gid = 0, 0
+	defer func() {
+		m.uid, m.gid = uid, gid
+	}()
+
+	var made []string
+	for _, e := range entries {
+		for _, dir := range ancestors(e.name) {
+			if _, err := m.Lstat(dir); err == nil {
+				continue
+			}
+			if err := m.Mkdir(dir, 0755); err != nil {
+				return err
+			}
+			made = append(made, dir)
+		}
+
+		var err error
+		switch {
+		case e.link:
+			err = m.Symlink(e.target, e.name)
+		case e.dir:
+			err = m.MkdirAll(e.name, 0755)
+		default:
+			err = m.WriteFile(e.name, []byte(e.data), 0644)
+		}
+		if err != nil {
+			return err
+		}
+		made = append(made, e.name)
+	}
+	for _, name := range made {
+		if err := m.Lchown(name, uid, gid); err != nil {
+			return err
+		}
+	}
+
+	// Then set modes and times, children before their parents, so that
+	// a parent's mtime is not touched afterwards.
+	slices.SortFunc(entries, func(a, b fixtureEntry) int {
+		return strings.Compare(b.name, a.name)
+	})
+	for _, e := range entries {
+		if e.link {
+			continue
+		}
+		if err := m.Chmod(e.name, e.mode); err != nil {
+			return err
+		}
+		if !e.mtime.IsZero() {
+			if err := m.Chtimes(e.name, e.mtime, e.mtime); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ancestors returns the directories above name, from the top down.
+func ancestors(name string) []string {
+	var dirs []string
+	for dir := path.Dir(name); dir != "/" && dir != "."; dir = path.Dir(dir) {
+		dirs = append(dirs, dir)
+	}
+	slices.Reverse(dirs)
+	return dirs
+}
+
+func newFixtureOS(entries []fixtureEntry, options []MemOSOption) (OS, error) {
+	m := NewMemOS(options...).(*memOS)
+	if err := buildFixture(m, m.cwd, entries); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// NewMapOS creates an in-memory OS, as NewMemOS does, holding the files
+// of the map, which go from names to contents.  Relative names are
+// below the working directory.  Each name may be followed by directives
+// that say what it is:
+//
+//	bin/run mode=0755                    a file with the permissions 0755
+//	bin/latest -> run                    a symbolic link to run
+//	logs/                                an empty directory
+//	logs/ mode=0700                      the same, with the permissions 0700
+//	old.txt mtime=2024-01-02T03:04:05Z   a file last modified then
+//	old.txt mtime=1704164645             the same, in Unix seconds
+//
+// Files are 0644 and directories 0755 unless they say otherwise, and
+// the directories above each name are made as needed.  A symbolic link
+// takes no directives.  A name with spaces is quoted, as in
+// "my notes.txt".
+//
+//	o, err := os.NewMapOS(map[string]string{
+//		"config.json":       `{"debug": true}`,
+//		"bin/run mode=0755": "#!/bin/sh\n",
+//		"bin/latest -> run": "",
+//		"cache/":            "",
+//	})
+func NewMapOS(files map[string]string, options ...MemOSOption) (OS, error) {
+	entries, err := mapFixture(files)
+	if err != nil {
+		return nil, err
+	}
+	return newFixtureOS(entries, options)
+}
+
+// NewTxtarOS creates an in-memory OS, as NewMapOS does, holding the
+// files of a txtar archive.  The directives go inside the file markers,
+// after the names:
+//
+//	-- bin/run mode=0755 --
+//	#!/bin/sh
+//	-- bin/latest -> run --
+//	-- logs/ --
+func NewTxtarOS(archive []byte, options ...MemOSOption) (OS, error) {
+	entries, err := txtarFixture(archive)
+	if err != nil {
+		return nil, err
+	}
+	return newFixtureOS(entries, options)
+}
+
+// fixtureFS is the fs.FS of a fixture.  Besides what a DirFS offers, it
+// has the Lstat and ReadLink of fs.ReadLinkFS, so that its symbolic
+// links can be seen as such.
+type fixtureFS struct {
+	dirFS
+	os *memOS
+}
+
+func (f fixtureFS) Lstat(name string) (stdfs.FileInfo, error) {
+	full, err := f.join("lstat", name)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := f.os.Lstat(full)
+	if err != nil {
+		return nil, f.rename(err, name)
+	}
+	return fsFileInfo{FileInfo: fi}, nil
+}
+
+func (f fixtureFS) ReadLink(name string) (string, error) {
+	full, err := f.join("readlink", name)
+	if err != nil {
+		return "", err
+	}
+
+	target, err := f.os.Readlink(full)
+	if err != nil {
+		return "", f.rename(err, name)
+	}
+	return target, nil
+}
+
+func newFixtureFS(entries []fixtureEntry) (fs.FS, error) {
+	for _, e := range entries {
+		if !stdfs.ValidPath(e.name) {
+			return nil, &PathError{Op: "fixture", Path: e.name, Err: ErrInvalid}
+		}
+	}
+
+	m := newMemOS()
+	m.cwd = "/"
+	if err := buildFixture(m, "/", entries); err != nil {
+		return nil, err
+	}
+	return fixtureFS{dirFS: newDirFS(m, "/"), os: m}, nil
+}
+
+// NewMapFS creates an fs.FS holding the files of the map, with the
+// directives of NewMapOS.  Names are relative, as fs.FS names are.  The
+// FS keeps its files in memory, as NewMemOS does, and also implements
+// fs.ReadDirFS, fs.ReadFileFS, fs.StatFS and, from Go 1.25,
+// fs.ReadLinkFS.  Its FileInfo and DirEntry values have the methods of
+// this library's, such as Owner and Inode, and an Unwrap method that
+// returns them as such.
+func NewMapFS(files map[string]string) (fs.FS, error) {
+	entries, err := mapFixture(files)
+	if err != nil {
+		return nil, err
+	}
+	return newFixtureFS(entries)
+}
+
+// NewTxtarFS creates an fs.FS, as NewMapFS does, holding the files of a
+// txtar archive, with the directives of NewTxtarOS.
+func NewTxtarFS(archive []byte) (fs.FS, error) {
+	entries, err := txtarFixture(archive)
+	if err != nil {
+		return nil, err
+	}
+	return newFixtureFS(entries)
+}
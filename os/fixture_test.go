@@ -0,0 +1,256 @@
+package os
+
+import (
+	"errors"
+	stdfs "io/fs"
+	"path"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/pdutton/go-interfaces/io/fs"
+)
+
+func TestNewMapOS(t *testing.T) {
+	o, err := NewMapOS(map[string]string{
+		"config.json":       `{"debug": true}`,
+		"bin/run mode=0755": "#!/bin/sh\n",
+		"bin/latest -> run": "",
+		"cache/ mode=0500":  "",
+		`"my notes.txt" mtime=2024-01-02T03:04:05Z`: "notes",
+		"/etc/hosts mtime=1704164645":               "127.0.0.1 localhost\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if data, err := o.ReadFile("config.json"); err != nil || string(data) != `{"debug": true}` {
+		t.Errorf("ReadFile(config.json) = %q, %v", data, err)
+	}
+	if data, err := o.ReadFile("/home/gopher/bin/latest"); err != nil || string(data) != "#!/bin/sh\n" {
+		t.Errorf("ReadFile() through link = %q, %v", data, err)
+	}
+	if target, err := o.Readlink("bin/latest"); err != nil || target != "run" {
+		t.Errorf("Readlink() = %q, %v, want run", target, err)
+	}
+
+	for name, want := range map[string]FSFileMode{
+		"bin/run":      0755,
+		"bin":          stdfs.ModeDir | 0755,
+		"cache":        stdfs.ModeDir | 0500,
+		"config.json":  0644,
+		"my notes.txt": 0644,
+	} {
+		fi, err := o.Lstat(name)
+		if err != nil {
+			t.Errorf("Lstat(%s) error = %v", name, err)
+			continue
+		}
+		if got := fi.Nub().Mode(); got != want {
+			t.Errorf("Lstat(%s).Mode() = %v, want %v", name, got, want)
+		}
+	}
+
+	when := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, name := range []string{"my notes.txt", "/etc/hosts"} {
+		if fi, err := o.Stat(name); err != nil || !fi.ModTime().Equal(when) {
+			t.Errorf("Stat(%s).ModTime() = %v, %v, want %v", name, fi.ModTime(), err, when)
+		}
+	}
+}
+
+func TestNewTxtarOS(t *testing.T) {
+	o, err := NewTxtarOS([]byte(`A fixture.
+-- /srv/app/main.sh mode=0700 --
+echo hi
+-- /srv/app/current -> main.sh --
+-- /srv/app/logs/ --
+-- /srv/app mtime=0 --
+`))
+	if err == nil {
+		t.Fatal("NewTxtarOS() of a directory with contents error = nil")
+	}
+
+	o, err = NewTxtarOS([]byte(`-- /srv/app/main.sh mode=0700 --
+echo hi
+-- /srv/app/current -> main.sh --
+-- /srv/app/ mtime=0 --
+-- /srv/app/logs/ --
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a FileInfo of this library, not of io/fs
+	var fi fs.FileInfo
+	if fi, err = o.Stat("/srv/app"); err != nil || !fi.ModTime().Equal(time.Unix(0, 0)) {
+		t.Errorf("Stat(/srv/app) = %v, %v, want mtime 0", fi, err)
+	}
+
+	entries, err := o.ReadDir("/srv/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if len(names) != 3 || names[0] != "current" || names[1] != "logs" || names[2] != "main.sh" {
+		t.Errorf("ReadDir() = %v", names)
+	}
+}
+
+func TestNewMapFS(t *testing.T) {
+	fsys, err := NewMapFS(map[string]string{
+		"a/b.txt":         "b",
+		"a/c mode=0600":   "c",
+		"a/link -> b.txt": "",
+		"empty/":          "",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fstest.TestFS(fsys, "a/b.txt", "a/c", "a/link", "empty"); err != nil {
+		t.Error(err)
+	}
+
+	lfs := fsys.(interface {
+		Lstat(string) (stdfs.FileInfo, error)
+		ReadLink(string) (string, error)
+	})
+	if fi, err := lfs.Lstat("a/link"); err != nil || fi.Mode()&stdfs.ModeSymlink == 0 {
+		t.Errorf("Lstat(a/link) = %v, %v, want a link", fi, err)
+	}
+	if target, err := lfs.ReadLink("a/link"); err != nil || target != "b.txt" {
+		t.Errorf("ReadLink(a/link) = %q, %v", target, err)
+	}
+
+	fi, err := lfs.Lstat("a/link")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkWrappedInfo(t, "Lstat(a/link)", fi)
+	checkWrapped(t, fsys, "a/b.txt")
+
+	if _, err := NewMapFS(map[string]string{"/abs": ""}); err == nil {
+		t.Error("NewMapFS() of absolute name error = nil")
+	}
+}
+
+func TestNewTxtarFS(t *testing.T) {
+	fsys, err := NewTxtarFS([]byte("-- x --\nhello\n-- d/y mode=0400 --\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if data, err := stdfs.ReadFile(fsys, "x"); err != nil || string(data) != "hello\n" {
+		t.Errorf("ReadFile(x) = %q, %v", data, err)
+	}
+	if fi, err := stdfs.Stat(fsys, "d/y"); err != nil || fi.Mode() != 0400 {
+		t.Errorf("Stat(d/y) = %v, %v, want mode 0400", fi, err)
+	}
+
+	checkWrapped(t, fsys, "d/y")
+}
+
+// checkWrapped checks that what fsys returns for name and its directory
+// are the FileInfo and DirEntry types of this library.
+func checkWrapped(t *testing.T, fsys stdfs.FS, name string) {
+	t.Helper()
+
+	fi, err := stdfs.Stat(fsys, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkWrappedInfo(t, "Stat("+name+")", fi)
+
+	f, err := fsys.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if fi, err = f.Stat(); err != nil {
+		t.Fatal(err)
+	}
+	checkWrappedInfo(t, "File.Stat", fi)
+
+	entries, err := stdfs.ReadDir(fsys, path.Dir(name))
+	if err != nil || len(entries) == 0 {
+		t.Fatalf("ReadDir(%s) = %v, %v", path.Dir(name), entries, err)
+	}
+	for _, e := range entries {
+		de, ok := e.(interface{ Unwrap() DirEntry })
+		if !ok {
+			t.Fatalf("ReadDir(%s) entry is a %T, not a DirEntry of this library", path.Dir(name), e)
+		}
+		if de.Unwrap().Name() != e.Name() {
+			t.Errorf("Unwrap().Name() = %q, want %q", de.Unwrap().Name(), e.Name())
+		}
+		fi, err := e.Info()
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkWrappedInfo(t, "DirEntry.Info", fi)
+	}
+}
+
+func checkWrappedInfo(t *testing.T, what string, fi stdfs.FileInfo) {
+	t.Helper()
+
+	wrapped, ok := fi.(interface{ Unwrap() FileInfo })
+	if !ok {
+		t.Fatalf("%s is a %T, not a FileInfo of this library", what, fi)
+	}
+	if got := wrapped.Unwrap().Mode().Nub(); got != fi.Mode() {
+		t.Errorf("%s: Unwrap().Mode() = %v, want %v", what, got, fi.Mode())
+	}
+	if _, ok := wrapped.Unwrap().Owner(); !ok {
+		t.Errorf("%s: Unwrap().Owner() is not known", what)
+	}
+}
+
+func TestFixture_BadDirectives(t *testing.T) {
+	for _, name := range []string{
+		"x mode=999",
+		"x mtime=yesterday",
+		"x owner=root",
+		"dir/ -> x",
+		"x -> ",
+		"l -> secret mode=0755",
+		`l -> "my file" mtime=0`,
+	} {
+		if _, err := NewMapOS(map[string]string{name: ""}); err == nil {
+			t.Errorf("NewMapOS(%q) error = nil", name)
+		}
+	}
+}
+
+func TestFixture_ModeZero(t *testing.T) {
+	o, err := NewMapOS(map[string]string{
+		"secret mode=0000": "x",
+		"d/ mode=0":        "",
+		"l -> \"my file\"": "",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]FSFileMode{
+		"secret": 0,
+		"d":      stdfs.ModeDir,
+	} {
+		if fi, err := o.Lstat(name); err != nil || fi.Nub().Mode() != want {
+			t.Errorf("Lstat(%s) = %v, %v, want mode %v", name, fi, err, want)
+		}
+	}
+	if _, err := o.ReadFile("secret"); !errors.Is(err, ErrPermission) {
+		t.Errorf("ReadFile(secret) error = %v, want ErrPermission", err)
+	}
+	if _, err := o.ReadDir("d"); !errors.Is(err, ErrPermission) {
+		t.Errorf("ReadDir(d) error = %v, want ErrPermission", err)
+	}
+	if target, err := o.Readlink("l"); err != nil || target != "my file" {
+		t.Errorf("Readlink(l) = %q, %v, want %q", target, err, "my file")
+	}
+}
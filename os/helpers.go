@@ -0,0 +1,362 @@
+package os
+
+import (
+	"errors"
+	"io"
+	stdfs "io/fs"
+	"math/rand/v2"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// The functions in this file implement the composite operations of
+// the os package (MkdirAll, RemoveAll, ReadFile, ...) in terms of the
+// primitive methods of an OS, so that every implementation of OS other
+// than the facade behaves the same way the standard library does.
+
+var (
+	errPathEscapes         = errors.New("path escapes from parent")
+	errPatternHasSeparator = errors.New("pattern contains path separator")
+	errWriteAtInAppendMode = errors.New("os: invalid use of WriteAt on file opened with O_APPEND")
+	errNegativeOffset      = errors.New("negative offset")
+)
+
+// dirMaker, treeRemover and fileWriter are the parts of OS and Root
+// that mkdirAll, removeAll and writeFile need, so that they serve both.
+type dirMaker interface {
+	Lstat(string) (FileInfo, error)
+	Mkdir(string, FSFileMode) error
+	Stat(string) (FileInfo, error)
+}
+
+type treeRemover interface {
+	Lstat(string) (FileInfo, error)
+	Open(string) (File, error)
+	Remove(string) error
+}
+
+type fileWriter interface {
+	OpenFile(string, int, FSFileMode) (File, error)
+}
+
+// mkdirAll mirrors os.MkdirAll.
+func mkdirAll(fsys dirMaker, name string, perm FSFileMode) error {
+	fi, err := fsys.Stat(name)
+	if err == nil {
+		if fi.IsDir() {
+			return nil
+		}
+		return &PathError{Op: "mkdir", Path: name, Err: syscall.ENOTDIR}
+	}
+
+	// Slow path: make sure parent exists and then call Mkdir for name.
+	i := len(name)
+	for i > 0 && name[i-1] == '/' {
+		i--
+	}
+	j := i
+	for j > 0 && name[j-1] != '/' {
+		j--
+	}
+	if j > 1 {
+		if err := mkdirAll(fsys, name[:j-1], perm); err != nil {
+			return err
+		}
+	}
+
+	err = fsys.Mkdir(name, perm)
+	if err != nil {
+		// Handle arguments like "foo/." by double-checking that
+		// directory doesn't exist.
+		dir, err1 := fsys.Lstat(name)
+		if err1 == nil && dir.IsDir() {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// removeAll mirrors os.RemoveAll.
+func removeAll(fsys treeRemover, name string) error {
+	if name == "" {
+		return nil
+	}
+	if name == "." || strings.HasSuffix(name, "/.") {
+		return &PathError{Op: "RemoveAll", Path: name, Err: syscall.EINVAL}
+	}
+
+	err := fsys.Remove(name)
+	if err == nil || errors.Is(err, ErrNotExist) {
+		return nil
+	}
+
+	fi, serr := fsys.Lstat(name)
+	if serr != nil {
+		if errors.Is(serr, ErrNotExist) {
+			return nil
+		}
+		return serr
+	}
+	if !fi.IsDir() {
+		return err
+	}
+
+	dir, err := fsys.Open(name)
+	if err != nil {
+		if errors.Is(err, ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	names, err := dir.Readdirnames(-1)
+	dir.Close()
+	if err != nil {
+		return err
+	}
+
+	var firstErr error
+	for _, n := range names {
+		if err := removeAll(fsys, name+"/"+n); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	err = fsys.Remove(name)
+	if err == nil || errors.Is(err, ErrNotExist) {
+		return nil
+	}
+	if firstErr != nil {
+		return firstErr
+	}
+	return err
+}
+
+// readFile mirrors os.ReadFile.
+func readFile(fsys fileOpener, name string) ([]byte, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return io.ReadAll(f)
+}
+
+// writeFile mirrors os.WriteFile.
+func writeFile(fsys fileWriter, name string, data []byte, perm FSFileMode) error {
+	f, err := fsys.OpenFile(name, O_WRONLY|O_CREATE|O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err1 := f.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	return err
+}
+
+func nextRandom() string {
+	return strconv.FormatUint(uint64(rand.Uint32()), 10)
+}
+
+// prefixAndSuffix splits pattern by the last wildcard "*", if applicable,
+// returning prefix as the part before "*" and suffix as the part after "*".
+func prefixAndSuffix(pattern string) (prefix, suffix string, err error) {
+	if strings.ContainsRune(pattern, '/') {
+		return "", "", errPatternHasSeparator
+	}
+	if pos := strings.LastIndexByte(pattern, '*'); pos != -1 {
+		prefix, suffix = pattern[:pos], pattern[pos+1:]
+	} else {
+		prefix = pattern
+	}
+	return prefix, suffix, nil
+}
+
+func joinTempName(dir, name string) string {
+	if len(dir) > 0 && dir[len(dir)-1] == '/' {
+		return dir + name
+	}
+	return dir + "/" + name
+}
+
+// createTemp mirrors os.CreateTemp.
+func createTemp(fsys OS, dir, pattern string) (File, error) {
+	if dir == "" {
+		dir = fsys.TempDir()
+	}
+
+	prefix, suffix, err := prefixAndSuffix(pattern)
+	if err != nil {
+		return nil, &PathError{Op: "createtemp", Path: pattern, Err: err}
+	}
+	prefix = joinTempName(dir, prefix)
+
+	try := 0
+	for {
+		name := prefix + nextRandom() + suffix
+		f, err := fsys.OpenFile(name, O_RDWR|O_CREATE|O_EXCL, 0600)
+		if errors.Is(err, ErrExist) {
+			if try++; try < 10000 {
+				continue
+			}
+			return nil, &PathError{Op: "createtemp", Path: prefix + "*" + suffix, Err: ErrExist}
+		}
+		return f, err
+	}
+}
+
+// mkdirTemp mirrors os.MkdirTemp.
+func mkdirTemp(fsys OS, dir, pattern string) (string, error) {
+	if dir == "" {
+		dir = fsys.TempDir()
+	}
+
+	prefix, suffix, err := prefixAndSuffix(pattern)
+	if err != nil {
+		return "", &PathError{Op: "mkdirtemp", Path: pattern, Err: err}
+	}
+	prefix = joinTempName(dir, prefix)
+
+	try := 0
+	for {
+		name := prefix + nextRandom() + suffix
+		err := fsys.Mkdir(name, 0700)
+		if err == nil {
+			return name, nil
+		}
+		if errors.Is(err, ErrExist) {
+			if try++; try < 10000 {
+				continue
+			}
+			return "", &PathError{Op: "mkdirtemp", Path: prefix + "*" + suffix, Err: ErrExist}
+		}
+		if errors.Is(err, ErrNotExist) {
+			if _, err := fsys.Stat(dir); errors.Is(err, ErrNotExist) {
+				return "", err
+			}
+		}
+		return "", err
+	}
+}
+
+// copyFS mirrors os.CopyFS.
+func copyFS(fsys OS, dir string, src stdfs.FS) error {
+	return stdfs.WalkDir(src, ".", func(name string, d stdfs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !stdfs.ValidPath(name) {
+			return &PathError{Op: "CopyFS", Path: name, Err: ErrInvalid}
+		}
+		newPath := path.Join(dir, name)
+
+		switch d.Type() {
+		case stdfs.ModeDir:
+			return fsys.MkdirAll(newPath, 0777)
+		case stdfs.ModeSymlink:
+			return &PathError{Op: "CopyFS", Path: name, Err: ErrInvalid}
+		case 0:
+			r, err := src.Open(name)
+			if err != nil {
+				return err
+			}
+			defer r.Close()
+			info, err := r.Stat()
+			if err != nil {
+				return err
+			}
+			w, err := fsys.OpenFile(newPath, O_CREATE|O_EXCL|O_WRONLY, 0666|info.Mode()&0777)
+			if err != nil {
+				return err
+			}
+
+			if _, err := io.Copy(w, r); err != nil {
+				w.Close()
+				return &PathError{Op: "Copy", Path: newPath, Err: err}
+			}
+			return w.Close()
+		default:
+			return &PathError{Op: "CopyFS", Path: name, Err: ErrInvalid}
+		}
+	})
+}
+
+// openInRoot mirrors os.OpenInRoot.
+func openInRoot(fsys OS, dir, name string) (File, error) {
+	r, err := fsys.OpenRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return r.Open(name)
+}
+
+// tempDir mirrors os.TempDir on Unix.
+func tempDir(getenv func(string) string) string {
+	dir := getenv("TMPDIR")
+	if dir == "" {
+		dir = "/tmp"
+	}
+	return dir
+}
+
+// userCacheDir mirrors os.UserCacheDir on Linux.
+func userCacheDir(getenv func(string) string) (string, error) {
+	dir := getenv("XDG_CACHE_HOME")
+	if dir == "" {
+		dir = getenv("HOME")
+		if dir == "" {
+			return "", errors.New("neither $XDG_CACHE_HOME nor $HOME are defined")
+		}
+		dir += "/.cache"
+	} else if !path.IsAbs(dir) {
+		return "", errors.New("path in $XDG_CACHE_HOME is relative")
+	}
+	return dir, nil
+}
+
+// userConfigDir mirrors os.UserConfigDir on Linux.
+func userConfigDir(getenv func(string) string) (string, error) {
+	dir := getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		dir = getenv("HOME")
+		if dir == "" {
+			return "", errors.New("neither $XDG_CONFIG_HOME nor $HOME are defined")
+		}
+		dir += "/.config"
+	} else if !path.IsAbs(dir) {
+		return "", errors.New("path in $XDG_CONFIG_HOME is relative")
+	}
+	return dir, nil
+}
+
+// userHomeDir mirrors os.UserHomeDir on Unix.
+func userHomeDir(getenv func(string) string) (string, error) {
+	v := getenv("HOME")
+	if v == "" {
+		return "", errors.New("$HOME is not defined")
+	}
+	return v, nil
+}
+
+// readDir mirrors os.ReadDir.
+func readDir(fsys OS, name string) ([]DirEntry, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	dirs, err := f.ReadDir(-1)
+	slices.SortFunc(dirs, func(a, b DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+	return dirs, err
+}
@@ -0,0 +1,460 @@
+package os
+
+import (
+	"path"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pdutton/go-interfaces/io/fs"
+)
+
+// JailOS is an OS confined to a directory of another OS.  Inside the
+// jail that directory is "/", and every name is resolved the way an
+// os.Root resolves it, so names that leave the directory through ".."
+// or a symbolic link fail with an error instead.  Symbolic links with
+// absolute targets cannot be followed at all.
+//
+// The environment, ids and the like are passed through to the base,
+// but nothing that names a place outside the jail: the user's home
+// directory is /home inside it, with /home/.cache and /home/.config
+// for UserCacheDir and UserConfigDir, TempDir is /tmp, and Executable
+// fails, as the executable is not in the jail.  Nor can a JailOS find
+// or start processes.
+type JailOS interface {
+	OS
+
+	// Close releases the directory, after which the file system of the
+	// jail can no longer be used
+	Close() error
+}
+
+type jailOS struct {
+	base OS
+	root Root
+
+	// ops is root, or before Go 1.25 a Root that resolves names for
+	// the operations os.Root lacks
+	ops   rootGo125Methods
+	paths Root
+
+	mu  sync.Mutex
+	cwd string
+}
+
+// NewJailOS creates an OS confined to the directory dir of base, whose
+// working directory starts out as the root of the jail.  Names are
+// resolved through base.OpenRoot, so with the OS returned by NewOS it
+// is the kernel that keeps them inside dir.  Before Go 1.25 os.Root
+// lacks operations such as Rename and Chmod, which then check each
+// element of the name with Lstat and Readlink before passing it to
+// base.
+func NewJailOS(base OS, dir string) (JailOS, error) {
+	root, err := base.OpenRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	paths, err := newPathRoot(base, dir)
+	if err != nil {
+		root.Close()
+		return nil, err
+	}
+
+	ops, ok := root.(rootGo125Methods)
+	if !ok {
+		ops = paths.(rootGo125Methods)
+	}
+
+	return &jailOS{
+		base:  base,
+		root:  root,
+		ops:   ops,
+		paths: paths,
+		cwd:   "/",
+	}, nil
+}
+
+// rel turns a name in the jail into a name relative to its root.  It
+// does not clean the name, so that the Root can reject a ".." that
+// would leave it.
+func (o *jailOS) rel(name string) string {
+	if name == "" {
+		return ""
+	}
+
+	if !path.IsAbs(name) {
+		o.mu.Lock()
+		name = o.cwd + "/" + name
+		o.mu.Unlock()
+	}
+
+	name = strings.TrimLeft(name, "/")
+	if name == "" {
+		return "."
+	}
+	return name
+}
+
+// rename reports an error of the Root in terms of the name given to
+// the jail, as the os package would.
+func (o *jailOS) rename(op, name string, err error) error {
+	return &PathError{Op: op, Path: name, Err: underlyingError(err)}
+}
+
+func (o *jailOS) renameLink(op, oldname, newname string, err error) error {
+	return &LinkError{Op: op, Old: oldname, New: newname, Err: underlyingError(err)}
+}
+
+func (o *jailOS) Close() error {
+	o.paths.Close()
+	return o.root.Close()
+}
+
+func (o *jailOS) Stdin() File {
+	return o.base.Stdin()
+}
+
+func (o *jailOS) Stderr() File {
+	return o.base.Stderr()
+}
+
+func (o *jailOS) Stdout() File {
+	return o.base.Stdout()
+}
+
+func (o *jailOS) Args() []string {
+	return o.base.Args()
+}
+
+func (o *jailOS) Chdir(dir string) error {
+	rel := o.rel(dir)
+
+	fi, err := o.root.Stat(rel)
+	if err == nil && !fi.IsDir() {
+		err = syscall.ENOTDIR
+	}
+	if err != nil {
+		return o.rename("chdir", dir, err)
+	}
+
+	o.mu.Lock()
+	o.cwd = path.Clean("/" + rel)
+	o.mu.Unlock()
+	return nil
+}
+
+func (o *jailOS) Chmod(name string, mode FSFileMode) error {
+	if err := o.ops.Chmod(o.rel(name), mode); err != nil {
+		return o.rename("chmod", name, err)
+	}
+	return nil
+}
+
+func (o *jailOS) Chown(name string, uid, gid int) error {
+	if err := o.ops.Chown(o.rel(name), uid, gid); err != nil {
+		return o.rename("chown", name, err)
+	}
+	return nil
+}
+
+func (o *jailOS) Chtimes(name string, atime, mtime time.Time) error {
+	if err := o.ops.Chtimes(o.rel(name), atime, mtime); err != nil {
+		return o.rename("chtimes", name, err)
+	}
+	return nil
+}
+
+func (o *jailOS) Clearenv() {
+	o.base.Clearenv()
+}
+
+func (o *jailOS) CopyFS(dir string, fsys fs.FS) error {
+	return copyFS(o, dir, fsys)
+}
+
+func (o *jailOS) DirFS(dir string) fs.FS {
+	return newDirFS(o, dir)
+}
+
+func (o *jailOS) Environ() []string {
+	return o.base.Environ()
+}
+
+func (o *jailOS) Executable() (string, error) {
+	return "", &SyscallError{Syscall: "executable", Err: syscall.ENOENT}
+}
+
+func (o *jailOS) Exit(code int) {
+	o.base.Exit(code)
+}
+
+func (o *jailOS) Expand(s string, mapping func(string) string) string {
+	return o.base.Expand(s, mapping)
+}
+
+func (o *jailOS) ExpandEnv(s string) string {
+	return o.base.ExpandEnv(s)
+}
+
+func (o *jailOS) Getegid() int {
+	return o.base.Getegid()
+}
+
+func (o *jailOS) Getenv(key string) string {
+	return o.base.Getenv(key)
+}
+
+func (o *jailOS) Geteuid() int {
+	return o.base.Geteuid()
+}
+
+func (o *jailOS) Getgid() int {
+	return o.base.Getgid()
+}
+
+func (o *jailOS) Getgroups() ([]int, error) {
+	return o.base.Getgroups()
+}
+
+func (o *jailOS) Getpagesize() int {
+	return o.base.Getpagesize()
+}
+
+func (o *jailOS) Getpid() int {
+	return o.base.Getpid()
+}
+
+func (o *jailOS) Getppid() int {
+	return o.base.Getppid()
+}
+
+func (o *jailOS) Getuid() int {
+	return o.base.Getuid()
+}
+
+// Getwd returns the working directory inside the jail.
+func (o *jailOS) Getwd() (string, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.cwd, nil
+}
+
+func (o *jailOS) Hostname() (string, error) {
+	return o.base.Hostname()
+}
+
+func (o *jailOS) IsExist(err error) bool {
+	return o.base.IsExist(err)
+}
+
+func (o *jailOS) IsNotExist(err error) bool {
+	return o.base.IsNotExist(err)
+}
+
+func (o *jailOS) IsPathSeparator(c uint8) bool {
+	return o.base.IsPathSeparator(c)
+}
+
+func (o *jailOS) IsPermission(err error) bool {
+	return o.base.IsPermission(err)
+}
+
+func (o *jailOS) IsTimeout(err error) bool {
+	return o.base.IsTimeout(err)
+}
+
+func (o *jailOS) Lchown(name string, uid, gid int) error {
+	if err := o.ops.Lchown(o.rel(name), uid, gid); err != nil {
+		return o.rename("lchown", name, err)
+	}
+	return nil
+}
+
+func (o *jailOS) Link(oldname, newname string) error {
+	if err := o.ops.Link(o.rel(oldname), o.rel(newname)); err != nil {
+		return o.renameLink("link", oldname, newname, err)
+	}
+	return nil
+}
+
+func (o *jailOS) LookupEnv(key string) (string, bool) {
+	return o.base.LookupEnv(key)
+}
+
+func (o *jailOS) Mkdir(name string, perm FSFileMode) error {
+	if err := o.root.Mkdir(o.rel(name), perm); err != nil {
+		return o.rename("mkdir", name, err)
+	}
+	return nil
+}
+
+func (o *jailOS) MkdirAll(name string, perm FSFileMode) error {
+	return mkdirAll(o, name, perm)
+}
+
+func (o *jailOS) MkdirTemp(dir, pattern string) (string, error) {
+	return mkdirTemp(o, dir, pattern)
+}
+
+func (o *jailOS) NewSyscallError(syscall string, err error) error {
+	return o.base.NewSyscallError(syscall, err)
+}
+
+func (o *jailOS) Pipe() (File, File, error) {
+	return o.base.Pipe()
+}
+
+func (o *jailOS) ReadFile(name string) ([]byte, error) {
+	return readFile(o, name)
+}
+
+func (o *jailOS) Readlink(name string) (string, error) {
+	target, err := o.ops.Readlink(o.rel(name))
+	if err != nil {
+		return "", o.rename("readlink", name, err)
+	}
+	return target, nil
+}
+
+func (o *jailOS) Remove(name string) error {
+	if err := o.root.Remove(o.rel(name)); err != nil {
+		return o.rename("remove", name, err)
+	}
+	return nil
+}
+
+func (o *jailOS) RemoveAll(name string) error {
+	return removeAll(o, name)
+}
+
+func (o *jailOS) Rename(oldpath, newpath string) error {
+	if err := o.ops.Rename(o.rel(oldpath), o.rel(newpath)); err != nil {
+		return o.renameLink("rename", oldpath, newpath, err)
+	}
+	return nil
+}
+
+func (o *jailOS) SameFile(fi1, fi2 FileInfo) bool {
+	return o.base.SameFile(fi1, fi2)
+}
+
+func (o *jailOS) Setenv(key, value string) error {
+	return o.base.Setenv(key, value)
+}
+
+// Symlink creates newname with oldname as its target, unchanged.  A
+// target that would leave the jail can be created, but not followed.
+func (o *jailOS) Symlink(oldname, newname string) error {
+	if err := o.ops.Symlink(oldname, o.rel(newname)); err != nil {
+		return o.renameLink("symlink", oldname, newname, err)
+	}
+	return nil
+}
+
+// TempDir returns /tmp inside the jail.  $TMPDIR names a directory of
+// the host, which is not in the jail, so it is not looked at.
+func (o *jailOS) TempDir() string {
+	return "/tmp"
+}
+
+func (o *jailOS) Truncate(name string, size int64) error {
+	f, err := o.root.OpenFile(o.rel(name), O_WRONLY, 0)
+	if err != nil {
+		return o.rename("truncate", name, err)
+	}
+	defer f.Close()
+
+	if err := f.Truncate(size); err != nil {
+		return o.rename("truncate", name, err)
+	}
+	return nil
+}
+
+func (o *jailOS) Unsetenv(key string) error {
+	return o.base.Unsetenv(key)
+}
+
+func (o *jailOS) UserCacheDir() (string, error) {
+	return "/home/.cache", nil
+}
+
+func (o *jailOS) UserConfigDir() (string, error) {
+	return "/home/.config", nil
+}
+
+func (o *jailOS) UserHomeDir() (string, error) {
+	return "/home", nil
+}
+
+func (o *jailOS) WriteFile(name string, data []byte, perm FSFileMode) error {
+	return writeFile(o, name, data, perm)
+}
+
+func (o *jailOS) ReadDir(name string) ([]DirEntry, error) {
+	return readDir(o, name)
+}
+
+func (o *jailOS) Create(name string) (File, error) {
+	return o.OpenFile(name, O_RDWR|O_CREATE|O_TRUNC, 0666)
+}
+
+func (o *jailOS) CreateTemp(dir, pattern string) (File, error) {
+	return createTemp(o, dir, pattern)
+}
+
+func (o *jailOS) NewFile(fd uintptr, name string) File {
+	return o.base.NewFile(fd, name)
+}
+
+func (o *jailOS) Open(name string) (File, error) {
+	return o.OpenFile(name, O_RDONLY, 0)
+}
+
+// OpenFile opens name through the Root.  The File is named after name,
+// rather than the path of the base it was found at.
+func (o *jailOS) OpenFile(name string, flag int, perm FSFileMode) (File, error) {
+	f, err := o.root.OpenFile(o.rel(name), flag, perm)
+	if err != nil {
+		return nil, o.rename("open", name, err)
+	}
+	return rootFile{File: f, name: name}, nil
+}
+
+func (o *jailOS) OpenInRoot(dir, name string) (File, error) {
+	return openInRoot(o, dir, name)
+}
+
+func (o *jailOS) Lstat(name string) (FileInfo, error) {
+	fi, err := o.root.Lstat(o.rel(name))
+	if err != nil {
+		return nil, o.rename("lstat", name, err)
+	}
+	return fi, nil
+}
+
+func (o *jailOS) Stat(name string) (FileInfo, error) {
+	fi, err := o.root.Stat(o.rel(name))
+	if err != nil {
+		return nil, o.rename("stat", name, err)
+	}
+	return fi, nil
+}
+
+func (o *jailOS) FindProcess(pid int) (Process, error) {
+	return nil, &SyscallError{Syscall: "findprocess", Err: syscall.EPERM}
+}
+
+func (o *jailOS) StartProcess(name string, argv []string, attr *ProcAttr) (Process, error) {
+	return nil, &PathError{Op: "fork/exec", Path: name, Err: syscall.EPERM}
+}
+
+func (o *jailOS) OpenRoot(name string) (Root, error) {
+	r, err := o.root.OpenRoot(o.rel(name))
+	if err != nil {
+		return nil, o.rename("open", name, err)
+	}
+	return r, nil
+}
@@ -0,0 +1,151 @@
+package os
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+)
+
+func TestNewJailOS(t *testing.T) {
+	j, err := NewJailOS(NewMemOS(), "/tmp")
+	if err != nil {
+		t.Fatalf("NewJailOS() error = %v", err)
+	}
+	defer j.Close()
+
+	var _ OS = j
+}
+
+func TestJailOS_Dirs(t *testing.T) {
+	base := NewMemOS()
+	base.Setenv("TMPDIR", "/var/tmp")
+	if err := base.MkdirAll("/tmp/jail/tmp", 0755); err != nil {
+		t.Fatal(err)
+	}
+	j, err := NewJailOS(base, "/tmp/jail")
+	if err != nil {
+		t.Fatalf("NewJailOS() error = %v", err)
+	}
+	defer j.Close()
+
+	if dir := j.TempDir(); dir != "/tmp" {
+		t.Errorf("TempDir() = %q, want %q", dir, "/tmp")
+	}
+	for _, tt := range []struct {
+		name string
+		fn   func() (string, error)
+		want string
+	}{
+		{"UserHomeDir", j.UserHomeDir, "/home"},
+		{"UserCacheDir", j.UserCacheDir, "/home/.cache"},
+		{"UserConfigDir", j.UserConfigDir, "/home/.config"},
+	} {
+		if dir, err := tt.fn(); err != nil || dir != tt.want {
+			t.Errorf("%s() = %q, %v, want %q", tt.name, dir, err, tt.want)
+		}
+	}
+	if exe, err := j.Executable(); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Executable() = %q, %v, want ErrNotExist", exe, err)
+	}
+	f, err := j.CreateTemp("", "x")
+	if err != nil {
+		t.Fatalf("CreateTemp() error = %v", err)
+	}
+	defer f.Close()
+	if _, err := base.Stat("/tmp/jail" + f.Name()); err != nil {
+		t.Errorf("CreateTemp() made %s outside the jail's /tmp: %v", f.Name(), err)
+	}
+}
+
+func TestJailOS(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		base OS
+		dir  func(*testing.T) string
+	}{
+		{"mem", NewMemOS(), func(*testing.T) string { return "/tmp" }},
+		{"os", NewOS(), func(t *testing.T) string { return t.TempDir() }},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := tt.dir(t) + "/jail"
+			if err := tt.base.Mkdir(dir, 0755); err != nil {
+				t.Fatalf("Mkdir() error = %v", err)
+			}
+			if err := tt.base.WriteFile(dir+"/../outside", []byte("secret"), 0644); err != nil {
+				t.Fatalf("WriteFile() outside error = %v", err)
+			}
+
+			j, err := NewJailOS(tt.base, dir)
+			if err != nil {
+				t.Fatalf("NewJailOS() error = %v", err)
+			}
+			defer j.Close()
+
+			if err := j.MkdirAll("/a/b", 0755); err != nil {
+				t.Fatalf("MkdirAll() error = %v", err)
+			}
+			if err := j.WriteFile("/a/b/f", []byte("data"), 0644); err != nil {
+				t.Fatalf("WriteFile() error = %v", err)
+			}
+			if data, err := tt.base.ReadFile(dir + "/a/b/f"); err != nil || string(data) != "data" {
+				t.Errorf("base ReadFile() = %q, %v, want %q", data, err, "data")
+			}
+
+			if err := j.Chdir("a"); err != nil {
+				t.Fatalf("Chdir() error = %v", err)
+			}
+			if wd, _ := j.Getwd(); wd != "/a" {
+				t.Errorf("Getwd() = %q, want %q", wd, "/a")
+			}
+			if data, err := j.ReadFile("b/../b/f"); err != nil || string(data) != "data" {
+				t.Errorf("ReadFile() = %q, %v, want %q", data, err, "data")
+			}
+
+			if err := j.Rename("b/f", "/g"); err != nil {
+				t.Errorf("Rename() error = %v", err)
+			}
+			if err := j.Symlink("g", "/link"); err != nil {
+				t.Errorf("Symlink() error = %v", err)
+			}
+			if target, err := j.Readlink("/link"); err != nil || target != "g" {
+				t.Errorf("Readlink() = %q, %v, want %q", target, err, "g")
+			}
+			f, err := j.Open("/link")
+			if err != nil {
+				t.Fatalf("Open() through symlink error = %v", err)
+			}
+			if f.Name() != "/link" {
+				t.Errorf("Name() = %q, want %q", f.Name(), "/link")
+			}
+			f.Close()
+
+			for _, name := range []string{"../../outside", "/../outside"} {
+				_, err := j.ReadFile(name)
+				if err == nil {
+					t.Errorf("ReadFile(%q) succeeded", name)
+				}
+			}
+			j.Symlink("..", "/up")
+			j.Symlink(dir+"/../outside", "/abs")
+			for _, name := range []string{"/up/outside", "/abs"} {
+				var pe *PathError
+				if _, err := j.ReadFile(name); !errors.As(err, &pe) || pe.Path != name {
+					t.Errorf("ReadFile(%q) error = %#v, want PathError", name, err)
+				}
+				if err := j.Chmod(name, 0600); err == nil {
+					t.Errorf("Chmod(%q) succeeded", name)
+				}
+			}
+
+			err = j.Rename("/g", "../../escaped")
+			var le *LinkError
+			if !errors.As(err, &le) || le.Op != "rename" || le.Old != "/g" {
+				t.Errorf("Rename() out of jail error = %#v, want LinkError", err)
+			}
+
+			if _, err := j.StartProcess("/bin/true", nil, &ProcAttr{}); !errors.Is(err, syscall.EPERM) {
+				t.Errorf("StartProcess() error = %v, want EPERM", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package os
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+// Locker is an advisory lock on an open file, such as flock(2) takes.
+// The lock belongs to the open file, not to the process: another
+// handle of the same file contends for it, even in the same process,
+// and closing the file releases it.  Being advisory, it only keeps out
+// those who take the lock too.
+type Locker interface {
+	// Lock takes an exclusive lock, waiting until no other handle holds
+	// the lock.
+	Lock() error
+
+	// RLock takes a shared lock, waiting until no other handle holds an
+	// exclusive one.
+	RLock() error
+
+	// TryLock takes an exclusive lock if it can without waiting, and
+	// reports whether it did.
+	TryLock() (bool, error)
+
+	// TryRLock takes a shared lock if it can without waiting, and
+	// reports whether it did.
+	TryRLock() (bool, error)
+
+	// Unlock releases the lock.
+	Unlock() error
+}
+
+// LockerFor returns a Locker for f.  Files from NewOS are locked with
+// flock(2), where there is one, and files from NewMemOS lock each other
+// in memory.  A file that wraps another, as those of NewJailOS,
+// NewFaultOS and NewOverlayOS do, is locked through the file it wraps,
+// which it returns from an Unwrap() File method.  For other files it
+// returns an error wrapping ErrUnsupported.
+func LockerFor(f File) (Locker, error) {
+	for inner := f; ; {
+		switch g := inner.(type) {
+		case Locker:
+			return g, nil
+		case interface{ Nub() *os.File }:
+			return newFlockLocker(g.Nub())
+		case interface{ Unwrap() File }:
+			if inner = g.Unwrap(); inner != nil {
+				continue
+			}
+		}
+		return nil, &PathError{Op: "lock", Path: f.Name(), Err: errors.ErrUnsupported}
+	}
+}
+
+// LockFile opens name through o, creating it if need be, and takes an
+// exclusive lock on it.  It waits up to timeout for the lock, and fails
+// with an error wrapping ErrDeadlineExceeded if it cannot get it; a
+// negative timeout waits for as long as it takes.  Closing the file
+// releases the lock.
+func LockFile(o OS, name string, timeout time.Duration) (File, error) {
+	f, err := o.OpenFile(name, O_RDWR|O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	l, err := LockerFor(f)
+	if err == nil {
+		err = lockWithin(l, timeout)
+	}
+	if err != nil {
+		f.Close()
+		if errors.Is(err, ErrDeadlineExceeded) {
+			err = &PathError{Op: "lock", Path: name, Err: err}
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
+// lockWithin takes an exclusive lock with l, trying again at growing
+// intervals until timeout has passed.
+func lockWithin(l Locker, timeout time.Duration) error {
+	if timeout < 0 {
+		return l.Lock()
+	}
+
+	var (
+		deadline = time.Now().Add(timeout)
+		delay    = time.Millisecond
+	)
+	for {
+		ok, err := l.TryLock()
+		if ok || err != nil {
+			return err
+		}
+
+		left := time.Until(deadline)
+		if left <= 0 {
+			return ErrDeadlineExceeded
+		}
+		time.Sleep(min(delay, left))
+		delay = min(2*delay, 100*time.Millisecond)
+	}
+}
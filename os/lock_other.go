@@ -0,0 +1,12 @@
+//go:build !unix
+
+package os
+
+import (
+	"errors"
+	"os"
+)
+
+func newFlockLocker(f *os.File) (Locker, error) {
+	return nil, &PathError{Op: "flock", Path: f.Name(), Err: errors.ErrUnsupported}
+}
@@ -0,0 +1,201 @@
+package os
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func lockers(t *testing.T, o OS, name string) (Locker, Locker) {
+	t.Helper()
+
+	var ls [2]Locker
+	for i := range ls {
+		f, err := o.OpenFile(name, O_RDWR|O_CREATE, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { f.Close() })
+
+		ls[i], err = LockerFor(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ls[0], ls[1]
+}
+
+func testLocker(t *testing.T, o OS, name string) {
+	a, b := lockers(t, o, name)
+
+	if ok, err := a.TryLock(); !ok || err != nil {
+		t.Fatalf("TryLock() = %v, %v, want true", ok, err)
+	}
+	if ok, err := b.TryRLock(); ok || err != nil {
+		t.Errorf("TryRLock() while locked = %v, %v, want false", ok, err)
+	}
+
+	// a blocking Lock waits for the Unlock
+	var locked = make(chan error)
+	go func() {
+		locked <- b.Lock()
+	}()
+	select {
+	case err := <-locked:
+		t.Fatalf("Lock() returned %v while another handle held the lock", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	if err := a.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-locked; err != nil {
+		t.Fatal(err)
+	}
+
+	// shared locks share, and keep out an exclusive one
+	if err := b.RLock(); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := a.TryRLock(); !ok || err != nil {
+		t.Errorf("TryRLock() beside a shared lock = %v, %v, want true", ok, err)
+	}
+	if ok, err := b.TryLock(); ok || err != nil {
+		t.Errorf("TryLock() beside a shared lock = %v, %v, want false", ok, err)
+	}
+	if err := a.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := b.TryLock(); !ok || err != nil {
+		t.Errorf("TryLock() converting a shared lock = %v, %v, want true", ok, err)
+	}
+}
+
+func TestLocker_Mem(t *testing.T) {
+	testLocker(t, NewMemOS(), "/tmp/lock")
+}
+
+func TestLocker_OS(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no flock on windows")
+	}
+	testLocker(t, NewOS(), filepath.Join(t.TempDir(), "lock"))
+}
+
+func TestMemLocker_Close(t *testing.T) {
+	o := NewMemOS()
+	f, err := o.Create("/tmp/lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, b := lockers(t, o, "/tmp/lock")
+
+	a, _ := LockerFor(f)
+	if err := a.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	if ok, err := b.TryLock(); !ok || err != nil {
+		t.Errorf("TryLock() after Close = %v, %v, want true", ok, err)
+	}
+	if err := a.Lock(); !errors.Is(err, ErrClosed) {
+		t.Errorf("Lock() of closed file error = %v, want ErrClosed", err)
+	}
+}
+
+func TestLockFile(t *testing.T) {
+	o := NewMemOS()
+
+	f, err := LockFile(o, "/tmp/app.lock", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	_, err = LockFile(o, "/tmp/app.lock", 30*time.Millisecond)
+	if !errors.Is(err, ErrDeadlineExceeded) {
+		t.Errorf("LockFile() of held lock error = %v, want ErrDeadlineExceeded", err)
+	}
+	if d := time.Since(start); d < 30*time.Millisecond {
+		t.Errorf("LockFile() gave up after %v, want 30ms", d)
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		f.Close()
+	}()
+	g, err := LockFile(o, "/tmp/app.lock", -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.Close()
+}
+
+func TestLocker_Wrappers(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no flock on windows")
+	}
+
+	jail, err := NewJailOS(NewOS(), t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name string
+		os   OS
+		file string
+	}{
+		{"jail", jail, "/lock"},
+		{"fault", NewFaultOS(NewOS()), filepath.Join(t.TempDir(), "lock")},
+		{"fault over mem", NewFaultOS(NewMemOS()), "/tmp/lock"},
+		{"overlay", NewOverlayOS(NewOS()), filepath.Join(t.TempDir(), "lock")},
+		{"record", NewRecordOS(NewMemOS(), io.Discard), "/tmp/lock"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			testLocker(t, tt.os, tt.file)
+
+			f, err := LockFile(tt.os, tt.file+".2", 0)
+			if err != nil {
+				t.Fatalf("LockFile() error = %v", err)
+			}
+			f.Close()
+		})
+	}
+}
+
+func TestLocker_Atomic(t *testing.T) {
+	o := NewMemOS()
+	f, err := CreateAtomic(o, "/tmp/file", 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	l, err := LockerFor(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := l.TryLock(); !ok || err != nil {
+		t.Errorf("TryLock() = %v, %v, want true", ok, err)
+	}
+}
+
+// plainFile hides the file it holds, and so cannot be locked.
+type plainFile struct {
+	File
+}
+
+func TestLockerFor_Unsupported(t *testing.T) {
+	file, err := NewMemOS().Create("/tmp/lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	if _, err := LockerFor(plainFile{file}); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("LockerFor() error = %v, want ErrUnsupported", err)
+	}
+}
@@ -0,0 +1,71 @@
+//go:build unix
+
+package os
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+type flockLocker struct {
+	f *os.File
+}
+
+func newFlockLocker(f *os.File) (Locker, error) {
+	return flockLocker{f: f}, nil
+}
+
+// flock calls flock(2) on the file, without taking it out of the
+// runtime's poller as Fd would.
+func (l flockLocker) flock(how int) error {
+	rc, err := l.f.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var ferr error
+	err = rc.Control(func(fd uintptr) {
+		for {
+			ferr = syscall.Flock(int(fd), how)
+			if ferr != syscall.EINTR {
+				return
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if ferr != nil {
+		return &PathError{Op: "flock", Path: l.f.Name(), Err: ferr}
+	}
+	return nil
+}
+
+func (l flockLocker) try(how int) (bool, error) {
+	err := l.flock(how | syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (l flockLocker) Lock() error {
+	return l.flock(syscall.LOCK_EX)
+}
+
+func (l flockLocker) RLock() error {
+	return l.flock(syscall.LOCK_SH)
+}
+
+func (l flockLocker) TryLock() (bool, error) {
+	return l.try(syscall.LOCK_EX)
+}
+
+func (l flockLocker) TryRLock() (bool, error) {
+	return l.try(syscall.LOCK_SH)
+}
+
+func (l flockLocker) Unlock() error {
+	return l.flock(syscall.LOCK_UN)
+}
@@ -0,0 +1,593 @@
+package os
+
+import (
+	"errors"
+	"io"
+	stdfs "io/fs"
+	"slices"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/pdutton/go-interfaces/io/fs"
+)
+
+// memFile is an open file of an in-memory OS.  Like a file descriptor
+// it refers to the node rather than the name, so it keeps working after
+// the file is renamed or removed.
+type memFile struct {
+	os     *memOS
+	node   *memNode
+	name   string
+	path   string
+	flag   int
+	fd     uintptr
+	offset int64
+	closed bool
+
+	// The directory entries, read on the first call to ReadDir,
+	// Readdir or Readdirnames.
+	dirNames []string
+	dirPos   int
+}
+
+func (f *memFile) checkValid(op string) error {
+	if f.closed {
+		return &PathError{Op: op, Path: f.name, Err: ErrClosed}
+	}
+	return nil
+}
+
+func (f *memFile) readable() bool {
+	return f.flag&(O_RDONLY|O_WRONLY|O_RDWR) != O_WRONLY
+}
+
+func (f *memFile) writable() bool {
+	return f.flag&(O_RDONLY|O_WRONLY|O_RDWR) != O_RDONLY
+}
+
+func (f *memFile) Chdir() error {
+	f.os.mu.Lock()
+	defer f.os.mu.Unlock()
+
+	if err := f.checkValid("chdir"); err != nil {
+		return err
+	}
+	if !f.node.isDir() {
+		return &PathError{Op: "chdir", Path: f.name, Err: syscall.ENOTDIR}
+	}
+
+	f.os.cwd = f.path
+	return nil
+}
+
+func (f *memFile) Chmod(mode FSFileMode) error {
+	f.os.mu.Lock()
+	defer f.os.mu.Unlock()
+
+	if err := f.checkValid("chmod"); err != nil {
+		return err
+	}
+	if !f.os.isOwner(f.node) {
+		return &PathError{Op: "chmod", Path: f.name, Err: syscall.EPERM}
+	}
+
+	f.os.setMode(f.node, mode)
+	return nil
+}
+
+func (f *memFile) Chown(uid, gid int) error {
+	f.os.mu.Lock()
+	defer f.os.mu.Unlock()
+
+	if err := f.checkValid("chown"); err != nil {
+		return err
+	}
+	if err := f.os.setOwner(f.node, uid, gid); err != nil {
+		return &PathError{Op: "chown", Path: f.name, Err: err}
+	}
+
+	return nil
+}
+
+func (f *memFile) Close() error {
+	f.os.mu.Lock()
+	defer f.os.mu.Unlock()
+
+	if err := f.checkValid("close"); err != nil {
+		return err
+	}
+
+	f.closed = true
+	f.unlock()
+	return nil
+}
+
+func (f *memFile) Fd() uintptr {
+	f.os.mu.Lock()
+	defer f.os.mu.Unlock()
+
+	if f.closed {
+		return ^uintptr(0)
+	}
+	return f.fd
+}
+
+func (f *memFile) Name() string {
+	return f.name
+}
+
+func (f *memFile) Read(b []byte) (int, error) {
+	f.os.mu.Lock()
+	defer f.os.mu.Unlock()
+
+	n, err := f.readAt(b, f.offset)
+	f.offset += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func (f *memFile) ReadAt(b []byte, off int64) (int, error) {
+	f.os.mu.Lock()
+	defer f.os.mu.Unlock()
+
+	if off < 0 {
+		return 0, &PathError{Op: "readat", Path: f.name, Err: errNegativeOffset}
+	}
+
+	n, err := f.readAt(b, off)
+	if err == nil && n < len(b) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (f *memFile) readAt(b []byte, off int64) (int, error) {
+	if err := f.checkValid("read"); err != nil {
+		return 0, err
+	}
+	if !f.readable() {
+		return 0, &PathError{Op: "read", Path: f.name, Err: syscall.EBADF}
+	}
+	if f.node.isDir() {
+		return 0, &PathError{Op: "read", Path: f.name, Err: syscall.EISDIR}
+	}
+	if len(b) == 0 {
+		return 0, nil
+	}
+	if off >= int64(len(f.node.data)) {
+		return 0, io.EOF
+	}
+
+	f.node.atime = f.os.clock()
+	return copy(b, f.node.data[off:]), nil
+}
+
+func (f *memFile) ReadDir(n int) ([]DirEntry, error) {
+	infos, err := f.readdir("readdir", n)
+
+	var dea = []DirEntry{}
+	for _, fi := range infos {
+		dea = append(dea, fs.NewDirEntry(stdfs.FileInfoToDirEntry(fi.Nub())))
+	}
+
+	return dea, err
+}
+
+func (f *memFile) ReadFrom(r io.Reader) (int64, error) {
+	if err := f.checkValidLocked("write"); err != nil {
+		return 0, err
+	}
+	return io.Copy(memFileWriter{f}, r)
+}
+
+func (f *memFile) Readdir(n int) ([]FileInfo, error) {
+	return f.readdir("readdir", n)
+}
+
+func (f *memFile) Readdirnames(n int) ([]string, error) {
+	infos, err := f.readdir("readdirent", n)
+
+	var names = []string{}
+	for _, fi := range infos {
+		names = append(names, fi.Name())
+	}
+
+	return names, err
+}
+
+// readdir returns the next n entries of the directory, or all the
+// remaining ones if n <= 0, in the manner of os.File.Readdir.
+func (f *memFile) readdir(op string, n int) ([]FileInfo, error) {
+	f.os.mu.Lock()
+	defer f.os.mu.Unlock()
+
+	if err := f.checkValid(op); err != nil {
+		return nil, err
+	}
+	if !f.node.isDir() {
+		return nil, &PathError{Op: "readdirent", Path: f.name, Err: syscall.ENOTDIR}
+	}
+
+	if f.dirNames == nil {
+		f.dirNames = make([]string, 0, len(f.node.children))
+		for name := range f.node.children {
+			f.dirNames = append(f.dirNames, name)
+		}
+		slices.Sort(f.dirNames)
+	}
+
+	var infos = []FileInfo{}
+	for f.dirPos < len(f.dirNames) && (n <= 0 || len(infos) < n) {
+		name := f.dirNames[f.dirPos]
+		f.dirPos++
+
+		// Skip entries that were removed since the directory was read.
+		if child := f.node.children[name]; child != nil {
+			infos = append(infos, f.os.fileInfo(name, child))
+		}
+	}
+
+	if n > 0 && len(infos) == 0 {
+		return infos, io.EOF
+	}
+	return infos, nil
+}
+
+func (f *memFile) Seek(offset int64, whence int) (int64, error) {
+	f.os.mu.Lock()
+	defer f.os.mu.Unlock()
+
+	if err := f.checkValid("seek"); err != nil {
+		return 0, err
+	}
+
+	switch whence {
+	case SEEK_SET:
+	case SEEK_CUR:
+		offset += f.offset
+	case SEEK_END:
+		offset += f.node.size()
+	default:
+		return 0, &PathError{Op: "seek", Path: f.name, Err: syscall.EINVAL}
+	}
+	if offset < 0 {
+		return 0, &PathError{Op: "seek", Path: f.name, Err: syscall.EINVAL}
+	}
+
+	if f.node.isDir() && offset == 0 {
+		f.dirNames = nil
+		f.dirPos = 0
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *memFile) SetDeadline(t time.Time) error {
+	if err := f.checkValidLocked("SetDeadline"); err != nil {
+		return err
+	}
+	return ErrNoDeadline
+}
+
+func (f *memFile) SetReadDeadline(t time.Time) error {
+	if err := f.checkValidLocked("SetReadDeadline"); err != nil {
+		return err
+	}
+	return ErrNoDeadline
+}
+
+func (f *memFile) SetWriteDeadline(t time.Time) error {
+	if err := f.checkValidLocked("SetWriteDeadline"); err != nil {
+		return err
+	}
+	return ErrNoDeadline
+}
+
+func (f *memFile) Stat() (FileInfo, error) {
+	f.os.mu.Lock()
+	defer f.os.mu.Unlock()
+
+	if err := f.checkValid("stat"); err != nil {
+		return nil, err
+	}
+
+	return f.os.fileInfo(f.name, f.node), nil
+}
+
+func (f *memFile) Sync() error {
+	return f.checkValidLocked("sync")
+}
+
+// SyscallConn is not supported, as there is no file descriptor.
+func (f *memFile) SyscallConn() (syscall.RawConn, error) {
+	if err := f.checkValidLocked("SyscallConn"); err != nil {
+		return nil, err
+	}
+	return nil, &PathError{Op: "SyscallConn", Path: f.name, Err: errors.ErrUnsupported}
+}
+
+func (f *memFile) Truncate(size int64) error {
+	f.os.mu.Lock()
+	defer f.os.mu.Unlock()
+
+	if err := f.checkValid("truncate"); err != nil {
+		return err
+	}
+	if !f.writable() || size < 0 {
+		return &PathError{Op: "truncate", Path: f.name, Err: syscall.EINVAL}
+	}
+
+	f.os.resize(f.node, size)
+	return nil
+}
+
+func (f *memFile) Write(b []byte) (int, error) {
+	f.os.mu.Lock()
+	defer f.os.mu.Unlock()
+
+	if f.flag&O_APPEND != 0 {
+		f.offset = int64(len(f.node.data))
+	}
+
+	n, err := f.writeAt(b, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+func (f *memFile) WriteAt(b []byte, off int64) (int, error) {
+	f.os.mu.Lock()
+	defer f.os.mu.Unlock()
+
+	if err := f.checkValid("write"); err != nil {
+		return 0, err
+	}
+	if f.flag&O_APPEND != 0 {
+		return 0, errWriteAtInAppendMode
+	}
+	if off < 0 {
+		return 0, &PathError{Op: "writeat", Path: f.name, Err: errNegativeOffset}
+	}
+
+	return f.writeAt(b, off)
+}
+
+func (f *memFile) writeAt(b []byte, off int64) (int, error) {
+	if err := f.checkValid("write"); err != nil {
+		return 0, err
+	}
+	if !f.writable() {
+		return 0, &PathError{Op: "write", Path: f.name, Err: syscall.EBADF}
+	}
+
+	n := f.node
+	if end := off + int64(len(b)); end > int64(len(n.data)) {
+		n.data = append(n.data, make([]byte, end-int64(len(n.data)))...)
+	}
+	copy(n.data[off:], b)
+	n.mtime = f.os.clock()
+	n.ctime = n.mtime
+
+	return len(b), nil
+}
+
+func (f *memFile) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
+
+func (f *memFile) WriteTo(w io.Writer) (int64, error) {
+	if err := f.checkValidLocked("read"); err != nil {
+		return 0, err
+	}
+	return io.Copy(w, memFileReader{f})
+}
+
+func (f *memFile) checkValidLocked(op string) error {
+	f.os.mu.Lock()
+	defer f.os.mu.Unlock()
+
+	return f.checkValid(op)
+}
+
+// memFileReader and memFileWriter hide the ReadFrom and WriteTo
+// methods of a memFile from io.Copy.
+type memFileReader struct {
+	f *memFile
+}
+
+func (r memFileReader) Read(b []byte) (int, error) {
+	return r.f.Read(b)
+}
+
+type memFileWriter struct {
+	f *memFile
+}
+
+func (w memFileWriter) Write(b []byte) (int, error) {
+	return w.f.Write(b)
+}
+
+// memStream is a File that is not backed by a node: the standard
+// streams of an in-memory OS and the ends of its pipes.
+type memStream struct {
+	name   string
+	fd     uintptr
+	mode   FSFileMode
+	r      io.Reader
+	w      io.Writer
+	closer io.Closer
+
+	closed atomic.Bool
+}
+
+func newMemStream(name string, fd uintptr, mode FSFileMode) *memStream {
+	return &memStream{
+		name: name,
+		fd:   fd,
+		mode: mode,
+	}
+}
+
+func (s *memStream) checkValid(op string) error {
+	if s.closed.Load() {
+		return &PathError{Op: op, Path: s.name, Err: ErrClosed}
+	}
+	return nil
+}
+
+func (s *memStream) fail(op string, err error) error {
+	if err := s.checkValid(op); err != nil {
+		return err
+	}
+	return &PathError{Op: op, Path: s.name, Err: err}
+}
+
+func (s *memStream) Chdir() error {
+	return s.fail("chdir", syscall.ENOTDIR)
+}
+
+func (s *memStream) Chmod(FSFileMode) error {
+	return s.fail("chmod", syscall.EINVAL)
+}
+
+func (s *memStream) Chown(int, int) error {
+	return s.fail("chown", syscall.EINVAL)
+}
+
+func (s *memStream) Close() error {
+	if s.closed.Swap(true) {
+		return &PathError{Op: "close", Path: s.name, Err: ErrClosed}
+	}
+
+	if s.closer != nil {
+		return s.closer.Close()
+	}
+	return nil
+}
+
+func (s *memStream) Fd() uintptr {
+	if s.closed.Load() {
+		return ^uintptr(0)
+	}
+	return s.fd
+}
+
+func (s *memStream) Name() string {
+	return s.name
+}
+
+func (s *memStream) Read(b []byte) (int, error) {
+	if err := s.checkValid("read"); err != nil {
+		return 0, err
+	}
+	if s.r == nil {
+		return 0, &PathError{Op: "read", Path: s.name, Err: syscall.EBADF}
+	}
+
+	n, err := s.r.Read(b)
+	if err != nil && err != io.EOF {
+		err = &PathError{Op: "read", Path: s.name, Err: err}
+	}
+	return n, err
+}
+
+func (s *memStream) ReadAt([]byte, int64) (int, error) {
+	return 0, s.fail("read", syscall.ESPIPE)
+}
+
+func (s *memStream) ReadDir(int) ([]DirEntry, error) {
+	return nil, s.fail("readdirent", syscall.ENOTDIR)
+}
+
+func (s *memStream) ReadFrom(r io.Reader) (int64, error) {
+	return io.Copy(memStreamWriter{s}, r)
+}
+
+func (s *memStream) Readdir(int) ([]FileInfo, error) {
+	return nil, s.fail("readdirent", syscall.ENOTDIR)
+}
+
+func (s *memStream) Readdirnames(int) ([]string, error) {
+	return nil, s.fail("readdirent", syscall.ENOTDIR)
+}
+
+func (s *memStream) Seek(int64, int) (int64, error) {
+	return 0, s.fail("seek", syscall.ESPIPE)
+}
+
+func (s *memStream) SetDeadline(time.Time) error {
+	return ErrNoDeadline
+}
+
+func (s *memStream) SetReadDeadline(time.Time) error {
+	return ErrNoDeadline
+}
+
+func (s *memStream) SetWriteDeadline(time.Time) error {
+	return ErrNoDeadline
+}
+
+func (s *memStream) Stat() (FileInfo, error) {
+	if err := s.checkValid("stat"); err != nil {
+		return nil, err
+	}
+
+	return fs.NewFileInfo(&memFileInfo{name: s.name, mode: s.mode}), nil
+}
+
+func (s *memStream) Sync() error {
+	return s.fail("sync", syscall.EINVAL)
+}
+
+func (s *memStream) SyscallConn() (syscall.RawConn, error) {
+	return nil, s.fail("SyscallConn", errors.ErrUnsupported)
+}
+
+func (s *memStream) Truncate(int64) error {
+	return s.fail("truncate", syscall.EINVAL)
+}
+
+func (s *memStream) Write(b []byte) (int, error) {
+	if err := s.checkValid("write"); err != nil {
+		return 0, err
+	}
+	if s.w == nil {
+		return 0, &PathError{Op: "write", Path: s.name, Err: syscall.EBADF}
+	}
+
+	n, err := s.w.Write(b)
+	if err != nil {
+		err = &PathError{Op: "write", Path: s.name, Err: err}
+	}
+	return n, err
+}
+
+func (s *memStream) WriteAt([]byte, int64) (int, error) {
+	return 0, s.fail("write", syscall.ESPIPE)
+}
+
+func (s *memStream) WriteString(str string) (int, error) {
+	return s.Write([]byte(str))
+}
+
+func (s *memStream) WriteTo(w io.Writer) (int64, error) {
+	return io.Copy(w, memStreamReader{s})
+}
+
+type memStreamReader struct {
+	s *memStream
+}
+
+func (r memStreamReader) Read(b []byte) (int, error) {
+	return r.s.Read(b)
+}
+
+type memStreamWriter struct {
+	s *memStream
+}
+
+func (w memStreamWriter) Write(b []byte) (int, error) {
+	return w.s.Write(b)
+}
@@ -0,0 +1,131 @@
+package os
+
+// The files of an in-memory OS lock as flock(2) does: each open file
+// holds its own lock, so two files of the same node contend even when
+// one process opened both, and taking a lock of the other kind converts
+// the one the file holds.
+
+func (f *memFile) Lock() error {
+	return f.lock(true)
+}
+
+func (f *memFile) RLock() error {
+	return f.lock(false)
+}
+
+func (f *memFile) TryLock() (bool, error) {
+	return f.tryLock(true)
+}
+
+func (f *memFile) TryRLock() (bool, error) {
+	return f.tryLock(false)
+}
+
+func (f *memFile) Unlock() error {
+	f.os.mu.Lock()
+	defer f.os.mu.Unlock()
+
+	if err := f.checkValid("flock"); err != nil {
+		return err
+	}
+
+	f.unlock()
+	return nil
+}
+
+func (f *memFile) lock(exclusive bool) error {
+	f.os.mu.Lock()
+	defer f.os.mu.Unlock()
+
+	if err := f.checkValid("flock"); err != nil {
+		return err
+	}
+
+	// Like flock, a conversion gives up the old lock first.
+	if f.holdsLock(!exclusive) {
+		f.unlock()
+	}
+	for !f.canLock(exclusive) {
+		f.os.unlocked.Wait()
+		if err := f.checkValid("flock"); err != nil {
+			return err
+		}
+	}
+
+	f.takeLock(exclusive)
+	return nil
+}
+
+func (f *memFile) tryLock(exclusive bool) (bool, error) {
+	f.os.mu.Lock()
+	defer f.os.mu.Unlock()
+
+	if err := f.checkValid("flock"); err != nil {
+		return false, err
+	}
+	if !f.canLock(exclusive) {
+		return false, nil
+	}
+
+	f.takeLock(exclusive)
+	return true, nil
+}
+
+// holdsLock reports whether f holds a lock of the given kind.  Its
+// caller holds f.os.mu.
+func (f *memFile) holdsLock(exclusive bool) bool {
+	if exclusive {
+		return f.node.lockEx == f
+	}
+	return f.node.lockSh[f]
+}
+
+// canLock reports whether f can take a lock of the given kind without
+// waiting.  Its caller holds f.os.mu.
+func (f *memFile) canLock(exclusive bool) bool {
+	n := f.node
+	if n.lockEx != nil && n.lockEx != f {
+		return false
+	}
+	if !exclusive {
+		return true
+	}
+	for g := range n.lockSh {
+		if g != f {
+			return false
+		}
+	}
+	return true
+}
+
+// takeLock gives f a lock of the given kind, in place of any it holds.
+// Its caller holds f.os.mu.
+func (f *memFile) takeLock(exclusive bool) {
+	n := f.node
+	delete(n.lockSh, f)
+	if exclusive {
+		n.lockEx = f
+		return
+	}
+
+	if n.lockEx == f {
+		n.lockEx = nil
+		f.os.unlocked.Broadcast()
+	}
+	if n.lockSh == nil {
+		n.lockSh = make(map[*memFile]bool)
+	}
+	n.lockSh[f] = true
+}
+
+// unlock releases any lock f holds, and wakes the files waiting for a
+// lock, including any waiting on f itself, should it have been closed.
+// Its caller holds f.os.mu.
+func (f *memFile) unlock() {
+	n := f.node
+	if n.lockEx == f {
+		n.lockEx = nil
+	}
+	delete(n.lockSh, f)
+	f.os.unlocked.Broadcast()
+}
@@ -0,0 +1,375 @@
+package os
+
+import (
+	stdfs "io/fs"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pdutton/go-interfaces/io/fs"
+)
+
+const (
+	// Maximum number of symbolic links followed while resolving
+	// a path, as on Linux.
+	memMaxSymlinks = 40
+
+	// Root only follows as many links as os.Root does.
+	memRootMaxSymlinks = 8
+
+	permRead  FSFileMode = 04
+	permWrite FSFileMode = 02
+	permExec  FSFileMode = 01
+)
+
+// memNode is an inode of the in-memory file system.  Directories keep
+// their entries in children, symbolic links keep their target and
+// regular files keep their contents in data.
+type memNode struct {
+	ino   uint64
+	mode  FSFileMode
+	uid   int
+	gid   int
+	nlink int
+	atime time.Time
+	mtime time.Time
+	ctime time.Time
+
+	data     []byte
+	target   string
+	children map[string]*memNode
+
+	// The advisory locks on the node, held by open files.
+	lockEx *memFile
+	lockSh map[*memFile]bool
+}
+
+func (n *memNode) isDir() bool {
+	return n.mode.IsDir()
+}
+
+func (n *memNode) isSymlink() bool {
+	return n.mode&stdfs.ModeSymlink != 0
+}
+
+func (n *memNode) size() int64 {
+	switch {
+	case n.isSymlink():
+		return int64(len(n.target))
+	case n.isDir():
+		return 0
+	}
+	return int64(len(n.data))
+}
+
+// contains reports whether other is n or lives somewhere below it.
+func (n *memNode) contains(other *memNode) bool {
+	if n == other {
+		return true
+	}
+	for _, child := range n.children {
+		if child.isDir() && child.contains(other) {
+			return true
+		}
+	}
+	return false
+}
+
+// memAt says where a path is resolved from: the top of the whole tree
+// for an OS, or the directory of a Root, which may not be escaped.
+// path is the absolute name of top, empty for the top of the tree.
+type memAt struct {
+	top      *memNode
+	confined bool
+	path     string
+}
+
+// memWalk is the result of resolving a path.  When the last element of
+// the path does not exist node is nil, and dir and base name the place
+// where it would be created.  For paths that end at a directory on the
+// walk itself ("/", "a/..") base is empty.
+type memWalk struct {
+	dir  *memNode
+	base string
+	node *memNode
+	path string
+}
+
+func splitPath(name string) []string {
+	var elems []string
+	for _, e := range strings.Split(name, "/") {
+		if e != "" && e != "." {
+			elems = append(elems, e)
+		}
+	}
+	return elems
+}
+
+// walk resolves name.  Symbolic links are followed in every element
+// but the last, which is only followed if follow is set.  Every
+// directory passed through must be searchable.  The error is one of
+// the syscall.Errno values the kernel would give, and still has to be
+// wrapped by the caller.
+func (m *memOS) walk(at memAt, name string, follow bool) (memWalk, error) {
+	if name == "" {
+		return memWalk{}, syscall.ENOENT
+	}
+	if at.confined && path.IsAbs(name) {
+		return memWalk{}, errPathEscapes
+	}
+	if !at.confined && !path.IsAbs(name) {
+		name = m.cwd + "/" + name
+	}
+
+	// A trailing slash insists on a directory.
+	mustDir := strings.HasSuffix(name, "/")
+	if mustDir {
+		follow = true
+	}
+
+	maxLinks := memMaxSymlinks
+	if at.confined {
+		maxLinks = memRootMaxSymlinks
+	}
+
+	var (
+		dirs  = []*memNode{at.top}
+		names []string
+		elems = splitPath(name)
+		links int
+	)
+
+	for i := 0; i < len(elems); i++ {
+		dir := dirs[len(dirs)-1]
+		elem := elems[i]
+
+		if elem == ".." {
+			if len(dirs) == 1 {
+				if at.confined {
+					return memWalk{}, errPathEscapes
+				}
+				continue
+			}
+			dirs = dirs[:len(dirs)-1]
+			names = names[:len(names)-1]
+			continue
+		}
+
+		if !m.permitted(dir, permExec) {
+			return memWalk{}, syscall.EACCES
+		}
+
+		last := i == len(elems)-1
+		child := dir.children[elem]
+		if child == nil {
+			if !last {
+				return memWalk{}, syscall.ENOENT
+			}
+			return memWalk{dir: dir, base: elem, path: joinNames(names, elem)}, nil
+		}
+
+		if child.isSymlink() && (follow || !last) {
+			links++
+			if links > maxLinks {
+				return memWalk{}, syscall.ELOOP
+			}
+			if path.IsAbs(child.target) {
+				if at.confined {
+					return memWalk{}, errPathEscapes
+				}
+				dirs = dirs[:1]
+				names = nil
+			}
+			elems = append(splitPath(child.target), elems[i+1:]...)
+			i = -1
+			continue
+		}
+
+		if last {
+			if mustDir && !child.isDir() {
+				return memWalk{}, syscall.ENOTDIR
+			}
+			return memWalk{dir: dir, base: elem, node: child, path: joinNames(names, elem)}, nil
+		}
+		if !child.isDir() {
+			return memWalk{}, syscall.ENOTDIR
+		}
+
+		dirs = append(dirs, child)
+		names = append(names, elem)
+	}
+
+	w := memWalk{
+		node: dirs[len(dirs)-1],
+		path: joinNames(names),
+	}
+	if len(dirs) > 1 {
+		w.dir = dirs[len(dirs)-2]
+		w.base = names[len(names)-1]
+	}
+	return w, nil
+}
+
+func joinNames(names []string, more ...string) string {
+	return "/" + strings.Join(append(names[:len(names):len(names)], more...), "/")
+}
+
+// permitted reports whether the user of the OS has all of the
+// permission bits in want (a combination of permRead, permWrite and
+// permExec) on n.
+func (m *memOS) permitted(n *memNode, want FSFileMode) bool {
+	if m.uid == 0 {
+		return true
+	}
+
+	perm := n.mode.Perm()
+	switch {
+	case n.uid == m.uid:
+		perm >>= 6
+	case m.inGroup(n.gid):
+		perm >>= 3
+	}
+
+	return perm&want == want
+}
+
+// mayUnlink reports whether n can be removed from (or renamed out of)
+// dir, taking the sticky bit into account.
+func (m *memOS) mayUnlink(dir, n *memNode) bool {
+	if !m.permitted(dir, permWrite|permExec) {
+		return false
+	}
+	if dir.mode&stdfs.ModeSticky == 0 || m.uid == 0 {
+		return true
+	}
+	return n.uid == m.uid || dir.uid == m.uid
+}
+
+func (m *memOS) isOwner(n *memNode) bool {
+	return m.uid == 0 || m.uid == n.uid
+}
+
+func (m *memOS) inGroup(gid int) bool {
+	if gid == m.gid {
+		return true
+	}
+	for _, g := range m.groups {
+		if g == gid {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *memOS) newNode(mode FSFileMode) *memNode {
+	m.ino++
+	now := m.clock()
+
+	n := &memNode{
+		ino:   m.ino,
+		mode:  mode,
+		uid:   m.uid,
+		gid:   m.gid,
+		nlink: 1,
+		atime: now,
+		mtime: now,
+		ctime: now,
+	}
+	if mode.IsDir() {
+		n.nlink = 2
+		n.children = map[string]*memNode{}
+	}
+
+	return n
+}
+
+// link adds n to dir under name.
+func (m *memOS) link(dir *memNode, name string, n *memNode) {
+	dir.children[name] = n
+	dir.mtime = m.clock()
+	dir.ctime = dir.mtime
+	if n.isDir() {
+		dir.nlink++
+	}
+}
+
+// detach removes the entry name, which refers to n, from dir without
+// touching n itself.
+func (m *memOS) detach(dir *memNode, name string, n *memNode) {
+	delete(dir.children, name)
+	dir.mtime = m.clock()
+	dir.ctime = dir.mtime
+	if n.isDir() {
+		dir.nlink--
+	}
+}
+
+// unlink removes the entry name, which refers to n, from dir.
+func (m *memOS) unlink(dir *memNode, name string, n *memNode) {
+	m.detach(dir, name, n)
+	n.nlink--
+	n.ctime = dir.mtime
+	if n.isDir() {
+		n.nlink = 0
+	}
+}
+
+// memFileInfo is the fs.FileInfo of a memNode.  The io/fs facade wraps
+// it so that callers see the same FileInfo type as from the real OS.
+type memFileInfo struct {
+	name    string
+	size    int64
+	mode    FSFileMode
+	modTime time.Time
+	node    *memNode
+	stat    *fs.Stat
+}
+
+func (m *memOS) fileInfo(name string, n *memNode) FileInfo {
+	return fs.NewFileInfo(&memFileInfo{
+		name:    path.Base(name),
+		size:    n.size(),
+		mode:    n.mode,
+		modTime: n.mtime,
+		node:    n,
+		stat: &fs.Stat{
+			Uid:   n.uid,
+			Gid:   n.gid,
+			Inode: n.ino,
+			Links: uint64(n.nlink),
+			Atime: n.atime,
+			Ctime: n.ctime,
+		},
+	})
+}
+
+func (fi *memFileInfo) Name() string {
+	return fi.name
+}
+
+func (fi *memFileInfo) Size() int64 {
+	return fi.size
+}
+
+func (fi *memFileInfo) Mode() FSFileMode {
+	return fi.mode
+}
+
+func (fi *memFileInfo) ModTime() time.Time {
+	return fi.modTime
+}
+
+func (fi *memFileInfo) IsDir() bool {
+	return fi.mode.IsDir()
+}
+
+// Sys returns a *fs.Stat, as a fake FileInfo's does, for the files of
+// a memOS, and nil for its streams and for replayed files.
+func (fi *memFileInfo) Sys() any {
+	if fi.stat == nil {
+		return nil
+	}
+	st := *fi.stat
+	return &st
+}
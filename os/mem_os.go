@@ -0,0 +1,978 @@
+package os
+
+import (
+	"io"
+	stdfs "io/fs"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pdutton/go-interfaces/io/fs"
+)
+
+// memOS is an implementation of OS that keeps its file system,
+// environment and working directory in memory, for use in tests.
+// Paths use Unix semantics regardless of the host platform.
+type memOS struct {
+	mu sync.Mutex
+
+	// unlocked is signalled whenever a file lock is released.
+	unlocked *sync.Cond
+
+	root   *memNode
+	ino    uint64
+	nextFd uintptr
+	cwd    string
+
+	env        map[string]string
+	args       []string
+	uid        int
+	gid        int
+	groups     []int
+	umask      FSFileMode
+	hostname   string
+	executable string
+	pid        int
+	ppid       int
+	clock      func() time.Time
+	exit       func(int)
+	procs      ProcessStarter
+
+	stdin  *memStream
+	stdout *memStream
+	stderr *memStream
+}
+
+// MemOSOption allows you to set options on an in-memory OS in the
+// NewMemOS constructor
+type MemOSOption func(*memOS)
+
+// Set the command line arguments returned by Args
+func WithArgs(args ...string) MemOSOption {
+	return func(m *memOS) {
+		m.args = args
+	}
+}
+
+// Replace the environment with the given "key=value" strings
+func WithEnviron(env []string) MemOSOption {
+	return func(m *memOS) {
+		m.env = map[string]string{}
+		for _, kv := range env {
+			if k, v, ok := strings.Cut(kv, "="); ok {
+				m.env[k] = v
+			}
+		}
+	}
+}
+
+// Set the user and groups that own new files and whose permissions
+// are checked.  A uid of 0 bypasses permission checks, as for root.
+func WithUser(uid, gid int, groups ...int) MemOSOption {
+	return func(m *memOS) {
+		m.uid = uid
+		m.gid = gid
+		m.groups = groups
+	}
+}
+
+// Set the umask applied to the permissions of new files and directories
+func WithUmask(mask FSFileMode) MemOSOption {
+	return func(m *memOS) {
+		m.umask = mask.Perm()
+	}
+}
+
+// Set the value returned by Hostname
+func WithHostname(name string) MemOSOption {
+	return func(m *memOS) {
+		m.hostname = name
+	}
+}
+
+// Set the value returned by Executable
+func WithExecutable(name string) MemOSOption {
+	return func(m *memOS) {
+		m.executable = name
+	}
+}
+
+// Set the values returned by Getpid and Getppid
+func WithPID(pid, ppid int) MemOSOption {
+	return func(m *memOS) {
+		m.pid = pid
+		m.ppid = ppid
+	}
+}
+
+// Set the initial working directory, which is created if necessary
+func WithWorkingDir(dir string) MemOSOption {
+	return func(m *memOS) {
+		m.cwd = path.Clean("/" + dir)
+	}
+}
+
+// Set the clock used to timestamp files
+func WithClock(now func() time.Time) MemOSOption {
+	return func(m *memOS) {
+		m.clock = now
+	}
+}
+
+// Set the function called by Exit.  By default Exit panics, because
+// an in-memory OS has no process to end.
+func WithExitFunc(exit func(int)) MemOSOption {
+	return func(m *memOS) {
+		m.exit = exit
+	}
+}
+
+// Find and start processes in the given table, such as one from
+// NewProcessTable.  Without one, FindProcess and StartProcess fail.
+func WithProcesses(procs ProcessStarter) MemOSOption {
+	return func(m *memOS) {
+		m.procs = procs
+	}
+}
+
+// Set the reader behind Stdin
+func WithStdin(r io.Reader) MemOSOption {
+	return func(m *memOS) {
+		m.stdin.r = r
+	}
+}
+
+// Set the writer behind Stdout
+func WithStdout(w io.Writer) MemOSOption {
+	return func(m *memOS) {
+		m.stdout.w = w
+	}
+}
+
+// Set the writer behind Stderr
+func WithStderr(w io.Writer) MemOSOption {
+	return func(m *memOS) {
+		m.stderr.w = w
+	}
+}
+
+// NewMemOS creates an OS whose file system lives in memory.  It starts
+// out with a root directory, a world-writable /tmp and a home directory
+// for the user, which is also the working directory.  By default the
+// user has uid and gid 1000 and the umask is 022.
+func NewMemOS(options ...MemOSOption) OS {
+	m := newMemOS(options...)
+
+	tmp := m.newNode(stdfs.ModeDir | stdfs.ModeSticky | 0777)
+	tmp.uid, tmp.gid = 0, 0
+	m.link(m.root, "tmp", tmp)
+
+	if home := m.env["HOME"]; home != "" {
+		m.seedDir(home)
+	}
+	if m.cwd == "" {
+		m.cwd = m.env["HOME"]
+	}
+	m.cwd = m.seedDir(m.cwd)
+
+	return m
+}
+
+// newMemOS creates an in-memory OS with nothing but a root directory.
+// The working directory is left for the caller to set up.
+func newMemOS(options ...MemOSOption) *memOS {
+	var m = &memOS{
+		nextFd:   3,
+		env:      map[string]string{"HOME": "/home/gopher", "PATH": "/usr/local/bin:/usr/bin:/bin"},
+		args:     []string{"memos"},
+		uid:      1000,
+		gid:      1000,
+		umask:    022,
+		hostname: "localhost",
+		pid:      2,
+		ppid:     1,
+		clock:    time.Now,
+		exit: func(code int) {
+			panic("os: Exit(" + strconv.Itoa(code) + ") called on an in-memory OS")
+		},
+		stdin:  newMemStream("/dev/stdin", 0, stdfs.ModeDevice|stdfs.ModeCharDevice|0620),
+		stdout: newMemStream("/dev/stdout", 1, stdfs.ModeDevice|stdfs.ModeCharDevice|0620),
+		stderr: newMemStream("/dev/stderr", 2, stdfs.ModeDevice|stdfs.ModeCharDevice|0620),
+	}
+	m.stdin.r = strings.NewReader("")
+	m.stdout.w = io.Discard
+	m.stderr.w = io.Discard
+
+	for _, opt := range options {
+		opt(m)
+	}
+
+	m.unlocked = sync.NewCond(&m.mu)
+	m.root = m.newNode(stdfs.ModeDir | 0755)
+	m.root.uid, m.root.gid = 0, 0
+
+	return m
+}
+
+// seedDir creates the directory name and its parents, owned by the
+// user, without checking permissions.  It returns the cleaned name.
+func (m *memOS) seedDir(name string) string {
+	dir := m.root
+	elems := splitPath(path.Clean("/" + name))
+	for _, elem := range elems {
+		child := dir.children[elem]
+		if child == nil {
+			child = m.newNode(stdfs.ModeDir | 0755)
+			m.link(dir, elem, child)
+		}
+		dir = child
+	}
+	return joinNames(elems)
+}
+
+func (m *memOS) osAt() memAt {
+	return memAt{top: m.root}
+}
+
+func (m *memOS) Stdin() File {
+	return m.stdin
+}
+
+func (m *memOS) Stderr() File {
+	return m.stderr
+}
+
+func (m *memOS) Stdout() File {
+	return m.stdout
+}
+
+func (m *memOS) Args() []string {
+	return slices.Clone(m.args)
+}
+
+func (m *memOS) Chdir(dir string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w, err := m.walk(m.osAt(), dir, true)
+	if err == nil && w.node == nil {
+		err = syscall.ENOENT
+	}
+	if err == nil && !w.node.isDir() {
+		err = syscall.ENOTDIR
+	}
+	if err == nil && !m.permitted(w.node, permExec) {
+		err = syscall.EACCES
+	}
+	if err != nil {
+		return &PathError{Op: "chdir", Path: dir, Err: err}
+	}
+
+	m.cwd = w.path
+	return nil
+}
+
+func (m *memOS) Chmod(name string, mode FSFileMode) error {
+	return m.chmod(m.osAt(), "chmod", name, mode)
+}
+
+func (m *memOS) Chown(name string, uid, gid int) error {
+	return m.chown(m.osAt(), "chown", name, uid, gid, true)
+}
+
+func (m *memOS) Chtimes(name string, atime, mtime time.Time) error {
+	return m.chtimes(m.osAt(), "chtimes", name, atime, mtime)
+}
+
+func (m *memOS) Clearenv() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.env = map[string]string{}
+}
+
+func (m *memOS) CopyFS(dir string, fsys fs.FS) error {
+	return copyFS(m, dir, fsys)
+}
+
+func (m *memOS) DirFS(dir string) fs.FS {
+	return newDirFS(m, dir)
+}
+
+func (m *memOS) Environ() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var env = make([]string, 0, len(m.env))
+	for k, v := range m.env {
+		env = append(env, k+"="+v)
+	}
+	slices.Sort(env)
+
+	return env
+}
+
+func (m *memOS) Executable() (string, error) {
+	if m.executable == "" {
+		return "", &PathError{Op: "executable", Path: "", Err: syscall.ENOENT}
+	}
+	return m.executable, nil
+}
+
+func (m *memOS) Exit(code int) {
+	m.exit(code)
+}
+
+func (m *memOS) Expand(s string, mapping func(string) string) string {
+	return os.Expand(s, mapping)
+}
+
+func (m *memOS) ExpandEnv(s string) string {
+	return os.Expand(s, m.Getenv)
+}
+
+func (m *memOS) Getegid() int {
+	return m.gid
+}
+
+func (m *memOS) Getenv(key string) string {
+	v, _ := m.LookupEnv(key)
+	return v
+}
+
+func (m *memOS) Geteuid() int {
+	return m.uid
+}
+
+func (m *memOS) Getgid() int {
+	return m.gid
+}
+
+func (m *memOS) Getgroups() ([]int, error) {
+	return slices.Clone(m.groups), nil
+}
+
+func (m *memOS) Getpagesize() int {
+	return 4096
+}
+
+func (m *memOS) Getpid() int {
+	return m.pid
+}
+
+func (m *memOS) Getppid() int {
+	return m.ppid
+}
+
+func (m *memOS) Getuid() int {
+	return m.uid
+}
+
+func (m *memOS) Getwd() (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.cwd, nil
+}
+
+func (m *memOS) Hostname() (string, error) {
+	return m.hostname, nil
+}
+
+func (m *memOS) IsExist(err error) bool {
+	return os.IsExist(err)
+}
+
+func (m *memOS) IsNotExist(err error) bool {
+	return os.IsNotExist(err)
+}
+
+func (m *memOS) IsPathSeparator(c uint8) bool {
+	return c == '/'
+}
+
+func (m *memOS) IsPermission(err error) bool {
+	return os.IsPermission(err)
+}
+
+func (m *memOS) IsTimeout(err error) bool {
+	return os.IsTimeout(err)
+}
+
+func (m *memOS) Lchown(name string, uid, gid int) error {
+	return m.chown(m.osAt(), "lchown", name, uid, gid, false)
+}
+
+func (m *memOS) Link(oldname, newname string) error {
+	return m.hardlink(m.osAt(), "link", oldname, newname)
+}
+
+func (m *memOS) LookupEnv(key string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	v, ok := m.env[key]
+	return v, ok
+}
+
+func (m *memOS) Mkdir(name string, perm FSFileMode) error {
+	return m.mkdir(m.osAt(), "mkdir", name, perm)
+}
+
+func (m *memOS) MkdirAll(name string, perm FSFileMode) error {
+	return mkdirAll(m, name, perm)
+}
+
+func (m *memOS) MkdirTemp(dir, pattern string) (string, error) {
+	return mkdirTemp(m, dir, pattern)
+}
+
+func (m *memOS) NewSyscallError(syscall string, err error) error {
+	return os.NewSyscallError(syscall, err)
+}
+
+func (m *memOS) Pipe() (File, File, error) {
+	pr, pw := io.Pipe()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r := newMemStream("|0", m.nextFd, stdfs.ModeNamedPipe|0600)
+	r.r = pr
+	r.closer = pr
+	w := newMemStream("|1", m.nextFd+1, stdfs.ModeNamedPipe|0600)
+	w.w = pw
+	w.closer = pw
+	m.nextFd += 2
+
+	return r, w, nil
+}
+
+func (m *memOS) ReadFile(name string) ([]byte, error) {
+	return readFile(m, name)
+}
+
+func (m *memOS) Readlink(name string) (string, error) {
+	return m.readlink(m.osAt(), "readlink", name)
+}
+
+func (m *memOS) Remove(name string) error {
+	return m.remove(m.osAt(), "remove", name)
+}
+
+func (m *memOS) RemoveAll(name string) error {
+	return removeAll(m, name)
+}
+
+func (m *memOS) Rename(oldpath, newpath string) error {
+	// Like os.Rename, refuse to replace a directory.
+	if fi, err := m.Lstat(newpath); err == nil && fi.IsDir() {
+		ofi, err := m.Lstat(oldpath)
+		if err != nil {
+			if pe, ok := err.(*PathError); ok {
+				err = pe.Err
+			}
+			return &LinkError{Op: "rename", Old: oldpath, New: newpath, Err: err}
+		}
+		if oldpath == newpath || !m.SameFile(fi, ofi) {
+			return &LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EEXIST}
+		}
+	}
+
+	return m.rename(m.osAt(), "rename", oldpath, newpath)
+}
+
+func (m *memOS) SameFile(fi1, fi2 FileInfo) bool {
+	if fi1 == nil || fi2 == nil {
+		return false
+	}
+
+	a, ok1 := fi1.Nub().(*memFileInfo)
+	b, ok2 := fi2.Nub().(*memFileInfo)
+	return ok1 && ok2 && a.node != nil && a.node == b.node
+}
+
+func (m *memOS) Setenv(key, value string) error {
+	if key == "" || strings.ContainsAny(key, "=\x00") || strings.ContainsRune(value, 0) {
+		return os.NewSyscallError("setenv", syscall.EINVAL)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.env[key] = value
+	return nil
+}
+
+func (m *memOS) Symlink(oldname, newname string) error {
+	return m.symlink(m.osAt(), "symlink", oldname, newname)
+}
+
+func (m *memOS) TempDir() string {
+	return tempDir(m.Getenv)
+}
+
+func (m *memOS) Truncate(name string, size int64) error {
+	return m.truncate(m.osAt(), "truncate", name, size)
+}
+
+func (m *memOS) Unsetenv(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.env, key)
+	return nil
+}
+
+func (m *memOS) UserCacheDir() (string, error) {
+	return userCacheDir(m.Getenv)
+}
+
+func (m *memOS) UserConfigDir() (string, error) {
+	return userConfigDir(m.Getenv)
+}
+
+func (m *memOS) UserHomeDir() (string, error) {
+	return userHomeDir(m.Getenv)
+}
+
+func (m *memOS) WriteFile(name string, data []byte, perm FSFileMode) error {
+	return writeFile(m, name, data, perm)
+}
+
+func (m *memOS) ReadDir(name string) ([]DirEntry, error) {
+	return readDir(m, name)
+}
+
+func (m *memOS) Create(name string) (File, error) {
+	return m.OpenFile(name, O_RDWR|O_CREATE|O_TRUNC, 0666)
+}
+
+func (m *memOS) CreateTemp(dir, pattern string) (File, error) {
+	return createTemp(m, dir, pattern)
+}
+
+// NewFile always returns nil, as there are no file descriptors to
+// wrap in memory.
+func (m *memOS) NewFile(fd uintptr, name string) File {
+	return nil
+}
+
+func (m *memOS) Open(name string) (File, error) {
+	return m.OpenFile(name, O_RDONLY, 0)
+}
+
+func (m *memOS) OpenFile(name string, flag int, perm FSFileMode) (File, error) {
+	f, err := m.openFile(m.osAt(), "open", name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (m *memOS) OpenInRoot(dir, name string) (File, error) {
+	return openInRoot(m, dir, name)
+}
+
+func (m *memOS) Lstat(name string) (FileInfo, error) {
+	return m.stat(m.osAt(), "lstat", name, false)
+}
+
+func (m *memOS) Stat(name string) (FileInfo, error) {
+	return m.stat(m.osAt(), "stat", name, true)
+}
+
+func (m *memOS) FindProcess(pid int) (Process, error) {
+	if m.procs != nil {
+		return m.procs.FindProcess(pid)
+	}
+	return nil, os.NewSyscallError("findprocess", syscall.ESRCH)
+}
+
+func (m *memOS) StartProcess(name string, argv []string, attr *ProcAttr) (Process, error) {
+	if m.procs != nil {
+		return m.procs.StartProcess(name, argv, attr)
+	}
+	return nil, &PathError{Op: "fork/exec", Path: name, Err: syscall.ENOSYS}
+}
+
+func (m *memOS) OpenRoot(name string) (Root, error) {
+	return m.openRoot(m.osAt(), "open", name)
+}
+
+// The methods below implement the operations shared by memOS and
+// memRoot.  op is the name reported in errors, which differs between
+// the two.
+
+func (m *memOS) openFile(at memAt, op, name string, flag int, perm FSFileMode) (*memFile, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	excl := flag&(O_CREATE|O_EXCL) == O_CREATE|O_EXCL
+	w, err := m.walk(at, name, !excl)
+	if err != nil {
+		return nil, &PathError{Op: op, Path: name, Err: err}
+	}
+
+	acc := flag & (O_RDONLY | O_WRONLY | O_RDWR)
+	n := w.node
+	switch {
+	case n == nil && flag&O_CREATE == 0:
+		err = syscall.ENOENT
+	case n == nil && !m.permitted(w.dir, permWrite|permExec):
+		err = syscall.EACCES
+	case n == nil:
+		n = m.newNode(perm & (stdfs.ModePerm | stdfs.ModeSetuid | stdfs.ModeSetgid | stdfs.ModeSticky) &^ m.umask)
+		m.link(w.dir, w.base, n)
+	case excl:
+		err = syscall.EEXIST
+	case n.isDir() && (acc != O_RDONLY || flag&O_CREATE != 0):
+		err = syscall.EISDIR
+	case acc != O_WRONLY && !m.permitted(n, permRead):
+		err = syscall.EACCES
+	case acc != O_RDONLY && !m.permitted(n, permWrite):
+		err = syscall.EACCES
+	case flag&O_TRUNC != 0 && acc != O_RDONLY && len(n.data) > 0:
+		n.data = nil
+		n.mtime = m.clock()
+		n.ctime = n.mtime
+	}
+	if err != nil {
+		return nil, &PathError{Op: op, Path: name, Err: err}
+	}
+
+	f := &memFile{
+		os:   m,
+		node: n,
+		name: name,
+		path: at.path + w.path,
+		flag: flag,
+		fd:   m.nextFd,
+	}
+	m.nextFd++
+
+	return f, nil
+}
+
+func (m *memOS) stat(at memAt, op, name string, follow bool) (FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w, err := m.walk(at, name, follow)
+	if err == nil && w.node == nil {
+		err = syscall.ENOENT
+	}
+	if err != nil {
+		return nil, &PathError{Op: op, Path: name, Err: err}
+	}
+
+	return m.fileInfo(name, w.node), nil
+}
+
+// lookup resolves name to an existing node.
+func (m *memOS) lookup(at memAt, name string, follow bool) (memWalk, error) {
+	w, err := m.walk(at, name, follow)
+	if err == nil && w.node == nil {
+		err = syscall.ENOENT
+	}
+	return w, err
+}
+
+func (m *memOS) chmod(at memAt, op, name string, mode FSFileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w, err := m.lookup(at, name, true)
+	if err == nil && !m.isOwner(w.node) {
+		err = syscall.EPERM
+	}
+	if err != nil {
+		return &PathError{Op: op, Path: name, Err: err}
+	}
+
+	m.setMode(w.node, mode)
+	return nil
+}
+
+func (m *memOS) setMode(n *memNode, mode FSFileMode) {
+	const bits = stdfs.ModePerm | stdfs.ModeSetuid | stdfs.ModeSetgid | stdfs.ModeSticky
+
+	n.mode = n.mode&^bits | mode&bits
+	n.ctime = m.clock()
+}
+
+func (m *memOS) chown(at memAt, op, name string, uid, gid int, follow bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w, err := m.lookup(at, name, follow)
+	if err == nil {
+		err = m.setOwner(w.node, uid, gid)
+	}
+	if err != nil {
+		return &PathError{Op: op, Path: name, Err: err}
+	}
+
+	return nil
+}
+
+// setOwner changes the owner of n.  Only root may give a file away;
+// other owners may only change the group to one they belong to.
+func (m *memOS) setOwner(n *memNode, uid, gid int) error {
+	if m.uid != 0 {
+		if n.uid != m.uid || (uid != -1 && uid != n.uid) || (gid != -1 && !m.inGroup(gid)) {
+			return syscall.EPERM
+		}
+	}
+
+	if uid != -1 {
+		n.uid = uid
+	}
+	if gid != -1 {
+		n.gid = gid
+	}
+	n.ctime = m.clock()
+	return nil
+}
+
+func (m *memOS) chtimes(at memAt, op, name string, atime, mtime time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w, err := m.lookup(at, name, true)
+	if err == nil && !m.isOwner(w.node) {
+		err = syscall.EPERM
+	}
+	if err != nil {
+		return &PathError{Op: op, Path: name, Err: err}
+	}
+
+	// As with os.Chtimes, a zero time leaves the value unchanged.
+	if !atime.IsZero() {
+		w.node.atime = atime
+	}
+	if !mtime.IsZero() {
+		w.node.mtime = mtime
+	}
+	w.node.ctime = m.clock()
+	return nil
+}
+
+func (m *memOS) truncate(at memAt, op, name string, size int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w, err := m.lookup(at, name, true)
+	switch {
+	case err != nil:
+	case w.node.isDir():
+		err = syscall.EISDIR
+	case !m.permitted(w.node, permWrite):
+		err = syscall.EACCES
+	case size < 0:
+		err = syscall.EINVAL
+	}
+	if err != nil {
+		return &PathError{Op: op, Path: name, Err: err}
+	}
+
+	m.resize(w.node, size)
+	return nil
+}
+
+func (m *memOS) resize(n *memNode, size int64) {
+	if size <= int64(len(n.data)) {
+		n.data = n.data[:size:size]
+	} else {
+		n.data = append(n.data, make([]byte, size-int64(len(n.data)))...)
+	}
+	n.mtime = m.clock()
+	n.ctime = n.mtime
+}
+
+func (m *memOS) mkdir(at memAt, op, name string, perm FSFileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w, err := m.walk(at, name, false)
+	switch {
+	case err != nil:
+	case w.node != nil:
+		err = syscall.EEXIST
+	case !m.permitted(w.dir, permWrite|permExec):
+		err = syscall.EACCES
+	}
+	if err != nil {
+		return &PathError{Op: op, Path: name, Err: err}
+	}
+
+	bits := perm & (stdfs.ModePerm | stdfs.ModeSetuid | stdfs.ModeSetgid | stdfs.ModeSticky) &^ m.umask
+	m.link(w.dir, w.base, m.newNode(stdfs.ModeDir|bits))
+	return nil
+}
+
+func (m *memOS) remove(at memAt, op, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w, err := m.lookup(at, name, false)
+	switch {
+	case err != nil:
+	case w.base == "":
+		err = syscall.EBUSY
+	case !m.mayUnlink(w.dir, w.node):
+		err = syscall.EACCES
+	case w.node.isDir() && len(w.node.children) > 0:
+		err = syscall.ENOTEMPTY
+	}
+	if err != nil {
+		return &PathError{Op: op, Path: name, Err: err}
+	}
+
+	m.unlink(w.dir, w.base, w.node)
+	return nil
+}
+
+// rename implements rename(2), which unlike os.Rename will replace
+// an empty directory with another one.
+func (m *memOS) rename(at memAt, op, oldname, newname string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ow, err := m.lookup(at, oldname, false)
+	if err != nil {
+		return &LinkError{Op: op, Old: oldname, New: newname, Err: err}
+	}
+	nw, err := m.walk(at, newname, false)
+	if err != nil {
+		return &LinkError{Op: op, Old: oldname, New: newname, Err: err}
+	}
+
+	src, dst := ow.node, nw.node
+	switch {
+	case ow.base == "" || nw.base == "":
+		err = syscall.EBUSY
+	case src == dst:
+		return nil
+	case !m.mayUnlink(ow.dir, src) || !m.permitted(nw.dir, permWrite|permExec):
+		err = syscall.EACCES
+	case dst != nil && !m.mayUnlink(nw.dir, dst):
+		err = syscall.EACCES
+	case src.isDir() && src.contains(nw.dir):
+		err = syscall.EINVAL
+	case src.isDir() && dst != nil && !dst.isDir():
+		err = syscall.ENOTDIR
+	case src.isDir() && dst != nil && len(dst.children) > 0:
+		err = syscall.ENOTEMPTY
+	case !src.isDir() && dst != nil && dst.isDir():
+		err = syscall.EISDIR
+	}
+	if err != nil {
+		return &LinkError{Op: op, Old: oldname, New: newname, Err: err}
+	}
+
+	m.detach(ow.dir, ow.base, src)
+	if dst != nil {
+		m.unlink(nw.dir, nw.base, dst)
+	}
+	src.ctime = m.clock()
+	m.link(nw.dir, nw.base, src)
+	return nil
+}
+
+func (m *memOS) hardlink(at memAt, op, oldname, newname string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ow, err := m.lookup(at, oldname, false)
+	if err != nil {
+		return &LinkError{Op: op, Old: oldname, New: newname, Err: err}
+	}
+	nw, err := m.walk(at, newname, false)
+	switch {
+	case err != nil:
+	case ow.node.isDir():
+		err = syscall.EPERM
+	case nw.node != nil:
+		err = syscall.EEXIST
+	case !m.permitted(nw.dir, permWrite|permExec):
+		err = syscall.EACCES
+	}
+	if err != nil {
+		return &LinkError{Op: op, Old: oldname, New: newname, Err: err}
+	}
+
+	ow.node.nlink++
+	ow.node.ctime = m.clock()
+	m.link(nw.dir, nw.base, ow.node)
+	return nil
+}
+
+func (m *memOS) symlink(at memAt, op, oldname, newname string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w, err := m.walk(at, newname, false)
+	switch {
+	case err != nil:
+	case w.node != nil:
+		err = syscall.EEXIST
+	case !m.permitted(w.dir, permWrite|permExec):
+		err = syscall.EACCES
+	}
+	if err != nil {
+		return &LinkError{Op: op, Old: oldname, New: newname, Err: err}
+	}
+
+	n := m.newNode(stdfs.ModeSymlink | 0777)
+	n.target = oldname
+	m.link(w.dir, w.base, n)
+	return nil
+}
+
+func (m *memOS) readlink(at memAt, op, name string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w, err := m.lookup(at, name, false)
+	if err == nil && !w.node.isSymlink() {
+		err = syscall.EINVAL
+	}
+	if err != nil {
+		return "", &PathError{Op: op, Path: name, Err: err}
+	}
+
+	return w.node.target, nil
+}
+
+func (m *memOS) openRoot(at memAt, op, name string) (Root, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w, err := m.lookup(at, name, true)
+	switch {
+	case err != nil:
+	case !w.node.isDir():
+		err = syscall.ENOTDIR
+	case !m.permitted(w.node, permRead):
+		err = syscall.EACCES
+	}
+	if err != nil {
+		return nil, &PathError{Op: op, Path: name, Err: err}
+	}
+
+	return &memRoot{
+		os:   m,
+		name: name,
+		at: memAt{
+			top:      w.node,
+			confined: true,
+			path:     strings.TrimSuffix(at.path+w.path, "/"),
+		},
+	}, nil
+}
@@ -0,0 +1,629 @@
+package os
+
+import (
+	"errors"
+	"io"
+	stdfs "io/fs"
+	"strings"
+	"syscall"
+	"testing"
+	"testing/fstest"
+)
+
+func TestNewMemOS(t *testing.T) {
+	var _ OS = NewMemOS()
+}
+
+func TestMemOS_ReadFileWriteFile(t *testing.T) {
+	m := NewMemOS()
+
+	if err := m.WriteFile("/tmp/test.txt", []byte("hello world"), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	data, err := m.ReadFile("/tmp/test.txt")
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if string(data) != "hello world" {
+		t.Errorf("ReadFile() = %q, want %q", data, "hello world")
+	}
+
+	fi, err := m.Stat("/tmp/test.txt")
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	if fi.Size() != 11 || fi.Mode().Perm() != 0644 || fi.Name() != "test.txt" {
+		t.Errorf("Stat() = %v %d %v, want test.txt 11 -rw-r--r--", fi.Name(), fi.Size(), fi.Mode())
+	}
+}
+
+func TestMemOS_Umask(t *testing.T) {
+	m := NewMemOS(WithUmask(027))
+
+	m.WriteFile("f", nil, 0666)
+	m.Mkdir("d", 0777)
+
+	if fi, _ := m.Stat("f"); fi.Mode().Perm() != 0640 {
+		t.Errorf("file mode = %v, want 0640", fi.Mode().Perm())
+	}
+	if fi, _ := m.Stat("d"); fi.Mode().Perm() != 0750 || !fi.IsDir() {
+		t.Errorf("dir mode = %v, want drwxr-x---", fi.Mode())
+	}
+}
+
+func TestMemOS_NotExist(t *testing.T) {
+	m := NewMemOS()
+
+	_, err := m.Open("missing.txt")
+
+	var pe *PathError
+	if !errors.As(err, &pe) {
+		t.Fatalf("Open() error = %T, want *PathError", err)
+	}
+	if pe.Op != "open" || pe.Path != "missing.txt" || pe.Err != syscall.ENOENT {
+		t.Errorf("Open() error = %#v", pe)
+	}
+	if !errors.Is(err, stdfs.ErrNotExist) {
+		t.Error("errors.Is(err, fs.ErrNotExist) = false, want true")
+	}
+}
+
+func TestMemOS_OpenFlags(t *testing.T) {
+	m := NewMemOS()
+
+	m.WriteFile("f", []byte("hello"), 0644)
+
+	if _, err := m.OpenFile("f", O_RDWR|O_CREATE|O_EXCL, 0644); !errors.Is(err, ErrExist) {
+		t.Errorf("OpenFile(O_EXCL) error = %v, want ErrExist", err)
+	}
+
+	f, err := m.OpenFile("f", O_WRONLY|O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("OpenFile(O_APPEND) error = %v", err)
+	}
+	f.Write([]byte(" world"))
+	if _, err := f.WriteAt([]byte("x"), 0); err == nil {
+		t.Error("WriteAt() in append mode succeeded")
+	}
+	if _, err := f.Read(make([]byte, 1)); !errors.Is(err, syscall.EBADF) {
+		t.Errorf("Read() on write-only file error = %v, want EBADF", err)
+	}
+	f.Close()
+
+	if data, _ := m.ReadFile("f"); string(data) != "hello world" {
+		t.Errorf("after append = %q, want %q", data, "hello world")
+	}
+
+	f, _ = m.OpenFile("f", O_WRONLY|O_TRUNC, 0)
+	f.Close()
+	if data, _ := m.ReadFile("f"); len(data) != 0 {
+		t.Errorf("after O_TRUNC = %q, want empty", data)
+	}
+
+	if err := f.Close(); !errors.Is(err, ErrClosed) {
+		t.Errorf("second Close() error = %v, want ErrClosed", err)
+	}
+}
+
+func TestMemOS_SeekReadAtWriteAt(t *testing.T) {
+	m := NewMemOS()
+
+	f, err := m.Create("f")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	defer f.Close()
+
+	f.WriteString("hello world")
+	if _, err := f.WriteAt([]byte("HELLO"), 0); err != nil {
+		t.Fatalf("WriteAt() error = %v", err)
+	}
+
+	if pos, err := f.Seek(-5, SEEK_END); err != nil || pos != 6 {
+		t.Fatalf("Seek() = %d, %v, want 6, nil", pos, err)
+	}
+	buf := make([]byte, 10)
+	n, _ := f.Read(buf)
+	if string(buf[:n]) != "world" {
+		t.Errorf("Read() = %q, want %q", buf[:n], "world")
+	}
+	if _, err := f.Read(buf); err != io.EOF {
+		t.Errorf("Read() at end error = %v, want io.EOF", err)
+	}
+
+	n, err = f.ReadAt(buf[:8], 6)
+	if n != 5 || err != io.EOF {
+		t.Errorf("ReadAt() = %d, %v, want 5, io.EOF", n, err)
+	}
+
+	// Writing past the end leaves a hole of zeros.
+	f.WriteAt([]byte("!"), 12)
+	data, _ := m.ReadFile("f")
+	if string(data) != "HELLO world\x00!" {
+		t.Errorf("contents = %q", data)
+	}
+
+	if _, err := f.Seek(-1, SEEK_SET); err == nil {
+		t.Error("Seek() to negative offset succeeded")
+	}
+}
+
+func TestMemOS_MkdirAllRemoveAll(t *testing.T) {
+	m := NewMemOS()
+
+	if err := m.MkdirAll("a/b/c", 0755); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	if err := m.MkdirAll("a/b/c", 0755); err != nil {
+		t.Errorf("MkdirAll() on existing directory error = %v", err)
+	}
+	m.WriteFile("a/b/c/f", nil, 0644)
+
+	if err := m.MkdirAll("a/b/c/f/g", 0755); !errors.Is(err, syscall.ENOTDIR) {
+		t.Errorf("MkdirAll() through file error = %v, want ENOTDIR", err)
+	}
+	if err := m.Mkdir("a", 0755); !errors.Is(err, ErrExist) {
+		t.Errorf("Mkdir() on existing error = %v, want ErrExist", err)
+	}
+	if err := m.Remove("a"); !errors.Is(err, syscall.ENOTEMPTY) {
+		t.Errorf("Remove() of non-empty directory error = %v, want ENOTEMPTY", err)
+	}
+
+	if err := m.RemoveAll("a"); err != nil {
+		t.Fatalf("RemoveAll() error = %v", err)
+	}
+	if _, err := m.Stat("a"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Stat() after RemoveAll() error = %v, want ErrNotExist", err)
+	}
+	if err := m.RemoveAll("a"); err != nil {
+		t.Errorf("RemoveAll() of missing path error = %v", err)
+	}
+}
+
+func TestMemOS_Rename(t *testing.T) {
+	m := NewMemOS()
+
+	m.WriteFile("old", []byte("data"), 0644)
+	m.Mkdir("dir", 0755)
+
+	f, _ := m.Open("old")
+	defer f.Close()
+
+	if err := m.Rename("old", "new"); err != nil {
+		t.Fatalf("Rename() error = %v", err)
+	}
+	if _, err := m.Stat("old"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Stat(old) error = %v, want ErrNotExist", err)
+	}
+	if data, _ := m.ReadFile("new"); string(data) != "data" {
+		t.Errorf("ReadFile(new) = %q, want %q", data, "data")
+	}
+
+	// The open file follows the node.
+	if data, _ := io.ReadAll(f); string(data) != "data" {
+		t.Errorf("read from renamed file = %q, want %q", data, "data")
+	}
+
+	err := m.Rename("new", "dir")
+	var le *LinkError
+	if !errors.As(err, &le) || le.Op != "rename" || !errors.Is(err, ErrExist) {
+		t.Errorf("Rename() onto directory error = %v, want rename EEXIST", err)
+	}
+
+	if err := m.Rename("dir", "dir/sub"); !errors.Is(err, syscall.EINVAL) {
+		t.Errorf("Rename() into itself error = %v, want EINVAL", err)
+	}
+	if err := m.Rename("missing", "x"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Rename() of missing file error = %v, want ErrNotExist", err)
+	}
+}
+
+func TestMemOS_Permissions(t *testing.T) {
+	m := NewMemOS()
+
+	m.WriteFile("secret", []byte("x"), 0200)
+	if _, err := m.ReadFile("secret"); !errors.Is(err, ErrPermission) {
+		t.Errorf("ReadFile() of write-only file error = %v, want ErrPermission", err)
+	}
+
+	m.Mkdir("locked", 0755)
+	m.WriteFile("locked/f", []byte("x"), 0644)
+	m.Chmod("locked", 0500)
+	if err := m.WriteFile("locked/g", nil, 0644); !errors.Is(err, ErrPermission) {
+		t.Errorf("WriteFile() in read-only directory error = %v, want ErrPermission", err)
+	}
+	if err := m.Remove("locked/f"); !errors.Is(err, ErrPermission) {
+		t.Errorf("Remove() in read-only directory error = %v, want ErrPermission", err)
+	}
+
+	m.Chmod("locked", 0600)
+	if _, err := m.Stat("locked/f"); !errors.Is(err, ErrPermission) {
+		t.Errorf("Stat() through unsearchable directory error = %v, want ErrPermission", err)
+	}
+
+	if err := m.Chmod("/tmp", 0777); !errors.Is(err, syscall.EPERM) {
+		t.Errorf("Chmod() of root's directory error = %v, want EPERM", err)
+	}
+	if err := m.Chown("secret", 0, 0); !errors.Is(err, syscall.EPERM) {
+		t.Errorf("Chown() to root error = %v, want EPERM", err)
+	}
+
+	root := NewMemOS(WithUser(0, 0))
+	root.WriteFile("secret", []byte("x"), 0)
+	if _, err := root.ReadFile("secret"); err != nil {
+		t.Errorf("ReadFile() as root error = %v", err)
+	}
+}
+
+func TestMemOS_Symlink(t *testing.T) {
+	m := NewMemOS()
+
+	m.MkdirAll("real/dir", 0755)
+	m.WriteFile("real/dir/f", []byte("via link"), 0644)
+
+	if err := m.Symlink("real/dir", "link"); err != nil {
+		t.Fatalf("Symlink() error = %v", err)
+	}
+	if err := m.Symlink("real", "link"); !errors.Is(err, ErrExist) {
+		t.Errorf("Symlink() over existing error = %v, want ErrExist", err)
+	}
+
+	if data, err := m.ReadFile("link/f"); err != nil || string(data) != "via link" {
+		t.Errorf("ReadFile(link/f) = %q, %v", data, err)
+	}
+
+	target, err := m.Readlink("link")
+	if err != nil || target != "real/dir" {
+		t.Errorf("Readlink() = %q, %v, want real/dir", target, err)
+	}
+	if _, err := m.Readlink("real"); !errors.Is(err, syscall.EINVAL) {
+		t.Errorf("Readlink() of directory error = %v, want EINVAL", err)
+	}
+
+	fi, _ := m.Lstat("link")
+	if !fi.Mode().IsSymlink() {
+		t.Errorf("Lstat() mode = %v, want symlink", fi.Mode())
+	}
+	fi, _ = m.Stat("link")
+	if !fi.IsDir() {
+		t.Errorf("Stat() mode = %v, want directory", fi.Mode())
+	}
+
+	// ".." is resolved relative to the target of the link.
+	if _, err := m.Stat("link/../dir/f"); err != nil {
+		t.Errorf("Stat(link/../dir/f) error = %v", err)
+	}
+
+	m.Symlink("loop", "loop")
+	if _, err := m.Stat("loop"); !errors.Is(err, syscall.ELOOP) {
+		t.Errorf("Stat() of symlink loop error = %v, want ELOOP", err)
+	}
+
+	// A dangling link is created through.
+	m.Symlink("created", "dangling")
+	m.WriteFile("dangling", []byte("x"), 0644)
+	if _, err := m.Stat("created"); err != nil {
+		t.Errorf("Stat(created) error = %v", err)
+	}
+	if _, err := m.OpenFile("dangling", O_CREATE|O_EXCL|O_WRONLY, 0644); !errors.Is(err, ErrExist) {
+		t.Errorf("OpenFile(O_EXCL) on symlink error = %v, want ErrExist", err)
+	}
+}
+
+func TestMemOS_Link(t *testing.T) {
+	m := NewMemOS()
+
+	m.WriteFile("a", []byte("one"), 0644)
+	if err := m.Link("a", "b"); err != nil {
+		t.Fatalf("Link() error = %v", err)
+	}
+	m.WriteFile("b", []byte("two"), 0644)
+
+	if data, _ := m.ReadFile("a"); string(data) != "two" {
+		t.Errorf("ReadFile(a) = %q, want %q", data, "two")
+	}
+
+	fa, _ := m.Stat("a")
+	fb, _ := m.Stat("b")
+	if !m.SameFile(fa, fb) {
+		t.Error("SameFile() = false for hard links")
+	}
+}
+
+func TestMemOS_ChdirGetwd(t *testing.T) {
+	m := NewMemOS(WithWorkingDir("/work"))
+
+	if wd, _ := m.Getwd(); wd != "/work" {
+		t.Errorf("Getwd() = %q, want /work", wd)
+	}
+
+	m.MkdirAll("a/b", 0755)
+	m.Symlink("a/b", "l")
+	if err := m.Chdir("l"); err != nil {
+		t.Fatalf("Chdir() error = %v", err)
+	}
+	if wd, _ := m.Getwd(); wd != "/work/a/b" {
+		t.Errorf("Getwd() = %q, want /work/a/b", wd)
+	}
+
+	m.WriteFile("../f", nil, 0644)
+	if _, err := m.Stat("/work/a/f"); err != nil {
+		t.Errorf("Stat() error = %v", err)
+	}
+
+	if err := m.Chdir("/work/a/f"); !errors.Is(err, syscall.ENOTDIR) {
+		t.Errorf("Chdir() to file error = %v, want ENOTDIR", err)
+	}
+}
+
+func TestMemOS_ReadDir(t *testing.T) {
+	m := NewMemOS()
+
+	for _, name := range []string{"c", "a", "b"} {
+		m.WriteFile(name, nil, 0644)
+	}
+	m.Mkdir("d", 0755)
+
+	entries, err := m.ReadDir(".")
+	if err != nil {
+		t.Fatalf("ReadDir() error = %v", err)
+	}
+
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if strings.Join(names, ",") != "a,b,c,d" {
+		t.Errorf("ReadDir() = %v, want [a b c d]", names)
+	}
+	if !entries[3].IsDir() {
+		t.Error("IsDir() = false for directory")
+	}
+
+	f, _ := m.Open(".")
+	defer f.Close()
+	first, _ := f.Readdirnames(3)
+	rest, _ := f.Readdirnames(3)
+	if len(first) != 3 || len(rest) != 1 {
+		t.Errorf("Readdirnames() = %v, %v", first, rest)
+	}
+	if _, err := f.Readdirnames(3); err != io.EOF {
+		t.Errorf("Readdirnames() at end error = %v, want io.EOF", err)
+	}
+}
+
+func TestMemOS_Env(t *testing.T) {
+	m := NewMemOS(WithEnviron([]string{"A=1", "HOME=/home/test"}))
+
+	if v := m.Getenv("A"); v != "1" {
+		t.Errorf("Getenv(A) = %q, want 1", v)
+	}
+	m.Setenv("B", "2")
+	if s := m.ExpandEnv("$A-${B}"); s != "1-2" {
+		t.Errorf("ExpandEnv() = %q, want 1-2", s)
+	}
+	m.Unsetenv("A")
+	if _, ok := m.LookupEnv("A"); ok {
+		t.Error("LookupEnv(A) found unset variable")
+	}
+	if env := m.Environ(); strings.Join(env, " ") != "B=2 HOME=/home/test" {
+		t.Errorf("Environ() = %v", env)
+	}
+	if err := m.Setenv("", "x"); err == nil {
+		t.Error("Setenv() with empty key succeeded")
+	}
+
+	if home, _ := m.UserHomeDir(); home != "/home/test" {
+		t.Errorf("UserHomeDir() = %q", home)
+	}
+	if dir, _ := m.UserCacheDir(); dir != "/home/test/.cache" {
+		t.Errorf("UserCacheDir() = %q", dir)
+	}
+	if _, err := m.Stat("/home/test"); err != nil {
+		t.Errorf("home directory was not created: %v", err)
+	}
+
+	m.Clearenv()
+	if _, err := m.UserHomeDir(); err == nil {
+		t.Error("UserHomeDir() without HOME succeeded")
+	}
+}
+
+func TestMemOS_Temp(t *testing.T) {
+	m := NewMemOS()
+
+	dir, err := m.MkdirTemp("", "test-*-dir")
+	if err != nil {
+		t.Fatalf("MkdirTemp() error = %v", err)
+	}
+	if !strings.HasPrefix(dir, "/tmp/test-") || !strings.HasSuffix(dir, "-dir") {
+		t.Errorf("MkdirTemp() = %q", dir)
+	}
+
+	f, err := m.CreateTemp(dir, "*.txt")
+	if err != nil {
+		t.Fatalf("CreateTemp() error = %v", err)
+	}
+	defer f.Close()
+	if !strings.HasPrefix(f.Name(), dir+"/") {
+		t.Errorf("CreateTemp() name = %q", f.Name())
+	}
+
+	if _, err := m.CreateTemp("", "a/*"); err == nil {
+		t.Error("CreateTemp() with separator in pattern succeeded")
+	}
+}
+
+func TestMemOS_DirFS(t *testing.T) {
+	m := NewMemOS()
+
+	m.MkdirAll("srv/a/b", 0755)
+	m.WriteFile("srv/top.txt", []byte("top"), 0644)
+	m.WriteFile("srv/a/b/deep.txt", []byte("deep"), 0644)
+
+	if err := fstest.TestFS(m.DirFS("srv"), "top.txt", "a/b/deep.txt"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMemOS_CopyFS(t *testing.T) {
+	m := NewMemOS()
+
+	src := fstest.MapFS{
+		"a.txt":     {Data: []byte("a")},
+		"sub/b.txt": {Data: []byte("b"), Mode: 0755},
+	}
+	if err := m.CopyFS("dst", src); err != nil {
+		t.Fatalf("CopyFS() error = %v", err)
+	}
+
+	if data, _ := m.ReadFile("dst/sub/b.txt"); string(data) != "b" {
+		t.Errorf("ReadFile() = %q, want b", data)
+	}
+	if fi, _ := m.Stat("dst/sub/b.txt"); fi.Mode().Perm() != 0755 {
+		t.Errorf("mode = %v, want 0755", fi.Mode().Perm())
+	}
+}
+
+func TestMemOS_Root(t *testing.T) {
+	m := NewMemOS()
+
+	m.MkdirAll("jail/sub", 0755)
+	m.WriteFile("jail/sub/f", []byte("inside"), 0644)
+	m.WriteFile("outside", []byte("outside"), 0644)
+	m.Symlink("sub/f", "jail/good")
+	m.Symlink("../outside", "jail/bad")
+	m.Symlink("/home/gopher/outside", "jail/abs")
+
+	r, err := m.OpenRoot("jail")
+	if err != nil {
+		t.Fatalf("OpenRoot() error = %v", err)
+	}
+	defer r.Close()
+
+	f, err := r.Open("good")
+	if err != nil {
+		t.Fatalf("Open(good) error = %v", err)
+	}
+	data, _ := io.ReadAll(f)
+	f.Close()
+	if string(data) != "inside" {
+		t.Errorf("read = %q, want inside", data)
+	}
+
+	if _, err := r.Open("sub/../sub/f"); err != nil {
+		t.Errorf("Open(sub/../sub/f) error = %v", err)
+	}
+
+	for _, name := range []string{"../outside", "bad", "abs", "/home/gopher/outside"} {
+		_, err := r.Open(name)
+		var pe *PathError
+		if !errors.As(err, &pe) || pe.Op != "openat" || pe.Err.Error() != "path escapes from parent" {
+			t.Errorf("Open(%q) error = %v, want path escapes from parent", name, err)
+		}
+	}
+
+	if err := r.Mkdir("new", 0755); err != nil {
+		t.Errorf("Mkdir() error = %v", err)
+	}
+	if _, err := m.Stat("jail/new"); err != nil {
+		t.Errorf("directory made in root is missing: %v", err)
+	}
+
+	sub, _ := stdfs.Sub(r.FS(), "sub")
+	if err := fstest.TestFS(sub, "f"); err != nil {
+		t.Error(err)
+	}
+
+	r.Close()
+	if _, err := r.Stat("sub"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Stat() after Close() error = %v, want ErrClosed", err)
+	}
+
+	f, err = m.OpenInRoot("jail", "sub/f")
+	if err != nil {
+		t.Fatalf("OpenInRoot() error = %v", err)
+	}
+	f.Close()
+}
+
+func TestMemOS_Pipe(t *testing.T) {
+	m := NewMemOS()
+
+	r, w, err := m.Pipe()
+	if err != nil {
+		t.Fatalf("Pipe() error = %v", err)
+	}
+
+	go func() {
+		w.Write([]byte("through the pipe"))
+		w.Close()
+	}()
+
+	data, err := io.ReadAll(r)
+	if err != nil || string(data) != "through the pipe" {
+		t.Errorf("ReadAll() = %q, %v", data, err)
+	}
+	r.Close()
+}
+
+func TestMemOS_StdStreams(t *testing.T) {
+	var out strings.Builder
+	m := NewMemOS(WithStdin(strings.NewReader("input")), WithStdout(&out))
+
+	data, _ := io.ReadAll(m.Stdin())
+	if string(data) != "input" {
+		t.Errorf("Stdin() read %q, want input", data)
+	}
+
+	m.Stdout().WriteString("output")
+	if out.String() != "output" {
+		t.Errorf("Stdout() wrote %q, want output", out.String())
+	}
+}
+
+func TestMemOS_Exit(t *testing.T) {
+	var code = -1
+	m := NewMemOS(WithExitFunc(func(c int) { code = c }))
+
+	m.Exit(3)
+	if code != 3 {
+		t.Errorf("exit code = %d, want 3", code)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Exit() without an exit function did not panic")
+		}
+	}()
+	NewMemOS().Exit(1)
+}
+
+func TestMemOS_StatAccessors(t *testing.T) {
+	m := NewMemOS()
+	if err := m.WriteFile("/tmp/a", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Link("/tmp/a", "/tmp/b"); err != nil {
+		t.Fatal(err)
+	}
+
+	a, _ := m.Stat("/tmp/a")
+	b, _ := m.Stat("/tmp/b")
+
+	if uid, ok := a.Owner(); !ok || uid != 1000 {
+		t.Errorf("Owner() = %d, %v, want 1000", uid, ok)
+	}
+	if n, ok := a.Links(); !ok || n != 2 {
+		t.Errorf("Links() = %d, %v, want 2", n, ok)
+	}
+	ia, _ := a.Inode()
+	ib, _ := b.Inode()
+	if ia == 0 || ia != ib {
+		t.Errorf("Inode() of hard links = %d and %d, want the same", ia, ib)
+	}
+	if ct, ok := a.Ctime(); !ok || ct.IsZero() {
+		t.Errorf("Ctime() = %v, %v", ct, ok)
+	}
+}
@@ -0,0 +1,183 @@
+package os
+
+import (
+	"errors"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/pdutton/go-interfaces/io/fs"
+)
+
+// memRoot is a Root of an in-memory OS.  Like os.Root it refers to the
+// directory itself, so it follows the directory if it is renamed, and
+// paths that leave the directory, through ".." or a symbolic link, are
+// rejected.
+type memRoot struct {
+	os     *memOS
+	name   string
+	at     memAt
+	closed atomic.Bool
+}
+
+func (r *memRoot) check(op, name string) error {
+	if r.closed.Load() {
+		return &PathError{Op: op, Path: name, Err: ErrClosed}
+	}
+	return nil
+}
+
+// Nub returns nil, as there is no os.Root behind an in-memory Root.
+func (r *memRoot) Nub() *os.Root {
+	return nil
+}
+
+func (r *memRoot) Close() error {
+	r.closed.Store(true)
+	return nil
+}
+
+func (r *memRoot) Create(name string) (File, error) {
+	return r.OpenFile(name, O_RDWR|O_CREATE|O_TRUNC, 0666)
+}
+
+func (r *memRoot) FS() fs.FS {
+	return newDirFS(r, "")
+}
+
+func (r *memRoot) Lstat(name string) (FileInfo, error) {
+	if err := r.check("lstatat", name); err != nil {
+		return nil, err
+	}
+	return r.os.stat(r.at, "lstatat", name, false)
+}
+
+func (r *memRoot) Mkdir(name string, perm FSFileMode) error {
+	if perm&0777 != perm {
+		return &PathError{Op: "mkdirat", Path: name, Err: errors.New("unsupported file mode")}
+	}
+	if err := r.check("mkdirat", name); err != nil {
+		return err
+	}
+	return r.os.mkdir(r.at, "mkdirat", name, perm)
+}
+
+func (r *memRoot) Name() string {
+	return r.name
+}
+
+func (r *memRoot) Open(name string) (File, error) {
+	return r.OpenFile(name, O_RDONLY, 0)
+}
+
+func (r *memRoot) OpenFile(name string, flag int, perm FSFileMode) (File, error) {
+	if perm&0777 != perm {
+		return nil, &PathError{Op: "openat", Path: name, Err: errors.New("unsupported file mode")}
+	}
+	if err := r.check("openat", name); err != nil {
+		return nil, err
+	}
+
+	f, err := r.os.openFile(r.at, "openat", name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (r *memRoot) OpenRoot(name string) (Root, error) {
+	if err := r.check("openat", name); err != nil {
+		return nil, err
+	}
+	return r.os.openRoot(r.at, "openat", name)
+}
+
+func (r *memRoot) Remove(name string) error {
+	if err := r.check("removeat", name); err != nil {
+		return err
+	}
+	return r.os.remove(r.at, "removeat", name)
+}
+
+func (r *memRoot) Stat(name string) (FileInfo, error) {
+	if err := r.check("statat", name); err != nil {
+		return nil, err
+	}
+	return r.os.stat(r.at, "statat", name, true)
+}
+
+func (r *memRoot) Chmod(name string, mode FSFileMode) error {
+	if err := r.check("chmodat", name); err != nil {
+		return err
+	}
+	return r.os.chmod(r.at, "chmodat", name, mode)
+}
+
+func (r *memRoot) Chown(name string, uid, gid int) error {
+	if err := r.check("chownat", name); err != nil {
+		return err
+	}
+	return r.os.chown(r.at, "chownat", name, uid, gid, true)
+}
+
+func (r *memRoot) Chtimes(name string, atime, mtime time.Time) error {
+	if err := r.check("chtimesat", name); err != nil {
+		return err
+	}
+	return r.os.chtimes(r.at, "chtimesat", name, atime, mtime)
+}
+
+func (r *memRoot) Lchown(name string, uid, gid int) error {
+	if err := r.check("lchownat", name); err != nil {
+		return err
+	}
+	return r.os.chown(r.at, "lchownat", name, uid, gid, false)
+}
+
+func (r *memRoot) Link(oldname, newname string) error {
+	if r.closed.Load() {
+		return &LinkError{Op: "linkat", Old: oldname, New: newname, Err: ErrClosed}
+	}
+	return r.os.hardlink(r.at, "linkat", oldname, newname)
+}
+
+func (r *memRoot) MkdirAll(name string, perm FSFileMode) error {
+	if perm&0777 != perm {
+		return &PathError{Op: "mkdirat", Path: name, Err: errors.New("unsupported file mode")}
+	}
+	return mkdirAll(r, name, perm)
+}
+
+func (r *memRoot) ReadFile(name string) ([]byte, error) {
+	return readFile(r, name)
+}
+
+func (r *memRoot) Readlink(name string) (string, error) {
+	if err := r.check("readlinkat", name); err != nil {
+		return "", err
+	}
+	return r.os.readlink(r.at, "readlinkat", name)
+}
+
+func (r *memRoot) RemoveAll(name string) error {
+	return removeAll(r, name)
+}
+
+func (r *memRoot) Rename(oldname, newname string) error {
+	if r.closed.Load() {
+		return &LinkError{Op: "renameat", Old: oldname, New: newname, Err: ErrClosed}
+	}
+	return r.os.rename(r.at, "renameat", oldname, newname)
+}
+
+// Symlink does not check oldname, which may point outside the Root.
+func (r *memRoot) Symlink(oldname, newname string) error {
+	if r.closed.Load() {
+		return &LinkError{Op: "symlinkat", Old: oldname, New: newname, Err: ErrClosed}
+	}
+	return r.os.symlink(r.at, "symlinkat", oldname, newname)
+}
+
+func (r *memRoot) WriteFile(name string, data []byte, perm FSFileMode) error {
+	return writeFile(r, name, data, perm)
+}
@@ -7,86 +7,41 @@ import (
 	"github.com/pdutton/go-interfaces/io/fs"
 )
 
+// OS mirrors the functions and variables of package os.  Its methods
+// are grouped by role in the interfaces it embeds, which can be used
+// on their own.
 type OS interface {
+	Env
+	FileReader
+	FileWriter
+	DirManager
+	ProcessInfo
+	UserDirs
+	ProcessStarter
+
 	// Access to global variables:
 	Stdin() File
 	Stderr() File
 	Stdout() File
 
-	Args() []string
-
 	// Functions:
-	Chdir(string) error
-	Chmod(string, FSFileMode) error
-	Chown(string, int, int) error
-	Chtimes(string, time.Time, time.Time) error
-	Clearenv()
 	CopyFS(string, fs.FS) error
 	DirFS(string) fs.FS
-	Environ() []string
-	Executable() (string, error)
 	Exit(int)
 	Expand(string, func(string) string) string
-	ExpandEnv(string) string
-	Getegid() int
-	Getenv(string) string
-	Geteuid() int
-	Getgid() int
-	Getgroups() ([]int, error)
-	Getpagesize() int
-	Getpid() int
-	Getppid() int
-	Getuid() int
-	Getwd() (string, error)
-	Hostname() (string, error)
 	IsExist(error) bool    // Deprecated
 	IsNotExist(error) bool // Deprecated
 	IsPathSeparator(uint8) bool
 	IsPermission(error) bool // Deprecated
 	IsTimeout(error) bool    // Deprecated
-	Lchown(string, int, int) error
-	Link(string, string) error
-	LookupEnv(string) (string, bool)
-	Mkdir(string, FSFileMode) error
-	MkdirAll(string, FSFileMode) error
-	MkdirTemp(string, string) (string, error)
 	NewSyscallError(string, error) error
 	Pipe() (File, File, error)
-	ReadFile(string) ([]byte, error)
-	Readlink(string) (string, error)
-	Remove(string) error
-	RemoveAll(string) error
-	Rename(string, string) error
 	SameFile(FileInfo, FileInfo) bool
-	Setenv(string, string) error
-	Symlink(string, string) error
-	TempDir() string
-	Truncate(string, int64) error
-	Unsetenv(string) error
-	UserCacheDir() (string, error)
-	UserConfigDir() (string, error)
-	UserHomeDir() (string, error)
-	WriteFile(string, []byte, FSFileMode) error
-
-	// DirEntry constructors
-	ReadDir(string) ([]DirEntry, error)
 
 	// File constructors
-	Create(string) (File, error)
-	CreateTemp(string, string) (File, error)
 	NewFile(uintptr, string) File
-	Open(string) (File, error)
-	OpenFile(string, int, FSFileMode) (File, error)
 	OpenInRoot(string, string) (File, error)
 
-	// FileInfo constructors
-	Lstat(string) (FileInfo, error)
-	Stat(string) (FileInfo, error)
-
-	// Process constructors
-	FindProcess(int) (Process, error)
-	StartProcess(string, []string, *ProcAttr) (Process, error)
-
 	// Root constructors
 	OpenRoot(string) (Root, error)
 }
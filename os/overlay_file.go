@@ -0,0 +1,191 @@
+package os
+
+import (
+	"io"
+	stdfs "io/fs"
+	"path"
+	"sync"
+	"syscall"
+
+	"github.com/pdutton/go-interfaces/io/fs"
+)
+
+// overlayFile is an open file of an overlay OS.  The file itself is
+// opened in one of the layers, but changes to its metadata go through
+// the overlay, so that they never reach the base, and directories list
+// the entries of both layers.
+type overlayFile struct {
+	File
+
+	os   *overlayOS
+	name string
+	path string
+
+	mu       sync.Mutex
+	closed   bool
+	dirNames []string
+	dirPos   int
+}
+
+func (f *overlayFile) Name() string {
+	return f.name
+}
+
+// Unwrap returns the file of the layer it was opened in, for LockerFor.
+func (f *overlayFile) Unwrap() File {
+	return f.File
+}
+
+func (f *overlayFile) Chdir() error {
+	if err := f.checkValid("chdir"); err != nil {
+		return err
+	}
+
+	f.os.mu.Lock()
+	defer f.os.mu.Unlock()
+
+	fi, err := f.os.exists(f.path)
+	if err == nil && !fi.IsDir() {
+		err = syscall.ENOTDIR
+	}
+	if err != nil {
+		return &PathError{Op: "chdir", Path: f.name, Err: err}
+	}
+
+	f.os.cwd = f.path
+	return nil
+}
+
+func (f *overlayFile) Chmod(mode FSFileMode) error {
+	if err := f.checkValid("chmod"); err != nil {
+		return err
+	}
+	return f.rename(f.os.Chmod(f.path, mode))
+}
+
+func (f *overlayFile) Chown(uid, gid int) error {
+	if err := f.checkValid("chown"); err != nil {
+		return err
+	}
+	return f.rename(f.os.Chown(f.path, uid, gid))
+}
+
+func (f *overlayFile) Close() error {
+	f.mu.Lock()
+	f.closed = true
+	f.mu.Unlock()
+
+	return f.File.Close()
+}
+
+func (f *overlayFile) ReadDir(n int) ([]DirEntry, error) {
+	infos, err := f.readdir("readdir", n)
+
+	var dea = []DirEntry{}
+	for _, fi := range infos {
+		dea = append(dea, fs.NewDirEntry(stdfs.FileInfoToDirEntry(fi.Nub())))
+	}
+
+	return dea, err
+}
+
+func (f *overlayFile) Readdir(n int) ([]FileInfo, error) {
+	return f.readdir("readdir", n)
+}
+
+func (f *overlayFile) Readdirnames(n int) ([]string, error) {
+	infos, err := f.readdir("readdirent", n)
+
+	var names = []string{}
+	for _, fi := range infos {
+		names = append(names, fi.Name())
+	}
+
+	return names, err
+}
+
+// readdir returns the next n entries of both layers, or all the
+// remaining ones if n <= 0, in the manner of os.File.Readdir.
+func (f *overlayFile) readdir(op string, n int) ([]FileInfo, error) {
+	if err := f.checkValid(op); err != nil {
+		return nil, err
+	}
+
+	f.os.mu.Lock()
+	defer f.os.mu.Unlock()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.dirNames == nil {
+		fi, err := f.os.exists(f.path)
+		if err == nil && !fi.IsDir() {
+			err = syscall.ENOTDIR
+		}
+		if err == nil {
+			f.dirNames, err = f.os.list(f.path)
+		}
+		if err != nil {
+			return nil, &PathError{Op: "readdirent", Path: f.name, Err: err}
+		}
+	}
+
+	var infos = []FileInfo{}
+	for f.dirPos < len(f.dirNames) && (n <= 0 || len(infos) < n) {
+		name := f.dirNames[f.dirPos]
+		f.dirPos++
+
+		// Skip entries that were removed since the directory was read.
+		if fi, err := f.os.exists(joinPath(f.path, name)); err == nil {
+			infos = append(infos, fi)
+		}
+	}
+
+	if n > 0 && len(infos) == 0 {
+		return infos, io.EOF
+	}
+	return infos, nil
+}
+
+func (f *overlayFile) Seek(offset int64, whence int) (int64, error) {
+	ret, err := f.File.Seek(offset, whence)
+	if err == nil && ret == 0 {
+		f.mu.Lock()
+		f.dirNames = nil
+		f.dirPos = 0
+		f.mu.Unlock()
+	}
+	return ret, err
+}
+
+// Stat names the file after the name it was opened by, which may be
+// a symbolic link.
+func (f *overlayFile) Stat() (FileInfo, error) {
+	fi, err := f.File.Stat()
+	if err != nil {
+		return nil, f.rename(err)
+	}
+
+	if base := path.Base(f.name); base != fi.Name() && f.path != "/" {
+		return fs.NewFileInfo(renamedInfo{FileInfo: fi.Nub(), name: base}), nil
+	}
+	return fi, nil
+}
+
+func (f *overlayFile) checkValid(op string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return &PathError{Op: op, Path: f.name, Err: ErrClosed}
+	}
+	return nil
+}
+
+// rename reports errors in terms of the name the file was opened by
+// rather than the path it was resolved to.
+func (f *overlayFile) rename(err error) error {
+	if pe, ok := err.(*PathError); ok {
+		return &PathError{Op: pe.Op, Path: f.name, Err: pe.Err}
+	}
+	return err
+}
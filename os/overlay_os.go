@@ -0,0 +1,1199 @@
+package os
+
+import (
+	"bytes"
+	"errors"
+	stdfs "io/fs"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pdutton/go-interfaces/io/fs"
+)
+
+// OverlayOS is an OS that reads through to a base OS, but keeps every
+// change to the file system in an in-memory layer on top of it, so
+// that the base is never modified.  Anything other than the file
+// system, such as the environment, is passed through to the base.
+type OverlayOS interface {
+	OS
+
+	// Changes lists the paths that differ from the base, sorted by name
+	Changes() ([]Change, error)
+
+	// Reset discards all changes, so that the base shows through again
+	Reset()
+}
+
+// ChangeKind says how a path differs from the base of an OverlayOS
+type ChangeKind int
+
+const (
+	ChangeAdded ChangeKind = iota + 1
+	ChangeModified
+	ChangeRemoved
+)
+
+// String returns "A", "M" or "D", as git status would.
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeAdded:
+		return "A"
+	case ChangeModified:
+		return "M"
+	case ChangeRemoved:
+		return "D"
+	}
+	return "?"
+}
+
+// Change is a path that was added, modified or removed in an OverlayOS
+type Change struct {
+	Kind ChangeKind
+	Path string
+}
+
+func (c Change) String() string {
+	return c.Kind.String() + " " + c.Path
+}
+
+// overlayOS keeps changes in upper, an in-memory OS in which the user
+// is root so that copying entries up is never refused.  Permissions
+// are instead checked by the overlay, against the owner bits of the
+// mode, as the ownership of files in the base is not portably known.
+//
+// Paths are resolved by the overlay itself, so the layers only ever
+// see absolute paths without symbolic links.  A path that exists in
+// upper hides the one in the base.  Removing an entry of the base
+// leaves a whiteout for it, and a directory that was removed and then
+// created again is opaque: none of the base's entries show through it.
+type overlayOS struct {
+	mu sync.Mutex
+
+	base      OS
+	upper     *memOS
+	cwd       string
+	whiteouts map[string]bool
+	opaque    map[string]bool
+}
+
+// NewOverlayOS creates an OverlayOS on top of base.  The working
+// directory starts out as that of base, and Chdir changes it for the
+// overlay only.
+func NewOverlayOS(base OS) OverlayOS {
+	var o = &overlayOS{
+		base: base,
+		cwd:  "/",
+	}
+	if wd, err := base.Getwd(); err == nil {
+		o.cwd = path.Clean(wd)
+	}
+	o.reset()
+
+	return o
+}
+
+func (o *overlayOS) reset() {
+	o.upper = newMemOS(WithUser(0, 0), WithWorkingDir("/"))
+	o.whiteouts = map[string]bool{}
+	o.opaque = map[string]bool{}
+}
+
+func (o *overlayOS) Reset() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.reset()
+}
+
+func joinPath(dir, name string) string {
+	if dir == "/" {
+		return dir + name
+	}
+	return dir + "/" + name
+}
+
+// hidden reports whether the base's entry for p is covered by a
+// whiteout or an opaque directory.
+func (o *overlayOS) hidden(p string) bool {
+	if o.whiteouts[p] {
+		return true
+	}
+	for dir := p; dir != "/"; {
+		dir = path.Dir(dir)
+		if o.whiteouts[dir] || o.opaque[dir] {
+			return true
+		}
+	}
+	return false
+}
+
+// inBase reports whether p exists in the base, whether or not it is
+// hidden.
+func (o *overlayOS) inBase(p string) bool {
+	for dir := p; dir != "/"; {
+		dir = path.Dir(dir)
+		if o.whiteouts[dir] || o.opaque[dir] {
+			return false
+		}
+	}
+	_, err := o.base.Lstat(p)
+	return err == nil
+}
+
+// lstat looks p up in upper, and then in the base.  It also reports
+// whether p was found in upper.
+func (o *overlayOS) lstat(p string) (FileInfo, bool, error) {
+	fi, err := o.upper.Lstat(p)
+	if err == nil {
+		return fi, true, nil
+	}
+	if !errors.Is(err, ErrNotExist) {
+		return nil, false, underlyingError(err)
+	}
+	if o.hidden(p) {
+		return nil, false, syscall.ENOENT
+	}
+
+	fi, err = o.base.Lstat(p)
+	if err != nil {
+		return nil, false, underlyingError(err)
+	}
+	return fi, false, nil
+}
+
+// exists is lstat for callers that only care about the error.
+func (o *overlayOS) exists(p string) (FileInfo, error) {
+	fi, _, err := o.lstat(p)
+	return fi, err
+}
+
+func (o *overlayOS) readlink(p string, upper bool) (string, error) {
+	var (
+		target string
+		err    error
+	)
+	if upper {
+		target, err = o.upper.Readlink(p)
+	} else {
+		target, err = o.base.Readlink(p)
+	}
+	return target, underlyingError(err)
+}
+
+// resolve turns name into an absolute path without symbolic links,
+// following them in every element but the last, which is only
+// followed if follow is set.  A last element that does not exist is
+// not an error.
+func (o *overlayOS) resolve(name string, follow bool) (string, error) {
+	if name == "" {
+		return "", syscall.ENOENT
+	}
+	if !path.IsAbs(name) {
+		name = o.cwd + "/" + name
+	}
+
+	mustDir := strings.HasSuffix(name, "/")
+	if mustDir {
+		follow = true
+	}
+
+	var (
+		names []string
+		elems = splitPath(name)
+		links int
+	)
+
+	for i := 0; i < len(elems); i++ {
+		elem := elems[i]
+		if elem == ".." {
+			if len(names) > 0 {
+				names = names[:len(names)-1]
+			}
+			continue
+		}
+
+		last := i == len(elems)-1
+		p := joinNames(names, elem)
+
+		fi, upper, err := o.lstat(p)
+		if err != nil {
+			if last && errors.Is(err, ErrNotExist) {
+				return p, nil
+			}
+			return "", err
+		}
+
+		if fi.Mode().IsSymlink() && (follow || !last) {
+			links++
+			if links > memMaxSymlinks {
+				return "", syscall.ELOOP
+			}
+			target, err := o.readlink(p, upper)
+			if err != nil {
+				return "", err
+			}
+			if path.IsAbs(target) {
+				names = nil
+			}
+			elems = append(splitPath(target), elems[i+1:]...)
+			i = -1
+			continue
+		}
+
+		if (!last || mustDir) && !fi.IsDir() {
+			return "", syscall.ENOTDIR
+		}
+		names = append(names, elem)
+	}
+
+	return joinNames(names), nil
+}
+
+// list returns the names in the directory p of both layers.
+func (o *overlayOS) list(p string) ([]string, error) {
+	var names = map[string]bool{}
+
+	if fi, err := o.upper.Lstat(p); err == nil && fi.IsDir() {
+		if err := o.listLayer(o.upper, p, names); err != nil {
+			return nil, err
+		}
+	}
+	if !o.opaque[p] && !o.hidden(p) {
+		if fi, err := o.base.Lstat(p); err == nil && fi.IsDir() {
+			if err := o.listLayer(o.base, p, names); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return slices.Sorted(maps.Keys(names)), nil
+}
+
+func (o *overlayOS) listLayer(layer OS, p string, names map[string]bool) error {
+	f, err := layer.Open(p)
+	if err != nil {
+		return underlyingError(err)
+	}
+	defer f.Close()
+
+	dirNames, err := f.Readdirnames(-1)
+	if err != nil {
+		return underlyingError(err)
+	}
+	for _, name := range dirNames {
+		if !o.whiteouts[joinPath(p, name)] {
+			names[name] = true
+		}
+	}
+	return nil
+}
+
+// mayWrite reports whether the user may write to the file fi.
+func (o *overlayOS) mayWrite(fi FileInfo) bool {
+	return o.base.Geteuid() == 0 || fi.Mode().Perm()&0200 != 0
+}
+
+// mayChange reports whether the user may add or remove entries of the
+// directory dir.
+func (o *overlayOS) mayChange(dir string) error {
+	fi, err := o.exists(dir)
+	if err != nil {
+		return err
+	}
+	if o.base.Geteuid() != 0 && fi.Mode().Perm()&0300 != 0300 {
+		return syscall.EACCES
+	}
+	return nil
+}
+
+// copyUp copies the entry p of the base, and the directories above
+// it, into upper unless it is there already.
+func (o *overlayOS) copyUp(p string) error {
+	if _, err := o.upper.Lstat(p); err == nil {
+		return nil
+	}
+	if err := o.copyUp(path.Dir(p)); err != nil {
+		return err
+	}
+
+	fi, err := o.base.Lstat(p)
+	if err != nil {
+		return underlyingError(err)
+	}
+
+	mode := fi.Mode()
+	switch {
+	case mode.IsDir():
+		err = o.upper.Mkdir(p, 0700)
+	case mode.IsRegular():
+		var data []byte
+		data, err = o.base.ReadFile(p)
+		if err == nil {
+			err = o.upper.WriteFile(p, data, 0600)
+		}
+	case mode.IsSymlink():
+		var target string
+		target, err = o.base.Readlink(p)
+		if err == nil {
+			err = o.upper.Symlink(target, p)
+		}
+		return underlyingError(err)
+	default:
+		err = errors.ErrUnsupported
+	}
+	if err == nil {
+		err = o.upper.Chmod(p, mode.Nub()&(stdfs.ModePerm|stdfs.ModeSetuid|stdfs.ModeSetgid|stdfs.ModeSticky))
+	}
+	if err == nil {
+		err = o.upper.Chtimes(p, fi.ModTime(), fi.ModTime())
+	}
+	return underlyingError(err)
+}
+
+// copyUpTree copies p and everything below it into upper.
+func (o *overlayOS) copyUpTree(p string) error {
+	if err := o.copyUp(p); err != nil {
+		return err
+	}
+
+	fi, err := o.upper.Lstat(p)
+	if err != nil || !fi.IsDir() {
+		return underlyingError(err)
+	}
+	names, err := o.list(p)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := o.copyUpTree(joinPath(p, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// created notes that p was created in upper.  If it replaces an entry
+// of the base that was removed, a directory must not show what was
+// in the base.
+func (o *overlayOS) created(p string, dir bool) {
+	if o.whiteouts[p] {
+		delete(o.whiteouts, p)
+		if dir {
+			o.opaque[p] = true
+		}
+	}
+}
+
+// forget drops the whiteouts and opaque directories below p, which
+// are covered by p itself.
+func (o *overlayOS) forget(p string) {
+	prefix := joinPath(p, "")
+	for _, m := range []map[string]bool{o.whiteouts, o.opaque} {
+		maps.DeleteFunc(m, func(name string, _ bool) bool {
+			return strings.HasPrefix(name, prefix)
+		})
+	}
+}
+
+// renamedInfo is the FileInfo of a path reached through a symbolic
+// link, which is named after the link.
+type renamedInfo struct {
+	stdfs.FileInfo
+	name string
+}
+
+func (fi renamedInfo) Name() string {
+	return fi.name
+}
+
+func (o *overlayOS) stat(op, name string, follow bool) (FileInfo, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	p, err := o.resolve(name, follow)
+	if err != nil {
+		return nil, &PathError{Op: op, Path: name, Err: err}
+	}
+	fi, err := o.exists(p)
+	if err != nil {
+		return nil, &PathError{Op: op, Path: name, Err: err}
+	}
+
+	if base := path.Base(name); base != fi.Name() && p != "/" {
+		return fs.NewFileInfo(renamedInfo{FileInfo: fi.Nub(), name: base}), nil
+	}
+	return fi, nil
+}
+
+// change copies the entry that name resolves to up and applies fn to
+// it in upper.
+func (o *overlayOS) change(op, name string, follow bool, fn func(p string, fi FileInfo) error) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	p, err := o.resolve(name, follow)
+	if err == nil {
+		var fi FileInfo
+		fi, err = o.exists(p)
+		if err == nil {
+			err = o.copyUp(p)
+		}
+		if err == nil {
+			err = underlyingError(fn(p, fi))
+		}
+	}
+	if err != nil {
+		return &PathError{Op: op, Path: name, Err: err}
+	}
+	return nil
+}
+
+func (o *overlayOS) Stdin() File {
+	return o.base.Stdin()
+}
+
+func (o *overlayOS) Stderr() File {
+	return o.base.Stderr()
+}
+
+func (o *overlayOS) Stdout() File {
+	return o.base.Stdout()
+}
+
+func (o *overlayOS) Args() []string {
+	return o.base.Args()
+}
+
+func (o *overlayOS) Chdir(dir string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	p, err := o.resolve(dir, true)
+	if err == nil {
+		var fi FileInfo
+		fi, err = o.exists(p)
+		if err == nil && !fi.IsDir() {
+			err = syscall.ENOTDIR
+		}
+	}
+	if err != nil {
+		return &PathError{Op: "chdir", Path: dir, Err: err}
+	}
+
+	o.cwd = p
+	return nil
+}
+
+func (o *overlayOS) Chmod(name string, mode FSFileMode) error {
+	return o.change("chmod", name, true, func(p string, _ FileInfo) error {
+		return o.upper.Chmod(p, mode)
+	})
+}
+
+func (o *overlayOS) Chown(name string, uid, gid int) error {
+	return o.change("chown", name, true, func(p string, _ FileInfo) error {
+		return o.upper.Chown(p, uid, gid)
+	})
+}
+
+func (o *overlayOS) Chtimes(name string, atime, mtime time.Time) error {
+	return o.change("chtimes", name, true, func(p string, _ FileInfo) error {
+		return o.upper.Chtimes(p, atime, mtime)
+	})
+}
+
+func (o *overlayOS) Clearenv() {
+	o.base.Clearenv()
+}
+
+func (o *overlayOS) CopyFS(dir string, fsys fs.FS) error {
+	return copyFS(o, dir, fsys)
+}
+
+func (o *overlayOS) DirFS(dir string) fs.FS {
+	return newDirFS(o, dir)
+}
+
+func (o *overlayOS) Environ() []string {
+	return o.base.Environ()
+}
+
+func (o *overlayOS) Executable() (string, error) {
+	return o.base.Executable()
+}
+
+func (o *overlayOS) Exit(code int) {
+	o.base.Exit(code)
+}
+
+func (o *overlayOS) Expand(s string, mapping func(string) string) string {
+	return o.base.Expand(s, mapping)
+}
+
+func (o *overlayOS) ExpandEnv(s string) string {
+	return o.base.ExpandEnv(s)
+}
+
+func (o *overlayOS) Getegid() int {
+	return o.base.Getegid()
+}
+
+func (o *overlayOS) Getenv(key string) string {
+	return o.base.Getenv(key)
+}
+
+func (o *overlayOS) Geteuid() int {
+	return o.base.Geteuid()
+}
+
+func (o *overlayOS) Getgid() int {
+	return o.base.Getgid()
+}
+
+func (o *overlayOS) Getgroups() ([]int, error) {
+	return o.base.Getgroups()
+}
+
+func (o *overlayOS) Getpagesize() int {
+	return o.base.Getpagesize()
+}
+
+func (o *overlayOS) Getpid() int {
+	return o.base.Getpid()
+}
+
+func (o *overlayOS) Getppid() int {
+	return o.base.Getppid()
+}
+
+func (o *overlayOS) Getuid() int {
+	return o.base.Getuid()
+}
+
+func (o *overlayOS) Getwd() (string, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.cwd, nil
+}
+
+func (o *overlayOS) Hostname() (string, error) {
+	return o.base.Hostname()
+}
+
+func (o *overlayOS) IsExist(err error) bool {
+	return o.base.IsExist(err)
+}
+
+func (o *overlayOS) IsNotExist(err error) bool {
+	return o.base.IsNotExist(err)
+}
+
+func (o *overlayOS) IsPathSeparator(c uint8) bool {
+	return o.base.IsPathSeparator(c)
+}
+
+func (o *overlayOS) IsPermission(err error) bool {
+	return o.base.IsPermission(err)
+}
+
+func (o *overlayOS) IsTimeout(err error) bool {
+	return o.base.IsTimeout(err)
+}
+
+func (o *overlayOS) Lchown(name string, uid, gid int) error {
+	return o.change("lchown", name, false, func(p string, _ FileInfo) error {
+		return o.upper.Lchown(p, uid, gid)
+	})
+}
+
+func (o *overlayOS) Link(oldname, newname string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if err := o.link(oldname, newname); err != nil {
+		return &LinkError{Op: "link", Old: oldname, New: newname, Err: err}
+	}
+	return nil
+}
+
+func (o *overlayOS) link(oldname, newname string) error {
+	op, err := o.resolve(oldname, false)
+	if err != nil {
+		return err
+	}
+	np, err := o.resolve(newname, false)
+	if err != nil {
+		return err
+	}
+
+	ofi, err := o.exists(op)
+	if err != nil {
+		return err
+	}
+	if ofi.IsDir() {
+		return syscall.EPERM
+	}
+	if _, err := o.exists(np); err == nil {
+		return syscall.EEXIST
+	}
+	if err := o.mayChange(path.Dir(np)); err != nil {
+		return err
+	}
+
+	if err := o.copyUp(op); err != nil {
+		return err
+	}
+	if err := o.copyUp(path.Dir(np)); err != nil {
+		return err
+	}
+	if err := o.upper.Link(op, np); err != nil {
+		return underlyingError(err)
+	}
+
+	o.created(np, false)
+	return nil
+}
+
+func (o *overlayOS) LookupEnv(key string) (string, bool) {
+	return o.base.LookupEnv(key)
+}
+
+func (o *overlayOS) Mkdir(name string, perm FSFileMode) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if err := o.mkdir(name, perm); err != nil {
+		return &PathError{Op: "mkdir", Path: name, Err: err}
+	}
+	return nil
+}
+
+func (o *overlayOS) mkdir(name string, perm FSFileMode) error {
+	p, err := o.resolve(name, false)
+	if err != nil {
+		return err
+	}
+
+	if _, err := o.exists(p); err == nil {
+		return syscall.EEXIST
+	} else if !errors.Is(err, ErrNotExist) {
+		return err
+	}
+	if err := o.mayChange(path.Dir(p)); err != nil {
+		return err
+	}
+
+	if err := o.copyUp(path.Dir(p)); err != nil {
+		return err
+	}
+	if err := o.upper.Mkdir(p, perm); err != nil {
+		return underlyingError(err)
+	}
+
+	o.created(p, true)
+	return nil
+}
+
+func (o *overlayOS) MkdirAll(name string, perm FSFileMode) error {
+	return mkdirAll(o, name, perm)
+}
+
+func (o *overlayOS) MkdirTemp(dir, pattern string) (string, error) {
+	return mkdirTemp(o, dir, pattern)
+}
+
+func (o *overlayOS) NewSyscallError(syscall string, err error) error {
+	return o.base.NewSyscallError(syscall, err)
+}
+
+func (o *overlayOS) Pipe() (File, File, error) {
+	return o.base.Pipe()
+}
+
+func (o *overlayOS) ReadFile(name string) ([]byte, error) {
+	return readFile(o, name)
+}
+
+func (o *overlayOS) Readlink(name string) (string, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	p, err := o.resolve(name, false)
+	if err == nil {
+		var (
+			fi    FileInfo
+			upper bool
+		)
+		fi, upper, err = o.lstat(p)
+		if err == nil && !fi.Mode().IsSymlink() {
+			err = syscall.EINVAL
+		}
+		if err == nil {
+			var target string
+			if target, err = o.readlink(p, upper); err == nil {
+				return target, nil
+			}
+		}
+	}
+
+	return "", &PathError{Op: "readlink", Path: name, Err: err}
+}
+
+func (o *overlayOS) Remove(name string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if err := o.remove(name); err != nil {
+		return &PathError{Op: "remove", Path: name, Err: err}
+	}
+	return nil
+}
+
+func (o *overlayOS) remove(name string) error {
+	p, err := o.resolve(name, false)
+	if err != nil {
+		return err
+	}
+
+	fi, upper, err := o.lstat(p)
+	if err != nil {
+		return err
+	}
+	if p == "/" {
+		return syscall.EBUSY
+	}
+	if err := o.mayChange(path.Dir(p)); err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		names, err := o.list(p)
+		if err != nil {
+			return err
+		}
+		if len(names) > 0 {
+			return syscall.ENOTEMPTY
+		}
+	}
+
+	if upper {
+		if err := o.upper.Remove(p); err != nil {
+			return underlyingError(err)
+		}
+	}
+	if o.inBase(p) {
+		o.whiteouts[p] = true
+	}
+	delete(o.opaque, p)
+	o.forget(p)
+
+	return nil
+}
+
+func (o *overlayOS) RemoveAll(name string) error {
+	return removeAll(o, name)
+}
+
+func (o *overlayOS) Rename(oldpath, newpath string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if err := o.rename(oldpath, newpath); err != nil {
+		return &LinkError{Op: "rename", Old: oldpath, New: newpath, Err: err}
+	}
+	return nil
+}
+
+func (o *overlayOS) rename(oldpath, newpath string) error {
+	op, err := o.resolve(oldpath, false)
+	if err != nil {
+		return err
+	}
+	np, err := o.resolve(newpath, false)
+	if err != nil {
+		return err
+	}
+
+	ofi, err := o.exists(op)
+	if err != nil {
+		return err
+	}
+	nfi, err := o.exists(np)
+	replace := err == nil
+	if err != nil && !errors.Is(err, ErrNotExist) {
+		return err
+	}
+
+	switch {
+	// Like os.Rename, refuse to replace a directory.
+	case replace && nfi.IsDir() && (oldpath == newpath || op != np):
+		return syscall.EEXIST
+	case op == np:
+		return nil
+	case op == "/" || np == "/":
+		return syscall.EBUSY
+	case ofi.IsDir() && strings.HasPrefix(np, op+"/"):
+		return syscall.EINVAL
+	case replace && ofi.IsDir() && !nfi.IsDir():
+		return syscall.ENOTDIR
+	}
+	if err := o.mayChange(path.Dir(op)); err != nil {
+		return err
+	}
+	if err := o.mayChange(path.Dir(np)); err != nil {
+		return err
+	}
+
+	if err := o.copyUpTree(op); err != nil {
+		return err
+	}
+	if err := o.copyUp(path.Dir(np)); err != nil {
+		return err
+	}
+	inBase := o.inBase(op)
+	if err := o.upper.Rename(op, np); err != nil {
+		return underlyingError(err)
+	}
+
+	if inBase {
+		o.whiteouts[op] = true
+	}
+	o.forget(op)
+	delete(o.whiteouts, np)
+	if ofi.IsDir() {
+		// Everything in the directory was copied up.
+		o.opaque[np] = true
+	}
+	return nil
+}
+
+func (o *overlayOS) SameFile(fi1, fi2 FileInfo) bool {
+	if fi1 == nil || fi2 == nil {
+		return false
+	}
+
+	// Look behind the names given to entries reached through links.
+	unwrap := func(fi FileInfo) FileInfo {
+		if ri, ok := fi.Nub().(renamedInfo); ok {
+			return fs.NewFileInfo(ri.FileInfo)
+		}
+		return fi
+	}
+	fi1, fi2 = unwrap(fi1), unwrap(fi2)
+
+	_, mem1 := fi1.Nub().(*memFileInfo)
+	_, mem2 := fi2.Nub().(*memFileInfo)
+	switch {
+	case mem1 && mem2:
+		return o.upper.SameFile(fi1, fi2)
+	case mem1 || mem2:
+		return false
+	}
+	return o.base.SameFile(fi1, fi2)
+}
+
+func (o *overlayOS) Setenv(key, value string) error {
+	return o.base.Setenv(key, value)
+}
+
+func (o *overlayOS) Symlink(oldname, newname string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if err := o.symlink(oldname, newname); err != nil {
+		return &LinkError{Op: "symlink", Old: oldname, New: newname, Err: err}
+	}
+	return nil
+}
+
+func (o *overlayOS) symlink(oldname, newname string) error {
+	np, err := o.resolve(newname, false)
+	if err != nil {
+		return err
+	}
+
+	if _, err := o.exists(np); err == nil {
+		return syscall.EEXIST
+	}
+	if err := o.mayChange(path.Dir(np)); err != nil {
+		return err
+	}
+
+	if err := o.copyUp(path.Dir(np)); err != nil {
+		return err
+	}
+	if err := o.upper.Symlink(oldname, np); err != nil {
+		return underlyingError(err)
+	}
+
+	o.created(np, false)
+	return nil
+}
+
+func (o *overlayOS) TempDir() string {
+	return o.base.TempDir()
+}
+
+func (o *overlayOS) Truncate(name string, size int64) error {
+	return o.change("truncate", name, true, func(p string, fi FileInfo) error {
+		switch {
+		case fi.IsDir():
+			return syscall.EISDIR
+		case !o.mayWrite(fi):
+			return syscall.EACCES
+		}
+		return o.upper.Truncate(p, size)
+	})
+}
+
+func (o *overlayOS) Unsetenv(key string) error {
+	return o.base.Unsetenv(key)
+}
+
+func (o *overlayOS) UserCacheDir() (string, error) {
+	return o.base.UserCacheDir()
+}
+
+func (o *overlayOS) UserConfigDir() (string, error) {
+	return o.base.UserConfigDir()
+}
+
+func (o *overlayOS) UserHomeDir() (string, error) {
+	return o.base.UserHomeDir()
+}
+
+func (o *overlayOS) WriteFile(name string, data []byte, perm FSFileMode) error {
+	return writeFile(o, name, data, perm)
+}
+
+func (o *overlayOS) ReadDir(name string) ([]DirEntry, error) {
+	return readDir(o, name)
+}
+
+func (o *overlayOS) Create(name string) (File, error) {
+	return o.OpenFile(name, O_RDWR|O_CREATE|O_TRUNC, 0666)
+}
+
+func (o *overlayOS) CreateTemp(dir, pattern string) (File, error) {
+	return createTemp(o, dir, pattern)
+}
+
+func (o *overlayOS) NewFile(fd uintptr, name string) File {
+	return o.base.NewFile(fd, name)
+}
+
+func (o *overlayOS) Open(name string) (File, error) {
+	return o.OpenFile(name, O_RDONLY, 0)
+}
+
+func (o *overlayOS) OpenFile(name string, flag int, perm FSFileMode) (File, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	f, err := o.openFile(name, flag, perm)
+	if err != nil {
+		return nil, &PathError{Op: "open", Path: name, Err: err}
+	}
+	return f, nil
+}
+
+func (o *overlayOS) openFile(name string, flag int, perm FSFileMode) (File, error) {
+	excl := flag&(O_CREATE|O_EXCL) == O_CREATE|O_EXCL
+	p, err := o.resolve(name, !excl)
+	if err != nil {
+		return nil, err
+	}
+
+	acc := flag & (O_RDONLY | O_WRONLY | O_RDWR)
+	fi, upper, err := o.lstat(p)
+	switch {
+	case err == nil && excl:
+		return nil, syscall.EEXIST
+	case err == nil && fi.IsDir() && (acc != O_RDONLY || flag&O_CREATE != 0):
+		return nil, syscall.EISDIR
+	case err == nil && acc != O_RDONLY:
+		if !o.mayWrite(fi) {
+			return nil, syscall.EACCES
+		}
+		if err := o.copyUp(p); err != nil {
+			return nil, err
+		}
+		upper = true
+	case err == nil:
+	case errors.Is(err, ErrNotExist) && flag&O_CREATE != 0:
+		if err := o.mayChange(path.Dir(p)); err != nil {
+			return nil, err
+		}
+		if err := o.copyUp(path.Dir(p)); err != nil {
+			return nil, err
+		}
+		upper = true
+	default:
+		return nil, err
+	}
+
+	var f File
+	if upper {
+		f, err = o.upper.OpenFile(p, flag, perm)
+	} else {
+		f, err = o.base.OpenFile(p, flag, perm)
+	}
+	if err != nil {
+		return nil, underlyingError(err)
+	}
+	if flag&O_CREATE != 0 {
+		o.created(p, false)
+	}
+
+	return &overlayFile{File: f, os: o, name: name, path: p}, nil
+}
+
+func (o *overlayOS) OpenInRoot(dir, name string) (File, error) {
+	return openInRoot(o, dir, name)
+}
+
+func (o *overlayOS) Lstat(name string) (FileInfo, error) {
+	return o.stat("lstat", name, false)
+}
+
+func (o *overlayOS) Stat(name string) (FileInfo, error) {
+	return o.stat("stat", name, true)
+}
+
+func (o *overlayOS) FindProcess(pid int) (Process, error) {
+	return o.base.FindProcess(pid)
+}
+
+func (o *overlayOS) StartProcess(name string, argv []string, attr *ProcAttr) (Process, error) {
+	return o.base.StartProcess(name, argv, attr)
+}
+
+func (o *overlayOS) OpenRoot(name string) (Root, error) {
+	return newPathRoot(o, name)
+}
+
+func (o *overlayOS) Changes() ([]Change, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var changes = map[string]ChangeKind{}
+
+	// Everything in upper was either added or copied up, possibly
+	// without being changed.
+	var walk func(p string) error
+	walk = func(p string) error {
+		ufi, err := o.upper.Lstat(p)
+		if err != nil {
+			return err
+		}
+
+		if p == "/" {
+			// The root is never copied up.
+		} else if !o.inBase(p) {
+			changes[p] = ChangeAdded
+		} else if differs, err := o.differs(p, ufi); err != nil {
+			return err
+		} else if differs {
+			changes[p] = ChangeModified
+		}
+
+		if !ufi.IsDir() {
+			return nil
+		}
+		names, err := o.upper.Open(p)
+		if err != nil {
+			return err
+		}
+		dirNames, err := names.Readdirnames(-1)
+		names.Close()
+		if err != nil {
+			return err
+		}
+		for _, name := range dirNames {
+			if err := walk(joinPath(p, name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk("/"); err != nil {
+		return nil, err
+	}
+
+	for p := range o.whiteouts {
+		if _, ok := changes[p]; !ok && o.inBase(p) {
+			changes[p] = ChangeRemoved
+		}
+	}
+
+	// The entries of the base hidden by an opaque directory.
+	for dir := range o.opaque {
+		err := stdfs.WalkDir(o.base.DirFS(dir), ".", func(name string, d stdfs.DirEntry, err error) error {
+			if err != nil || name == "." {
+				return err
+			}
+			p := joinPath(dir, name)
+			if _, ok := changes[p]; !ok {
+				if _, err := o.upper.Lstat(p); err != nil {
+					changes[p] = ChangeRemoved
+				}
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, ErrNotExist) && !errors.Is(err, syscall.ENOTDIR) {
+			return nil, err
+		}
+	}
+
+	var list = make([]Change, 0, len(changes))
+	for _, p := range slices.Sorted(maps.Keys(changes)) {
+		list = append(list, Change{Kind: changes[p], Path: p})
+	}
+	return list, nil
+}
+
+// differs reports whether the entry p of upper, ufi, differs from that
+// of the base.
+func (o *overlayOS) differs(p string, ufi FileInfo) (bool, error) {
+	bfi, err := o.base.Lstat(p)
+	if err != nil {
+		return false, err
+	}
+
+	umode, bmode := ufi.Mode().Nub(), bfi.Mode().Nub()
+	if umode.Type() != bmode.Type() || umode.Perm() != bmode.Perm() {
+		return true, nil
+	}
+
+	switch {
+	case umode.IsRegular():
+		if ufi.Size() != bfi.Size() {
+			return true, nil
+		}
+		udata, err := o.upper.ReadFile(p)
+		if err != nil {
+			return false, err
+		}
+		bdata, err := o.base.ReadFile(p)
+		if err != nil {
+			return false, err
+		}
+		return !bytes.Equal(udata, bdata), nil
+	case umode&stdfs.ModeSymlink != 0:
+		utarget, err := o.upper.Readlink(p)
+		if err != nil {
+			return false, err
+		}
+		btarget, err := o.base.Readlink(p)
+		if err != nil {
+			return false, err
+		}
+		return utarget != btarget, nil
+	}
+	return false, nil
+}
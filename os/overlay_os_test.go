@@ -0,0 +1,311 @@
+package os
+
+import (
+	"errors"
+	"slices"
+	"syscall"
+	"testing"
+	"testing/fstest"
+)
+
+func changeStrings(t *testing.T, o OverlayOS) []string {
+	t.Helper()
+
+	changes, err := o.Changes()
+	if err != nil {
+		t.Fatalf("Changes() error = %v", err)
+	}
+
+	var list []string
+	for _, c := range changes {
+		list = append(list, c.String())
+	}
+	return list
+}
+
+func TestNewOverlayOS(t *testing.T) {
+	var _ OS = NewOverlayOS(NewMemOS())
+}
+
+func TestOverlayOS_ReadThrough(t *testing.T) {
+	base := NewMemOS(WithUser(0, 0))
+	for _, err := range []error{
+		base.MkdirAll("/fix/dir/sub", 0755),
+		base.WriteFile("/fix/a.txt", []byte("a"), 0644),
+		base.WriteFile("/fix/dir/b.txt", []byte("b"), 0644),
+		base.WriteFile("/fix/dir/sub/c.txt", []byte("c"), 0644),
+		base.Symlink("dir", "/fix/link"),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	o := NewOverlayOS(base)
+
+	data, err := o.ReadFile("/fix/link/sub/c.txt")
+	if err != nil || string(data) != "c" {
+		t.Errorf("ReadFile() = %q, %v, want %q", data, err, "c")
+	}
+
+	fi, err := o.Stat("/fix/link")
+	if err != nil || !fi.IsDir() || fi.Name() != "link" {
+		t.Errorf("Stat() = %v, %v, want directory named link", fi, err)
+	}
+
+	if err := fstest.TestFS(o.DirFS("/fix"), "a.txt", "dir/b.txt", "dir/sub/c.txt"); err != nil {
+		t.Error(err)
+	}
+	if got := changeStrings(t, o); len(got) != 0 {
+		t.Errorf("Changes() = %v, want none", got)
+	}
+}
+
+func TestOverlayOS_Writes(t *testing.T) {
+	base := NewMemOS(WithUser(0, 0))
+	for _, err := range []error{
+		base.MkdirAll("/fix/dir/sub", 0755),
+		base.WriteFile("/fix/a.txt", []byte("a"), 0644),
+		base.WriteFile("/fix/dir/b.txt", []byte("b"), 0644),
+		base.WriteFile("/fix/dir/sub/c.txt", []byte("c"), 0644),
+		base.Symlink("dir", "/fix/link"),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	o := NewOverlayOS(base)
+
+	if err := o.WriteFile("/fix/a.txt", []byte("changed"), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if err := o.WriteFile("/fix/dir/new.txt", []byte("new"), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if err := o.Chmod("/fix/dir/b.txt", 0600); err != nil {
+		t.Fatalf("Chmod() error = %v", err)
+	}
+	if err := o.Remove("/fix/dir/sub/c.txt"); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+
+	if data, _ := o.ReadFile("/fix/a.txt"); string(data) != "changed" {
+		t.Errorf("overlay ReadFile() = %q, want %q", data, "changed")
+	}
+	if data, _ := base.ReadFile("/fix/a.txt"); string(data) != "a" {
+		t.Errorf("base ReadFile() = %q, want %q", data, "a")
+	}
+	if _, err := base.Stat("/fix/dir/new.txt"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("base Stat() error = %v, want ErrNotExist", err)
+	}
+	if fi, _ := base.Stat("/fix/dir/b.txt"); fi.Mode().Perm() != 0644 {
+		t.Errorf("base mode = %v, want 0644", fi.Mode().Perm())
+	}
+	if _, err := o.Stat("/fix/dir/sub/c.txt"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("overlay Stat() error = %v, want ErrNotExist", err)
+	}
+	if _, err := base.Stat("/fix/dir/sub/c.txt"); err != nil {
+		t.Errorf("base Stat() error = %v", err)
+	}
+
+	entries, err := o.ReadDir("/fix/dir")
+	if err != nil {
+		t.Fatalf("ReadDir() error = %v", err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if want := []string{"b.txt", "new.txt", "sub"}; !slices.Equal(names, want) {
+		t.Errorf("ReadDir() = %v, want %v", names, want)
+	}
+
+	want := []string{
+		"M /fix/a.txt",
+		"M /fix/dir/b.txt",
+		"A /fix/dir/new.txt",
+		"D /fix/dir/sub/c.txt",
+	}
+	if got := changeStrings(t, o); !slices.Equal(got, want) {
+		t.Errorf("Changes() = %q, want %q", got, want)
+	}
+
+	o.Reset()
+	if data, _ := o.ReadFile("/fix/a.txt"); string(data) != "a" {
+		t.Errorf("ReadFile() after Reset() = %q, want %q", data, "a")
+	}
+	if got := changeStrings(t, o); len(got) != 0 {
+		t.Errorf("Changes() after Reset() = %v, want none", got)
+	}
+}
+
+func TestOverlayOS_Rename(t *testing.T) {
+	base := NewMemOS(WithUser(0, 0))
+	for _, err := range []error{
+		base.MkdirAll("/fix/dir/sub", 0755),
+		base.WriteFile("/fix/a.txt", []byte("a"), 0644),
+		base.WriteFile("/fix/dir/b.txt", []byte("b"), 0644),
+		base.WriteFile("/fix/dir/sub/c.txt", []byte("c"), 0644),
+		base.Symlink("dir", "/fix/link"),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	o := NewOverlayOS(base)
+
+	if err := o.Rename("/fix/dir", "/fix/moved"); err != nil {
+		t.Fatalf("Rename() error = %v", err)
+	}
+
+	if data, err := o.ReadFile("/fix/moved/sub/c.txt"); err != nil || string(data) != "c" {
+		t.Errorf("ReadFile() = %q, %v, want %q", data, err, "c")
+	}
+	if _, err := o.Stat("/fix/dir"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Stat() error = %v, want ErrNotExist", err)
+	}
+	if _, err := base.Stat("/fix/dir/sub/c.txt"); err != nil {
+		t.Errorf("base Stat() error = %v", err)
+	}
+
+	// The link now dangles.
+	if _, err := o.Stat("/fix/link"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Stat() of dangling link error = %v, want ErrNotExist", err)
+	}
+
+	// Creating the directory again must not bring back its contents.
+	if err := o.Mkdir("/fix/dir", 0755); err != nil {
+		t.Fatalf("Mkdir() error = %v", err)
+	}
+	if entries, err := o.ReadDir("/fix/dir"); err != nil || len(entries) != 0 {
+		t.Errorf("ReadDir() = %v, %v, want empty", entries, err)
+	}
+
+	want := []string{
+		"D /fix/dir/b.txt",
+		"D /fix/dir/sub",
+		"D /fix/dir/sub/c.txt",
+		"A /fix/moved",
+		"A /fix/moved/b.txt",
+		"A /fix/moved/sub",
+		"A /fix/moved/sub/c.txt",
+	}
+	if got := changeStrings(t, o); !slices.Equal(got, want) {
+		t.Errorf("Changes() = %q, want %q", got, want)
+	}
+}
+
+func TestOverlayOS_Errors(t *testing.T) {
+	base := NewMemOS(WithUser(0, 0))
+	for _, err := range []error{
+		base.MkdirAll("/fix/dir/sub", 0755),
+		base.WriteFile("/fix/a.txt", []byte("a"), 0644),
+		base.WriteFile("/fix/dir/b.txt", []byte("b"), 0644),
+		base.WriteFile("/fix/dir/sub/c.txt", []byte("c"), 0644),
+		base.Symlink("dir", "/fix/link"),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	o := NewOverlayOS(base)
+
+	err := o.Remove("/fix/dir")
+	var pe *PathError
+	if !errors.As(err, &pe) || pe.Op != "remove" || pe.Path != "/fix/dir" || pe.Err != syscall.ENOTEMPTY {
+		t.Errorf("Remove() error = %#v, want ENOTEMPTY", err)
+	}
+
+	if err := o.Mkdir("/fix/dir", 0755); !errors.Is(err, ErrExist) {
+		t.Errorf("Mkdir() error = %v, want ErrExist", err)
+	}
+
+	err = o.Rename("/fix/a.txt", "/fix/dir")
+	var le *LinkError
+	if !errors.As(err, &le) || le.Err != syscall.EEXIST {
+		t.Errorf("Rename() error = %#v, want EEXIST", err)
+	}
+
+	if err := o.RemoveAll("/fix"); err != nil {
+		t.Fatalf("RemoveAll() error = %v", err)
+	}
+	if _, err := o.Stat("/fix"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Stat() error = %v, want ErrNotExist", err)
+	}
+}
+
+func TestOverlayOS_Permissions(t *testing.T) {
+	base := NewMemOS()
+	base.WriteFile("ro.txt", []byte("ro"), 0444)
+	o := NewOverlayOS(base)
+
+	if _, err := o.OpenFile("ro.txt", O_WRONLY, 0); !errors.Is(err, ErrPermission) {
+		t.Errorf("OpenFile() error = %v, want ErrPermission", err)
+	}
+	if f, err := o.Open("ro.txt"); err != nil {
+		t.Errorf("Open() error = %v", err)
+	} else {
+		f.Close()
+	}
+}
+
+func TestOverlayOS_Root(t *testing.T) {
+	base := NewMemOS(WithUser(0, 0))
+	for _, err := range []error{
+		base.MkdirAll("/fix/dir/sub", 0755),
+		base.WriteFile("/fix/a.txt", []byte("a"), 0644),
+		base.WriteFile("/fix/dir/b.txt", []byte("b"), 0644),
+		base.WriteFile("/fix/dir/sub/c.txt", []byte("c"), 0644),
+		base.Symlink("dir", "/fix/link"),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	o := NewOverlayOS(base)
+
+	r, err := o.OpenRoot("/fix")
+	if err != nil {
+		t.Fatalf("OpenRoot() error = %v", err)
+	}
+	defer r.Close()
+
+	if _, err := r.Open("../fix/a.txt"); err == nil || !errors.Is(err, errPathEscapes) {
+		t.Errorf("Open() error = %v, want path escapes", err)
+	}
+
+	f, err := r.Create("link/made.txt")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	f.Close()
+
+	if _, err := o.Stat("/fix/dir/made.txt"); err != nil {
+		t.Errorf("Stat() error = %v", err)
+	}
+	if err := fstest.TestFS(r.FS(), "a.txt", "dir/made.txt"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestOverlayOS_RealBase(t *testing.T) {
+	dir := t.TempDir()
+	base := NewOS()
+	if err := base.WriteFile(dir+"/keep.txt", []byte("keep"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	o := NewOverlayOS(base)
+	if err := o.WriteFile(dir+"/keep.txt", []byte("lost"), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if err := o.Remove(dir + "/keep.txt"); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+
+	if data, err := base.ReadFile(dir + "/keep.txt"); err != nil || string(data) != "keep" {
+		t.Errorf("base ReadFile() = %q, %v, want %q", data, err, "keep")
+	}
+	if want := []string{"D " + dir + "/keep.txt"}; !slices.Equal(changeStrings(t, o), want) {
+		t.Errorf("Changes() = %q, want %q", changeStrings(t, o), want)
+	}
+}
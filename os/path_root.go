@@ -0,0 +1,408 @@
+package os
+
+import (
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/pdutton/go-interfaces/io/fs"
+)
+
+// pathRoot is a Root for implementations of OS that have no way of
+// holding on to a directory.  It resolves names one element at a time
+// with Lstat and Readlink, rejecting those that leave the directory,
+// and hands the resolved path to the OS.  Unlike os.Root it refers to
+// the directory by name, so it does not follow the directory when it
+// is renamed.
+type pathRoot struct {
+	fsys   OS
+	name   string
+	dir    string
+	closed atomic.Bool
+}
+
+func newPathRoot(fsys OS, name string) (Root, error) {
+	fi, err := fsys.Stat(name)
+	if err == nil && !fi.IsDir() {
+		err = syscall.ENOTDIR
+	}
+	if err != nil {
+		return nil, &PathError{Op: "open", Path: name, Err: underlyingError(err)}
+	}
+
+	dir := name
+	if !path.IsAbs(dir) {
+		wd, err := fsys.Getwd()
+		if err != nil {
+			return nil, &PathError{Op: "open", Path: name, Err: underlyingError(err)}
+		}
+		dir = wd + "/" + dir
+	}
+
+	return &pathRoot{
+		fsys: fsys,
+		name: name,
+		dir:  path.Clean(dir),
+	}, nil
+}
+
+// underlyingError returns the error wrapped by a PathError, LinkError
+// or SyscallError, as the os package does.
+func underlyingError(err error) error {
+	switch err := err.(type) {
+	case *PathError:
+		return err.Err
+	case *LinkError:
+		return err.Err
+	case *SyscallError:
+		return err.Err
+	}
+	return err
+}
+
+// resolve maps name to a path of the OS, following symbolic links in
+// every element but the last, which is only followed if follow is set.
+// A last element that does not exist is not an error.
+func (r *pathRoot) resolve(op, name string, follow bool) (string, error) {
+	var err error
+	switch {
+	case r.closed.Load():
+		err = ErrClosed
+	case name == "":
+		err = syscall.ENOENT
+	case path.IsAbs(name):
+		err = errPathEscapes
+	}
+	if err != nil {
+		return "", &PathError{Op: op, Path: name, Err: err}
+	}
+
+	if strings.HasSuffix(name, "/") {
+		follow = true
+	}
+
+	var (
+		names []string
+		elems = splitPath(name)
+		links int
+	)
+
+	for i := 0; i < len(elems); i++ {
+		elem := elems[i]
+		if elem == ".." {
+			if len(names) == 0 {
+				return "", &PathError{Op: op, Path: name, Err: errPathEscapes}
+			}
+			names = names[:len(names)-1]
+			continue
+		}
+
+		last := i == len(elems)-1
+		full := path.Join(r.dir, joinNames(names, elem))
+
+		fi, err := r.fsys.Lstat(full)
+		if err != nil {
+			if last && errors.Is(err, ErrNotExist) {
+				return full, nil
+			}
+			return "", &PathError{Op: op, Path: name, Err: underlyingError(err)}
+		}
+
+		if fi.Mode().IsSymlink() && (follow || !last) {
+			links++
+			if links > memRootMaxSymlinks {
+				return "", &PathError{Op: op, Path: name, Err: syscall.ELOOP}
+			}
+			target, err := r.fsys.Readlink(full)
+			if err != nil {
+				return "", &PathError{Op: op, Path: name, Err: underlyingError(err)}
+			}
+			if path.IsAbs(target) {
+				return "", &PathError{Op: op, Path: name, Err: errPathEscapes}
+			}
+			elems = append(splitPath(target), elems[i+1:]...)
+			i = -1
+			continue
+		}
+
+		if !last && !fi.IsDir() {
+			return "", &PathError{Op: op, Path: name, Err: syscall.ENOTDIR}
+		}
+		names = append(names, elem)
+	}
+
+	return path.Join(r.dir, joinNames(names)), nil
+}
+
+// rename reports an error of the OS in terms of the name given to
+// the Root.
+func (r *pathRoot) rename(op, name string, err error) error {
+	return &PathError{Op: op, Path: name, Err: underlyingError(err)}
+}
+
+func (r *pathRoot) renameLink(op, oldname, newname string, err error) error {
+	return &LinkError{Op: op, Old: oldname, New: newname, Err: underlyingError(err)}
+}
+
+// resolveLink resolves both names of a call that takes two, without
+// following the last element of either.
+func (r *pathRoot) resolveLink(op, oldname, newname string) (string, string, error) {
+	oldfull, err := r.resolve(op, oldname, false)
+	if err != nil {
+		return "", "", r.renameLink(op, oldname, newname, err)
+	}
+	newfull, err := r.resolve(op, newname, false)
+	if err != nil {
+		return "", "", r.renameLink(op, oldname, newname, err)
+	}
+	return oldfull, newfull, nil
+}
+
+// Nub returns nil, as there is no os.Root behind the Root.
+func (r *pathRoot) Nub() *os.Root {
+	return nil
+}
+
+func (r *pathRoot) Close() error {
+	r.closed.Store(true)
+	return nil
+}
+
+func (r *pathRoot) Create(name string) (File, error) {
+	return r.OpenFile(name, O_RDWR|O_CREATE|O_TRUNC, 0666)
+}
+
+func (r *pathRoot) FS() fs.FS {
+	return newDirFS(r, "")
+}
+
+func (r *pathRoot) Lstat(name string) (FileInfo, error) {
+	full, err := r.resolve("lstatat", name, false)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := r.fsys.Lstat(full)
+	if err != nil {
+		return nil, r.rename("lstatat", name, err)
+	}
+	return fi, nil
+}
+
+func (r *pathRoot) Mkdir(name string, perm FSFileMode) error {
+	if perm&0777 != perm {
+		return &PathError{Op: "mkdirat", Path: name, Err: errors.New("unsupported file mode")}
+	}
+
+	full, err := r.resolve("mkdirat", name, false)
+	if err != nil {
+		return err
+	}
+
+	if err := r.fsys.Mkdir(full, perm); err != nil {
+		return r.rename("mkdirat", name, err)
+	}
+	return nil
+}
+
+func (r *pathRoot) Name() string {
+	return r.name
+}
+
+func (r *pathRoot) Open(name string) (File, error) {
+	return r.OpenFile(name, O_RDONLY, 0)
+}
+
+func (r *pathRoot) OpenFile(name string, flag int, perm FSFileMode) (File, error) {
+	if perm&0777 != perm {
+		return nil, &PathError{Op: "openat", Path: name, Err: errors.New("unsupported file mode")}
+	}
+
+	excl := flag&(O_CREATE|O_EXCL) == O_CREATE|O_EXCL
+	full, err := r.resolve("openat", name, !excl)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := r.fsys.OpenFile(full, flag, perm)
+	if err != nil {
+		return nil, r.rename("openat", name, err)
+	}
+	return rootFile{File: f, name: path.Join(r.name, name)}, nil
+}
+
+func (r *pathRoot) OpenRoot(name string) (Root, error) {
+	full, err := r.resolve("openat", name, true)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := newPathRoot(r.fsys, full)
+	if err != nil {
+		return nil, r.rename("openat", name, err)
+	}
+	root.(*pathRoot).name = path.Join(r.name, name)
+	return root, nil
+}
+
+func (r *pathRoot) Remove(name string) error {
+	full, err := r.resolve("removeat", name, false)
+	if err != nil {
+		return err
+	}
+
+	if err := r.fsys.Remove(full); err != nil {
+		return r.rename("removeat", name, err)
+	}
+	return nil
+}
+
+func (r *pathRoot) Stat(name string) (FileInfo, error) {
+	full, err := r.resolve("statat", name, true)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := r.fsys.Stat(full)
+	if err != nil {
+		return nil, r.rename("statat", name, err)
+	}
+	return fi, nil
+}
+
+func (r *pathRoot) Chmod(name string, mode FSFileMode) error {
+	full, err := r.resolve("chmodat", name, true)
+	if err != nil {
+		return err
+	}
+
+	if err := r.fsys.Chmod(full, mode); err != nil {
+		return r.rename("chmodat", name, err)
+	}
+	return nil
+}
+
+func (r *pathRoot) Chown(name string, uid, gid int) error {
+	full, err := r.resolve("chownat", name, true)
+	if err != nil {
+		return err
+	}
+
+	if err := r.fsys.Chown(full, uid, gid); err != nil {
+		return r.rename("chownat", name, err)
+	}
+	return nil
+}
+
+func (r *pathRoot) Chtimes(name string, atime, mtime time.Time) error {
+	full, err := r.resolve("chtimesat", name, true)
+	if err != nil {
+		return err
+	}
+
+	if err := r.fsys.Chtimes(full, atime, mtime); err != nil {
+		return r.rename("chtimesat", name, err)
+	}
+	return nil
+}
+
+func (r *pathRoot) Lchown(name string, uid, gid int) error {
+	full, err := r.resolve("lchownat", name, false)
+	if err != nil {
+		return err
+	}
+
+	if err := r.fsys.Lchown(full, uid, gid); err != nil {
+		return r.rename("lchownat", name, err)
+	}
+	return nil
+}
+
+func (r *pathRoot) Link(oldname, newname string) error {
+	oldfull, newfull, err := r.resolveLink("linkat", oldname, newname)
+	if err != nil {
+		return err
+	}
+
+	if err := r.fsys.Link(oldfull, newfull); err != nil {
+		return r.renameLink("linkat", oldname, newname, err)
+	}
+	return nil
+}
+
+func (r *pathRoot) MkdirAll(name string, perm FSFileMode) error {
+	if perm&0777 != perm {
+		return &PathError{Op: "mkdirat", Path: name, Err: errors.New("unsupported file mode")}
+	}
+	return mkdirAll(r, name, perm)
+}
+
+func (r *pathRoot) ReadFile(name string) ([]byte, error) {
+	return readFile(r, name)
+}
+
+func (r *pathRoot) Readlink(name string) (string, error) {
+	full, err := r.resolve("readlinkat", name, false)
+	if err != nil {
+		return "", err
+	}
+
+	target, err := r.fsys.Readlink(full)
+	if err != nil {
+		return "", r.rename("readlinkat", name, err)
+	}
+	return target, nil
+}
+
+func (r *pathRoot) RemoveAll(name string) error {
+	return removeAll(r, name)
+}
+
+func (r *pathRoot) Rename(oldname, newname string) error {
+	oldfull, newfull, err := r.resolveLink("renameat", oldname, newname)
+	if err != nil {
+		return err
+	}
+
+	if err := r.fsys.Rename(oldfull, newfull); err != nil {
+		return r.renameLink("renameat", oldname, newname, err)
+	}
+	return nil
+}
+
+// Symlink does not check oldname, which may point outside the Root.
+func (r *pathRoot) Symlink(oldname, newname string) error {
+	full, err := r.resolve("symlinkat", newname, false)
+	if err != nil {
+		return r.renameLink("symlinkat", oldname, newname, err)
+	}
+
+	if err := r.fsys.Symlink(oldname, full); err != nil {
+		return r.renameLink("symlinkat", oldname, newname, err)
+	}
+	return nil
+}
+
+func (r *pathRoot) WriteFile(name string, data []byte, perm FSFileMode) error {
+	return writeFile(r, name, data, perm)
+}
+
+// rootFile is a file opened through a Root, which is named after
+// the Root rather than the path it was resolved to.
+type rootFile struct {
+	File
+	name string
+}
+
+func (f rootFile) Name() string {
+	return f.name
+}
+
+// Unwrap returns the file as it was opened, for LockerFor.
+func (f rootFile) Unwrap() File {
+	return f.File
+}
@@ -0,0 +1,177 @@
+package os
+
+import (
+	"io"
+	"syscall"
+	"time"
+)
+
+// recordFile is a File opened through a RecordOS, whose calls are
+// recorded along with those of the OS.
+type recordFile struct {
+	File
+	os *recordOS
+	id int
+}
+
+// Unwrap returns the file of the base OS, for LockerFor.  Locks taken
+// through it are not recorded.
+func (f *recordFile) Unwrap() File {
+	return f.File
+}
+
+func (f *recordFile) Chdir() error {
+	err := f.File.Chdir()
+	f.os.record("Chdir", f.id, nil, err)
+	return err
+}
+
+func (f *recordFile) Chmod(mode FSFileMode) error {
+	err := f.File.Chmod(mode)
+	f.os.record("Chmod", f.id, []any{mode}, err)
+	return err
+}
+
+func (f *recordFile) Chown(uid, gid int) error {
+	err := f.File.Chown(uid, gid)
+	f.os.record("Chown", f.id, []any{uid, gid}, err)
+	return err
+}
+
+func (f *recordFile) Close() error {
+	err := f.File.Close()
+	f.os.record("Close", f.id, nil, err)
+	return err
+}
+
+func (f *recordFile) Fd() uintptr {
+	fd := f.File.Fd()
+	f.os.record("Fd", f.id, nil, nil, fd)
+	return fd
+}
+
+func (f *recordFile) Read(b []byte) (int, error) {
+	n, err := f.File.Read(b)
+	f.os.record("Read", f.id, []any{len(b)}, err, b[:n])
+	return n, err
+}
+
+func (f *recordFile) ReadAt(b []byte, off int64) (int, error) {
+	n, err := f.File.ReadAt(b, off)
+	f.os.record("ReadAt", f.id, []any{len(b), off}, err, b[:n])
+	return n, err
+}
+
+func (f *recordFile) ReadDir(n int) ([]DirEntry, error) {
+	entries, err := f.File.ReadDir(n)
+	f.os.record("ReadDir", f.id, []any{n}, err, entries)
+	return entries, err
+}
+
+// ReadFrom copies through Write, which is recorded.
+func (f *recordFile) ReadFrom(r io.Reader) (int64, error) {
+	return io.Copy(recordFileWriter{f}, r)
+}
+
+func (f *recordFile) Readdir(n int) ([]FileInfo, error) {
+	infos, err := f.File.Readdir(n)
+	f.os.record("Readdir", f.id, []any{n}, err, infos)
+	return infos, err
+}
+
+func (f *recordFile) Readdirnames(n int) ([]string, error) {
+	names, err := f.File.Readdirnames(n)
+	f.os.record("Readdirnames", f.id, []any{n}, err, names)
+	return names, err
+}
+
+func (f *recordFile) Seek(offset int64, whence int) (int64, error) {
+	ret, err := f.File.Seek(offset, whence)
+	f.os.record("Seek", f.id, []any{offset, whence}, err, ret)
+	return ret, err
+}
+
+func (f *recordFile) SetDeadline(t time.Time) error {
+	err := f.File.SetDeadline(t)
+	f.os.record("SetDeadline", f.id, []any{t}, err)
+	return err
+}
+
+func (f *recordFile) SetReadDeadline(t time.Time) error {
+	err := f.File.SetReadDeadline(t)
+	f.os.record("SetReadDeadline", f.id, []any{t}, err)
+	return err
+}
+
+func (f *recordFile) SetWriteDeadline(t time.Time) error {
+	err := f.File.SetWriteDeadline(t)
+	f.os.record("SetWriteDeadline", f.id, []any{t}, err)
+	return err
+}
+
+func (f *recordFile) Stat() (FileInfo, error) {
+	fi, err := f.File.Stat()
+	f.os.record("Stat", f.id, nil, err, fi)
+	return fi, err
+}
+
+func (f *recordFile) Sync() error {
+	err := f.File.Sync()
+	f.os.record("Sync", f.id, nil, err)
+	return err
+}
+
+// SyscallConn records only the error, as a RawConn cannot be played
+// back.
+func (f *recordFile) SyscallConn() (syscall.RawConn, error) {
+	conn, err := f.File.SyscallConn()
+	f.os.record("SyscallConn", f.id, nil, err)
+	return conn, err
+}
+
+func (f *recordFile) Truncate(size int64) error {
+	err := f.File.Truncate(size)
+	f.os.record("Truncate", f.id, []any{size}, err)
+	return err
+}
+
+func (f *recordFile) Write(b []byte) (int, error) {
+	n, err := f.File.Write(b)
+	f.os.record("Write", f.id, []any{b}, err, n)
+	return n, err
+}
+
+func (f *recordFile) WriteAt(b []byte, off int64) (int, error) {
+	n, err := f.File.WriteAt(b, off)
+	f.os.record("WriteAt", f.id, []any{b, off}, err, n)
+	return n, err
+}
+
+func (f *recordFile) WriteString(s string) (int, error) {
+	n, err := f.File.WriteString(s)
+	f.os.record("WriteString", f.id, []any{s}, err, n)
+	return n, err
+}
+
+// WriteTo copies through Read, which is recorded.
+func (f *recordFile) WriteTo(w io.Writer) (int64, error) {
+	return io.Copy(w, recordFileReader{f})
+}
+
+// recordFileReader and recordFileWriter hide the ReaderFrom and
+// WriterTo methods of a recordFile from io.Copy.
+type recordFileReader struct {
+	f *recordFile
+}
+
+func (r recordFileReader) Read(b []byte) (int, error) {
+	return r.f.Read(b)
+}
+
+type recordFileWriter struct {
+	f *recordFile
+}
+
+func (w recordFileWriter) Write(b []byte) (int, error) {
+	return w.f.Write(b)
+}
@@ -0,0 +1,499 @@
+package os
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/pdutton/go-interfaces/encoding/json"
+	"github.com/pdutton/go-interfaces/io/fs"
+)
+
+// RecordOS is an OS that passes every call through to another OS and
+// writes it, with its arguments, results and errors, to a transcript
+// that a ReplayOS can play back.  Calls are written in the order they
+// return, so code that uses the OS from several goroutines at once
+// will not replay reliably.
+type RecordOS interface {
+	OS
+
+	// Err returns the first error met writing the transcript
+	Err() error
+}
+
+type recordOS struct {
+	base OS
+	json json.JSON
+
+	mu    sync.Mutex
+	enc   json.Encoder
+	files int
+	err   error
+}
+
+// NewRecordOS creates a RecordOS that passes calls through to base and
+// writes the transcript to w, one JSON object per call.  Contents read
+// from and written to files are part of the transcript.
+func NewRecordOS(base OS, w io.Writer) RecordOS {
+	return &recordOS{
+		base: base,
+		json: json.NewJSON(),
+		enc:  json.NewEncoder(w),
+	}
+}
+
+func (r *recordOS) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.err
+}
+
+// record writes a call of method, on the File numbered file if it is
+// not zero, to the transcript.
+func (r *recordOS) record(method string, file int, args []any, err error, results ...any) {
+	call := transcriptCall{
+		Method: method,
+		File:   file,
+		Err:    newTranscriptError(err),
+	}
+
+	var merr error
+	call.Args, merr = marshalTranscript(r.json, args)
+	if merr == nil {
+		call.Results, merr = marshalTranscript(r.json, results)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if merr == nil {
+		merr = r.enc.Encode(call)
+	}
+	if merr != nil && r.err == nil {
+		r.err = merr
+	}
+}
+
+// wrap numbers a File returned by the base, so that calls of its
+// methods can be told apart in the transcript.
+func (r *recordOS) wrap(f File) (File, transcriptFile) {
+	if f == nil {
+		return nil, transcriptFile{}
+	}
+
+	r.mu.Lock()
+	r.files++
+	id := r.files
+	r.mu.Unlock()
+
+	return &recordFile{File: f, os: r, id: id}, transcriptFile{ID: id, Name: f.Name()}
+}
+
+func (r *recordOS) file(method string, f File) File {
+	file, tf := r.wrap(f)
+	r.record(method, 0, nil, nil, tf)
+	return file
+}
+
+func (r *recordOS) Stdin() File {
+	return r.file("Stdin", r.base.Stdin())
+}
+
+func (r *recordOS) Stderr() File {
+	return r.file("Stderr", r.base.Stderr())
+}
+
+func (r *recordOS) Stdout() File {
+	return r.file("Stdout", r.base.Stdout())
+}
+
+func (r *recordOS) Args() []string {
+	args := r.base.Args()
+	r.record("Args", 0, nil, nil, args)
+	return args
+}
+
+func (r *recordOS) Chdir(dir string) error {
+	err := r.base.Chdir(dir)
+	r.record("Chdir", 0, []any{dir}, err)
+	return err
+}
+
+func (r *recordOS) Chmod(name string, mode FSFileMode) error {
+	err := r.base.Chmod(name, mode)
+	r.record("Chmod", 0, []any{name, mode}, err)
+	return err
+}
+
+func (r *recordOS) Chown(name string, uid, gid int) error {
+	err := r.base.Chown(name, uid, gid)
+	r.record("Chown", 0, []any{name, uid, gid}, err)
+	return err
+}
+
+func (r *recordOS) Chtimes(name string, atime, mtime time.Time) error {
+	err := r.base.Chtimes(name, atime, mtime)
+	r.record("Chtimes", 0, []any{name, atime, mtime}, err)
+	return err
+}
+
+func (r *recordOS) Clearenv() {
+	r.base.Clearenv()
+	r.record("Clearenv", 0, nil, nil)
+}
+
+// CopyFS is made of calls of the other methods, which are recorded.
+func (r *recordOS) CopyFS(dir string, fsys fs.FS) error {
+	return copyFS(r, dir, fsys)
+}
+
+// DirFS is made of calls of the other methods, which are recorded.
+func (r *recordOS) DirFS(dir string) fs.FS {
+	return newDirFS(r, dir)
+}
+
+func (r *recordOS) Environ() []string {
+	env := r.base.Environ()
+	r.record("Environ", 0, nil, nil, env)
+	return env
+}
+
+func (r *recordOS) Executable() (string, error) {
+	name, err := r.base.Executable()
+	r.record("Executable", 0, nil, err, name)
+	return name, err
+}
+
+func (r *recordOS) Exit(code int) {
+	r.record("Exit", 0, []any{code}, nil)
+	r.base.Exit(code)
+}
+
+func (r *recordOS) Expand(s string, mapping func(string) string) string {
+	result := r.base.Expand(s, mapping)
+	r.record("Expand", 0, []any{s}, nil, result)
+	return result
+}
+
+func (r *recordOS) ExpandEnv(s string) string {
+	result := r.base.ExpandEnv(s)
+	r.record("ExpandEnv", 0, []any{s}, nil, result)
+	return result
+}
+
+func (r *recordOS) Getegid() int {
+	id := r.base.Getegid()
+	r.record("Getegid", 0, nil, nil, id)
+	return id
+}
+
+func (r *recordOS) Getenv(key string) string {
+	value := r.base.Getenv(key)
+	r.record("Getenv", 0, []any{key}, nil, value)
+	return value
+}
+
+func (r *recordOS) Geteuid() int {
+	id := r.base.Geteuid()
+	r.record("Geteuid", 0, nil, nil, id)
+	return id
+}
+
+func (r *recordOS) Getgid() int {
+	id := r.base.Getgid()
+	r.record("Getgid", 0, nil, nil, id)
+	return id
+}
+
+func (r *recordOS) Getgroups() ([]int, error) {
+	groups, err := r.base.Getgroups()
+	r.record("Getgroups", 0, nil, err, groups)
+	return groups, err
+}
+
+func (r *recordOS) Getpagesize() int {
+	size := r.base.Getpagesize()
+	r.record("Getpagesize", 0, nil, nil, size)
+	return size
+}
+
+func (r *recordOS) Getpid() int {
+	pid := r.base.Getpid()
+	r.record("Getpid", 0, nil, nil, pid)
+	return pid
+}
+
+func (r *recordOS) Getppid() int {
+	pid := r.base.Getppid()
+	r.record("Getppid", 0, nil, nil, pid)
+	return pid
+}
+
+func (r *recordOS) Getuid() int {
+	id := r.base.Getuid()
+	r.record("Getuid", 0, nil, nil, id)
+	return id
+}
+
+func (r *recordOS) Getwd() (string, error) {
+	dir, err := r.base.Getwd()
+	r.record("Getwd", 0, nil, err, dir)
+	return dir, err
+}
+
+func (r *recordOS) Hostname() (string, error) {
+	name, err := r.base.Hostname()
+	r.record("Hostname", 0, nil, err, name)
+	return name, err
+}
+
+func (r *recordOS) IsExist(err error) bool {
+	result := r.base.IsExist(err)
+	r.record("IsExist", 0, []any{err}, nil, result)
+	return result
+}
+
+func (r *recordOS) IsNotExist(err error) bool {
+	result := r.base.IsNotExist(err)
+	r.record("IsNotExist", 0, []any{err}, nil, result)
+	return result
+}
+
+func (r *recordOS) IsPathSeparator(c uint8) bool {
+	result := r.base.IsPathSeparator(c)
+	r.record("IsPathSeparator", 0, []any{c}, nil, result)
+	return result
+}
+
+func (r *recordOS) IsPermission(err error) bool {
+	result := r.base.IsPermission(err)
+	r.record("IsPermission", 0, []any{err}, nil, result)
+	return result
+}
+
+func (r *recordOS) IsTimeout(err error) bool {
+	result := r.base.IsTimeout(err)
+	r.record("IsTimeout", 0, []any{err}, nil, result)
+	return result
+}
+
+func (r *recordOS) Lchown(name string, uid, gid int) error {
+	err := r.base.Lchown(name, uid, gid)
+	r.record("Lchown", 0, []any{name, uid, gid}, err)
+	return err
+}
+
+func (r *recordOS) Link(oldname, newname string) error {
+	err := r.base.Link(oldname, newname)
+	r.record("Link", 0, []any{oldname, newname}, err)
+	return err
+}
+
+func (r *recordOS) LookupEnv(key string) (string, bool) {
+	value, ok := r.base.LookupEnv(key)
+	r.record("LookupEnv", 0, []any{key}, nil, value, ok)
+	return value, ok
+}
+
+func (r *recordOS) Mkdir(name string, perm FSFileMode) error {
+	err := r.base.Mkdir(name, perm)
+	r.record("Mkdir", 0, []any{name, perm}, err)
+	return err
+}
+
+func (r *recordOS) MkdirAll(name string, perm FSFileMode) error {
+	err := r.base.MkdirAll(name, perm)
+	r.record("MkdirAll", 0, []any{name, perm}, err)
+	return err
+}
+
+func (r *recordOS) MkdirTemp(dir, pattern string) (string, error) {
+	name, err := r.base.MkdirTemp(dir, pattern)
+	r.record("MkdirTemp", 0, []any{dir, pattern}, err, name)
+	return name, err
+}
+
+func (r *recordOS) NewSyscallError(syscall string, err error) error {
+	result := r.base.NewSyscallError(syscall, err)
+	r.record("NewSyscallError", 0, []any{syscall, err}, nil, result)
+	return result
+}
+
+func (r *recordOS) Pipe() (File, File, error) {
+	pr, pw, err := r.base.Pipe()
+	pr, tr := r.wrap(pr)
+	pw, tw := r.wrap(pw)
+	r.record("Pipe", 0, nil, err, tr, tw)
+	return pr, pw, err
+}
+
+func (r *recordOS) ReadFile(name string) ([]byte, error) {
+	data, err := r.base.ReadFile(name)
+	r.record("ReadFile", 0, []any{name}, err, data)
+	return data, err
+}
+
+func (r *recordOS) Readlink(name string) (string, error) {
+	target, err := r.base.Readlink(name)
+	r.record("Readlink", 0, []any{name}, err, target)
+	return target, err
+}
+
+func (r *recordOS) Remove(name string) error {
+	err := r.base.Remove(name)
+	r.record("Remove", 0, []any{name}, err)
+	return err
+}
+
+func (r *recordOS) RemoveAll(name string) error {
+	err := r.base.RemoveAll(name)
+	r.record("RemoveAll", 0, []any{name}, err)
+	return err
+}
+
+func (r *recordOS) Rename(oldpath, newpath string) error {
+	err := r.base.Rename(oldpath, newpath)
+	r.record("Rename", 0, []any{oldpath, newpath}, err)
+	return err
+}
+
+func (r *recordOS) SameFile(fi1, fi2 FileInfo) bool {
+	result := r.base.SameFile(fi1, fi2)
+	r.record("SameFile", 0, []any{fi1, fi2}, nil, result)
+	return result
+}
+
+func (r *recordOS) Setenv(key, value string) error {
+	err := r.base.Setenv(key, value)
+	r.record("Setenv", 0, []any{key, value}, err)
+	return err
+}
+
+func (r *recordOS) Symlink(oldname, newname string) error {
+	err := r.base.Symlink(oldname, newname)
+	r.record("Symlink", 0, []any{oldname, newname}, err)
+	return err
+}
+
+func (r *recordOS) TempDir() string {
+	dir := r.base.TempDir()
+	r.record("TempDir", 0, nil, nil, dir)
+	return dir
+}
+
+func (r *recordOS) Truncate(name string, size int64) error {
+	err := r.base.Truncate(name, size)
+	r.record("Truncate", 0, []any{name, size}, err)
+	return err
+}
+
+func (r *recordOS) Unsetenv(key string) error {
+	err := r.base.Unsetenv(key)
+	r.record("Unsetenv", 0, []any{key}, err)
+	return err
+}
+
+func (r *recordOS) UserCacheDir() (string, error) {
+	dir, err := r.base.UserCacheDir()
+	r.record("UserCacheDir", 0, nil, err, dir)
+	return dir, err
+}
+
+func (r *recordOS) UserConfigDir() (string, error) {
+	dir, err := r.base.UserConfigDir()
+	r.record("UserConfigDir", 0, nil, err, dir)
+	return dir, err
+}
+
+func (r *recordOS) UserHomeDir() (string, error) {
+	dir, err := r.base.UserHomeDir()
+	r.record("UserHomeDir", 0, nil, err, dir)
+	return dir, err
+}
+
+func (r *recordOS) WriteFile(name string, data []byte, perm FSFileMode) error {
+	err := r.base.WriteFile(name, data, perm)
+	r.record("WriteFile", 0, []any{name, data, perm}, err)
+	return err
+}
+
+func (r *recordOS) ReadDir(name string) ([]DirEntry, error) {
+	entries, err := r.base.ReadDir(name)
+	r.record("ReadDir", 0, []any{name}, err, entries)
+	return entries, err
+}
+
+func (r *recordOS) Create(name string) (File, error) {
+	f, err := r.base.Create(name)
+	f, tf := r.wrap(f)
+	r.record("Create", 0, []any{name}, err, tf)
+	return f, err
+}
+
+func (r *recordOS) CreateTemp(dir, pattern string) (File, error) {
+	f, err := r.base.CreateTemp(dir, pattern)
+	f, tf := r.wrap(f)
+	r.record("CreateTemp", 0, []any{dir, pattern}, err, tf)
+	return f, err
+}
+
+func (r *recordOS) NewFile(fd uintptr, name string) File {
+	f, tf := r.wrap(r.base.NewFile(fd, name))
+	r.record("NewFile", 0, []any{fd, name}, nil, tf)
+	return f
+}
+
+func (r *recordOS) Open(name string) (File, error) {
+	f, err := r.base.Open(name)
+	f, tf := r.wrap(f)
+	r.record("Open", 0, []any{name}, err, tf)
+	return f, err
+}
+
+func (r *recordOS) OpenFile(name string, flag int, perm FSFileMode) (File, error) {
+	f, err := r.base.OpenFile(name, flag, perm)
+	f, tf := r.wrap(f)
+	r.record("OpenFile", 0, []any{name, flag, perm}, err, tf)
+	return f, err
+}
+
+// OpenInRoot is made of calls of the other methods, which are
+// recorded.
+func (r *recordOS) OpenInRoot(dir, name string) (File, error) {
+	return openInRoot(r, dir, name)
+}
+
+func (r *recordOS) Lstat(name string) (FileInfo, error) {
+	fi, err := r.base.Lstat(name)
+	r.record("Lstat", 0, []any{name}, err, fi)
+	return fi, err
+}
+
+func (r *recordOS) Stat(name string) (FileInfo, error) {
+	fi, err := r.base.Stat(name)
+	r.record("Stat", 0, []any{name}, err, fi)
+	return fi, err
+}
+
+// FindProcess records only the error, as a Process cannot be played
+// back.
+func (r *recordOS) FindProcess(pid int) (Process, error) {
+	p, err := r.base.FindProcess(pid)
+	r.record("FindProcess", 0, []any{pid}, err)
+	return p, err
+}
+
+// StartProcess records only the error, as a Process cannot be played
+// back.
+func (r *recordOS) StartProcess(name string, argv []string, attr *ProcAttr) (Process, error) {
+	p, err := r.base.StartProcess(name, argv, attr)
+	r.record("StartProcess", 0, []any{name, argv}, err)
+	return p, err
+}
+
+// OpenRoot returns a Root made of calls of the other methods, which
+// are recorded.
+func (r *recordOS) OpenRoot(name string) (Root, error) {
+	return newPathRoot(r, name)
+}
@@ -0,0 +1,146 @@
+package os
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestNewRecordOS(t *testing.T) {
+	var _ OS = NewRecordOS(NewMemOS(), io.Discard)
+}
+
+// fakeTB collects what a replaying OS reports.
+type fakeTB struct {
+	errors   []string
+	cleanups []func()
+}
+
+func (tb *fakeTB) Helper() {}
+
+func (tb *fakeTB) Errorf(format string, args ...any) {
+	tb.errors = append(tb.errors, fmt.Sprintf(format, args...))
+}
+
+func (tb *fakeTB) Cleanup(f func()) {
+	tb.cleanups = append(tb.cleanups, f)
+}
+
+func (tb *fakeTB) finish() {
+	for i := len(tb.cleanups) - 1; i >= 0; i-- {
+		tb.cleanups[i]()
+	}
+}
+
+// session makes the same calls whether recording or replaying.
+func session(t *testing.T, o OS) {
+	t.Helper()
+
+	if err := o.WriteFile("/tmp/a", []byte("hello"), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if data, err := o.ReadFile("/tmp/a"); err != nil || string(data) != "hello" {
+		t.Errorf("ReadFile() = %q, %v, want %q", data, err, "hello")
+	}
+
+	fi, err := o.Stat("/tmp/a")
+	if err != nil || fi.Name() != "a" || fi.Size() != 5 || fi.Mode().Perm() != 0644 {
+		t.Errorf("Stat() = %v, %v", fi, err)
+	}
+
+	f, err := o.Open("/tmp/a")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if f.Name() != "/tmp/a" {
+		t.Errorf("Name() = %q, want %q", f.Name(), "/tmp/a")
+	}
+	var b = make([]byte, 3)
+	if n, err := f.Read(b); n != 3 || err != nil || string(b) != "hel" {
+		t.Errorf("Read() = %d, %v, %q", n, err, b)
+	}
+	if data, err := io.ReadAll(f); err != nil || string(data) != "lo" {
+		t.Errorf("ReadAll() = %q, %v, want %q", data, err, "lo")
+	}
+	if err := f.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+
+	_, err = o.Open("/tmp/missing")
+	var pe *PathError
+	if !errors.As(err, &pe) || pe.Op != "open" || pe.Err != syscall.ENOENT || !errors.Is(err, ErrNotExist) {
+		t.Errorf("Open() of missing file error = %#v, want ENOENT", err)
+	}
+
+	if err := o.Rename("/tmp/a", "/tmp/b"); err != nil {
+		t.Errorf("Rename() error = %v", err)
+	}
+	entries, err := o.ReadDir("/tmp")
+	if err != nil || len(entries) != 1 || entries[0].Name() != "b" || entries[0].IsDir() {
+		t.Errorf("ReadDir() = %v, %v", entries, err)
+	}
+}
+
+func TestRecordOS_Replay(t *testing.T) {
+	var transcript bytes.Buffer
+	r := NewRecordOS(NewMemOS(), &transcript)
+	session(t, r)
+	if err := r.Err(); err != nil {
+		t.Fatalf("Err() = %v", err)
+	}
+
+	tb := &fakeTB{}
+	o, err := NewReplayOS(tb, bytes.NewReader(transcript.Bytes()))
+	if err != nil {
+		t.Fatalf("NewReplayOS() error = %v", err)
+	}
+	session(t, o)
+	tb.finish()
+
+	if len(tb.errors) != 0 {
+		t.Errorf("replay reported %q", tb.errors)
+	}
+}
+
+func TestReplayOS_Diverge(t *testing.T) {
+	var transcript bytes.Buffer
+	r := NewRecordOS(NewMemOS(), &transcript)
+	r.Mkdir("/tmp/d", 0755)
+	r.Remove("/tmp/d")
+
+	tb := &fakeTB{}
+	o, _ := NewReplayOS(tb, &transcript)
+	if err := o.Mkdir("/tmp/e", 0755); err != errReplayDiverged {
+		t.Errorf("Mkdir() of other path error = %v, want errReplayDiverged", err)
+	}
+	if err := o.Remove("/tmp/d"); err != errReplayDiverged {
+		t.Errorf("Remove() after divergence error = %v, want errReplayDiverged", err)
+	}
+	tb.finish()
+
+	if len(tb.errors) != 1 || !strings.Contains(tb.errors[0], `Mkdir("/tmp/d", 493)`) {
+		t.Errorf("replay reported %q, want one divergence at Mkdir", tb.errors)
+	}
+}
+
+func TestReplayOS_Unused(t *testing.T) {
+	var transcript bytes.Buffer
+	r := NewRecordOS(NewMemOS(), &transcript)
+	r.Getenv("HOME")
+	r.Getwd()
+
+	tb := &fakeTB{}
+	o, _ := NewReplayOS(tb, &transcript)
+	if home := o.Getenv("HOME"); home != "/home/gopher" {
+		t.Errorf("Getenv() = %q", home)
+	}
+	tb.finish()
+
+	if len(tb.errors) != 1 || !strings.Contains(tb.errors[0], "Getwd()") {
+		t.Errorf("replay reported %q, want Getwd not made", tb.errors)
+	}
+}
@@ -0,0 +1,676 @@
+package os
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	stdfs "io/fs"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pdutton/go-interfaces/encoding/json"
+	"github.com/pdutton/go-interfaces/io/fs"
+)
+
+// TB is the part of testing.TB through which a replaying OS reports
+// calls that differ from its transcript.
+type TB interface {
+	Helper()
+	Errorf(format string, args ...any)
+	Cleanup(func())
+}
+
+var errReplayDiverged = errors.New("os: call differs from the transcript being replayed")
+
+// replayOS plays back a transcript written by a RecordOS.  Once a call
+// differs from the transcript, every later call fails as well, without
+// being reported again.
+type replayOS struct {
+	t    TB
+	json json.JSON
+
+	mu       sync.Mutex
+	calls    []transcriptCall
+	pos      int
+	diverged bool
+}
+
+// NewReplayOS creates an OS that plays back the transcript read from
+// r, which was written by a RecordOS.  Every call must be the next one
+// in the transcript, with the same arguments, and gets the results
+// and errors that were recorded.  Calls that differ are reported to t,
+// as are calls of the transcript that were never made by the time the
+// test finishes.  Processes and raw connections cannot be played back,
+// so FindProcess, StartProcess and File.SyscallConn fail with
+// errors.ErrUnsupported where they succeeded when recorded.
+func NewReplayOS(t TB, r io.Reader) (OS, error) {
+	var o = &replayOS{
+		t:    t,
+		json: json.NewJSON(),
+	}
+
+	dec := json.NewDecoder(r)
+	for dec.More() {
+		var c transcriptCall
+		if err := dec.Decode(&c); err != nil {
+			return nil, err
+		}
+		o.calls = append(o.calls, c)
+	}
+
+	t.Cleanup(func() {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+
+		if !o.diverged && o.pos < len(o.calls) {
+			t.Errorf("os: %d calls of the transcript were not made, starting with %s",
+				len(o.calls)-o.pos, o.describe(o.calls[o.pos].Method, o.calls[o.pos].File, o.calls[o.pos].Args))
+		}
+	})
+
+	return o, nil
+}
+
+func (o *replayOS) describe(method string, file int, args []json.RawMessage) string {
+	var sb strings.Builder
+	if file != 0 {
+		sb.WriteString("file " + strconv.Itoa(file) + " ")
+	}
+	sb.WriteString(method + "(")
+	for i, arg := range args {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.Write(arg)
+	}
+	sb.WriteString(")")
+	return sb.String()
+}
+
+func (o *replayOS) diverge(format string, args ...any) error {
+	o.t.Helper()
+
+	o.diverged = true
+	o.t.Errorf(format, args...)
+	return errReplayDiverged
+}
+
+func (o *replayOS) sameArgs(a, b []json.RawMessage) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		var ca, cb bytes.Buffer
+		if o.json.Compact(&ca, a[i]) != nil || o.json.Compact(&cb, b[i]) != nil {
+			return false
+		}
+		if !bytes.Equal(ca.Bytes(), cb.Bytes()) {
+			return false
+		}
+	}
+	return true
+}
+
+// replay checks that a call of method, on the File numbered file if it
+// is not zero, is the next call of the transcript, and decodes its
+// results into results.  It returns the error that was recorded.
+func (o *replayOS) replay(method string, file int, args []any, results ...any) error {
+	o.t.Helper()
+
+	raw, merr := marshalTranscript(o.json, args)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.diverged {
+		return errReplayDiverged
+	}
+	if merr != nil {
+		return o.diverge("os: cannot compare %s with the transcript: %v", method, merr)
+	}
+
+	call := o.describe(method, file, raw)
+	if o.pos >= len(o.calls) {
+		return o.diverge("os: unexpected call %s after the end of the transcript", call)
+	}
+
+	c := &o.calls[o.pos]
+	if c.Method != method || c.File != file || !o.sameArgs(c.Args, raw) {
+		return o.diverge("os: call %d is %s, but the transcript has %s",
+			o.pos+1, call, o.describe(c.Method, c.File, c.Args))
+	}
+	o.pos++
+
+	for i, result := range results {
+		if i >= len(c.Results) {
+			break
+		}
+		if err := o.json.Unmarshal(c.Results[i], result); err != nil {
+			return o.diverge("os: cannot decode result %d of %s: %v", i+1, call, err)
+		}
+	}
+
+	return c.Err.error()
+}
+
+func (o *replayOS) file(tf transcriptFile) File {
+	if tf.ID == 0 {
+		return nil
+	}
+	return &replayFile{os: o, id: tf.ID, name: tf.Name}
+}
+
+// replayFile replays a call that returns a File.
+func (o *replayOS) replayFile(method string, args ...any) (File, error) {
+	o.t.Helper()
+
+	var tf transcriptFile
+	err := o.replay(method, 0, args, &tf)
+	return o.file(tf), err
+}
+
+// replayString replays a call that returns a string and an error.
+func (o *replayOS) replayString(method string, args ...any) (string, error) {
+	o.t.Helper()
+
+	var s string
+	err := o.replay(method, 0, args, &s)
+	return s, err
+}
+
+// replayInt replays a call that returns an int.
+func (o *replayOS) replayInt(method string) int {
+	o.t.Helper()
+
+	var n int
+	o.replay(method, 0, nil, &n)
+	return n
+}
+
+// replayBool replays a call that returns a bool.
+func (o *replayOS) replayBool(method string, args ...any) bool {
+	o.t.Helper()
+
+	var b bool
+	o.replay(method, 0, args, &b)
+	return b
+}
+
+func (o *replayOS) Stdin() File {
+	f, _ := o.replayFile("Stdin")
+	return f
+}
+
+func (o *replayOS) Stderr() File {
+	f, _ := o.replayFile("Stderr")
+	return f
+}
+
+func (o *replayOS) Stdout() File {
+	f, _ := o.replayFile("Stdout")
+	return f
+}
+
+func (o *replayOS) Args() []string {
+	var args []string
+	o.replay("Args", 0, nil, &args)
+	return args
+}
+
+func (o *replayOS) Chdir(dir string) error {
+	return o.replay("Chdir", 0, []any{dir})
+}
+
+func (o *replayOS) Chmod(name string, mode FSFileMode) error {
+	return o.replay("Chmod", 0, []any{name, mode})
+}
+
+func (o *replayOS) Chown(name string, uid, gid int) error {
+	return o.replay("Chown", 0, []any{name, uid, gid})
+}
+
+func (o *replayOS) Chtimes(name string, atime, mtime time.Time) error {
+	return o.replay("Chtimes", 0, []any{name, atime, mtime})
+}
+
+func (o *replayOS) Clearenv() {
+	o.replay("Clearenv", 0, nil)
+}
+
+func (o *replayOS) CopyFS(dir string, fsys fs.FS) error {
+	return copyFS(o, dir, fsys)
+}
+
+func (o *replayOS) DirFS(dir string) fs.FS {
+	return newDirFS(o, dir)
+}
+
+func (o *replayOS) Environ() []string {
+	var env []string
+	o.replay("Environ", 0, nil, &env)
+	return env
+}
+
+func (o *replayOS) Executable() (string, error) {
+	return o.replayString("Executable")
+}
+
+// Exit panics once the call is checked, as the process cannot end.
+func (o *replayOS) Exit(code int) {
+	o.replay("Exit", 0, []any{code})
+	panic("os: Exit(" + strconv.Itoa(code) + ") called on a replayed OS")
+}
+
+// Expand returns the recorded result without calling mapping.
+func (o *replayOS) Expand(s string, mapping func(string) string) string {
+	result, _ := o.replayString("Expand", s)
+	return result
+}
+
+func (o *replayOS) ExpandEnv(s string) string {
+	result, _ := o.replayString("ExpandEnv", s)
+	return result
+}
+
+func (o *replayOS) Getegid() int {
+	return o.replayInt("Getegid")
+}
+
+func (o *replayOS) Getenv(key string) string {
+	value, _ := o.replayString("Getenv", key)
+	return value
+}
+
+func (o *replayOS) Geteuid() int {
+	return o.replayInt("Geteuid")
+}
+
+func (o *replayOS) Getgid() int {
+	return o.replayInt("Getgid")
+}
+
+func (o *replayOS) Getgroups() ([]int, error) {
+	var groups []int
+	err := o.replay("Getgroups", 0, nil, &groups)
+	return groups, err
+}
+
+func (o *replayOS) Getpagesize() int {
+	return o.replayInt("Getpagesize")
+}
+
+func (o *replayOS) Getpid() int {
+	return o.replayInt("Getpid")
+}
+
+func (o *replayOS) Getppid() int {
+	return o.replayInt("Getppid")
+}
+
+func (o *replayOS) Getuid() int {
+	return o.replayInt("Getuid")
+}
+
+func (o *replayOS) Getwd() (string, error) {
+	return o.replayString("Getwd")
+}
+
+func (o *replayOS) Hostname() (string, error) {
+	return o.replayString("Hostname")
+}
+
+func (o *replayOS) IsExist(err error) bool {
+	return o.replayBool("IsExist", err)
+}
+
+func (o *replayOS) IsNotExist(err error) bool {
+	return o.replayBool("IsNotExist", err)
+}
+
+func (o *replayOS) IsPathSeparator(c uint8) bool {
+	return o.replayBool("IsPathSeparator", c)
+}
+
+func (o *replayOS) IsPermission(err error) bool {
+	return o.replayBool("IsPermission", err)
+}
+
+func (o *replayOS) IsTimeout(err error) bool {
+	return o.replayBool("IsTimeout", err)
+}
+
+func (o *replayOS) Lchown(name string, uid, gid int) error {
+	return o.replay("Lchown", 0, []any{name, uid, gid})
+}
+
+func (o *replayOS) Link(oldname, newname string) error {
+	return o.replay("Link", 0, []any{oldname, newname})
+}
+
+func (o *replayOS) LookupEnv(key string) (string, bool) {
+	var (
+		value string
+		ok    bool
+	)
+	o.replay("LookupEnv", 0, []any{key}, &value, &ok)
+	return value, ok
+}
+
+func (o *replayOS) Mkdir(name string, perm FSFileMode) error {
+	return o.replay("Mkdir", 0, []any{name, perm})
+}
+
+func (o *replayOS) MkdirAll(name string, perm FSFileMode) error {
+	return o.replay("MkdirAll", 0, []any{name, perm})
+}
+
+func (o *replayOS) MkdirTemp(dir, pattern string) (string, error) {
+	return o.replayString("MkdirTemp", dir, pattern)
+}
+
+func (o *replayOS) NewSyscallError(syscall string, err error) error {
+	var result *transcriptError
+	o.replay("NewSyscallError", 0, []any{syscall, err}, &result)
+	return result.error()
+}
+
+func (o *replayOS) Pipe() (File, File, error) {
+	var tr, tw transcriptFile
+	err := o.replay("Pipe", 0, nil, &tr, &tw)
+	return o.file(tr), o.file(tw), err
+}
+
+func (o *replayOS) ReadFile(name string) ([]byte, error) {
+	var data []byte
+	err := o.replay("ReadFile", 0, []any{name}, &data)
+	return data, err
+}
+
+func (o *replayOS) Readlink(name string) (string, error) {
+	return o.replayString("Readlink", name)
+}
+
+func (o *replayOS) Remove(name string) error {
+	return o.replay("Remove", 0, []any{name})
+}
+
+func (o *replayOS) RemoveAll(name string) error {
+	return o.replay("RemoveAll", 0, []any{name})
+}
+
+func (o *replayOS) Rename(oldpath, newpath string) error {
+	return o.replay("Rename", 0, []any{oldpath, newpath})
+}
+
+func (o *replayOS) SameFile(fi1, fi2 FileInfo) bool {
+	return o.replayBool("SameFile", fi1, fi2)
+}
+
+func (o *replayOS) Setenv(key, value string) error {
+	return o.replay("Setenv", 0, []any{key, value})
+}
+
+func (o *replayOS) Symlink(oldname, newname string) error {
+	return o.replay("Symlink", 0, []any{oldname, newname})
+}
+
+func (o *replayOS) TempDir() string {
+	dir, _ := o.replayString("TempDir")
+	return dir
+}
+
+func (o *replayOS) Truncate(name string, size int64) error {
+	return o.replay("Truncate", 0, []any{name, size})
+}
+
+func (o *replayOS) Unsetenv(key string) error {
+	return o.replay("Unsetenv", 0, []any{key})
+}
+
+func (o *replayOS) UserCacheDir() (string, error) {
+	return o.replayString("UserCacheDir")
+}
+
+func (o *replayOS) UserConfigDir() (string, error) {
+	return o.replayString("UserConfigDir")
+}
+
+func (o *replayOS) UserHomeDir() (string, error) {
+	return o.replayString("UserHomeDir")
+}
+
+func (o *replayOS) WriteFile(name string, data []byte, perm FSFileMode) error {
+	return o.replay("WriteFile", 0, []any{name, data, perm})
+}
+
+func (o *replayOS) ReadDir(name string) ([]DirEntry, error) {
+	var infos []*transcriptFileInfo
+	err := o.replay("ReadDir", 0, []any{name}, &infos)
+	return replayDirEntries(infos), err
+}
+
+func (o *replayOS) Create(name string) (File, error) {
+	return o.replayFile("Create", name)
+}
+
+func (o *replayOS) CreateTemp(dir, pattern string) (File, error) {
+	return o.replayFile("CreateTemp", dir, pattern)
+}
+
+func (o *replayOS) NewFile(fd uintptr, name string) File {
+	f, _ := o.replayFile("NewFile", fd, name)
+	return f
+}
+
+func (o *replayOS) Open(name string) (File, error) {
+	return o.replayFile("Open", name)
+}
+
+func (o *replayOS) OpenFile(name string, flag int, perm FSFileMode) (File, error) {
+	return o.replayFile("OpenFile", name, flag, perm)
+}
+
+func (o *replayOS) OpenInRoot(dir, name string) (File, error) {
+	return openInRoot(o, dir, name)
+}
+
+func (o *replayOS) Lstat(name string) (FileInfo, error) {
+	var fi *transcriptFileInfo
+	err := o.replay("Lstat", 0, []any{name}, &fi)
+	return fi.fileInfo(), err
+}
+
+func (o *replayOS) Stat(name string) (FileInfo, error) {
+	var fi *transcriptFileInfo
+	err := o.replay("Stat", 0, []any{name}, &fi)
+	return fi.fileInfo(), err
+}
+
+func (o *replayOS) FindProcess(pid int) (Process, error) {
+	if err := o.replay("FindProcess", 0, []any{pid}); err != nil {
+		return nil, err
+	}
+	return nil, &SyscallError{Syscall: "findprocess", Err: errors.ErrUnsupported}
+}
+
+func (o *replayOS) StartProcess(name string, argv []string, attr *ProcAttr) (Process, error) {
+	if err := o.replay("StartProcess", 0, []any{name, argv}); err != nil {
+		return nil, err
+	}
+	return nil, &PathError{Op: "fork/exec", Path: name, Err: errors.ErrUnsupported}
+}
+
+func (o *replayOS) OpenRoot(name string) (Root, error) {
+	return newPathRoot(o, name)
+}
+
+func replayDirEntries(infos []*transcriptFileInfo) []DirEntry {
+	var entries = make([]DirEntry, 0, len(infos))
+	for _, info := range infos {
+		entries = append(entries, fs.NewDirEntry(stdfs.FileInfoToDirEntry(info.fileInfo().Nub())))
+	}
+	return entries
+}
+
+// replayFile is a File of a replaying OS, whose calls are checked
+// against the transcript like those of the OS.
+type replayFile struct {
+	os   *replayOS
+	id   int
+	name string
+}
+
+func (f *replayFile) replay(method string, args []any, results ...any) error {
+	f.os.t.Helper()
+	return f.os.replay(method, f.id, args, results...)
+}
+
+func (f *replayFile) Chdir() error {
+	return f.replay("Chdir", nil)
+}
+
+func (f *replayFile) Chmod(mode FSFileMode) error {
+	return f.replay("Chmod", []any{mode})
+}
+
+func (f *replayFile) Chown(uid, gid int) error {
+	return f.replay("Chown", []any{uid, gid})
+}
+
+func (f *replayFile) Close() error {
+	return f.replay("Close", nil)
+}
+
+func (f *replayFile) Fd() uintptr {
+	var fd uintptr
+	f.replay("Fd", nil, &fd)
+	return fd
+}
+
+func (f *replayFile) Name() string {
+	return f.name
+}
+
+func (f *replayFile) Read(b []byte) (int, error) {
+	var data []byte
+	err := f.replay("Read", []any{len(b)}, &data)
+	return copy(b, data), err
+}
+
+func (f *replayFile) ReadAt(b []byte, off int64) (int, error) {
+	var data []byte
+	err := f.replay("ReadAt", []any{len(b), off}, &data)
+	return copy(b, data), err
+}
+
+func (f *replayFile) ReadDir(n int) ([]DirEntry, error) {
+	var infos []*transcriptFileInfo
+	err := f.replay("ReadDir", []any{n}, &infos)
+	return replayDirEntries(infos), err
+}
+
+func (f *replayFile) ReadFrom(r io.Reader) (int64, error) {
+	return io.Copy(replayFileWriter{f}, r)
+}
+
+func (f *replayFile) Readdir(n int) ([]FileInfo, error) {
+	var infos []*transcriptFileInfo
+	err := f.replay("Readdir", []any{n}, &infos)
+
+	var fis = make([]FileInfo, 0, len(infos))
+	for _, info := range infos {
+		fis = append(fis, info.fileInfo())
+	}
+	return fis, err
+}
+
+func (f *replayFile) Readdirnames(n int) ([]string, error) {
+	var names []string
+	err := f.replay("Readdirnames", []any{n}, &names)
+	if names == nil {
+		names = []string{}
+	}
+	return names, err
+}
+
+func (f *replayFile) Seek(offset int64, whence int) (int64, error) {
+	var ret int64
+	err := f.replay("Seek", []any{offset, whence}, &ret)
+	return ret, err
+}
+
+func (f *replayFile) SetDeadline(t time.Time) error {
+	return f.replay("SetDeadline", []any{t})
+}
+
+func (f *replayFile) SetReadDeadline(t time.Time) error {
+	return f.replay("SetReadDeadline", []any{t})
+}
+
+func (f *replayFile) SetWriteDeadline(t time.Time) error {
+	return f.replay("SetWriteDeadline", []any{t})
+}
+
+func (f *replayFile) Stat() (FileInfo, error) {
+	var fi *transcriptFileInfo
+	err := f.replay("Stat", nil, &fi)
+	return fi.fileInfo(), err
+}
+
+func (f *replayFile) Sync() error {
+	return f.replay("Sync", nil)
+}
+
+func (f *replayFile) SyscallConn() (syscall.RawConn, error) {
+	if err := f.replay("SyscallConn", nil); err != nil {
+		return nil, err
+	}
+	return nil, &PathError{Op: "SyscallConn", Path: f.name, Err: errors.ErrUnsupported}
+}
+
+func (f *replayFile) Truncate(size int64) error {
+	return f.replay("Truncate", []any{size})
+}
+
+func (f *replayFile) Write(b []byte) (int, error) {
+	var n int
+	err := f.replay("Write", []any{b}, &n)
+	return n, err
+}
+
+func (f *replayFile) WriteAt(b []byte, off int64) (int, error) {
+	var n int
+	err := f.replay("WriteAt", []any{b, off}, &n)
+	return n, err
+}
+
+func (f *replayFile) WriteString(s string) (int, error) {
+	var n int
+	err := f.replay("WriteString", []any{s}, &n)
+	return n, err
+}
+
+func (f *replayFile) WriteTo(w io.Writer) (int64, error) {
+	return io.Copy(w, replayFileReader{f})
+}
+
+// replayFileReader and replayFileWriter hide the ReaderFrom and
+// WriterTo methods of a replayFile from io.Copy.
+type replayFileReader struct {
+	f *replayFile
+}
+
+func (r replayFileReader) Read(b []byte) (int, error) {
+	return r.f.Read(b)
+}
+
+type replayFileWriter struct {
+	f *replayFile
+}
+
+func (w replayFileWriter) Write(b []byte) (int, error) {
+	return w.f.Write(b)
+}
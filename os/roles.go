@@ -0,0 +1,92 @@
+package os
+
+import (
+	"time"
+)
+
+// The interfaces in this file split OS by role, so that code which
+// needs only part of it can say so, and a fake for that code need only
+// implement that part.  OS embeds all of them.
+
+// EnvReader reads environment variables.
+type EnvReader interface {
+	Environ() []string
+	ExpandEnv(string) string
+	Getenv(string) string
+	LookupEnv(string) (string, bool)
+}
+
+// Env reads and changes environment variables.
+type Env interface {
+	EnvReader
+
+	Clearenv()
+	Setenv(string, string) error
+	Unsetenv(string) error
+}
+
+// FileReader opens and inspects files without changing them.
+type FileReader interface {
+	Lstat(string) (FileInfo, error)
+	Open(string) (File, error)
+	ReadDir(string) ([]DirEntry, error)
+	ReadFile(string) ([]byte, error)
+	Readlink(string) (string, error)
+	Stat(string) (FileInfo, error)
+}
+
+// FileWriter creates, changes and removes files.
+type FileWriter interface {
+	Chmod(string, FSFileMode) error
+	Chown(string, int, int) error
+	Chtimes(string, time.Time, time.Time) error
+	Create(string) (File, error)
+	CreateTemp(string, string) (File, error)
+	Lchown(string, int, int) error
+	Link(string, string) error
+	OpenFile(string, int, FSFileMode) (File, error)
+	Remove(string) error
+	Rename(string, string) error
+	Symlink(string, string) error
+	Truncate(string, int64) error
+	WriteFile(string, []byte, FSFileMode) error
+}
+
+// DirManager creates and removes directories, and moves between them.
+type DirManager interface {
+	Chdir(string) error
+	Getwd() (string, error)
+	Mkdir(string, FSFileMode) error
+	MkdirAll(string, FSFileMode) error
+	MkdirTemp(string, string) (string, error)
+	RemoveAll(string) error
+	TempDir() string
+}
+
+// ProcessInfo describes the current process and the host it runs on.
+type ProcessInfo interface {
+	Args() []string
+	Executable() (string, error)
+	Getegid() int
+	Geteuid() int
+	Getgid() int
+	Getgroups() ([]int, error)
+	Getpagesize() int
+	Getpid() int
+	Getppid() int
+	Getuid() int
+	Hostname() (string, error)
+}
+
+// UserDirs locates the directories of the current user.
+type UserDirs interface {
+	UserCacheDir() (string, error)
+	UserConfigDir() (string, error)
+	UserHomeDir() (string, error)
+}
+
+// ProcessStarter starts processes, or finds running ones.
+type ProcessStarter interface {
+	FindProcess(int) (Process, error)
+	StartProcess(string, []string, *ProcAttr) (Process, error)
+}
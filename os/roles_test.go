@@ -0,0 +1,56 @@
+package os
+
+import (
+	"os"
+	"testing"
+)
+
+// envOnly implements nothing but EnvReader, as a fake for code that
+// depends on it alone would.
+type envOnly map[string]string
+
+func (e envOnly) Environ() []string {
+	var env []string
+	for k, v := range e {
+		env = append(env, k+"="+v)
+	}
+	return env
+}
+
+func (e envOnly) ExpandEnv(s string) string {
+	return os.Expand(s, func(k string) string { return e[k] })
+}
+
+func (e envOnly) Getenv(key string) string {
+	return e[key]
+}
+
+func (e envOnly) LookupEnv(key string) (string, bool) {
+	v, ok := e[key]
+	return v, ok
+}
+
+func TestRoles(t *testing.T) {
+	var o OS = NewMemOS()
+
+	var (
+		_ Env            = o
+		_ FileReader     = o
+		_ FileWriter     = o
+		_ DirManager     = o
+		_ ProcessInfo    = o
+		_ UserDirs       = o
+		_ ProcessStarter = o
+	)
+
+	greeting := func(env EnvReader) string {
+		return env.ExpandEnv("hello $USER")
+	}
+	if got := greeting(envOnly{"USER": "gopher"}); got != "hello gopher" {
+		t.Errorf("greeting() with fake = %q, want %q", got, "hello gopher")
+	}
+	o.Setenv("USER", "root")
+	if got := greeting(o); got != "hello root" {
+		t.Errorf("greeting() with OS = %q, want %q", got, "hello root")
+	}
+}
@@ -2,11 +2,16 @@ package os
 
 import (
 	"os"
+	"time"
 
 	"github.com/pdutton/go-interfaces/io/fs"
 )
 
+// Root mirrors os.Root.  When built with Go 1.25 or later it also has
+// the methods os.Root gained in that release, listed in rootGo125Methods.
 type Root interface {
+	rootGo125
+
 	Close() error
 	Create(string) (File, error)
 	FS() fs.FS
@@ -22,6 +27,24 @@ type Root interface {
 	Nub() *os.Root
 }
 
+// rootGo125Methods are the methods added to os.Root in Go 1.25.  The
+// implementations of Root in this package have them whatever version
+// of Go they are built with.
+type rootGo125Methods interface {
+	Chmod(string, FSFileMode) error
+	Chown(string, int, int) error
+	Chtimes(string, time.Time, time.Time) error
+	Lchown(string, int, int) error
+	Link(string, string) error
+	MkdirAll(string, FSFileMode) error
+	ReadFile(string) ([]byte, error)
+	Readlink(string) (string, error)
+	RemoveAll(string) error
+	Rename(string, string) error
+	Symlink(string, string) error
+	WriteFile(string, []byte, FSFileMode) error
+}
+
 type rootFacade struct {
 	nub *os.Root
 }
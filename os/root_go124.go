@@ -0,0 +1,6 @@
+//go:build !go1.25
+
+package os
+
+// Before Go 1.25, os.Root has none of the methods in rootGo125Methods.
+type rootGo125 = interface{}
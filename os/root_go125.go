@@ -0,0 +1,57 @@
+//go:build go1.25
+
+package os
+
+import (
+	"time"
+)
+
+type rootGo125 = rootGo125Methods
+
+func (r rootFacade) Chmod(name string, mode FSFileMode) error {
+	return r.nub.Chmod(name, mode)
+}
+
+func (r rootFacade) Chown(name string, uid, gid int) error {
+	return r.nub.Chown(name, uid, gid)
+}
+
+func (r rootFacade) Chtimes(name string, atime, mtime time.Time) error {
+	return r.nub.Chtimes(name, atime, mtime)
+}
+
+func (r rootFacade) Lchown(name string, uid, gid int) error {
+	return r.nub.Lchown(name, uid, gid)
+}
+
+func (r rootFacade) Link(oldname, newname string) error {
+	return r.nub.Link(oldname, newname)
+}
+
+func (r rootFacade) MkdirAll(name string, perm FSFileMode) error {
+	return r.nub.MkdirAll(name, perm)
+}
+
+func (r rootFacade) ReadFile(name string) ([]byte, error) {
+	return r.nub.ReadFile(name)
+}
+
+func (r rootFacade) Readlink(name string) (string, error) {
+	return r.nub.Readlink(name)
+}
+
+func (r rootFacade) RemoveAll(name string) error {
+	return r.nub.RemoveAll(name)
+}
+
+func (r rootFacade) Rename(oldname, newname string) error {
+	return r.nub.Rename(oldname, newname)
+}
+
+func (r rootFacade) Symlink(oldname, newname string) error {
+	return r.nub.Symlink(oldname, newname)
+}
+
+func (r rootFacade) WriteFile(name string, data []byte, perm FSFileMode) error {
+	return r.nub.WriteFile(name, data, perm)
+}
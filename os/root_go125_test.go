@@ -0,0 +1,83 @@
+//go:build go1.25
+
+package os
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRoot_Go125(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		open func(*testing.T) (Root, error)
+	}{
+		{"os", func(t *testing.T) (Root, error) { return NewOS().OpenRoot(t.TempDir()) }},
+		{"mem", func(*testing.T) (Root, error) { return NewMemOS().OpenRoot("/tmp") }},
+		{"path", func(*testing.T) (Root, error) { return newPathRoot(NewMemOS(), "/tmp") }},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := tt.open(t)
+			if err != nil {
+				t.Fatalf("OpenRoot() error = %v", err)
+			}
+			defer r.Close()
+
+			if err := r.MkdirAll("a/b", 0755); err != nil {
+				t.Fatalf("MkdirAll() error = %v", err)
+			}
+			if err := r.WriteFile("a/b/f", []byte("data"), 0644); err != nil {
+				t.Fatalf("WriteFile() error = %v", err)
+			}
+			if data, err := r.ReadFile("a/b/f"); err != nil || string(data) != "data" {
+				t.Errorf("ReadFile() = %q, %v, want %q", data, err, "data")
+			}
+
+			if err := r.Chmod("a/b/f", 0600); err != nil {
+				t.Errorf("Chmod() error = %v", err)
+			}
+			mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+			if err := r.Chtimes("a/b/f", mtime, mtime); err != nil {
+				t.Errorf("Chtimes() error = %v", err)
+			}
+			if fi, err := r.Stat("a/b/f"); err != nil || fi.Mode().Perm() != 0600 || !fi.ModTime().Equal(mtime) {
+				t.Errorf("Stat() = %v, %v", fi, err)
+			}
+
+			if err := r.Rename("a/b/f", "g"); err != nil {
+				t.Errorf("Rename() error = %v", err)
+			}
+			if err := r.Link("g", "h"); err != nil {
+				t.Errorf("Link() error = %v", err)
+			}
+			if err := r.Symlink("g", "link"); err != nil {
+				t.Errorf("Symlink() error = %v", err)
+			}
+			if target, err := r.Readlink("link"); err != nil || target != "g" {
+				t.Errorf("Readlink() = %q, %v, want %q", target, err, "g")
+			}
+
+			// os.Root has its own copy of errPathEscapes.
+			escapes := func(err error) bool {
+				return err != nil && strings.HasSuffix(err.Error(), errPathEscapes.Error())
+			}
+			err = r.Rename("g", "../escaped")
+			var le *LinkError
+			if !errors.As(err, &le) || le.Op != "renameat" || !escapes(err) {
+				t.Errorf("Rename() out of root error = %#v, want %v", err, errPathEscapes)
+			}
+			if _, err := r.ReadFile("../escaped"); !escapes(err) {
+				t.Errorf("ReadFile() out of root error = %v, want %v", err, errPathEscapes)
+			}
+
+			if err := r.RemoveAll("a"); err != nil {
+				t.Errorf("RemoveAll() error = %v", err)
+			}
+			if _, err := r.Lstat("a"); !errors.Is(err, ErrNotExist) {
+				t.Errorf("Lstat() after RemoveAll() error = %v, want ErrNotExist", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package signal
+
+import (
+	"context"
+	"os"
+	"sync"
+)
+
+// FakeSignal is a Signal that delivers only the signals a test raises,
+// instead of those the process receives.  It keeps the rules of
+// os/signal: a signal goes to every channel that asked for it, without
+// blocking, so a full channel misses it, and nowhere while it is
+// ignored.
+type FakeSignal interface {
+	Signal
+
+	// Raise delivers sig as if the process had received it.  Contexts
+	// from NotifyContext are done by the time Raise returns.
+	Raise(sig os.Signal)
+}
+
+// sigSet is a set of signals, or of all signals but some.
+type sigSet struct {
+	all    bool
+	sigs   map[os.Signal]bool
+	except map[os.Signal]bool
+}
+
+func (s *sigSet) has(sig os.Signal) bool {
+	if s.all {
+		return !s.except[sig]
+	}
+	return s.sigs[sig]
+}
+
+// add adds sigs, or every signal if there are none.
+func (s *sigSet) add(sigs ...os.Signal) {
+	if len(sigs) == 0 {
+		*s = sigSet{all: true}
+		return
+	}
+	for _, sig := range sigs {
+		if s.all {
+			delete(s.except, sig)
+			continue
+		}
+		if s.sigs == nil {
+			s.sigs = make(map[os.Signal]bool)
+		}
+		s.sigs[sig] = true
+	}
+}
+
+// remove removes sigs, or every signal if there are none.
+func (s *sigSet) remove(sigs ...os.Signal) {
+	if len(sigs) == 0 {
+		*s = sigSet{}
+		return
+	}
+	for _, sig := range sigs {
+		if !s.all {
+			delete(s.sigs, sig)
+			continue
+		}
+		if s.except == nil {
+			s.except = make(map[os.Signal]bool)
+		}
+		s.except[sig] = true
+	}
+}
+
+func (s *sigSet) empty() bool {
+	return !s.all && len(s.sigs) == 0
+}
+
+// notifyCtxt is a context from NotifyContext.
+type notifyCtxt struct {
+	sigs   sigSet
+	cancel context.CancelFunc
+}
+
+type fakeSignal struct {
+	mu      sync.Mutex
+	chans   map[chan<- os.Signal]*sigSet
+	ctxts   map[*notifyCtxt]bool
+	ignored sigSet
+}
+
+// NewFakeSignal creates a FakeSignal, with no signal ignored.
+func NewFakeSignal() FakeSignal {
+	return &fakeSignal{
+		chans: make(map[chan<- os.Signal]*sigSet),
+		ctxts: make(map[*notifyCtxt]bool),
+	}
+}
+
+// Ignore ignores sigs, or every signal if there are none, and stops
+// delivering them anywhere.
+func (s *fakeSignal) Ignore(sig ...os.Signal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ignored.add(sig...)
+	s.remove(sig...)
+}
+
+func (s *fakeSignal) Ignored(sig os.Signal) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.ignored.has(sig)
+}
+
+// Notify delivers sig, or every signal if there are none, to c, and
+// stops ignoring them.
+func (s *fakeSignal) Notify(c chan<- os.Signal, sig ...os.Signal) {
+	if c == nil {
+		panic("os/signal: Notify using nil channel")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	set, ok := s.chans[c]
+	if !ok {
+		set = &sigSet{}
+		s.chans[c] = set
+	}
+	set.add(sig...)
+	s.ignored.remove(sig...)
+}
+
+// NotifyContext returns a copy of parent that is done when one of
+// signals, or any signal if there are none, is raised, or stop is
+// called.
+func (s *fakeSignal) NotifyContext(parent context.Context, signals ...os.Signal) (ctxt context.Context, stop context.CancelFunc) {
+	ctxt, cancel := context.WithCancel(parent)
+
+	var n = &notifyCtxt{cancel: cancel}
+	n.sigs.add(signals...)
+
+	s.mu.Lock()
+	s.ctxts[n] = true
+	s.ignored.remove(signals...)
+	s.mu.Unlock()
+
+	return ctxt, func() {
+		s.mu.Lock()
+		delete(s.ctxts, n)
+		s.mu.Unlock()
+
+		cancel()
+	}
+}
+
+// Reset stops delivering sig, or every signal if there are none, and
+// stops ignoring them.
+func (s *fakeSignal) Reset(sig ...os.Signal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ignored.remove(sig...)
+	s.remove(sig...)
+}
+
+func (s *fakeSignal) Stop(c chan<- os.Signal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.chans, c)
+}
+
+// remove stops delivering sigs, or every signal, to channels and
+// contexts.  Its caller holds s.mu.
+func (s *fakeSignal) remove(sigs ...os.Signal) {
+	for c, set := range s.chans {
+		set.remove(sigs...)
+		if set.empty() {
+			delete(s.chans, c)
+		}
+	}
+	for n := range s.ctxts {
+		n.sigs.remove(sigs...)
+	}
+}
+
+func (s *fakeSignal) Raise(sig os.Signal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ignored.has(sig) {
+		return
+	}
+
+	for c, set := range s.chans {
+		if !set.has(sig) {
+			continue
+		}
+		select {
+		case c <- sig:
+		default:
+		}
+	}
+	for n := range s.ctxts {
+		if n.sigs.has(sig) {
+			n.cancel()
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package signal
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestNewFakeSignal(t *testing.T) {
+	var _ Signal = NewFakeSignal()
+}
+
+func TestFakeSignal_Notify(t *testing.T) {
+	s := NewFakeSignal()
+
+	var (
+		hup = make(chan os.Signal, 1)
+		all = make(chan os.Signal, 2)
+	)
+	s.Notify(hup, syscall.SIGHUP)
+	s.Notify(all)
+
+	s.Raise(syscall.SIGHUP)
+	s.Raise(syscall.SIGTERM)
+	// all is full, and hup does not want SIGTERM
+	s.Raise(syscall.SIGTERM)
+
+	if got := <-hup; got != syscall.SIGHUP {
+		t.Errorf("hup got %v, want SIGHUP", got)
+	}
+	if got := []os.Signal{<-all, <-all}; got[0] != syscall.SIGHUP || got[1] != syscall.SIGTERM {
+		t.Errorf("all got %v, want [SIGHUP SIGTERM]", got)
+	}
+	select {
+	case sig := <-all:
+		t.Errorf("full channel got %v", sig)
+	case sig := <-hup:
+		t.Errorf("hup got %v", sig)
+	default:
+	}
+
+	s.Stop(hup)
+	s.Raise(syscall.SIGHUP)
+	select {
+	case sig := <-hup:
+		t.Errorf("stopped channel got %v", sig)
+	default:
+	}
+}
+
+func TestFakeSignal_IgnoreReset(t *testing.T) {
+	s := NewFakeSignal()
+
+	c := make(chan os.Signal, 1)
+	s.Notify(c)
+	s.Ignore(syscall.SIGINT)
+	if !s.Ignored(syscall.SIGINT) || s.Ignored(syscall.SIGTERM) {
+		t.Error("Ignore(SIGINT) did not ignore just SIGINT")
+	}
+	s.Raise(syscall.SIGINT)
+	select {
+	case sig := <-c:
+		t.Errorf("ignored signal delivered as %v", sig)
+	default:
+	}
+
+	s.Notify(c, syscall.SIGINT)
+	if s.Ignored(syscall.SIGINT) {
+		t.Error("Notify() did not stop ignoring SIGINT")
+	}
+	s.Raise(syscall.SIGINT)
+	if sig := <-c; sig != syscall.SIGINT {
+		t.Errorf("got %v, want SIGINT", sig)
+	}
+
+	s.Reset(syscall.SIGINT)
+	s.Raise(syscall.SIGINT)
+	s.Raise(syscall.SIGTERM)
+	if sig := <-c; sig != syscall.SIGTERM {
+		t.Errorf("got %v after Reset(SIGINT), want SIGTERM", sig)
+	}
+
+	s.Reset()
+	s.Raise(syscall.SIGTERM)
+	select {
+	case sig := <-c:
+		t.Errorf("got %v after Reset()", sig)
+	default:
+	}
+}
+
+func TestFakeSignal_NotifyContext(t *testing.T) {
+	s := NewFakeSignal()
+
+	ctxt, stop := s.NotifyContext(context.Background(), syscall.SIGTERM)
+	defer stop()
+
+	s.Raise(syscall.SIGHUP)
+	if ctxt.Err() != nil {
+		t.Fatal("context done after SIGHUP")
+	}
+	s.Raise(syscall.SIGTERM)
+	if ctxt.Err() != context.Canceled {
+		t.Errorf("Err() after SIGTERM = %v, want Canceled", ctxt.Err())
+	}
+
+	ctxt, stop = s.NotifyContext(context.Background(), syscall.SIGTERM)
+	s.Ignore(syscall.SIGTERM)
+	s.Raise(syscall.SIGTERM)
+	if ctxt.Err() != nil {
+		t.Error("context done by an ignored signal")
+	}
+	stop()
+	if ctxt.Err() == nil {
+		t.Error("context not done after stop()")
+	}
+}
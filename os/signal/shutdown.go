@@ -0,0 +1,271 @@
+package signal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	stdsync "sync"
+	"syscall"
+	"time"
+
+	http "github.com/pdutton/go-interfaces/net/http/server"
+	"github.com/pdutton/go-interfaces/os"
+	"github.com/pdutton/go-interfaces/sync"
+)
+
+// Shutdown waits for a signal, then shuts a service down in phases.
+// The phases run one after the other, in the order they were added,
+// each with its own time limit, and the steps of a phase run in the
+// order they were added to it.  A second signal, while the phases are
+// still running, exits the process at once.  A shutdown started by
+// Trigger or a context counts no signal, so it takes two.
+//
+// A typical service adds a phase that stops its servers, then one that
+// waits for its workers, then one that closes what they shared:
+//
+//	s := signal.NewShutdown(signal.NewSignal(), os.NewOS())
+//	s.Phase("servers", 10*time.Second).Server("http", srv)
+//	s.Phase("workers", 30*time.Second).WaitGroup("jobs", wg)
+//	s.Phase("storage", 5*time.Second).Closer("db", db)
+//	go work(s.Context(), wg)
+//	err := s.Run(context.Background())
+type Shutdown struct {
+	sig      Signal
+	os       os.OS
+	signals  []os.Signal
+	exitCode int
+
+	ctxt   context.Context
+	cancel context.CancelFunc
+
+	mu      stdsync.Mutex
+	phases  []*Phase
+	trigger chan struct{}
+	once    stdsync.Once
+}
+
+type ShutdownOption func(*Shutdown)
+
+// WithShutdownSignals sets the signals that start the shutdown, which
+// are SIGINT and SIGTERM by default.
+func WithShutdownSignals(sigs ...os.Signal) ShutdownOption {
+	return func(s *Shutdown) {
+		s.signals = sigs
+	}
+}
+
+// WithExitCode sets the status the process exits with on a second
+// signal, which is 1 by default.
+func WithExitCode(code int) ShutdownOption {
+	return func(s *Shutdown) {
+		s.exitCode = code
+	}
+}
+
+// NewShutdown creates a Shutdown that listens for signals on sig, and
+// exits the process with o.
+func NewShutdown(sig Signal, o os.OS, options ...ShutdownOption) *Shutdown {
+	var s = &Shutdown{
+		sig:      sig,
+		os:       o,
+		signals:  []os.Signal{syscall.SIGINT, syscall.SIGTERM},
+		exitCode: 1,
+		trigger:  make(chan struct{}),
+	}
+	s.ctxt, s.cancel = context.WithCancel(context.Background())
+
+	for _, f := range options {
+		f(s)
+	}
+
+	return s
+}
+
+// Context returns a context that is done once the shutdown starts.
+func (s *Shutdown) Context() context.Context {
+	return s.ctxt
+}
+
+// Trigger starts the shutdown, as a signal would.
+func (s *Shutdown) Trigger() {
+	s.once.Do(func() {
+		close(s.trigger)
+	})
+}
+
+// Phase adds a phase to the shutdown, whose steps have timeout to
+// finish between them.
+func (s *Shutdown) Phase(name string, timeout time.Duration) *Phase {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var p = &Phase{
+		name:    name,
+		timeout: timeout,
+	}
+	s.phases = append(s.phases, p)
+	return p
+}
+
+// Run waits for one of the signals, for Trigger to be called or for
+// ctxt to be done, then runs the phases.  It returns the errors of the
+// steps that failed, each a *ShutdownError, joined.
+func (s *Shutdown) Run(ctxt context.Context) error {
+	var sigs = make(chan os.Signal, 1)
+	s.sig.Notify(sigs, s.signals...)
+	defer s.sig.Stop(sigs)
+
+	// signals counts the signals received, so that it is the second
+	// that exits, whatever started the shutdown
+	var signals int
+	select {
+	case <-sigs:
+		signals++
+	case <-s.trigger:
+	case <-ctxt.Done():
+	}
+	s.cancel()
+
+	var done = make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-sigs:
+				if signals++; signals >= 2 {
+					s.os.Exit(s.exitCode)
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	s.mu.Lock()
+	phases := s.phases
+	s.mu.Unlock()
+
+	var errs []error
+	for _, p := range phases {
+		errs = append(errs, p.run(context.WithoutCancel(ctxt))...)
+	}
+	return errors.Join(errs...)
+}
+
+// ShutdownError is the failure of a step of a Shutdown.
+type ShutdownError struct {
+	Phase string
+	Step  string
+	Err   error
+}
+
+func (e *ShutdownError) Error() string {
+	return fmt.Sprintf("signal: shutdown %s: %s: %v", e.Phase, e.Step, e.Err)
+}
+
+func (e *ShutdownError) Unwrap() error {
+	return e.Err
+}
+
+// Phase is a stage of a Shutdown.  Its steps run in the order they
+// were added, and all must finish within its timeout; a step that is
+// still running when it expires is abandoned, and the rest are not
+// run, but reported as failed.  An abandoned step is not stopped: its
+// goroutine runs on until it returns, with its context done, which a
+// Hook should heed; Closer and WaitGroup steps do not look at it.
+type Phase struct {
+	name    string
+	timeout time.Duration
+
+	mu    stdsync.Mutex
+	steps []step
+}
+
+type step struct {
+	name string
+	run  func(context.Context) error
+}
+
+func (p *Phase) add(name string, run func(context.Context) error) *Phase {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.steps = append(p.steps, step{name: name, run: run})
+	return p
+}
+
+// Hook adds a step that calls f.
+func (p *Phase) Hook(name string, f func(context.Context) error) *Phase {
+	return p.add(name, f)
+}
+
+// Server adds a step that shuts srv down gracefully, and closes it if
+// that takes too long.  The functions in onShutdown are registered with
+// srv, to be called as its shutdown starts.
+func (p *Phase) Server(name string, srv http.Server, onShutdown ...func()) *Phase {
+	for _, f := range onShutdown {
+		srv.RegisterOnShutdown(f)
+	}
+
+	return p.add(name, func(ctxt context.Context) error {
+		err := srv.Shutdown(ctxt)
+		if ctxt.Err() != nil {
+			return errors.Join(err, srv.Close())
+		}
+		return err
+	})
+}
+
+// Closer adds a step that closes c.
+func (p *Phase) Closer(name string, c io.Closer) *Phase {
+	return p.add(name, func(context.Context) error {
+		return c.Close()
+	})
+}
+
+// WaitGroup adds a step that waits for wg.
+func (p *Phase) WaitGroup(name string, wg sync.WaitGroup) *Phase {
+	return p.add(name, func(context.Context) error {
+		wg.Wait()
+		return nil
+	})
+}
+
+func (p *Phase) run(parent context.Context) []error {
+	ctxt, cancel := context.WithTimeout(parent, p.timeout)
+	defer cancel()
+
+	p.mu.Lock()
+	steps := p.steps
+	p.mu.Unlock()
+
+	var errs []error
+	for _, st := range steps {
+		if err := p.runStep(ctxt, st); err != nil {
+			errs = append(errs, &ShutdownError{Phase: p.name, Step: st.name, Err: err})
+		}
+	}
+	return errs
+}
+
+// runStep runs st, or gives up on it once ctxt is done, leaving it to
+// run on in its goroutine.
+func (p *Phase) runStep(ctxt context.Context, st step) error {
+	if err := ctxt.Err(); err != nil {
+		return err
+	}
+
+	var done = make(chan error, 1)
+	go func() {
+		done <- st.run(ctxt)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctxt.Done():
+		return ctxt.Err()
+	}
+}
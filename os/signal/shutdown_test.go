@@ -0,0 +1,199 @@
+package signal
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	stdsync "sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/pdutton/go-interfaces/os"
+	"github.com/pdutton/go-interfaces/sync"
+)
+
+// testServer is an http.Server whose Shutdown waits for its context if
+// it is slow.
+type testServer struct {
+	slow   bool
+	hooks  []func()
+	closed chan struct{}
+}
+
+func (s *testServer) Close() error                           { close(s.closed); return nil }
+func (s *testServer) ListenAndServe() error                  { return nil }
+func (s *testServer) ListenAndServeTLS(string, string) error { return nil }
+func (s *testServer) RegisterOnShutdown(f func())            { s.hooks = append(s.hooks, f) }
+func (s *testServer) Serve(net.Listener) error               { return nil }
+func (s *testServer) ServeTLS(net.Listener, string, string) error {
+	return nil
+}
+func (s *testServer) SetKeepAlivesEnabled(bool) {}
+
+func (s *testServer) Shutdown(ctxt context.Context) error {
+	for _, f := range s.hooks {
+		f()
+	}
+	if s.slow {
+		<-ctxt.Done()
+		return ctxt.Err()
+	}
+	return nil
+}
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error {
+	return f()
+}
+
+func TestShutdown_Phases(t *testing.T) {
+	sig := NewFakeSignal()
+	s := NewShutdown(sig, os.NewMemOS())
+
+	var (
+		mu    stdsync.Mutex
+		steps []string
+		note  = func(step string) {
+			mu.Lock()
+			defer mu.Unlock()
+			steps = append(steps, step)
+		}
+		srv = &testServer{}
+		wg  = sync.NewSync().NewWaitGroup(sync.WithCount(1))
+	)
+	go func() {
+		<-s.Context().Done()
+		note("worker")
+		wg.Done()
+	}()
+
+	s.Phase("servers", time.Second).
+		Server("http", srv, func() { note("http") }).
+		Hook("drain", func(context.Context) error { note("drain"); return nil })
+	s.Phase("workers", time.Second).WaitGroup("jobs", wg)
+	s.Phase("storage", time.Second).Closer("db", closerFunc(func() error {
+		note("db")
+		return errors.New("already closed")
+	}))
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- s.Run(context.Background())
+	}()
+	// Run may not be listening yet, and a fake signal is not queued
+	for s.Context().Err() == nil {
+		sig.Raise(syscall.SIGTERM)
+		time.Sleep(time.Millisecond)
+	}
+
+	err := <-errc
+	var se *ShutdownError
+	if !errors.As(err, &se) || se.Phase != "storage" || se.Step != "db" {
+		t.Errorf("Run() error = %v, want storage db to fail", err)
+	}
+	// the worker runs alongside the first phase
+	servers := slices.DeleteFunc(slices.Clone(steps), func(s string) bool { return s == "worker" })
+	if len(steps) != 4 || steps[3] != "db" || !slices.Equal(servers, []string{"http", "drain", "db"}) {
+		t.Errorf("steps = %v, want http, drain and worker, then db", steps)
+	}
+}
+
+func TestShutdown_Timeout(t *testing.T) {
+	s := NewShutdown(NewFakeSignal(), os.NewMemOS())
+
+	var (
+		srv  = &testServer{slow: true, closed: make(chan struct{})}
+		late bool
+	)
+	s.Phase("servers", 10*time.Millisecond).
+		Server("http", srv).
+		Hook("late", func(context.Context) error { late = true; return nil })
+	s.Trigger()
+
+	err := s.Run(context.Background())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Run() error = %v, want DeadlineExceeded", err)
+	}
+	if late {
+		t.Error("step after the timeout ran")
+	}
+	// the server is closed once its Shutdown gives up
+	select {
+	case <-srv.closed:
+	case <-time.After(time.Second):
+		t.Error("slow server not closed")
+	}
+}
+
+func TestShutdown_ForceExit(t *testing.T) {
+	var (
+		sig    = NewFakeSignal()
+		exited = make(chan int, 1)
+		o      = os.NewMemOS(os.WithExitFunc(func(code int) { exited <- code }))
+		s      = NewShutdown(sig, o, WithExitCode(3))
+		stuck  = make(chan struct{})
+	)
+	defer close(stuck)
+	s.Phase("stuck", time.Minute).Hook("wait", func(context.Context) error {
+		<-stuck
+		return nil
+	})
+
+	go s.Run(context.Background())
+	for s.Context().Err() == nil {
+		sig.Raise(syscall.SIGINT)
+		time.Sleep(time.Millisecond)
+	}
+	sig.Raise(syscall.SIGINT)
+
+	select {
+	case code := <-exited:
+		if code != 3 {
+			t.Errorf("Exit(%d), want Exit(3)", code)
+		}
+	case <-time.After(time.Second):
+		t.Error("second signal did not exit")
+	}
+}
+
+func TestShutdown_TriggerThenSignals(t *testing.T) {
+	var (
+		sig    = NewFakeSignal()
+		exited = make(chan int, 1)
+		o      = os.NewMemOS(os.WithExitFunc(func(code int) { exited <- code }))
+		s      = NewShutdown(sig, o)
+		stuck  = make(chan struct{})
+		ran    = make(chan struct{})
+	)
+	defer close(stuck)
+	s.Phase("stuck", time.Minute).Hook("wait", func(context.Context) error {
+		close(ran)
+		<-stuck
+		return nil
+	})
+
+	go s.Run(context.Background())
+	s.Trigger()
+	<-ran
+
+	// the first signal is only the first, as Trigger was no signal
+	sig.Raise(syscall.SIGTERM)
+	select {
+	case code := <-exited:
+		t.Fatalf("first signal after Trigger exited with %d", code)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	sig.Raise(syscall.SIGTERM)
+	select {
+	case code := <-exited:
+		if code != 1 {
+			t.Errorf("Exit(%d), want Exit(1)", code)
+		}
+	case <-time.After(time.Second):
+		t.Error("second signal did not exit")
+	}
+}
@@ -0,0 +1,668 @@
+package os
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	stdfs "io/fs"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"unicode/utf8"
+
+	"github.com/pdutton/go-interfaces/internal/txtar"
+	"github.com/pdutton/go-interfaces/io/fs"
+)
+
+// Snapshot is a manifest of a directory tree, for checking that code
+// produced exactly the tree it should.  It lists everything below the
+// root, parents before their children, but not the root itself.
+type Snapshot struct {
+	Entries []SnapshotEntry
+}
+
+// SnapshotEntry is a file, directory or symbolic link of a Snapshot.
+// Modification times are left out, as they differ from run to run.
+type SnapshotEntry struct {
+	// Path is the slash-separated name of the entry below the root.
+	Path string
+	Mode FSFileMode
+
+	// Size and SHA256, the hex digest of the contents, are set for
+	// regular files only.
+	Size   int64
+	SHA256 string
+
+	// Target is the target of a symbolic link.
+	Target string
+
+	// Content is the contents of a regular file, if the snapshot was
+	// taken WithSnapshotContents.  It is shown in diffs, but the hash
+	// is what is compared.
+	Content []byte
+}
+
+// SnapshotOption sets what a snapshot records.
+type SnapshotOption func(*snapshotOptions)
+
+type snapshotOptions struct {
+	contents bool
+	ignore   []string
+}
+
+// WithSnapshotContents sets whether a snapshot records the contents of
+// regular files, as well as their hashes.
+func WithSnapshotContents(contents bool) SnapshotOption {
+	return func(o *snapshotOptions) {
+		o.contents = contents
+	}
+}
+
+// WithSnapshotIgnore leaves out the entries whose path, or base name,
+// matches one of the path.Match patterns, and everything below them.
+func WithSnapshotIgnore(patterns ...string) SnapshotOption {
+	return func(o *snapshotOptions) {
+		o.ignore = append(o.ignore, patterns...)
+	}
+}
+
+// snapshotSource is a tree to take a snapshot of, addressed by
+// slash-separated names below its root.
+type snapshotSource interface {
+	stat(name string) (stdfs.FileInfo, error)
+	lstat(name string) (stdfs.FileInfo, error)
+	readDir(name string) ([]string, error)
+	readlink(name string) (string, error)
+	readFile(name string) ([]byte, error)
+}
+
+type osSource struct {
+	os  OS
+	dir string
+}
+
+func (s osSource) path(name string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(name))
+}
+
+func (s osSource) stat(name string) (stdfs.FileInfo, error) {
+	fi, err := s.os.Stat(s.path(name))
+	if err != nil {
+		return nil, err
+	}
+	return fi.Nub(), nil
+}
+
+func (s osSource) lstat(name string) (stdfs.FileInfo, error) {
+	fi, err := s.os.Lstat(s.path(name))
+	if err != nil {
+		return nil, err
+	}
+	return fi.Nub(), nil
+}
+
+func (s osSource) readDir(name string) ([]string, error) {
+	entries, err := s.os.ReadDir(s.path(name))
+	if err != nil {
+		return nil, err
+	}
+	var names = make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names, nil
+}
+
+func (s osSource) readlink(name string) (string, error) {
+	return s.os.Readlink(s.path(name))
+}
+
+func (s osSource) readFile(name string) ([]byte, error) {
+	return s.os.ReadFile(s.path(name))
+}
+
+type fsSource struct {
+	fsys fs.FS
+	dir  string
+}
+
+func (s fsSource) path(name string) string {
+	return path.Join(s.dir, name)
+}
+
+func (s fsSource) stat(name string) (stdfs.FileInfo, error) {
+	return stdfs.Stat(s.fsys, s.path(name))
+}
+
+// lstat uses the Lstat method of file systems that have one, such as
+// those that implement fs.ReadLinkFS, and Stat otherwise.
+func (s fsSource) lstat(name string) (stdfs.FileInfo, error) {
+	if fsys, ok := s.fsys.(interface {
+		Lstat(string) (stdfs.FileInfo, error)
+	}); ok {
+		return fsys.Lstat(s.path(name))
+	}
+	return s.stat(name)
+}
+
+func (s fsSource) readDir(name string) ([]string, error) {
+	entries, err := stdfs.ReadDir(s.fsys, s.path(name))
+	if err != nil {
+		return nil, err
+	}
+	var names = make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names, nil
+}
+
+func (s fsSource) readlink(name string) (string, error) {
+	if fsys, ok := s.fsys.(interface {
+		ReadLink(string) (string, error)
+	}); ok {
+		return fsys.ReadLink(s.path(name))
+	}
+	return "", &PathError{Op: "readlink", Path: s.path(name), Err: errors.ErrUnsupported}
+}
+
+func (s fsSource) readFile(name string) ([]byte, error) {
+	return stdfs.ReadFile(s.fsys, s.path(name))
+}
+
+// SnapshotOS takes a snapshot of the tree below dir, through o.
+// Symbolic links are recorded, not followed, except for dir itself.
+func SnapshotOS(o OS, dir string, options ...SnapshotOption) (*Snapshot, error) {
+	return takeSnapshot(osSource{os: o, dir: dir}, dir, options)
+}
+
+// SnapshotFS takes a snapshot of the tree below dir in fsys.  Symbolic
+// links are recorded only if fsys has Lstat and ReadLink methods, as an
+// fs.ReadLinkFS does.
+func SnapshotFS(fsys fs.FS, dir string, options ...SnapshotOption) (*Snapshot, error) {
+	return takeSnapshot(fsSource{fsys: fsys, dir: dir}, dir, options)
+}
+
+func takeSnapshot(src snapshotSource, dir string, options []SnapshotOption) (*Snapshot, error) {
+	var opts snapshotOptions
+	for _, f := range options {
+		f(&opts)
+	}
+
+	fi, err := src.stat(".")
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return nil, &PathError{Op: "snapshot", Path: dir, Err: syscall.ENOTDIR}
+	}
+
+	var s = &Snapshot{}
+	if err := s.walk(src, ".", &opts); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Snapshot) walk(src snapshotSource, dir string, opts *snapshotOptions) error {
+	names, err := src.readDir(dir)
+	if err != nil {
+		return err
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		rel := name
+		if dir != "." {
+			rel = dir + "/" + name
+		}
+		if opts.ignored(rel) {
+			continue
+		}
+
+		fi, err := src.lstat(rel)
+		if err != nil {
+			return err
+		}
+
+		var e = SnapshotEntry{Path: rel, Mode: fi.Mode()}
+		switch {
+		case fi.Mode().IsRegular():
+			data, err := src.readFile(rel)
+			if err != nil {
+				return err
+			}
+			sum := sha256.Sum256(data)
+			e.Size = int64(len(data))
+			e.SHA256 = hex.EncodeToString(sum[:])
+			if opts.contents {
+				e.Content = data
+			}
+		case fi.Mode()&stdfs.ModeSymlink != 0:
+			if e.Target, err = src.readlink(rel); err != nil {
+				return err
+			}
+		}
+		s.Entries = append(s.Entries, e)
+
+		if fi.IsDir() {
+			if err := s.walk(src, rel, opts); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (o *snapshotOptions) ignored(rel string) bool {
+	for _, pattern := range o.ignore {
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, path.Base(rel)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the manifest line of e: its mode, size, hash and path,
+// and the target of a link, with - for what does not apply.
+//
+//	drwxr-xr-x - - bin
+//	-rwxr-xr-x 12 5891b5b5… bin/run
+//	Lrwxrwxrwx - - bin/latest -> run
+func (e SnapshotEntry) String() string {
+	var size, sum = "-", "-"
+	if e.Mode.IsRegular() {
+		size, sum = strconv.FormatInt(e.Size, 10), e.SHA256
+	}
+
+	line := e.Mode.String() + " " + size + " " + sum + " " + quotePath(e.Path)
+	if e.Mode&stdfs.ModeSymlink != 0 {
+		line += " -> " + quotePath(e.Target)
+	}
+	return line
+}
+
+// quotePath quotes names that would not survive a manifest line as
+// they are.
+func quotePath(name string) string {
+	if name == "" || strings.ContainsAny(name, " \"") || strings.IndexFunc(name, isNotPrint) >= 0 || !utf8.ValidString(name) {
+		return strconv.Quote(name)
+	}
+	return name
+}
+
+func isNotPrint(r rune) bool {
+	return !strconv.IsPrint(r)
+}
+
+func unquotePath(s string) (name, rest string, err error) {
+	if !strings.HasPrefix(s, `"`) {
+		name, rest, _ = strings.Cut(s, " ")
+		return name, rest, nil
+	}
+	q, err := strconv.QuotedPrefix(s)
+	if err != nil {
+		return "", "", err
+	}
+	name, err = strconv.Unquote(q)
+	return name, strings.TrimPrefix(s[len(q):], " "), err
+}
+
+// parseSnapshotLine parses the manifest line of an entry.
+func parseSnapshotLine(line string) (SnapshotEntry, error) {
+	var e SnapshotEntry
+
+	fields := strings.SplitN(line, " ", 4)
+	if len(fields) != 4 {
+		return e, fmt.Errorf("os: bad snapshot line %q", line)
+	}
+
+	mode, err := parseMode(fields[0])
+	if err != nil {
+		return e, err
+	}
+	e.Mode = mode
+
+	if mode.IsRegular() {
+		if e.Size, err = strconv.ParseInt(fields[1], 10, 64); err != nil {
+			return e, fmt.Errorf("os: bad size in snapshot line %q", line)
+		}
+		e.SHA256 = fields[2]
+	}
+
+	var rest string
+	if e.Path, rest, err = unquotePath(fields[3]); err != nil {
+		return e, fmt.Errorf("os: bad path in snapshot line %q", line)
+	}
+	if mode&stdfs.ModeSymlink != 0 {
+		target, ok := strings.CutPrefix(rest, "-> ")
+		if !ok {
+			return e, fmt.Errorf("os: no target in snapshot line %q", line)
+		}
+		if e.Target, _, err = unquotePath(target); err != nil {
+			return e, fmt.Errorf("os: bad target in snapshot line %q", line)
+		}
+	}
+	return e, nil
+}
+
+// parseMode parses the String of an FSFileMode.
+func parseMode(s string) (FSFileMode, error) {
+	const typeChars = "dalTLDpSugct?"
+
+	if len(s) < 10 {
+		return 0, fmt.Errorf("os: bad file mode %q", s)
+	}
+
+	var mode FSFileMode
+	for _, c := range s[:len(s)-9] {
+		i := strings.IndexRune(typeChars, c)
+		if i < 0 {
+			if c == '-' && len(s) == 10 {
+				continue
+			}
+			return 0, fmt.Errorf("os: bad file mode %q", s)
+		}
+		mode |= 1 << (31 - i)
+	}
+	for i, c := range []byte(s[len(s)-9:]) {
+		switch {
+		case c == "rwxrwxrwx"[i]:
+			mode |= 1 << (8 - i)
+		case c != '-':
+			return 0, fmt.Errorf("os: bad file mode %q", s)
+		}
+	}
+	return mode, nil
+}
+
+type snapshotEntryJSON struct {
+	Path          string  `json:"path"`
+	Mode          string  `json:"mode"`
+	Size          int64   `json:"size,omitempty"`
+	SHA256        string  `json:"sha256,omitempty"`
+	Target        string  `json:"target,omitempty"`
+	Content       *string `json:"content,omitempty"`
+	ContentBase64 []byte  `json:"content_base64,omitempty"`
+}
+
+// MarshalJSON writes the mode as its String, and contents as text if
+// they are valid UTF-8, and in base64 otherwise.
+func (e SnapshotEntry) MarshalJSON() ([]byte, error) {
+	var j = snapshotEntryJSON{
+		Path:   e.Path,
+		Mode:   e.Mode.String(),
+		Size:   e.Size,
+		SHA256: e.SHA256,
+		Target: e.Target,
+	}
+	switch {
+	case e.Content == nil:
+	case utf8.Valid(e.Content):
+		s := string(e.Content)
+		j.Content = &s
+	default:
+		j.ContentBase64 = e.Content
+	}
+	return json.Marshal(j)
+}
+
+func (e *SnapshotEntry) UnmarshalJSON(data []byte) error {
+	var j snapshotEntryJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+
+	mode, err := parseMode(j.Mode)
+	if err != nil {
+		return err
+	}
+	*e = SnapshotEntry{
+		Path:    j.Path,
+		Mode:    mode,
+		Size:    j.Size,
+		SHA256:  j.SHA256,
+		Target:  j.Target,
+		Content: j.ContentBase64,
+	}
+	if j.Content != nil {
+		e.Content = []byte(*j.Content)
+	}
+	return nil
+}
+
+// JSON returns s as indented JSON.
+func (s *Snapshot) JSON() ([]byte, error) {
+	data, err := json.MarshalIndent(struct {
+		Entries []SnapshotEntry `json:"entries"`
+	}{s.Entries}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// Txtar returns s as a txtar archive, whose comment is the manifest,
+// one String per line, and whose files are the contents of the regular
+// files, if the snapshot has them.
+func (s *Snapshot) Txtar() []byte {
+	var a = &txtar.Archive{}
+
+	var comment strings.Builder
+	for _, e := range s.Entries {
+		comment.WriteString(e.String() + "\n")
+		if e.Content != nil {
+			a.Files = append(a.Files, txtar.File{Name: e.Path, Data: e.Content})
+		}
+	}
+	a.Comment = []byte(comment.String())
+
+	return txtar.Format(a)
+}
+
+// ParseSnapshot parses a snapshot written by JSON or Txtar.
+func ParseSnapshot(data []byte) (*Snapshot, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		var j struct {
+			Entries []SnapshotEntry `json:"entries"`
+		}
+		if err := json.Unmarshal(data, &j); err != nil {
+			return nil, err
+		}
+		return &Snapshot{Entries: j.Entries}, nil
+	}
+
+	var (
+		a = txtar.Parse(data)
+		s = &Snapshot{}
+	)
+	for _, line := range strings.Split(string(a.Comment), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		e, err := parseSnapshotLine(line)
+		if err != nil {
+			return nil, err
+		}
+		s.Entries = append(s.Entries, e)
+	}
+	for _, f := range a.Files {
+		i := slices.IndexFunc(s.Entries, func(e SnapshotEntry) bool { return e.Path == f.Name })
+		if i < 0 || !s.Entries[i].Mode.IsRegular() {
+			return nil, fmt.Errorf("os: snapshot has contents of %q, which is not a file of it", f.Name)
+		}
+		// txtar ends every file with a newline, whether it had one or not
+		data := f.Data
+		if int64(len(data)) == s.Entries[i].Size+1 {
+			data = data[:len(data)-1]
+		}
+		s.Entries[i].Content = data
+	}
+	return s, nil
+}
+
+// Equal reports whether s and t list the same entries, comparing files
+// by hash rather than contents.
+func (s *Snapshot) Equal(t *Snapshot) bool {
+	return s.Diff(t) == ""
+}
+
+// Diff returns the differences between s, what was wanted, and got, or
+// "" if there are none.  Entries only in s are marked -, and those only
+// in got +; an entry that differs is shown both ways, followed by a
+// line diff of its contents if both snapshots have them and they are
+// text.
+func (s *Snapshot) Diff(got *Snapshot) string {
+	var (
+		want  = make(map[string]SnapshotEntry)
+		have  = make(map[string]SnapshotEntry)
+		paths []string
+	)
+	for _, e := range s.Entries {
+		want[e.Path] = e
+		paths = append(paths, e.Path)
+	}
+	for _, e := range got.Entries {
+		have[e.Path] = e
+		if _, ok := want[e.Path]; !ok {
+			paths = append(paths, e.Path)
+		}
+	}
+	slices.SortFunc(paths, func(a, b string) int {
+		return slices.Compare(strings.Split(a, "/"), strings.Split(b, "/"))
+	})
+
+	var buf strings.Builder
+	for _, p := range paths {
+		w, inWant := want[p]
+		g, inGot := have[p]
+		switch {
+		case !inGot:
+			buf.WriteString("-" + w.String() + "\n")
+		case !inWant:
+			buf.WriteString("+" + g.String() + "\n")
+		case w.String() != g.String():
+			buf.WriteString("-" + w.String() + "\n")
+			buf.WriteString("+" + g.String() + "\n")
+			if w.Content != nil && g.Content != nil && utf8.Valid(w.Content) && utf8.Valid(g.Content) {
+				for _, line := range diffLines(string(w.Content), string(g.Content)) {
+					buf.WriteString("    " + line + "\n")
+				}
+			}
+		}
+	}
+	return buf.String()
+}
+
+// diffLines returns a line diff of a and b, from their longest common
+// subsequence of lines.  Texts too long for that are shown as all
+// removed and all added.
+func diffLines(a, b string) []string {
+	var (
+		as = strings.SplitAfter(a, "\n")
+		bs = strings.SplitAfter(b, "\n")
+	)
+	as = slices.DeleteFunc(as, func(s string) bool { return s == "" })
+	bs = slices.DeleteFunc(bs, func(s string) bool { return s == "" })
+
+	var out []string
+	line := func(prefix, s string) {
+		out = append(out, prefix+strings.TrimSuffix(s, "\n"))
+	}
+
+	if len(as)*len(bs) > 1<<20 {
+		for _, s := range as {
+			line("-", s)
+		}
+		for _, s := range bs {
+			line("+", s)
+		}
+		return out
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of
+	// as[i:] and bs[j:]
+	lcs := make([][]int, len(as)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(bs)+1)
+	}
+	for i := len(as) - 1; i >= 0; i-- {
+		for j := len(bs) - 1; j >= 0; j-- {
+			if as[i] == bs[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var i, j int
+	for i < len(as) || j < len(bs) {
+		switch {
+		case i < len(as) && j < len(bs) && as[i] == bs[j]:
+			line(" ", as[i])
+			i++
+			j++
+		case i < len(as) && (j == len(bs) || lcs[i+1][j] >= lcs[i][j+1]):
+			line("-", as[i])
+			i++
+		default:
+			line("+", bs[j])
+			j++
+		}
+	}
+	return out
+}
+
+// CheckSnapshot compares got with the snapshot in the file golden, read
+// through o, and fails t with their diff if they differ.  If update is
+// true, it writes got to golden instead, as JSON if the name ends in
+// .json and as txtar otherwise.  A test usually takes update from a
+// flag:
+//
+//	var update = flag.Bool("update", false, "update golden files")
+//
+//	got, err := os.SnapshotOS(o, dir)
+//	...
+//	os.CheckSnapshot(t, o, "testdata/tree.txtar", got, *update)
+func CheckSnapshot(t TB, o OS, golden string, got *Snapshot, update bool) {
+	t.Helper()
+
+	if update {
+		var data = got.Txtar()
+		if strings.HasSuffix(golden, ".json") {
+			var err error
+			if data, err = got.JSON(); err != nil {
+				t.Errorf("snapshot %s: %v", golden, err)
+				return
+			}
+		}
+		if err := o.WriteFile(golden, data, 0644); err != nil {
+			t.Errorf("snapshot %s: %v", golden, err)
+		}
+		return
+	}
+
+	data, err := o.ReadFile(golden)
+	if err != nil {
+		t.Errorf("snapshot %s: %v (run with update to create it)", golden, err)
+		return
+	}
+	want, err := ParseSnapshot(data)
+	if err != nil {
+		t.Errorf("snapshot %s: %v", golden, err)
+		return
+	}
+	if diff := want.Diff(got); diff != "" {
+		t.Errorf("snapshot %s differs (-want +got):\n%s", golden, diff)
+	}
+}
@@ -0,0 +1,199 @@
+package os
+
+import (
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func snapshotTree(t *testing.T, o OS, dir string) {
+	t.Helper()
+
+	for _, err := range []error{
+		o.MkdirAll(filepath.Join(dir, "bin"), 0755),
+		o.WriteFile(filepath.Join(dir, "bin", "run"), []byte("#!/bin/sh\necho hi\n"), 0755),
+		o.Chmod(filepath.Join(dir, "bin", "run"), 0755),
+		o.Symlink("run", filepath.Join(dir, "bin", "latest")),
+		o.WriteFile(filepath.Join(dir, "my notes.txt"), []byte("one\ntwo\nthree\n"), 0644),
+		o.Mkdir(filepath.Join(dir, ".git"), 0755),
+		o.WriteFile(filepath.Join(dir, ".git", "HEAD"), []byte("ref\n"), 0644),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSnapshotOS(t *testing.T) {
+	o := NewMemOS()
+	snapshotTree(t, o, "/tmp/out")
+
+	s, err := SnapshotOS(o, "/tmp/out", WithSnapshotIgnore(".git"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := string(s.Txtar())
+	want := `drwxr-xr-x - - bin
+Lrwxrwxrwx - - bin/latest -> run
+-rwxr-xr-x 18 299001868fb8c02fd431c336c6d058f5558c5dff5b5af5e6fe04b870a6a9cbba bin/run
+-rw-r--r-- 14 b6285c57e8797db5d4c51c80d6f11938afda9b11c6a003549709189e9b4b92a2 "my notes.txt"
+`
+	if got != want {
+		t.Errorf("Txtar() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func stripHashes(s string) string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		f := strings.SplitN(line, " ", 4)
+		if len(f) == 4 && !strings.HasPrefix(line, " ") {
+			f[2] = "#"
+		}
+		lines = append(lines, strings.Join(f, " "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestSnapshot_RoundTrip(t *testing.T) {
+	o := NewMemOS()
+	snapshotTree(t, o, "/tmp/out")
+
+	s, err := SnapshotOS(o, "/tmp/out", WithSnapshotContents(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	j, err := s.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string][]byte{"txtar": s.Txtar(), "json": j} {
+		p, err := ParseSnapshot(data)
+		if err != nil {
+			t.Fatalf("ParseSnapshot(%s) error = %v", name, err)
+		}
+		if diff := s.Diff(p); diff != "" {
+			t.Errorf("ParseSnapshot(%s) differs:\n%s", name, diff)
+		}
+		if i := len(p.Entries) - 1; string(p.Entries[i].Content) != "one\ntwo\nthree\n" {
+			t.Errorf("ParseSnapshot(%s) content = %q", name, p.Entries[i].Content)
+		}
+	}
+}
+
+func TestSnapshot_Diff(t *testing.T) {
+	o := NewMemOS()
+	snapshotTree(t, o, "/tmp/out")
+	want, _ := SnapshotOS(o, "/tmp/out", WithSnapshotContents(true))
+
+	o.WriteFile("/tmp/out/my notes.txt", []byte("one\n2\nthree\n"), 0644)
+	o.Remove("/tmp/out/bin/latest")
+	o.WriteFile("/tmp/out/new", nil, 0600)
+	got, _ := SnapshotOS(o, "/tmp/out", WithSnapshotContents(true))
+
+	if want.Equal(got) {
+		t.Fatal("Equal() = true for different trees")
+	}
+
+	diff := stripHashes(want.Diff(got))
+	for _, line := range []string{
+		"-Lrwxrwxrwx - # bin/latest -> run",
+		`--rw-r--r-- 14 # "my notes.txt"`,
+		`+-rw-r--r-- 12 # "my notes.txt"`,
+		"     one",
+		"    -two",
+		"    +2",
+		"     three",
+		"+-rw------- 0 # new",
+	} {
+		if !strings.Contains(diff, line+"\n") {
+			t.Errorf("Diff() has no line %q:\n%s", line, diff)
+		}
+	}
+}
+
+func TestSnapshotFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a/b.txt": {Data: []byte("b"), Mode: 0644},
+		"c":       {Data: []byte("c"), Mode: 0600},
+	}
+
+	s, err := SnapshotFS(fsys, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var paths []string
+	for _, e := range s.Entries {
+		paths = append(paths, e.Path)
+	}
+	if got := strings.Join(paths, " "); got != "a a/b.txt c" {
+		t.Errorf("SnapshotFS() paths = %s", got)
+	}
+}
+
+func TestSnapshotOS_Real(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symbolic links need privileges on windows")
+	}
+
+	o := NewOS()
+	dir := t.TempDir()
+	snapshotTree(t, o, dir)
+	m := NewMemOS()
+	snapshotTree(t, m, "/tmp/out")
+
+	disk, err := SnapshotOS(o, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mem, err := SnapshotOS(m, "/tmp/out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := mem.Diff(disk); diff != "" {
+		t.Errorf("real tree differs from in-memory one:\n%s", diff)
+	}
+}
+
+type goldenTB struct {
+	testing.TB
+	errors []string
+}
+
+func (t *goldenTB) Errorf(format string, args ...any) {
+	t.errors = append(t.errors, format)
+}
+
+func TestCheckSnapshot(t *testing.T) {
+	o := NewMemOS()
+	snapshotTree(t, o, "/tmp/out")
+	s, _ := SnapshotOS(o, "/tmp/out")
+
+	for _, golden := range []string{"/tmp/tree.txtar", "/tmp/tree.json"} {
+		tb := &goldenTB{TB: t}
+		CheckSnapshot(tb, o, golden, s, false)
+		if len(tb.errors) != 1 {
+			t.Errorf("CheckSnapshot() of missing %s reported %d errors, want 1", golden, len(tb.errors))
+		}
+
+		tb = &goldenTB{TB: t}
+		CheckSnapshot(tb, o, golden, s, true)
+		CheckSnapshot(tb, o, golden, s, false)
+		if len(tb.errors) != 0 {
+			t.Errorf("CheckSnapshot() after update of %s reported %q", golden, tb.errors)
+		}
+	}
+
+	o.WriteFile("/tmp/out/extra", nil, 0644)
+	changed, _ := SnapshotOS(o, "/tmp/out")
+	tb := &goldenTB{TB: t}
+	CheckSnapshot(tb, o, "/tmp/tree.txtar", changed, false)
+	if len(tb.errors) != 1 {
+		t.Errorf("CheckSnapshot() of changed tree reported %d errors, want 1", len(tb.errors))
+	}
+}
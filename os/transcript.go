@@ -0,0 +1,199 @@
+package os
+
+import (
+	"errors"
+	"io"
+	"syscall"
+	"time"
+
+	"github.com/pdutton/go-interfaces/encoding/json"
+	"github.com/pdutton/go-interfaces/io/fs"
+)
+
+// A transcript is a sequence of JSON objects, one for each call, as
+// written by a RecordOS and read by a ReplayOS.  Methods of a File
+// carry the number of the File, which is assigned when the call that
+// returned it was recorded.
+
+type transcriptCall struct {
+	Method  string            `json:"method"`
+	File    int               `json:"file,omitempty"`
+	Args    []json.RawMessage `json:"args,omitempty"`
+	Results []json.RawMessage `json:"results,omitempty"`
+	Err     *transcriptError  `json:"err,omitempty"`
+}
+
+// transcriptFile stands for a File returned by a call.
+type transcriptFile struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type transcriptFileInfo struct {
+	Name    string     `json:"name"`
+	Size    int64      `json:"size"`
+	Mode    FSFileMode `json:"mode"`
+	ModTime time.Time  `json:"modTime"`
+}
+
+func newTranscriptFileInfo(fi FileInfo) *transcriptFileInfo {
+	if fi == nil {
+		return nil
+	}
+	return &transcriptFileInfo{
+		Name:    fi.Name(),
+		Size:    fi.Size(),
+		Mode:    fi.Mode().Nub(),
+		ModTime: fi.ModTime(),
+	}
+}
+
+func newTranscriptDirEntry(de DirEntry) *transcriptFileInfo {
+	if fi, err := de.Info(); err == nil {
+		return newTranscriptFileInfo(fi)
+	}
+	return &transcriptFileInfo{
+		Name: de.Name(),
+		Mode: de.Type().Nub(),
+	}
+}
+
+// fileInfo returns the FileInfo recorded, which has no Sys.
+func (t *transcriptFileInfo) fileInfo() FileInfo {
+	if t == nil {
+		return nil
+	}
+	return fs.NewFileInfo(&memFileInfo{
+		name:    t.Name,
+		size:    t.Size,
+		mode:    t.Mode,
+		modTime: t.ModTime,
+	})
+}
+
+// transcriptError records an error well enough to rebuild it so that
+// errors.Is and errors.As give the same answers.  Errno is set for a
+// syscall.Errno, and otherwise Message is matched against the errors
+// the os package is known to return, such as io.EOF.
+type transcriptError struct {
+	Type    string `json:"type,omitempty"`
+	Op      string `json:"op,omitempty"`
+	Path    string `json:"path,omitempty"`
+	Old     string `json:"old,omitempty"`
+	New     string `json:"new,omitempty"`
+	Errno   int    `json:"errno,omitempty"`
+	Message string `json:"message"`
+}
+
+var transcriptErrors = []error{
+	io.EOF,
+	io.ErrUnexpectedEOF,
+	io.ErrShortWrite,
+	io.ErrClosedPipe,
+	ErrInvalid,
+	ErrPermission,
+	ErrExist,
+	ErrNotExist,
+	ErrClosed,
+	ErrNoDeadline,
+	ErrDeadlineExceeded,
+	ErrProcessDone,
+	errors.ErrUnsupported,
+	errPathEscapes,
+	errPatternHasSeparator,
+	errWriteAtInAppendMode,
+	errNegativeOffset,
+}
+
+func newTranscriptError(err error) *transcriptError {
+	if err == nil {
+		return nil
+	}
+
+	var t transcriptError
+	switch e := err.(type) {
+	case *PathError:
+		t.Type, t.Op, t.Path = "PathError", e.Op, e.Path
+		err = e.Err
+	case *LinkError:
+		t.Type, t.Op, t.Old, t.New = "LinkError", e.Op, e.Old, e.New
+		err = e.Err
+	case *SyscallError:
+		t.Type, t.Op = "SyscallError", e.Syscall
+		err = e.Err
+	}
+
+	if errno, ok := err.(syscall.Errno); ok {
+		t.Errno = int(errno)
+	}
+	t.Message = err.Error()
+
+	return &t
+}
+
+func (t *transcriptError) error() error {
+	if t == nil {
+		return nil
+	}
+
+	var err error
+	if t.Errno != 0 {
+		err = syscall.Errno(t.Errno)
+	} else {
+		for _, known := range transcriptErrors {
+			if known.Error() == t.Message {
+				err = known
+				break
+			}
+		}
+		if err == nil {
+			err = errors.New(t.Message)
+		}
+	}
+
+	switch t.Type {
+	case "PathError":
+		return &PathError{Op: t.Op, Path: t.Path, Err: err}
+	case "LinkError":
+		return &LinkError{Op: t.Op, Old: t.Old, New: t.New, Err: err}
+	case "SyscallError":
+		return &SyscallError{Syscall: t.Op, Err: err}
+	}
+	return err
+}
+
+// transcriptValue turns an argument or result into something that
+// can be written to a transcript.
+func transcriptValue(v any) any {
+	switch v := v.(type) {
+	case error:
+		return newTranscriptError(v)
+	case FileInfo:
+		return newTranscriptFileInfo(v)
+	case []FileInfo:
+		var infos = make([]*transcriptFileInfo, 0, len(v))
+		for _, fi := range v {
+			infos = append(infos, newTranscriptFileInfo(fi))
+		}
+		return infos
+	case []DirEntry:
+		var infos = make([]*transcriptFileInfo, 0, len(v))
+		for _, de := range v {
+			infos = append(infos, newTranscriptDirEntry(de))
+		}
+		return infos
+	}
+	return v
+}
+
+func marshalTranscript(j json.JSON, values []any) ([]json.RawMessage, error) {
+	var raw = make([]json.RawMessage, 0, len(values))
+	for _, v := range values {
+		data, err := j.Marshal(transcriptValue(v))
+		if err != nil {
+			return nil, err
+		}
+		raw = append(raw, data)
+	}
+	return raw, nil
+}
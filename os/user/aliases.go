@@ -0,0 +1,11 @@
+package user
+
+import (
+	"os/user"
+)
+
+type Group = user.Group
+type UnknownGroupError = user.UnknownGroupError
+type UnknownGroupIdError = user.UnknownGroupIdError
+type UnknownUserError = user.UnknownUserError
+type UnknownUserIdError = user.UnknownUserIdError
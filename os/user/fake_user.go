@@ -0,0 +1,226 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"os/user"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// FakeUser is a User whose users and groups come from tables a test
+// fills in, in the formats of /etc/passwd and /etc/group:
+//
+//	u := user.NewFakeUser()
+//	u.AddPasswd("gopher:x:1000:1000:Gopher,,,:/home/gopher:/bin/sh")
+//	u.AddGroup("gopher:x:1000:\nwheel:x:10:gopher")
+//	u.SetCurrent("gopher")
+type FakeUser interface {
+	User
+
+	// AddPasswd adds the users in passwd, one per line as
+	// name:password:uid:gid:gecos:home:shell.  Blank lines and lines
+	// starting with # are skipped.  A user replaces any of the same name.
+	AddPasswd(passwd string) error
+
+	// AddGroup adds the groups in group, one per line as
+	// name:password:gid:members, the members separated by commas.  Blank
+	// lines and lines starting with # are skipped.  A group replaces any
+	// of the same name.
+	AddGroup(group string) error
+
+	// SetCurrent makes Current return the user called username, who must
+	// have been added.
+	SetCurrent(username string) error
+}
+
+var errNoCurrent = errors.New("user: no current user set")
+
+type fakeGroup struct {
+	Group
+	members []string
+}
+
+type fakeUser struct {
+	mu      sync.Mutex
+	users   []*user.User
+	groups  []*fakeGroup
+	current string
+}
+
+// NewFakeUser creates a FakeUser with no users or groups, and no
+// current user.
+func NewFakeUser() FakeUser {
+	return &fakeUser{}
+}
+
+// records splits table into lines, and the lines into n fields, skipping
+// blank lines and comments.
+func records(table string, n int) ([][]string, error) {
+	var recs [][]string
+	for i, line := range strings.Split(table, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, ":")
+		if len(fields) != n {
+			return nil, fmt.Errorf("user: line %d: %d fields, want %d: %q", i+1, len(fields), n, line)
+		}
+		recs = append(recs, fields)
+	}
+	return recs, nil
+}
+
+func (f *fakeUser) AddPasswd(passwd string) error {
+	recs, err := records(passwd, 7)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, r := range recs {
+		// the full name is the first field of the GECOS field
+		name, _, _ := strings.Cut(r[4], ",")
+		u := &user.User{
+			Username: r[0],
+			Uid:      r[2],
+			Gid:      r[3],
+			Name:     name,
+			HomeDir:  r[5],
+		}
+
+		f.users = slices.DeleteFunc(f.users, func(v *user.User) bool {
+			return v.Username == u.Username
+		})
+		f.users = append(f.users, u)
+	}
+	return nil
+}
+
+func (f *fakeUser) AddGroup(group string) error {
+	recs, err := records(group, 4)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, r := range recs {
+		g := &fakeGroup{Group: Group{Gid: r[2], Name: r[0]}}
+		if r[3] != "" {
+			g.members = strings.Split(r[3], ",")
+		}
+
+		f.groups = slices.DeleteFunc(f.groups, func(v *fakeGroup) bool {
+			return v.Name == g.Name
+		})
+		f.groups = append(f.groups, g)
+	}
+	return nil
+}
+
+func (f *fakeUser) SetCurrent(username string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.findUser(func(u *user.User) bool { return u.Username == username }) == nil {
+		return UnknownUserError(username)
+	}
+	f.current = username
+	return nil
+}
+
+// findUser returns a copy of the first user for which match is true, or
+// nil.  Its caller holds f.mu.
+func (f *fakeUser) findUser(match func(*user.User) bool) *user.User {
+	if i := slices.IndexFunc(f.users, match); i >= 0 {
+		u := *f.users[i]
+		return &u
+	}
+	return nil
+}
+
+// findGroup is findUser for groups.
+func (f *fakeUser) findGroup(match func(*fakeGroup) bool) *Group {
+	if i := slices.IndexFunc(f.groups, match); i >= 0 {
+		g := f.groups[i].Group
+		return &g
+	}
+	return nil
+}
+
+func (f *fakeUser) Current() (*user.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.current == "" {
+		return nil, errNoCurrent
+	}
+	return f.findUser(func(u *user.User) bool { return u.Username == f.current }), nil
+}
+
+// GroupIds returns the primary group of u, then the groups that list u
+// as a member, in the order they were added.
+func (f *fakeUser) GroupIds(u *user.User) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var gids = []string{u.Gid}
+	for _, g := range f.groups {
+		if slices.Contains(g.members, u.Username) && !slices.Contains(gids, g.Gid) {
+			gids = append(gids, g.Gid)
+		}
+	}
+	return gids, nil
+}
+
+func (f *fakeUser) Lookup(username string) (*user.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if u := f.findUser(func(u *user.User) bool { return u.Username == username }); u != nil {
+		return u, nil
+	}
+	return nil, UnknownUserError(username)
+}
+
+func (f *fakeUser) LookupId(uid string) (*user.User, error) {
+	id, err := strconv.Atoi(uid)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if u := f.findUser(func(u *user.User) bool { return u.Uid == uid }); u != nil {
+		return u, nil
+	}
+	return nil, UnknownUserIdError(id)
+}
+
+func (f *fakeUser) LookupGroup(name string) (*Group, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if g := f.findGroup(func(g *fakeGroup) bool { return g.Name == name }); g != nil {
+		return g, nil
+	}
+	return nil, UnknownGroupError(name)
+}
+
+func (f *fakeUser) LookupGroupId(gid string) (*Group, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if g := f.findGroup(func(g *fakeGroup) bool { return g.Gid == gid }); g != nil {
+		return g, nil
+	}
+	return nil, UnknownGroupIdError(gid)
+}
@@ -0,0 +1,119 @@
+package user
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+const passwd = `
+# users
+root:x:0:0:root:/root:/bin/bash
+gopher:x:1000:1000:Gopher,,,:/home/gopher:/bin/sh
+`
+
+const group = `
+root:x:0:
+wheel:x:10:root,gopher
+docker:x:999:gopher
+gopher:x:1000:
+`
+
+func TestFakeUser_Lookup(t *testing.T) {
+	u := NewFakeUser()
+	if err := u.AddPasswd(passwd); err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := u.Lookup("gopher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Uid != "1000" || g.Gid != "1000" || g.Name != "Gopher" || g.HomeDir != "/home/gopher" {
+		t.Errorf("Lookup() = %+v", g)
+	}
+
+	r, err := u.LookupId("0")
+	if err != nil || r.Username != "root" {
+		t.Errorf("LookupId(0) = %+v, %v, want root", r, err)
+	}
+
+	var uerr UnknownUserError
+	if _, err := u.Lookup("nobody"); !errors.As(err, &uerr) {
+		t.Errorf("Lookup() of unknown user error = %v, want UnknownUserError", err)
+	}
+	var ierr UnknownUserIdError
+	if _, err := u.LookupId("42"); !errors.As(err, &ierr) || ierr != 42 {
+		t.Errorf("LookupId() of unknown uid error = %v, want UnknownUserIdError", err)
+	}
+}
+
+func TestFakeUser_Groups(t *testing.T) {
+	u := NewFakeUser()
+	if err := u.AddPasswd(passwd); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.AddGroup(group); err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := u.LookupGroup("docker")
+	if err != nil || g.Gid != "999" {
+		t.Errorf("LookupGroup() = %+v, %v, want gid 999", g, err)
+	}
+	g, err = u.LookupGroupId("10")
+	if err != nil || g.Name != "wheel" {
+		t.Errorf("LookupGroupId() = %+v, %v, want wheel", g, err)
+	}
+	if _, err := u.LookupGroup("staff"); !errors.As(err, new(UnknownGroupError)) {
+		t.Errorf("LookupGroup() of unknown group error = %v, want UnknownGroupError", err)
+	}
+	if _, err := u.LookupGroupId("20"); !errors.As(err, new(UnknownGroupIdError)) {
+		t.Errorf("LookupGroupId() of unknown gid error = %v, want UnknownGroupIdError", err)
+	}
+
+	gopher, _ := u.Lookup("gopher")
+	gids, err := u.GroupIds(gopher)
+	if want := []string{"1000", "10", "999"}; err != nil || !slices.Equal(gids, want) {
+		t.Errorf("GroupIds() = %v, %v, want %v", gids, err, want)
+	}
+}
+
+func TestFakeUser_Current(t *testing.T) {
+	u := NewFakeUser()
+	if err := u.AddPasswd(passwd); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := u.Current(); err == nil {
+		t.Error("Current() with none set error = nil")
+	}
+	if err := u.SetCurrent("nobody"); err == nil {
+		t.Error("SetCurrent() of unknown user error = nil")
+	}
+	if err := u.SetCurrent("gopher"); err != nil {
+		t.Fatal(err)
+	}
+
+	cur, err := u.Current()
+	if err != nil || cur.Username != "gopher" {
+		t.Errorf("Current() = %+v, %v, want gopher", cur, err)
+	}
+
+	// changing the result does not change the table
+	cur.HomeDir = "/tmp"
+	if again, _ := u.Current(); again.HomeDir != "/home/gopher" {
+		t.Errorf("Current().HomeDir = %q after change to copy", again.HomeDir)
+	}
+}
+
+func TestFakeUser_BadTable(t *testing.T) {
+	u := NewFakeUser()
+
+	if err := u.AddPasswd("gopher:x:1000"); err == nil {
+		t.Error("AddPasswd() of short line error = nil")
+	}
+	if err := u.AddGroup("wheel:x:10:a:b"); err == nil {
+		t.Error("AddGroup() of long line error = nil")
+	}
+}
@@ -0,0 +1,3 @@
+// This package provides an interface to functions and structs
+// in the standard os/user package to facilitate mocking.
+package user
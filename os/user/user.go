@@ -0,0 +1,47 @@
+package user
+
+import (
+	"os/user"
+)
+
+// User looks up users and groups.  GroupIds stands in for the method of
+// *user.User, which always asks the system, so that code that lists a
+// user's groups can be given a fake too.
+type User interface {
+	Current() (*user.User, error)
+	GroupIds(u *user.User) ([]string, error)
+	Lookup(username string) (*user.User, error)
+	LookupGroup(name string) (*Group, error)
+	LookupGroupId(gid string) (*Group, error)
+	LookupId(uid string) (*user.User, error)
+}
+
+type userFacade struct{}
+
+func NewUser() User {
+	return userFacade{}
+}
+
+func (_ userFacade) Current() (*user.User, error) {
+	return user.Current()
+}
+
+func (_ userFacade) GroupIds(u *user.User) ([]string, error) {
+	return u.GroupIds()
+}
+
+func (_ userFacade) Lookup(username string) (*user.User, error) {
+	return user.Lookup(username)
+}
+
+func (_ userFacade) LookupGroup(name string) (*Group, error) {
+	return user.LookupGroup(name)
+}
+
+func (_ userFacade) LookupGroupId(gid string) (*Group, error) {
+	return user.LookupGroupId(gid)
+}
+
+func (_ userFacade) LookupId(uid string) (*user.User, error) {
+	return user.LookupId(uid)
+}
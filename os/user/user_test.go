@@ -0,0 +1,31 @@
+package user
+
+import (
+	"testing"
+)
+
+func TestNewUser(t *testing.T) {
+	u := NewUser()
+	_ = u
+}
+
+func TestUser_Current(t *testing.T) {
+	u := NewUser()
+
+	cur, err := u.Current()
+	if err != nil {
+		t.Skipf("Current() error = %v", err)
+	}
+
+	byID, err := u.LookupId(cur.Uid)
+	if err != nil {
+		t.Skipf("LookupId(%q) error = %v", cur.Uid, err)
+	}
+	if byID.Username != cur.Username {
+		t.Errorf("LookupId(%q).Username = %q, want %q", cur.Uid, byID.Username, cur.Username)
+	}
+
+	if _, err := u.GroupIds(cur); err != nil {
+		t.Logf("GroupIds() error = %v", err)
+	}
+}
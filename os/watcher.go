@@ -0,0 +1,312 @@
+package os
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WatchOp is the kind of change a WatchEvent reports.  An event may
+// combine several.
+type WatchOp uint32
+
+const (
+	WatchCreate WatchOp = 1 << iota
+	WatchWrite
+	WatchRemove
+	WatchRename
+	WatchChmod
+)
+
+// Has reports whether op includes each of the changes in o.
+func (op WatchOp) Has(o WatchOp) bool {
+	return op&o == o
+}
+
+func (op WatchOp) String() string {
+	var names []string
+	for _, n := range []struct {
+		op   WatchOp
+		name string
+	}{
+		{WatchCreate, "CREATE"},
+		{WatchWrite, "WRITE"},
+		{WatchRemove, "REMOVE"},
+		{WatchRename, "RENAME"},
+		{WatchChmod, "CHMOD"},
+	} {
+		if op.Has(n.op) {
+			names = append(names, n.name)
+		}
+	}
+	if len(names) == 0 {
+		return "0"
+	}
+	return strings.Join(names, "|")
+}
+
+// WatchEvent is a change to a watched file, or to an entry of a watched
+// directory.
+type WatchEvent struct {
+	Name string
+	Op   WatchOp
+}
+
+func (e WatchEvent) String() string {
+	return e.Op.String() + " " + e.Name
+}
+
+// Watcher reports changes to files and directories.  A watched
+// directory reports changes to its entries, but not to theirs.
+type Watcher interface {
+	// Add starts watching name.
+	Add(name string) error
+
+	// Remove stops watching name.
+	Remove(name string) error
+
+	// Events returns the channel that changes are sent on.  It is closed
+	// by Close.
+	Events() <-chan WatchEvent
+
+	// Errors returns the channel that errors are sent on.  It is closed
+	// by Close.
+	Errors() <-chan error
+
+	// Close stops watching everything.
+	Close() error
+}
+
+var errWatcherClosed = errors.New("os: watcher closed")
+
+// pollStat is what a pollWatcher knows of a file.
+type pollStat struct {
+	mode    FSFileMode
+	size    int64
+	modTime time.Time
+}
+
+type pollWatcher struct {
+	os       OS
+	interval time.Duration
+	events   chan WatchEvent
+	errors   chan error
+	done     chan struct{}
+
+	mu      sync.Mutex
+	watches map[string]map[string]pollStat
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+// NewPollWatcher creates a Watcher that looks for changes through o
+// every interval, with Lstat and ReadDir.  It reports a rename as the
+// removal of the old name and the creation of the new, and a write
+// only once the size or modification time has changed.  It panics if
+// interval is not positive, as time.NewTicker does.
+func NewPollWatcher(o OS, interval time.Duration) Watcher {
+	if interval <= 0 {
+		panic("os: non-positive interval for NewPollWatcher")
+	}
+
+	var w = &pollWatcher{
+		os:       o,
+		interval: interval,
+		events:   make(chan WatchEvent),
+		errors:   make(chan error),
+		done:     make(chan struct{}),
+		watches:  make(map[string]map[string]pollStat),
+	}
+
+	w.wg.Add(1)
+	go w.poll()
+
+	return w
+}
+
+func (w *pollWatcher) Events() <-chan WatchEvent {
+	return w.events
+}
+
+func (w *pollWatcher) Errors() <-chan error {
+	return w.errors
+}
+
+func (w *pollWatcher) Add(name string) error {
+	name = filepath.Clean(name)
+	files, err := w.scan(name)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return errWatcherClosed
+	}
+	w.watches[name] = files
+	return nil
+}
+
+func (w *pollWatcher) Remove(name string) error {
+	name = filepath.Clean(name)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, ok := w.watches[name]; !ok {
+		return &PathError{Op: "remove watch", Path: name, Err: ErrNotExist}
+	}
+	delete(w.watches, name)
+	return nil
+}
+
+func (w *pollWatcher) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.done)
+	w.mu.Unlock()
+
+	w.wg.Wait()
+	close(w.events)
+	close(w.errors)
+	return nil
+}
+
+// scan returns what is at name: the file itself, and its entries if it
+// is a directory.  A file that does not exist has no entries.
+func (w *pollWatcher) scan(name string) (map[string]pollStat, error) {
+	fi, err := w.os.Lstat(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var files = map[string]pollStat{
+		name: {mode: fi.Nub().Mode(), size: fi.Size(), modTime: fi.ModTime()},
+	}
+	if !fi.IsDir() {
+		return files, nil
+	}
+
+	entries, err := w.os.ReadDir(name)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		path := filepath.Join(name, e.Name())
+		fi, err := w.os.Lstat(path)
+		if err != nil {
+			// removed since ReadDir
+			continue
+		}
+		files[path] = pollStat{mode: fi.Nub().Mode(), size: fi.Size(), modTime: fi.ModTime()}
+	}
+	return files, nil
+}
+
+func (w *pollWatcher) poll() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+		}
+
+		w.mu.Lock()
+		var names = make([]string, 0, len(w.watches))
+		for name := range w.watches {
+			names = append(names, name)
+		}
+		w.mu.Unlock()
+
+		for _, name := range names {
+			if !w.check(name) {
+				return
+			}
+		}
+	}
+}
+
+// check compares what is at name with what was there, and reports the
+// changes.  It returns false once the watcher is closed.
+func (w *pollWatcher) check(name string) bool {
+	files, err := w.scan(name)
+	if err != nil && !errors.Is(err, ErrNotExist) {
+		return w.sendError(err)
+	}
+
+	w.mu.Lock()
+	old, ok := w.watches[name]
+	if ok {
+		w.watches[name] = files
+	}
+	w.mu.Unlock()
+	if !ok {
+		return true
+	}
+
+	var events []WatchEvent
+	for path, was := range old {
+		is, ok := files[path]
+		switch {
+		case !ok:
+			events = append(events, WatchEvent{Name: path, Op: WatchRemove})
+			continue
+		case is.mode.Type() != was.mode.Type():
+			events = append(events, WatchEvent{Name: path, Op: WatchRemove}, WatchEvent{Name: path, Op: WatchCreate})
+			continue
+		}
+
+		// A file may be both chmodded and written between polls.
+		var op WatchOp
+		if is.mode != was.mode {
+			op |= WatchChmod
+		}
+		if !is.mode.IsDir() && (is.size != was.size || !is.modTime.Equal(was.modTime)) {
+			op |= WatchWrite
+		}
+		if op != 0 {
+			events = append(events, WatchEvent{Name: path, Op: op})
+		}
+	}
+	for path := range files {
+		if _, ok := old[path]; !ok {
+			events = append(events, WatchEvent{Name: path, Op: WatchCreate})
+		}
+	}
+
+	// report changes in a stable order, parents first
+	slices.SortStableFunc(events, func(a, b WatchEvent) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	for _, ev := range events {
+		select {
+		case w.events <- ev:
+		case <-w.done:
+			return false
+		}
+	}
+	return true
+}
+
+func (w *pollWatcher) sendError(err error) bool {
+	select {
+	case w.errors <- err:
+		return true
+	case <-w.done:
+		return false
+	}
+}
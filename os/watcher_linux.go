@@ -0,0 +1,213 @@
+package os
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+var errWatchOverflow = errors.New("os: watch queue overflowed, events were lost")
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_MOVED_TO |
+	syscall.IN_MODIFY |
+	syscall.IN_DELETE | syscall.IN_DELETE_SELF |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVE_SELF |
+	syscall.IN_ATTRIB
+
+type inotifyWatcher struct {
+	fd     int
+	file   *os.File
+	events chan WatchEvent
+	errors chan error
+	done   chan struct{}
+
+	mu     sync.Mutex
+	names  map[int]string
+	wds    map[string]int
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// NewWatcher creates a Watcher of the real file system.  On Linux it
+// uses inotify; elsewhere it polls every second.
+func NewWatcher() (Watcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+
+	var w = &inotifyWatcher{
+		fd:     fd,
+		file:   os.NewFile(uintptr(fd), "inotify"),
+		events: make(chan WatchEvent),
+		errors: make(chan error),
+		done:   make(chan struct{}),
+		names:  make(map[int]string),
+		wds:    make(map[string]int),
+	}
+
+	w.wg.Add(1)
+	go w.read()
+
+	return w, nil
+}
+
+func (w *inotifyWatcher) Events() <-chan WatchEvent {
+	return w.events
+}
+
+func (w *inotifyWatcher) Errors() <-chan error {
+	return w.errors
+}
+
+func (w *inotifyWatcher) Add(name string) error {
+	name = filepath.Clean(name)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return errWatcherClosed
+	}
+	wd, err := syscall.InotifyAddWatch(w.fd, name, inotifyMask)
+	if err != nil {
+		return &PathError{Op: "inotify_add_watch", Path: name, Err: err}
+	}
+	w.names[wd] = name
+	w.wds[name] = wd
+	return nil
+}
+
+func (w *inotifyWatcher) Remove(name string) error {
+	name = filepath.Clean(name)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	wd, ok := w.wds[name]
+	if !ok {
+		return &PathError{Op: "inotify_rm_watch", Path: name, Err: ErrNotExist}
+	}
+	delete(w.wds, name)
+	delete(w.names, wd)
+	if _, err := syscall.InotifyRmWatch(w.fd, uint32(wd)); err != nil {
+		return &PathError{Op: "inotify_rm_watch", Path: name, Err: err}
+	}
+	return nil
+}
+
+func (w *inotifyWatcher) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.done)
+	w.mu.Unlock()
+
+	// closing the file ends the Read in w.read
+	err := w.file.Close()
+	w.wg.Wait()
+	close(w.events)
+	close(w.errors)
+	return err
+}
+
+func (w *inotifyWatcher) read() {
+	defer w.wg.Done()
+
+	var buf = make([]byte, 64*1024)
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			if !errors.Is(err, ErrClosed) {
+				w.send(nil, err)
+			}
+			return
+		}
+
+		for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+			var (
+				wd   = int(int32(binary.NativeEndian.Uint32(buf[off:])))
+				mask = binary.NativeEndian.Uint32(buf[off+4:])
+				size = int(binary.NativeEndian.Uint32(buf[off+12:]))
+				name = string(buf[off+syscall.SizeofInotifyEvent : off+syscall.SizeofInotifyEvent+size])
+			)
+			off += syscall.SizeofInotifyEvent + size
+
+			if mask&syscall.IN_Q_OVERFLOW != 0 {
+				if !w.send(nil, errWatchOverflow) {
+					return
+				}
+				continue
+			}
+
+			ev, ok := w.event(wd, mask, strings.TrimRight(name, "\x00"))
+			if ok && !w.send(&ev, nil) {
+				return
+			}
+		}
+	}
+}
+
+// event makes the WatchEvent for an inotify event, if there is one to
+// report.
+func (w *inotifyWatcher) event(wd int, mask uint32, name string) (WatchEvent, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	base, ok := w.names[wd]
+	if !ok {
+		return WatchEvent{}, false
+	}
+	if mask&syscall.IN_IGNORED != 0 {
+		delete(w.names, wd)
+		delete(w.wds, base)
+		return WatchEvent{}, false
+	}
+
+	var ev = WatchEvent{Name: base}
+	if name != "" {
+		ev.Name = filepath.Join(base, name)
+	}
+	if mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+		ev.Op |= WatchCreate
+	}
+	if mask&syscall.IN_MODIFY != 0 {
+		ev.Op |= WatchWrite
+	}
+	if mask&(syscall.IN_DELETE|syscall.IN_DELETE_SELF) != 0 {
+		ev.Op |= WatchRemove
+	}
+	if mask&(syscall.IN_MOVED_FROM|syscall.IN_MOVE_SELF) != 0 {
+		ev.Op |= WatchRename
+	}
+	if mask&syscall.IN_ATTRIB != 0 {
+		ev.Op |= WatchChmod
+	}
+	return ev, ev.Op != 0
+}
+
+// send sends ev or err, and returns false if the watcher is closed
+// first.
+func (w *inotifyWatcher) send(ev *WatchEvent, err error) bool {
+	if ev != nil {
+		select {
+		case w.events <- *ev:
+			return true
+		case <-w.done:
+			return false
+		}
+	}
+	select {
+	case w.errors <- err:
+		return true
+	case <-w.done:
+		return false
+	}
+}
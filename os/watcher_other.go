@@ -0,0 +1,13 @@
+//go:build !linux
+
+package os
+
+import (
+	"time"
+)
+
+// NewWatcher creates a Watcher of the real file system.  On Linux it
+// uses inotify; elsewhere it polls every second.
+func NewWatcher() (Watcher, error) {
+	return NewPollWatcher(NewOS(), time.Second), nil
+}
@@ -0,0 +1,165 @@
+package os
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// nextEvent returns the next event from w, skipping those for other
+// names, which real file systems may add.
+func nextEvent(t *testing.T, w Watcher, name string) WatchEvent {
+	t.Helper()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case ev := <-w.Events():
+			if ev.Name == name {
+				return ev
+			}
+		case err := <-w.Errors():
+			t.Fatalf("watcher error = %v", err)
+		case <-timeout:
+			t.Fatalf("no event for %s", name)
+		}
+	}
+}
+
+func TestFakeWatcher(t *testing.T) {
+	w := NewFakeWatcher()
+	w.Add("/etc/app")
+	w.Add("/etc/hosts")
+
+	go func() {
+		w.Emit("/etc/app/config.yaml", WatchCreate)
+		w.Emit("/etc/other/file", WatchWrite)
+		w.Emit("/etc/hosts", WatchWrite|WatchChmod)
+		w.Fail(errors.New("boom"))
+	}()
+
+	if ev := <-w.Events(); ev != (WatchEvent{"/etc/app/config.yaml", WatchCreate}) {
+		t.Errorf("event = %v", ev)
+	}
+	if ev := <-w.Events(); ev.Name != "/etc/hosts" || !ev.Op.Has(WatchWrite) || ev.String() != "WRITE|CHMOD /etc/hosts" {
+		t.Errorf("event = %v", ev)
+	}
+	if err := <-w.Errors(); err == nil || err.Error() != "boom" {
+		t.Errorf("error = %v", err)
+	}
+
+	if got := w.Watched(); !slices.Equal(got, []string{"/etc/app", "/etc/hosts"}) {
+		t.Errorf("Watched() = %q", got)
+	}
+	if err := w.Remove("/etc/app"); err != nil {
+		t.Errorf("Remove() error = %v", err)
+	}
+	if err := w.Remove("/etc/app"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("second Remove() error = %v, want ErrNotExist", err)
+	}
+
+	w.Close()
+	if w.Emit("/etc/hosts", WatchRemove) {
+		t.Error("Emit() after Close() succeeded")
+	}
+	if _, ok := <-w.Events(); ok {
+		t.Error("Events() not closed")
+	}
+}
+
+func TestPollWatcher(t *testing.T) {
+	o := NewMemOS()
+	o.Mkdir("/tmp/conf", 0755)
+	o.WriteFile("/tmp/conf/a", []byte("1"), 0644)
+
+	w := NewPollWatcher(o, time.Millisecond)
+	defer w.Close()
+	if err := w.Add("/tmp/conf"); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if err := w.Add("/tmp/missing"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Add() of a missing file error = %v, want ErrNotExist", err)
+	}
+
+	o.WriteFile("/tmp/conf/b", nil, 0644)
+	if ev := nextEvent(t, w, "/tmp/conf/b"); ev.Op != WatchCreate {
+		t.Errorf("event = %v, want CREATE", ev)
+	}
+	o.WriteFile("/tmp/conf/a", []byte("22"), 0644)
+	if ev := nextEvent(t, w, "/tmp/conf/a"); ev.Op != WatchWrite {
+		t.Errorf("event = %v, want WRITE", ev)
+	}
+	o.Chmod("/tmp/conf/a", 0600)
+	if ev := nextEvent(t, w, "/tmp/conf/a"); ev.Op != WatchChmod {
+		t.Errorf("event = %v, want CHMOD", ev)
+	}
+	o.Remove("/tmp/conf/b")
+	if ev := nextEvent(t, w, "/tmp/conf/b"); ev.Op != WatchRemove {
+		t.Errorf("event = %v, want REMOVE", ev)
+	}
+}
+
+func TestPollWatcher_ChmodAndWrite(t *testing.T) {
+	o := NewMemOS()
+	o.Mkdir("/tmp/conf", 0755)
+	o.WriteFile("/tmp/conf/a", []byte("1"), 0644)
+
+	// poll by hand, so that both changes fall in one poll
+	w := NewPollWatcher(o, time.Hour)
+	defer w.Close()
+	if err := w.Add("/tmp/conf"); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	o.Chmod("/tmp/conf/a", 0600)
+	o.WriteFile("/tmp/conf/a", []byte("22"), 0644)
+	go w.(*pollWatcher).check("/tmp/conf")
+
+	if ev := nextEvent(t, w, "/tmp/conf/a"); ev.Op != WatchChmod|WatchWrite {
+		t.Errorf("event = %v, want CHMOD|WRITE", ev)
+	}
+}
+
+func TestNewPollWatcher_BadInterval(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("NewPollWatcher() with a zero interval did not panic")
+		}
+	}()
+	NewPollWatcher(NewMemOS(), 0)
+}
+
+func TestNewWatcher(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewWatcher()
+	if err != nil {
+		t.Fatalf("NewWatcher() error = %v", err)
+	}
+	defer w.Close()
+	if err := w.Add(dir); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	o := NewOS()
+	name := filepath.Join(dir, "file")
+	if err := o.WriteFile(name, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if ev := nextEvent(t, w, name); !ev.Op.Has(WatchCreate) {
+		t.Errorf("event = %v, want CREATE", ev)
+	}
+	if err := o.Remove(name); err != nil {
+		t.Fatal(err)
+	}
+	for ev := nextEvent(t, w, name); !ev.Op.Has(WatchRemove); ev = nextEvent(t, w, name) {
+	}
+
+	if err := w.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+	if err := w.Add(dir); err == nil {
+		t.Error("Add() after Close() succeeded")
+	}
+}
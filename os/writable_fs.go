@@ -0,0 +1,125 @@
+package os
+
+import (
+	"errors"
+	stdfs "io/fs"
+
+	"github.com/pdutton/go-interfaces/io/fs"
+)
+
+// writableOpener is the part of OS and Root that is needed to present
+// a directory tree as an fs.WritableFS.  Rename and WriteFile are used
+// if they are there too, which they are on a Root only from Go 1.25.
+type writableOpener interface {
+	fileOpener
+	Mkdir(string, FSFileMode) error
+	OpenFile(string, int, FSFileMode) (File, error)
+	Remove(string) error
+}
+
+// writableFS is a dirFS that can also write.
+type writableFS struct {
+	dirFS
+	w writableOpener
+}
+
+// NewRootFS presents the tree below r as an fs.WritableFS.  Any Root
+// will do: one from NewOS().OpenRoot, which is confined as os.Root is,
+// or one from an in-memory or other OS.
+func NewRootFS(r Root) fs.WritableFS {
+	return writableFS{dirFS: newDirFS(r, ""), w: r}
+}
+
+// NewWritableDirFS presents the tree below dir, through o, as an
+// fs.WritableFS.  Like DirFS, it does not stop symbolic links from
+// leading out of dir; NewRootFS does.
+func NewWritableDirFS(o OS, dir string) fs.WritableFS {
+	return writableFS{dirFS: newDirFS(o, dir), w: o}
+}
+
+// NewMemFS creates an empty fs.WritableFS that keeps its files in
+// memory, with the rules of NewMemOS.
+func NewMemFS() fs.WritableFS {
+	m := newMemOS()
+	m.cwd = "/"
+	m.root.uid, m.root.gid = m.uid, m.gid
+	return NewWritableDirFS(m, "/")
+}
+
+func (w writableFS) Mkdir(name string, perm FSFileMode) error {
+	full, err := w.join("mkdir", name)
+	if err != nil {
+		return err
+	}
+
+	return w.rename(w.w.Mkdir(full, perm), name)
+}
+
+func (w writableFS) OpenFile(name string, flag int, perm FSFileMode) (stdfs.File, error) {
+	full, err := w.join("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := w.w.OpenFile(full, flag, perm)
+	if err != nil {
+		return nil, w.rename(err, name)
+	}
+	return fsFile{File: f}, nil
+}
+
+func (w writableFS) Remove(name string) error {
+	full, err := w.join("remove", name)
+	if err != nil {
+		return err
+	}
+
+	return w.rename(w.w.Remove(full), name)
+}
+
+// Rename returns an error wrapping errors.ErrUnsupported if there is no
+// Rename behind w, so that fs.Rename falls back to copying.
+func (w writableFS) Rename(oldname, newname string) error {
+	oldfull, err := w.join("rename", oldname)
+	if err != nil {
+		return err
+	}
+	newfull, err := w.join("rename", newname)
+	if err != nil {
+		return err
+	}
+
+	r, ok := w.w.(interface{ Rename(string, string) error })
+	if !ok {
+		return &LinkError{Op: "rename", Old: oldname, New: newname, Err: errors.ErrUnsupported}
+	}
+
+	err = r.Rename(oldfull, newfull)
+	if le, ok := err.(*LinkError); ok {
+		return &LinkError{Op: le.Op, Old: oldname, New: newname, Err: le.Err}
+	}
+	return w.rename(err, oldname)
+}
+
+func (w writableFS) WriteFile(name string, data []byte, perm FSFileMode) error {
+	full, err := w.join("open", name)
+	if err != nil {
+		return err
+	}
+
+	if wf, ok := w.w.(interface {
+		WriteFile(string, []byte, FSFileMode) error
+	}); ok {
+		return w.rename(wf.WriteFile(full, data, perm), name)
+	}
+
+	f, err := w.w.OpenFile(full, O_WRONLY|O_CREATE|O_TRUNC, perm)
+	if err != nil {
+		return w.rename(err, name)
+	}
+	_, err = f.Write(data)
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	return w.rename(err, name)
+}
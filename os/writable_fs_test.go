@@ -0,0 +1,79 @@
+package os
+
+import (
+	"errors"
+	"io"
+	stdfs "io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/pdutton/go-interfaces/io/fs"
+)
+
+func testWritableFS(t *testing.T, fsys fs.WritableFS) {
+	if err := fs.MkdirAll(fsys, "a/b", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.WriteFile("a/b/c.txt", []byte("c"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := fsys.OpenFile("a/d.txt", O_WRONLY|O_CREATE|O_EXCL, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.(io.Writer).Write([]byte("d")); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if _, err := fsys.OpenFile("a/d.txt", O_WRONLY|O_CREATE|O_EXCL, 0600); !errors.Is(err, stdfs.ErrExist) {
+		t.Errorf("OpenFile(O_EXCL) of existing file error = %v, want ErrExist", err)
+	}
+
+	if err := fsys.Rename("a/d.txt", "a/e.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(fsys, "a/b/c.txt", "a/e.txt"); err != nil {
+		t.Error(err)
+	}
+
+	if err := fsys.Remove("a/b"); err == nil {
+		t.Error("Remove() of non-empty directory error = nil")
+	}
+	if err := fs.RemoveAll(fsys, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stdfs.Stat(fsys, "a"); !errors.Is(err, stdfs.ErrNotExist) {
+		t.Errorf("Stat() after RemoveAll error = %v, want ErrNotExist", err)
+	}
+
+	var pe *PathError
+	if err := fsys.Mkdir("../x", 0755); !errors.As(err, &pe) || pe.Path != "../x" {
+		t.Errorf("Mkdir(../x) error = %v, want invalid path", err)
+	}
+}
+
+func TestNewRootFS(t *testing.T) {
+	r, err := NewOS().OpenRoot(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	testWritableFS(t, NewRootFS(r))
+
+	m := NewMemOS()
+	mr, err := m.OpenRoot("/tmp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testWritableFS(t, NewRootFS(mr))
+}
+
+func TestNewWritableDirFS(t *testing.T) {
+	testWritableFS(t, NewWritableDirFS(NewOS(), t.TempDir()))
+	testWritableFS(t, NewWritableDirFS(NewMemOS(), "/tmp"))
+}
+
+func TestNewMemFS(t *testing.T) {
+	testWritableFS(t, NewMemFS())
+}
@@ -0,0 +1,322 @@
+package filepath
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/pdutton/go-interfaces/os"
+)
+
+// osFilePath is a FilePath whose methods that touch the file system go
+// through an os.OS rather than the real one.
+type osFilePath struct {
+	filePathFacade
+	os os.OS
+}
+
+// NewFilePathFor returns a FilePath whose Abs, EvalSymlinks, Glob, Walk
+// and WalkDir go through o, so that they see its files, its symbolic
+// links and its working directory, as those of an os.NewMemOS().  The
+// other methods only work on strings, and are those of NewFilePath.
+func NewFilePathFor(o os.OS) FilePath {
+	return osFilePath{os: o}
+}
+
+func isSeparator(c byte) bool {
+	return c == '/' || c == filepath.Separator
+}
+
+// Abs makes p absolute by joining it to the working directory of the OS.
+func (f osFilePath) Abs(p string) (string, error) {
+	if filepath.IsAbs(p) {
+		return filepath.Clean(p), nil
+	}
+
+	wd, err := f.os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(wd, p), nil
+}
+
+// EvalSymlinks resolves p one name at a time, as filepath.EvalSymlinks
+// does, with Lstat and Readlink.  A relative p gives a relative result.
+func (f osFilePath) EvalSymlinks(p string) (string, error) {
+	volLen := len(filepath.VolumeName(p))
+	if volLen < len(p) && isSeparator(p[volLen]) {
+		volLen++
+	}
+	vol := p[:volLen]
+	dest := vol
+	links := 0
+
+	// lastSeparator returns the index of the last separator in dest
+	// after the volume, or -1.
+	lastSeparator := func() int {
+		for r := len(dest) - 1; r >= volLen; r-- {
+			if isSeparator(dest[r]) {
+				return r
+			}
+		}
+		return -1
+	}
+
+	for start, end := volLen, volLen; start < len(p); start = end {
+		for start < len(p) && isSeparator(p[start]) {
+			start++
+		}
+		end = start
+		for end < len(p) && !isSeparator(p[end]) {
+			end++
+		}
+
+		switch elem := p[start:end]; elem {
+		case "":
+			// no more names
+			return filepath.Clean(dest), nil
+		case ".":
+			continue
+		case "..":
+			// Back up over the last name, unless there is none or it
+			// is a ".." that had to be kept.
+			if r := lastSeparator(); r >= 0 && dest[r+1:] != ".." {
+				dest = dest[:r]
+				continue
+			}
+			if len(dest) > volLen {
+				dest += string(filepath.Separator)
+			}
+			dest += ".."
+			continue
+		}
+
+		if len(dest) > len(filepath.VolumeName(dest)) && !isSeparator(dest[len(dest)-1]) {
+			dest += string(filepath.Separator)
+		}
+		dest += p[start:end]
+
+		fi, err := f.os.Lstat(dest)
+		if err != nil {
+			return "", err
+		}
+		if fi.Nub().Mode()&fs.ModeSymlink == 0 {
+			if !fi.IsDir() && end < len(p) {
+				return "", syscall.ENOTDIR
+			}
+			continue
+		}
+
+		links++
+		if links > 255 {
+			return "", errors.New("EvalSymlinks: too many links")
+		}
+		link, err := f.os.Readlink(dest)
+		if err != nil {
+			return "", err
+		}
+		p = link + p[end:]
+
+		if v := len(filepath.VolumeName(link)); v > 0 || (link != "" && isSeparator(link[0])) {
+			// A link to an absolute name starts again from there.
+			if v < len(link) && isSeparator(link[v]) {
+				v++
+			}
+			vol, volLen = link[:v], v
+			dest = vol
+			end = v
+		} else {
+			// A link to a relative name replaces the last name.
+			if r := lastSeparator(); r < 0 {
+				dest = vol
+			} else {
+				dest = dest[:r]
+			}
+			end = 0
+		}
+	}
+	return filepath.Clean(dest), nil
+}
+
+// Glob returns the names that match pattern, as filepath.Glob does,
+// reading directories with ReadDir.  As there, I/O errors are ignored.
+func (f osFilePath) Glob(pattern string) ([]string, error) {
+	return f.glob(pattern, 0)
+}
+
+// maxGlobDepth limits how many directories of a pattern Glob will
+// descend, as filepath.Glob does.
+const maxGlobDepth = 10000
+
+func (f osFilePath) glob(pattern string, depth int) ([]string, error) {
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	if depth == maxGlobDepth {
+		return nil, ErrBadPattern
+	}
+	if !hasMeta(pattern) {
+		if _, err := f.os.Lstat(pattern); err != nil {
+			return nil, nil
+		}
+		return []string{pattern}, nil
+	}
+
+	dir, file := filepath.Split(pattern)
+	volLen, dir := cleanGlobPath(dir)
+	if !hasMeta(dir[volLen:]) {
+		return f.globDir(dir, file, nil)
+	}
+	if dir == pattern {
+		return nil, ErrBadPattern
+	}
+
+	dirs, err := f.glob(dir, depth+1)
+	if err != nil {
+		return nil, err
+	}
+	var matches []string
+	for _, d := range dirs {
+		if matches, err = f.globDir(d, file, matches); err != nil {
+			return matches, err
+		}
+	}
+	return matches, nil
+}
+
+// globDir appends the names in dir that match pattern to matches.
+func (f osFilePath) globDir(dir, pattern string, matches []string) ([]string, error) {
+	fi, err := f.os.Stat(dir)
+	if err != nil || !fi.IsDir() {
+		return matches, nil
+	}
+	entries, _ := f.os.ReadDir(dir)
+
+	for _, e := range entries {
+		matched, err := filepath.Match(pattern, e.Name())
+		if err != nil {
+			return matches, err
+		}
+		if matched {
+			matches = append(matches, filepath.Join(dir, e.Name()))
+		}
+	}
+	return matches, nil
+}
+
+// cleanGlobPath prepares the directory of a pattern for globbing,
+// returning it without its trailing separator and the length of its
+// volume name.
+func cleanGlobPath(dir string) (int, string) {
+	volLen := len(filepath.VolumeName(dir))
+	switch {
+	case dir == "":
+		return 0, "."
+	case volLen+1 == len(dir) && isSeparator(dir[volLen]):
+		// the root, as /, \ or C:\
+		return volLen + 1, dir
+	case volLen == len(dir):
+		// C: alone
+		return volLen, dir + "."
+	default:
+		return volLen, dir[:len(dir)-1]
+	}
+}
+
+func hasMeta(p string) bool {
+	magic := `*?[`
+	if filepath.Separator != '\\' {
+		magic = `*?[\`
+	}
+	return strings.ContainsAny(p, magic)
+}
+
+// Walk walks the tree at root as filepath.Walk does, with Lstat and
+// ReadDir.
+func (f osFilePath) Walk(root string, fn WalkFunc) error {
+	info, err := f.os.Lstat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = f.walk(root, info.Nub(), fn)
+	}
+	if err == SkipDir || err == SkipAll {
+		return nil
+	}
+	return err
+}
+
+func (f osFilePath) walk(p string, info FileInfo, fn WalkFunc) error {
+	if !info.IsDir() {
+		return fn(p, info, nil)
+	}
+
+	entries, err := f.os.ReadDir(p)
+	if err1 := fn(p, info, err); err != nil || err1 != nil {
+		return err1
+	}
+
+	for _, e := range entries {
+		name := filepath.Join(p, e.Name())
+		fi, err := f.os.Lstat(name)
+		if err != nil {
+			if err := fn(name, nil, err); err != nil && err != SkipDir {
+				return err
+			}
+			continue
+		}
+		if err := f.walk(name, fi.Nub(), fn); err != nil {
+			if !fi.IsDir() || err != SkipDir {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// WalkDir walks the tree at root as filepath.WalkDir does, with Lstat
+// and ReadDir.
+func (f osFilePath) WalkDir(root string, fn WalkDirFunc) error {
+	info, err := f.os.Lstat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = f.walkDir(root, fs.FileInfoToDirEntry(info.Nub()), fn)
+	}
+	if err == SkipDir || err == SkipAll {
+		return nil
+	}
+	return err
+}
+
+func (f osFilePath) walkDir(p string, d DirEntry, fn WalkDirFunc) error {
+	if err := fn(p, d, nil); err != nil || !d.IsDir() {
+		if err == SkipDir && d.IsDir() {
+			err = nil
+		}
+		return err
+	}
+
+	entries, err := f.os.ReadDir(p)
+	if err != nil {
+		// a second call, to report the error
+		if err = fn(p, d, err); err != nil {
+			if err == SkipDir {
+				err = nil
+			}
+			return err
+		}
+	}
+
+	for _, e := range entries {
+		if err := f.walkDir(filepath.Join(p, e.Name()), e.Nub(), fn); err != nil {
+			if err == SkipDir {
+				break
+			}
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+package filepath
+
+import (
+	"errors"
+	"io/fs"
+	"slices"
+	"testing"
+
+	"github.com/pdutton/go-interfaces/os"
+)
+
+func TestFilePathFor_Abs(t *testing.T) {
+	o, err := os.NewMapOS(map[string]string{
+		"/src/a.go":            "package a\n",
+		"/src/b.go":            "package b\n",
+		"/src/lib/c.go":        "package c\n",
+		"/src/lib/d.txt":       "d\n",
+		"/src/skip/e.go":       "package e\n",
+		"/src/link -> lib":     "",
+		"/src/abs -> /src/lib": "",
+		"/src/loop -> loop":    "",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Chdir("/src"); err != nil {
+		t.Fatal(err)
+	}
+	fp := NewFilePathFor(o)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a.go", "/src/a.go"},
+		{"lib/../b.go", "/src/b.go"},
+		{"/etc//passwd", "/etc/passwd"},
+		{".", "/src"},
+	}
+
+	for _, tt := range tests {
+		got, err := fp.Abs(tt.input)
+		if err != nil {
+			t.Fatalf("Abs(%q): %v", tt.input, err)
+		}
+		if got != tt.expected {
+			t.Errorf("Abs(%q) = %q, want %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestFilePathFor_EvalSymlinks(t *testing.T) {
+	o, err := os.NewMapOS(map[string]string{
+		"/src/a.go":            "package a\n",
+		"/src/b.go":            "package b\n",
+		"/src/lib/c.go":        "package c\n",
+		"/src/lib/d.txt":       "d\n",
+		"/src/skip/e.go":       "package e\n",
+		"/src/link -> lib":     "",
+		"/src/abs -> /src/lib": "",
+		"/src/loop -> loop":    "",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Chdir("/src"); err != nil {
+		t.Fatal(err)
+	}
+	fp := NewFilePathFor(o)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"/src/link/c.go", "/src/lib/c.go"},
+		{"/src/abs/d.txt", "/src/lib/d.txt"},
+		{"link/c.go", "lib/c.go"},
+		{"abs", "/src/lib"},
+		{"lib/../link", "lib"},
+		{"/src/a.go", "/src/a.go"},
+	}
+
+	for _, tt := range tests {
+		got, err := fp.EvalSymlinks(tt.input)
+		if err != nil {
+			t.Fatalf("EvalSymlinks(%q): %v", tt.input, err)
+		}
+		if got != tt.expected {
+			t.Errorf("EvalSymlinks(%q) = %q, want %q", tt.input, got, tt.expected)
+		}
+	}
+
+	if _, err := fp.EvalSymlinks("/src/missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("EvalSymlinks of a missing file: %v, want ErrNotExist", err)
+	}
+	if _, err := fp.EvalSymlinks("/src/a.go/x"); err == nil {
+		t.Error("EvalSymlinks through a file succeeded")
+	}
+	if _, err := fp.EvalSymlinks("/src/loop"); err == nil {
+		t.Error("EvalSymlinks of a loop succeeded")
+	}
+}
+
+func TestFilePathFor_Glob(t *testing.T) {
+	o, err := os.NewMapOS(map[string]string{
+		"/src/a.go":            "package a\n",
+		"/src/b.go":            "package b\n",
+		"/src/lib/c.go":        "package c\n",
+		"/src/lib/d.txt":       "d\n",
+		"/src/skip/e.go":       "package e\n",
+		"/src/link -> lib":     "",
+		"/src/abs -> /src/lib": "",
+		"/src/loop -> loop":    "",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Chdir("/src"); err != nil {
+		t.Fatal(err)
+	}
+	fp := NewFilePathFor(o)
+
+	tests := []struct {
+		pattern  string
+		expected []string
+	}{
+		{"/src/*.go", []string{"/src/a.go", "/src/b.go"}},
+		{"*.go", []string{"a.go", "b.go"}},
+		{"/src/*/*.go", []string{"/src/abs/c.go", "/src/lib/c.go", "/src/link/c.go", "/src/skip/e.go"}},
+		{"lib/d.txt", []string{"lib/d.txt"}},
+		{"/nowhere/*", nil},
+	}
+
+	for _, tt := range tests {
+		got, err := fp.Glob(tt.pattern)
+		if err != nil {
+			t.Fatalf("Glob(%q): %v", tt.pattern, err)
+		}
+		if !slices.Equal(got, tt.expected) {
+			t.Errorf("Glob(%q) = %q, want %q", tt.pattern, got, tt.expected)
+		}
+	}
+
+	if _, err := fp.Glob("[x"); err != ErrBadPattern {
+		t.Errorf("Glob of a bad pattern: %v, want ErrBadPattern", err)
+	}
+}
+
+func TestFilePathFor_Walk(t *testing.T) {
+	o, err := os.NewMapOS(map[string]string{
+		"/src/a.go":            "package a\n",
+		"/src/b.go":            "package b\n",
+		"/src/lib/c.go":        "package c\n",
+		"/src/lib/d.txt":       "d\n",
+		"/src/skip/e.go":       "package e\n",
+		"/src/link -> lib":     "",
+		"/src/abs -> /src/lib": "",
+		"/src/loop -> loop":    "",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Chdir("/src"); err != nil {
+		t.Fatal(err)
+	}
+	fp := NewFilePathFor(o)
+
+	var got []string
+	err = fp.Walk(".", func(p string, info FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && info.Name() == "skip" {
+			return SkipDir
+		}
+		got = append(got, p)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{".", "a.go", "abs", "b.go", "lib", "lib/c.go", "lib/d.txt", "link", "loop"}
+	if !slices.Equal(got, expected) {
+		t.Errorf("Walk visited %q, want %q", got, expected)
+	}
+}
+
+func TestFilePathFor_WalkDir(t *testing.T) {
+	o, err := os.NewMapOS(map[string]string{
+		"/src/a.go":            "package a\n",
+		"/src/b.go":            "package b\n",
+		"/src/lib/c.go":        "package c\n",
+		"/src/lib/d.txt":       "d\n",
+		"/src/skip/e.go":       "package e\n",
+		"/src/link -> lib":     "",
+		"/src/abs -> /src/lib": "",
+		"/src/loop -> loop":    "",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Chdir("/src"); err != nil {
+		t.Fatal(err)
+	}
+	fp := NewFilePathFor(o)
+
+	var got []string
+	err = fp.WalkDir("/src", func(p string, d DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type()&fs.ModeSymlink != 0 {
+			return nil
+		}
+		got = append(got, p)
+		if p == "/src/lib/c.go" {
+			return SkipAll
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"/src", "/src/a.go", "/src/b.go", "/src/lib", "/src/lib/c.go"}
+	if !slices.Equal(got, expected) {
+		t.Errorf("WalkDir visited %q, want %q", got, expected)
+	}
+
+	var missing error
+	err = fp.WalkDir("/nowhere", func(p string, d DirEntry, err error) error {
+		missing = err
+		return nil
+	})
+	if err != nil || !errors.Is(missing, fs.ErrNotExist) {
+		t.Errorf("WalkDir of a missing root: %v, %v", err, missing)
+	}
+}
@@ -0,0 +1,541 @@
+package filepath
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	stdos "os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrSymlinkLoop is given to the WalkDirFunc of a parallel walk, in a
+// *fs.PathError, for a symbolic link that leads back to a directory
+// above it.
+var ErrSymlinkLoop = errors.New("symbolic link loop")
+
+// maxLinks is how many symbolic links to directories a parallel walk
+// follows, one inside another, before it takes them for a loop that it
+// could not otherwise see.
+const maxLinks = 255
+
+// ParallelWalkOption configures ParallelWalkDir and ParallelWalkFS.
+type ParallelWalkOption func(*parallelWalk)
+
+type parallelWalk struct {
+	workers int
+	ordered bool
+	follow  bool
+}
+
+// WithWorkers sets how many directories are read at once.  The default
+// is runtime.GOMAXPROCS(0).
+func WithWorkers(n int) ParallelWalkOption {
+	return func(p *parallelWalk) {
+		p.workers = n
+	}
+}
+
+// WithOrdered makes the walk call its WalkDirFunc from one goroutine
+// at a time, in the lexical order of WalkDir, while directories are
+// still read ahead in parallel.
+func WithOrdered(ordered bool) ParallelWalkOption {
+	return func(p *parallelWalk) {
+		p.ordered = ordered
+	}
+}
+
+// WithFollowSymlinks makes the walk follow symbolic links, the root
+// included, and go into the directories they lead to.  Such an entry
+// is given to the WalkDirFunc as what the link leads to, under the
+// name of the link.  A link that leads back to a directory above it is
+// given with an error wrapping ErrSymlinkLoop instead, and not entered.
+func WithFollowSymlinks(follow bool) ParallelWalkOption {
+	return func(p *parallelWalk) {
+		p.follow = follow
+	}
+}
+
+// ParallelWalkDir walks the tree at root as WalkDir does, but reads
+// directories with a pool of goroutines.  Unless WithOrdered is given,
+// fn is called from several goroutines at once, and in no set order
+// beyond this: a directory comes before what is in it, and the entries
+// of a directory come in lexical order, so a SkipDir from a file skips
+// the rest of its directory as it does for WalkDir.  SkipAll stops the
+// walk, as does an error from fn, which ParallelWalkDir returns once
+// the calls already under way have returned.  If ctx is cancelled, the
+// walk stops and returns ctx.Err().
+func ParallelWalkDir(ctx context.Context, root string, fn WalkDirFunc, options ...ParallelWalkOption) error {
+	return parallelWalkDir(ctx, osSource{}, root, fn, options)
+}
+
+// ParallelWalkFS walks the tree at root in fsys, as fs.WalkDir does,
+// in the way of ParallelWalkDir.  Symbolic links can be followed in an
+// fsys that has the Lstat and ReadLink of fs.ReadLinkFS, or whose
+// FileInfo values are those of the os package, as those of os.DirFS.
+func ParallelWalkFS(ctx context.Context, fsys fs.FS, root string, fn WalkDirFunc, options ...ParallelWalkOption) error {
+	return parallelWalkDir(ctx, fsSource{fsys: fsys}, root, fn, options)
+}
+
+// walkSource is the file system a parallel walk reads.
+type walkSource interface {
+	readDir(string) ([]DirEntry, error)
+	lstat(string) (FileInfo, error)
+	stat(string) (FileInfo, error)
+	join(string, string) string
+
+	// realPath returns name with its symbolic links resolved, or ""
+	// if that cannot be done.
+	realPath(string) string
+}
+
+// osSource is the real file system.  Directories are told apart with
+// os.SameFile, so it has no need of realPath.
+type osSource struct{}
+
+func (osSource) readDir(name string) ([]DirEntry, error) {
+	return stdos.ReadDir(name)
+}
+
+func (osSource) lstat(name string) (FileInfo, error) {
+	return stdos.Lstat(name)
+}
+
+func (osSource) stat(name string) (FileInfo, error) {
+	return stdos.Stat(name)
+}
+
+func (osSource) join(dir, name string) string {
+	return filepath.Join(dir, name)
+}
+
+func (osSource) realPath(string) string {
+	return ""
+}
+
+// readLinkFS is fs.ReadLinkFS, which is only there from Go 1.25.
+type readLinkFS interface {
+	fs.FS
+	ReadLink(string) (string, error)
+	Lstat(string) (FileInfo, error)
+}
+
+type fsSource struct {
+	fsys fs.FS
+}
+
+func (s fsSource) readDir(name string) ([]DirEntry, error) {
+	return fs.ReadDir(s.fsys, name)
+}
+
+func (s fsSource) lstat(name string) (FileInfo, error) {
+	if rl, ok := s.fsys.(readLinkFS); ok {
+		return rl.Lstat(name)
+	}
+	return fs.Stat(s.fsys, name)
+}
+
+func (s fsSource) stat(name string) (FileInfo, error) {
+	return fs.Stat(s.fsys, name)
+}
+
+func (fsSource) join(dir, name string) string {
+	return path.Join(dir, name)
+}
+
+func (s fsSource) realPath(name string) string {
+	rl, ok := s.fsys.(readLinkFS)
+	if !ok {
+		return ""
+	}
+
+	resolved, rest, links := ".", name, 0
+	for rest != "" {
+		var elem string
+		elem, rest, _ = strings.Cut(rest, "/")
+		switch elem {
+		case "", ".":
+			continue
+		case "..":
+			if resolved == "." {
+				return ""
+			}
+			resolved = path.Dir(resolved)
+			continue
+		}
+
+		next := path.Join(resolved, elem)
+		fi, err := rl.Lstat(next)
+		if err != nil {
+			return ""
+		}
+		if fi.Mode()&fs.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+
+		if links++; links > maxLinks {
+			return ""
+		}
+		target, err := rl.ReadLink(next)
+		if err != nil || path.IsAbs(target) {
+			return ""
+		}
+		rest = target + "/" + rest
+	}
+	return resolved
+}
+
+// dirNode is a directory on the way down from the root, kept when
+// symbolic links are followed so that loops can be seen.
+type dirNode struct {
+	parent *dirNode
+	info   FileInfo
+	real   string
+	links  int
+}
+
+// loops reports whether n is a directory above it.
+func (n *dirNode) loops() bool {
+	if n.links > maxLinks {
+		return true
+	}
+	for a := n.parent; a != nil; a = a.parent {
+		if n.real != "" && a.real != "" {
+			if n.real == a.real {
+				return true
+			}
+			continue
+		}
+		if n.info != nil && a.info != nil && stdos.SameFile(n.info, a.info) {
+			return true
+		}
+	}
+	return false
+}
+
+// linkEntry is what a symbolic link leads to, under the name of the
+// link.
+type linkEntry struct {
+	DirEntry
+	name string
+}
+
+func (e linkEntry) Name() string {
+	return e.name
+}
+
+// walkEntry is an entry met on a parallel walk.
+type walkEntry struct {
+	path string
+	d    DirEntry
+	node *dirNode
+	loop bool
+}
+
+// listing is a directory read, or to be read, ahead of an ordered walk.
+type listing struct {
+	dir     *walkEntry
+	claimed atomic.Bool
+	done    chan struct{}
+	entries []*walkEntry
+	err     error
+}
+
+// workStack holds work for the pool, which takes the newest first, so
+// that it works deep in the tree, where an ordered walk goes next.
+type workStack struct {
+	mu     sync.Mutex
+	cond   sync.Cond
+	work   []func()
+	closed bool
+}
+
+func (s *workStack) push(f func()) {
+	s.mu.Lock()
+	s.work = append(s.work, f)
+	s.mu.Unlock()
+	s.cond.Signal()
+}
+
+// pop waits for work, and returns false once the stack is closed.
+func (s *workStack) pop() (func(), bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for len(s.work) == 0 && !s.closed {
+		s.cond.Wait()
+	}
+	if s.closed {
+		return nil, false
+	}
+	f := s.work[len(s.work)-1]
+	s.work = s.work[:len(s.work)-1]
+	return f, true
+}
+
+func (s *workStack) close() {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	s.cond.Broadcast()
+}
+
+type walker struct {
+	parallelWalk
+	ctx   context.Context
+	src   walkSource
+	fn    WalkDirFunc
+	queue workStack
+	tasks sync.WaitGroup
+
+	stopped atomic.Bool
+	mu      sync.Mutex
+	err     error
+}
+
+func parallelWalkDir(ctx context.Context, src walkSource, root string, fn WalkDirFunc, options []ParallelWalkOption) error {
+	w := &walker{ctx: ctx, src: src, fn: fn}
+	w.queue.cond.L = &w.queue.mu
+	for _, option := range options {
+		option(&w.parallelWalk)
+	}
+	if w.workers < 1 {
+		w.workers = runtime.GOMAXPROCS(0)
+	}
+
+	lstat := src.lstat
+	if w.follow {
+		lstat = src.stat
+	}
+	fi, err := lstat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else if err = ctx.Err(); err == nil {
+		err = fn(root, fs.FileInfoToDirEntry(fi), nil)
+	}
+	if err != nil || fi == nil || !fi.IsDir() {
+		if err == SkipDir || err == SkipAll {
+			return nil
+		}
+		return err
+	}
+
+	top := &walkEntry{path: root, d: fs.FileInfoToDirEntry(fi)}
+	if w.follow {
+		top.node = &dirNode{info: fi, real: src.realPath(root)}
+	}
+
+	var workers sync.WaitGroup
+	for range w.workers {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for {
+				f, ok := w.queue.pop()
+				if !ok {
+					return
+				}
+				f()
+			}
+		}()
+	}
+
+	if w.ordered {
+		w.walkOrdered(top, &listing{dir: top, done: make(chan struct{})})
+	} else {
+		w.push(top)
+		w.tasks.Wait()
+	}
+	w.queue.close()
+	workers.Wait()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// halt stops the walk, which returns err unless it was stopped before.
+func (w *walker) halt(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.stopped.Load() {
+		w.err = err
+		w.stopped.Store(true)
+	}
+}
+
+// call calls fn unless the walk has stopped, and stops it if fn returns
+// SkipAll or an error.  It reports whether fn returned SkipDir, and
+// whether the walk goes on.
+func (w *walker) call(p string, d DirEntry, err error) (skip, ok bool) {
+	if w.stopped.Load() {
+		return false, false
+	}
+	if err := w.ctx.Err(); err != nil {
+		w.halt(err)
+		return false, false
+	}
+
+	switch err := w.fn(p, d, err); err {
+	case nil:
+		return false, true
+	case SkipDir:
+		return true, true
+	case SkipAll:
+		w.halt(nil)
+		return false, false
+	default:
+		w.halt(err)
+		return false, false
+	}
+}
+
+// list reads the directory dir, and when links are followed, finds what
+// they lead to and whether they loop.
+func (w *walker) list(dir *walkEntry) ([]*walkEntry, error) {
+	des, err := w.src.readDir(dir.path)
+
+	var entries = make([]*walkEntry, 0, len(des))
+	for _, d := range des {
+		e := &walkEntry{path: w.src.join(dir.path, d.Name()), d: d}
+		if w.follow {
+			w.resolve(e, dir.node)
+		}
+		entries = append(entries, e)
+	}
+	return entries, err
+}
+
+func (w *walker) resolve(e *walkEntry, parent *dirNode) {
+	if e.d.Type()&fs.ModeSymlink == 0 {
+		if e.d.IsDir() {
+			info, _ := e.d.Info()
+			var real string
+			if parent.real != "" {
+				real = w.src.join(parent.real, e.d.Name())
+			}
+			e.node = &dirNode{parent: parent, info: info, real: real, links: parent.links}
+		}
+		return
+	}
+
+	fi, err := w.src.stat(e.path)
+	if err != nil {
+		// a dangling link, which is given as it is
+		return
+	}
+	e.d = linkEntry{DirEntry: fs.FileInfoToDirEntry(fi), name: e.d.Name()}
+	if fi.IsDir() {
+		e.node = &dirNode{parent: parent, info: fi, real: w.src.realPath(e.path), links: parent.links + 1}
+		e.loop = e.node.loops()
+	}
+}
+
+// visit calls fn for each of entries in turn, and descend for each
+// directory that fn does not skip, until the walk stops or fn skips the
+// rest of the directory.
+func (w *walker) visit(entries []*walkEntry, descend func(int, *walkEntry)) {
+	for i, e := range entries {
+		switch {
+		case e.loop:
+			err := &fs.PathError{Op: "walk", Path: e.path, Err: ErrSymlinkLoop}
+			if _, ok := w.call(e.path, e.d, err); !ok {
+				return
+			}
+		case e.d.IsDir():
+			skip, ok := w.call(e.path, e.d, nil)
+			if !ok {
+				return
+			}
+			if !skip {
+				descend(i, e)
+			}
+		default:
+			if skip, ok := w.call(e.path, e.d, nil); skip || !ok {
+				return
+			}
+		}
+	}
+}
+
+// push queues the directory dir, whose fn has been called, to be walked
+// by the pool.
+func (w *walker) push(dir *walkEntry) {
+	w.tasks.Add(1)
+	w.queue.push(func() {
+		defer w.tasks.Done()
+		w.walkDir(dir)
+	})
+}
+
+func (w *walker) walkDir(dir *walkEntry) {
+	if w.stopped.Load() {
+		return
+	}
+
+	entries, err := w.list(dir)
+	if err != nil {
+		// a second call, to report the error
+		if skip, ok := w.call(dir.path, dir.d, err); skip || !ok {
+			return
+		}
+	}
+	w.visit(entries, func(_ int, e *walkEntry) {
+		w.push(e)
+	})
+}
+
+// read reads the directory of l, unless it has been claimed already.
+func (w *walker) read(l *listing) {
+	if l.claimed.Swap(true) {
+		return
+	}
+	l.entries, l.err = w.list(l.dir)
+	close(l.done)
+}
+
+// walkOrdered walks the directory of l, whose fn has been called, in
+// lexical order.  It reads the directory itself if the pool has not yet
+// begun to, and has the pool read the directories in it meanwhile.
+func (w *walker) walkOrdered(dir *walkEntry, l *listing) {
+	w.read(l)
+	select {
+	case <-l.done:
+	case <-w.ctx.Done():
+		w.halt(w.ctx.Err())
+		return
+	}
+
+	if l.err != nil {
+		if skip, ok := w.call(dir.path, dir.d, l.err); skip || !ok {
+			return
+		}
+	}
+
+	// Queue the last first, so that the pool reads the first first.
+	var ahead = make([]*listing, len(l.entries))
+	for i := len(l.entries) - 1; i >= 0; i-- {
+		if e := l.entries[i]; e.d.IsDir() && !e.loop {
+			ahead[i] = &listing{dir: e, done: make(chan struct{})}
+			w.queue.push(func() { w.read(ahead[i]) })
+		}
+	}
+	defer func() {
+		// keep the pool from reading what will not be walked
+		for _, l := range ahead {
+			if l != nil {
+				l.claimed.Store(true)
+			}
+		}
+	}()
+
+	w.visit(l.entries, func(i int, e *walkEntry) {
+		w.walkOrdered(e, ahead[i])
+	})
+}
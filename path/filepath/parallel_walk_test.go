@@ -0,0 +1,325 @@
+package filepath
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/pdutton/go-interfaces/os"
+)
+
+// collector gathers the paths a walk visits, from any goroutine.
+type collector struct {
+	mu    sync.Mutex
+	paths []string
+}
+
+func (c *collector) add(p string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.paths = append(c.paths, p)
+}
+
+func (c *collector) sorted() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Sorted(slices.Values(c.paths))
+}
+
+func TestParallelWalkFS_Ordered(t *testing.T) {
+	files := map[string]string{
+		"lib/":                    "",
+		"lib/up -> ..":            "",
+		"lib/self -> .":           "",
+		"lib/f.go":                "package f\n",
+		"src/link -> ../lib":      "",
+		"src/dangling -> nowhere": "",
+	}
+	for _, dir := range []string{"a", "b", "c", "d"} {
+		for _, sub := range []string{"x", "y", "z"} {
+			files[dir+"/"+sub+"/1.txt"] = "1\n"
+			files[dir+"/"+sub+"/2.txt"] = "2\n"
+		}
+		files[dir+"/top.txt"] = "top\n"
+	}
+	fsys, err := os.NewMapFS(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var expected []string
+	err = fs.WalkDir(fsys, ".", func(p string, d DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == "b" || p == "c/x/1.txt" {
+			return SkipDir
+		}
+		expected = append(expected, p)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, workers := range []int{1, 8} {
+		var got []string
+		err := ParallelWalkFS(context.Background(), fsys, ".", func(p string, d DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if p == "b" || p == "c/x/1.txt" {
+				return SkipDir
+			}
+			got = append(got, p)
+			return nil
+		}, WithWorkers(workers), WithOrdered(true))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(got, expected) {
+			t.Errorf("with %d workers, visited %q, want %q", workers, got, expected)
+		}
+	}
+}
+
+func TestParallelWalkFS_Unordered(t *testing.T) {
+	files := map[string]string{
+		"lib/":                    "",
+		"lib/up -> ..":            "",
+		"lib/self -> .":           "",
+		"lib/f.go":                "package f\n",
+		"src/link -> ../lib":      "",
+		"src/dangling -> nowhere": "",
+	}
+	for _, dir := range []string{"a", "b", "c", "d"} {
+		for _, sub := range []string{"x", "y", "z"} {
+			files[dir+"/"+sub+"/1.txt"] = "1\n"
+			files[dir+"/"+sub+"/2.txt"] = "2\n"
+		}
+		files[dir+"/top.txt"] = "top\n"
+	}
+	fsys, err := os.NewMapFS(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var expected []string
+	fs.WalkDir(fsys, ".", func(p string, d DirEntry, err error) error {
+		if p == "b" || p == "c/x/1.txt" {
+			return SkipDir
+		}
+		expected = append(expected, p)
+		return nil
+	})
+	slices.Sort(expected)
+
+	var c collector
+	err = ParallelWalkFS(context.Background(), fsys, ".", func(p string, d DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == "b" || p == "c/x/1.txt" {
+			return SkipDir
+		}
+		c.add(p)
+		return nil
+	}, WithWorkers(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.sorted(); !slices.Equal(got, expected) {
+		t.Errorf("visited %q, want %q", got, expected)
+	}
+}
+
+func TestParallelWalkFS_Stop(t *testing.T) {
+	files := map[string]string{
+		"lib/":                    "",
+		"lib/up -> ..":            "",
+		"lib/self -> .":           "",
+		"lib/f.go":                "package f\n",
+		"src/link -> ../lib":      "",
+		"src/dangling -> nowhere": "",
+	}
+	for _, dir := range []string{"a", "b", "c", "d"} {
+		for _, sub := range []string{"x", "y", "z"} {
+			files[dir+"/"+sub+"/1.txt"] = "1\n"
+			files[dir+"/"+sub+"/2.txt"] = "2\n"
+		}
+		files[dir+"/top.txt"] = "top\n"
+	}
+	fsys, err := os.NewMapFS(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	boom := errors.New("boom")
+
+	for _, ordered := range []bool{false, true} {
+		err := ParallelWalkFS(context.Background(), fsys, ".", func(p string, d DirEntry, err error) error {
+			if p == "c/y/2.txt" {
+				return boom
+			}
+			return nil
+		}, WithOrdered(ordered))
+		if err != boom {
+			t.Errorf("ordered %v: an error from fn gave %v, want %v", ordered, err, boom)
+		}
+
+		var after int
+		var seen bool
+		var mu sync.Mutex
+		err = ParallelWalkFS(context.Background(), fsys, ".", func(p string, d DirEntry, err error) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if seen {
+				after++
+			}
+			if p == "a" {
+				seen = true
+				return SkipAll
+			}
+			return nil
+		}, WithOrdered(ordered), WithWorkers(1))
+		if err != nil {
+			t.Errorf("ordered %v: SkipAll gave %v", ordered, err)
+		}
+		if ordered && after != 0 {
+			t.Errorf("fn was called %d times after SkipAll", after)
+		}
+	}
+}
+
+func TestParallelWalkFS_Cancel(t *testing.T) {
+	files := map[string]string{
+		"lib/":                    "",
+		"lib/up -> ..":            "",
+		"lib/self -> .":           "",
+		"lib/f.go":                "package f\n",
+		"src/link -> ../lib":      "",
+		"src/dangling -> nowhere": "",
+	}
+	for _, dir := range []string{"a", "b", "c", "d"} {
+		for _, sub := range []string{"x", "y", "z"} {
+			files[dir+"/"+sub+"/1.txt"] = "1\n"
+			files[dir+"/"+sub+"/2.txt"] = "2\n"
+		}
+		files[dir+"/top.txt"] = "top\n"
+	}
+	fsys, err := os.NewMapFS(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ordered := range []bool{false, true} {
+		ctx, cancel := context.WithCancel(context.Background())
+		err := ParallelWalkFS(ctx, fsys, ".", func(p string, d DirEntry, err error) error {
+			if p == "a" {
+				cancel()
+			}
+			return nil
+		}, WithOrdered(ordered))
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("ordered %v: a cancelled walk gave %v", ordered, err)
+		}
+	}
+}
+
+func TestParallelWalkFS_FollowSymlinks(t *testing.T) {
+	files := map[string]string{
+		"lib/":                    "",
+		"lib/up -> ..":            "",
+		"lib/self -> .":           "",
+		"lib/f.go":                "package f\n",
+		"src/link -> ../lib":      "",
+		"src/dangling -> nowhere": "",
+	}
+	for _, dir := range []string{"a", "b", "c", "d"} {
+		for _, sub := range []string{"x", "y", "z"} {
+			files[dir+"/"+sub+"/1.txt"] = "1\n"
+			files[dir+"/"+sub+"/2.txt"] = "2\n"
+		}
+		files[dir+"/top.txt"] = "top\n"
+	}
+	fsys, err := os.NewMapFS(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	var loops []string
+	err = ParallelWalkFS(context.Background(), fsys, ".", func(p string, d DirEntry, err error) error {
+		if errors.Is(err, ErrSymlinkLoop) {
+			loops = append(loops, p)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if p == "a" || p == "b" || p == "c" || p == "d" {
+			return SkipDir
+		}
+		got = append(got, p)
+		return nil
+	}, WithOrdered(true), WithFollowSymlinks(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{".", "lib", "lib/f.go", "src", "src/dangling", "src/link", "src/link/f.go"}
+	if !slices.Equal(got, expected) {
+		t.Errorf("visited %q, want %q", got, expected)
+	}
+	expectedLoops := []string{"lib/self", "lib/up", "src/link/self", "src/link/up"}
+	if !slices.Equal(loops, expectedLoops) {
+		t.Errorf("found loops at %q, want %q", loops, expectedLoops)
+	}
+}
+
+func TestParallelWalkDir(t *testing.T) {
+	o := os.NewOS()
+	root := t.TempDir()
+	for _, dir := range []string{"a/b", "c"} {
+		if err := o.MkdirAll(Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := o.WriteFile(Join(root, "a/b/f.txt"), []byte("f\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Symlink("../a", Join(root, "c/a")); err != nil {
+		t.Skipf("no symbolic links: %v", err)
+	}
+	if err := o.Symlink("..", Join(root, "a/b/up")); err != nil {
+		t.Fatal(err)
+	}
+
+	var c collector
+	var loops collector
+	err := ParallelWalkDir(context.Background(), root, func(p string, d DirEntry, err error) error {
+		if errors.Is(err, ErrSymlinkLoop) {
+			loops.add(p)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		rel, _ := Rel(root, p)
+		c.add(ToSlash(rel))
+		return nil
+	}, WithFollowSymlinks(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{".", "a", "a/b", "a/b/f.txt", "c", "c/a", "c/a/b", "c/a/b/f.txt"}
+	if got := c.sorted(); !slices.Equal(got, expected) {
+		t.Errorf("visited %q, want %q", got, expected)
+	}
+	if got := loops.sorted(); len(got) != 2 {
+		t.Errorf("found loops at %q, want a/b/up and c/a/b/up", got)
+	}
+}